@@ -0,0 +1,154 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat is the format of access log entries.
+type AccessLogFormat int
+
+// Access log formats
+const (
+	// AccessLogCombined is the Combined Log Format, which is the Common Log
+	// Format followed by the Referer and User-Agent request headers.
+	AccessLogCombined AccessLogFormat = iota
+
+	// AccessLogCommon is the Common Log Format, eg:
+	//  192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /things HTTP/1.1" 200 2326
+	AccessLogCommon
+
+	// AccessLogJSON logs each request as a JSON object on a single line.
+	AccessLogJSON
+)
+
+// clfTimeFormat is the format of the time in the Common Log Format.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogConfig is the configuration for AccessLog.
+type AccessLogConfig struct {
+	// Writer is where log entries are written, one per line.
+	// If nil, log entries are written to os.Stdout.
+	Writer io.Writer
+
+	// Format is the format of the log entries. The zero value
+	// is AccessLogCombined.
+	Format AccessLogFormat
+
+	// Sample returns the fraction of requests like rl that are logged,
+	// between zero (none) and one (all), eg to log only some of the requests
+	// to a high-volume health check route. If nil, all requests are logged.
+	// Requests with a 5xx status are always logged.
+	Sample func(rl *RequestLog) float64
+}
+
+// AccessLog returns middleware that writes an access log entry for every
+// request, after the request has been handled, in a standard format for
+// access logs. See LogRequests for logging requests in other ways.
+//
+// For example, to log requests in JSON format, and only one in a
+// hundred requests to the health check endpoint:
+//  httpapi.AccessLog(httpapi.AccessLogConfig{
+//      Format: httpapi.AccessLogJSON,
+//      Sample: func(rl *httpapi.RequestLog) float64 {
+//          if rl.Path == "/healthz" {
+//              return 0.01
+//          }
+//          return 1
+//      },
+//  })
+func AccessLog(config AccessLogConfig) Middleware {
+	w := config.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	var mutex sync.Mutex
+	return LogRequests(func(rl *RequestLog) {
+		if config.Sample != nil && rl.Status < 500 {
+			if rate := config.Sample(rl); rate < 1 && rand.Float64() >= rate {
+				return
+			}
+		}
+		var line []byte
+		if config.Format == AccessLogJSON {
+			line = accessLogJSON(rl)
+		} else {
+			line = accessLogCLF(rl, config.Format == AccessLogCombined)
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		_, _ = w.Write(line)
+	})
+}
+
+// accessLogCLF formats the request in the Common Log Format, or the
+// Combined Log Format if combined is true.
+func accessLogCLF(rl *RequestLog, combined bool) []byte {
+	r := rl.Request
+	start := time.Now().Add(-rl.Duration)
+	b := make([]byte, 0, 256)
+	b = append(b, remoteIP(r)...)
+	b = append(b, " - - ["...)
+	b = start.AppendFormat(b, clfTimeFormat)
+	b = append(b, "] "...)
+	b = strconv.AppendQuote(b, r.Method+" "+r.URL.RequestURI()+" "+r.Proto)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(rl.Status), 10)
+	b = append(b, ' ')
+	if rl.Bytes > 0 {
+		b = strconv.AppendInt(b, rl.Bytes, 10)
+	} else {
+		b = append(b, '-')
+	}
+	if combined {
+		b = append(b, ' ')
+		b = appendQuotedOrDash(b, r.Referer())
+		b = append(b, ' ')
+		b = appendQuotedOrDash(b, r.UserAgent())
+	}
+	return append(b, '\n')
+}
+
+func appendQuotedOrDash(b []byte, s string) []byte {
+	if s == "" {
+		return append(b, `"-"`...)
+	}
+	return strconv.AppendQuote(b, s)
+}
+
+// accessLogJSON formats the request as a JSON object.
+func accessLogJSON(rl *RequestLog) []byte {
+	r := rl.Request
+	entry := struct {
+		Time       string  `json:"time"`
+		Remote     string  `json:"remote"`
+		Method     string  `json:"method"`
+		URI        string  `json:"uri"`
+		Proto      string  `json:"proto"`
+		Status     int     `json:"status"`
+		Bytes      int64   `json:"bytes"`
+		DurationMS float64 `json:"duration_ms"`
+		Referer    string  `json:"referer,omitempty"`
+		UserAgent  string  `json:"user_agent,omitempty"`
+		Trace      string  `json:"trace,omitempty"`
+	}{
+		Time:       time.Now().Add(-rl.Duration).UTC().Format(time.RFC3339Nano),
+		Remote:     remoteIP(r),
+		Method:     r.Method,
+		URI:        r.URL.RequestURI(),
+		Proto:      r.Proto,
+		Status:     rl.Status,
+		Bytes:      rl.Bytes,
+		DurationMS: float64(rl.Duration) / float64(time.Millisecond),
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+		Trace:      rl.Trace,
+	}
+	line, _ := json.Marshal(entry) // cannot fail
+	return append(line, '\n')
+}
@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("hello"))
+	})
+	tests := []struct {
+		format AccessLogFormat
+		path   string
+		want   string // regular expression
+	}{
+		{
+			format: AccessLogCommon,
+			path:   "/things?a=1",
+			want:   `^192\.0\.2\.1 - - \[\d\d/\w\w\w/\d{4}:\d\d:\d\d:\d\d [-+]\d{4}\] "GET /things\?a=1 HTTP/1\.1" 200 5\n$`,
+		},
+		{
+			format: AccessLogCombined,
+			path:   "/things",
+			want:   `^192\.0\.2\.1 - - \[[^\]]+\] "GET /things HTTP/1\.1" 200 5 "https://example\.com/" "test \\"agent\\""\n$`,
+		},
+		{
+			format: AccessLogCommon,
+			path:   "/error",
+			want:   `"GET /error HTTP/1\.1" 500 -\n$`,
+		},
+	}
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		h := AccessLog(AccessLogConfig{Writer: &buf, Format: tt.format})(handler)
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Header.Set("Referer", "https://example.com/")
+		r.Header.Set("User-Agent", `test "agent"`)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if !regexp.MustCompile(tt.want).MatchString(buf.String()) {
+			t.Errorf("%d: want match for %s, got %q", i, tt.want, buf.String())
+		}
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	h := AccessLog(AccessLogConfig{Writer: &buf, Format: AccessLogJSON})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	r := httptest.NewRequest("GET", "/things", nil)
+	r.Header.Set("User-Agent", "test")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !strings.HasSuffix(buf.String(), "}\n") || strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("want one line, got %q", buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"remote":     "192.0.2.1",
+		"method":     "GET",
+		"uri":        "/things",
+		"proto":      "HTTP/1.1",
+		"status":     float64(200),
+		"bytes":      float64(5),
+		"user_agent": "test",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s: want %v, got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["referer"]; ok {
+		t.Errorf("want no referer, got %v", entry["referer"])
+	}
+}
+
+func TestAccessLogSample(t *testing.T) {
+	var buf bytes.Buffer
+	h := AccessLog(AccessLogConfig{
+		Writer: &buf,
+		Sample: func(rl *RequestLog) float64 {
+			if rl.Path == "/healthz" {
+				return 0
+			}
+			return 1
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	for _, target := range []string{"/healthz", "/things", "/healthz?fail=1"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "/things") || !strings.Contains(lines[1], "fail=1") {
+		t.Errorf("want /things and failed health check, got %q", lines)
+	}
+}
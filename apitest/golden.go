@@ -0,0 +1,243 @@
+// Package apitest provides support for testing HTTP handlers that
+// implement a JSON API.
+//
+// Golden file testing compares the JSON response of a handler with the
+// contents of a file that was recorded earlier. This makes it easy to
+// detect unintended changes to large responses. Values that are different
+// for each test run, such as timestamps and generated IDs, are replaced
+// with placeholders using normalizers before the comparison.
+//
+// To record the golden files, run the tests with the -update flag:
+//  go test -run TestThings -update
+// This package defines the -update flag, so tests that use it should
+// not define their own -update flag.
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// update is true if golden files should be written instead of compared.
+var update = flag.Bool("update", false, "update golden files")
+
+// defaultDir is the directory containing golden files, unless another
+// directory is specified.
+const defaultDir = "testdata"
+
+// A Normalizer is called for each value in a JSON document before it is
+// compared with a golden file, and returns the value to compare, eg a
+// placeholder for a timestamp. The path identifies the value in the
+// document, eg "items[0].created", and the path of the document itself is
+// the empty string. The value is the result of unmarshalling JSON into an
+// interface{} value, except that numbers are json.Number values.
+type Normalizer func(path string, v interface{}) interface{}
+
+// Golden compares JSON documents with golden files. The zero value
+// is ready to use, and uses golden files in the "testdata" directory.
+// For example:
+//  golden := apitest.Golden{
+//      Normalizers: []apitest.Normalizer{
+//          apitest.ReplaceFields("<time>", "created", "updated"),
+//      },
+//  }
+//  r := httptest.NewRequest("GET", "/things", nil)
+//  golden.AssertResponse(t, handler, r, "things")
+type Golden struct {
+	// Dir is the directory containing the golden files.
+	// If empty, the directory is "testdata".
+	Dir string
+
+	// Normalizers are called in order for each value in
+	// the JSON document before it is compared.
+	Normalizers []Normalizer
+}
+
+// AssertJSON compares the JSON document with the golden file name, and
+// reports an error using t if they are different. The golden file is the
+// name with a ".json" suffix in the golden file directory, and the name can
+// contain slashes to use a subdirectory. If the -update flag is set, the
+// golden file is written instead.
+func (g *Golden) AssertJSON(t testing.TB, name string, data []byte) {
+	t.Helper()
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		t.Errorf("%s: cannot unmarshal JSON: %v", name, err)
+		return
+	}
+	g.compare(t, name, g.normalize("", doc))
+}
+
+// AssertResponse sends the request to the handler, and compares the
+// response with the golden file name (see AssertJSON). The golden file
+// contains the status code and the JSON response body, for example:
+//  {
+//    "status": 200,
+//    "body": {"id": "<id>", "name": "thing"}
+//  }
+// A response body that is empty is recorded as null. The path of the
+// body in the document passed to normalizers is "body".
+func (g *Golden) AssertResponse(t testing.TB, h http.Handler, r *http.Request, name string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var body interface{}
+	if w.Body.Len() > 0 {
+		decoder := json.NewDecoder(w.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&body); err != nil {
+			t.Errorf("%s: cannot unmarshal response body: %v", name, err)
+			return
+		}
+	}
+	doc := map[string]interface{}{
+		"status": json.Number(strconv.Itoa(w.Code)),
+		"body":   body,
+	}
+	g.compare(t, name, g.normalize("", doc))
+}
+
+// compare compares the normalized document with the golden file.
+func (g *Golden) compare(t testing.TB, name string, doc interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		t.Errorf("%s: cannot marshal JSON: %v", name, err)
+		return
+	}
+	got := buf.Bytes()
+
+	filename := g.filename(name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Errorf("%s: %v", name, err)
+			return
+		}
+		if err := os.WriteFile(filename, got, 0o644); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(filename)
+	if err != nil {
+		t.Errorf("%s: %v (run with -update to create it)", name, err)
+		return
+	}
+	if line, ok := firstDifference(want, got); !ok {
+		t.Errorf("%s: does not match %s:\n%s\ngot:\n%s", name, filename, line, got)
+	}
+}
+
+// filename returns the name of the golden file.
+func (g *Golden) filename(name string) string {
+	dir := g.Dir
+	if dir == "" {
+		dir = defaultDir
+	}
+	return filepath.Join(dir, filepath.FromSlash(name)+".json")
+}
+
+// normalize calls the normalizers for v and each value it contains.
+func (g *Golden) normalize(path string, v interface{}) interface{} {
+	for _, n := range g.Normalizers {
+		v = n(path, v)
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = g.normalize(joinPath(path, key), value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = g.normalize(path+"["+strconv.Itoa(i)+"]", value)
+		}
+	}
+	return v
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// firstDifference compares the golden file contents with the actual
+// contents, and describes the first line that is different.
+func firstDifference(want, got []byte) (string, bool) {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want: %s\n   got: %s", i+1, w, g), false
+		}
+	}
+	return "", true
+}
+
+// ReplaceFields returns a normalizer that replaces the values of object
+// members with any of the names, eg "created" or "id", wherever they are
+// in the document.
+func ReplaceFields(replacement interface{}, names ...string) Normalizer {
+	return func(path string, v interface{}) interface{} {
+		key := path
+		if i := strings.LastIndexAny(path, ".]"); i >= 0 {
+			key = path[i+1:]
+		}
+		for _, name := range names {
+			if key == name {
+				return replacement
+			}
+		}
+		return v
+	}
+}
+
+// ReplacePaths returns a normalizer that replaces the values at the
+// paths, eg "body.items[0].id".
+func ReplacePaths(replacement interface{}, paths ...string) Normalizer {
+	return func(path string, v interface{}) interface{} {
+		for _, p := range paths {
+			if path == p {
+				return replacement
+			}
+		}
+		return v
+	}
+}
+
+// ReplacePattern returns a normalizer that replaces all matches of the
+// regular expression in string values, eg to replace UUIDs wherever
+// they appear. The replacement can refer to submatches as described
+// for regexp.Regexp.ReplaceAllString.
+func ReplacePattern(re *regexp.Regexp, replacement string) Normalizer {
+	return func(path string, v interface{}) interface{} {
+		if s, ok := v.(string); ok {
+			return re.ReplaceAllString(s, replacement)
+		}
+		return v
+	}
+}
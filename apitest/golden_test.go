@@ -0,0 +1,80 @@
+package apitest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder records the errors reported by a test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func thingsHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"items":[{"id":"%d","name":%q,"created":%q,"ref":"thing-%d"}]}`,
+			time.Now().UnixNano(), name, time.Now().Format(time.RFC3339Nano), time.Now().UnixNano())
+	})
+}
+
+var normalizers = []Normalizer{
+	ReplaceFields("<time>", "created"),
+	ReplacePaths("<id>", "body.items[0].id"),
+	ReplacePattern(regexp.MustCompile(`thing-\d+`), "thing-<n>"),
+}
+
+func TestGolden(t *testing.T) {
+	golden := Golden{Normalizers: normalizers}
+	golden.AssertResponse(t, thingsHandler("widget"), httptest.NewRequest("GET", "/things", nil), "things")
+	golden.AssertJSON(t, "object", []byte(`{"b":[1,2.5,"x"],"a":null,"created":"today"}`))
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	dir := t.TempDir()
+	golden := Golden{Dir: dir, Normalizers: normalizers}
+	r := httptest.NewRequest("GET", "/things", nil)
+
+	rec := &recorder{TB: t}
+	golden.AssertResponse(rec, thingsHandler("widget"), r, "sub/things")
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "-update") {
+		t.Errorf("missing file: want error mentioning -update, got %q", rec.errors)
+	}
+
+	*update = true
+	rec = &recorder{TB: t}
+	golden.AssertResponse(rec, thingsHandler("widget"), r, "sub/things")
+	*update = false
+	if len(rec.errors) != 0 {
+		t.Fatalf("update: want no errors, got %q", rec.errors)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "things.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = &recorder{TB: t}
+	golden.AssertResponse(rec, thingsHandler("widget"), r, "sub/things")
+	if len(rec.errors) != 0 {
+		t.Errorf("same: want no errors, got %q", rec.errors)
+	}
+
+	rec = &recorder{TB: t}
+	golden.AssertResponse(rec, thingsHandler("gadget"), r, "sub/things")
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], `"gadget"`) {
+		t.Errorf("different: want error showing difference, got %q", rec.errors)
+	}
+}
@@ -0,0 +1,95 @@
+package apitest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/httpapi"
+)
+
+// ResponseRecorder records the response written by a handler, and
+// decodes the response body, so that tests can check typed values
+// rather than bytes. For example:
+//  w := apitest.NewRecorder()
+//  handler.ServeHTTP(w, r)
+//  var thing Thing
+//  if err := w.DecodeJSON(&thing); err != nil {
+//      t.Fatal(err)
+//  }
+type ResponseRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+// NewRecorder returns an initialized ResponseRecorder.
+func NewRecorder() *ResponseRecorder {
+	return &ResponseRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+// Serve sends the request to the handler, and returns the recorded response.
+func Serve(h http.Handler, r *http.Request) *ResponseRecorder {
+	w := NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// BodyBytes returns the response body, decompressed if the
+// response has a Content-Encoding of gzip or deflate.
+func (w *ResponseRecorder) BodyBytes() ([]byte, error) {
+	data := w.Body.Bytes()
+	var reader io.Reader
+	switch encoding := w.Header().Get("Content-Encoding"); encoding {
+	case "", "identity":
+		return data, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decompress response body")
+		}
+		reader = zr
+	case "deflate":
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decompress response body")
+		}
+		reader = zr
+	default:
+		return nil, errors.New("unknown content-encoding").With("content-encoding", encoding)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decompress response body")
+	}
+	return data, nil
+}
+
+// DecodeJSON unmarshals the JSON response body into the value pointed to by v.
+func (w *ResponseRecorder) DecodeJSON(v interface{}) error {
+	data, err := w.BodyBytes()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.Wrap(err, "cannot unmarshal response body")
+	}
+	return nil
+}
+
+// APIError returns the error sent by httpapi.WriteError, or false if the
+// response status is not an error status, or the body is not an error in
+// a format that httpapi.Error can unmarshal.
+func (w *ResponseRecorder) APIError() (*httpapi.Error, bool) {
+	if w.Code < 400 {
+		return nil, false
+	}
+	var e httpapi.Error
+	if err := w.DecodeJSON(&e); err != nil || e.Message == "" {
+		return nil, false
+	}
+	return &e, true
+}
@@ -0,0 +1,75 @@
+package apitest
+
+import (
+	"compress/zlib"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi"
+)
+
+func TestResponseRecorder(t *testing.T) {
+	type Thing struct {
+		Name string `json:"name"`
+	}
+	large := strings.Repeat("widget ", 1000)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/deflate" {
+			w.Header().Set("Content-Encoding", "deflate")
+			zw := zlib.NewWriter(w)
+			json.NewEncoder(zw).Encode(Thing{Name: large})
+			zw.Close()
+			return
+		}
+		if r.URL.Path == "/missing" {
+			httpapi.WriteError(w, r, errkind.Public("thing not found", http.StatusNotFound))
+			return
+		}
+		httpapi.WriteResponse(w, r, Thing{Name: large})
+	})
+	tests := []struct {
+		path     string
+		encoding string
+		name     string
+		err      string
+	}{
+		{path: "/thing", name: large},
+		{path: "/thing", encoding: "gzip", name: large},
+		{path: "/deflate", encoding: "deflate", name: large},
+		{path: "/missing", err: "thing not found"},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if tt.encoding != "" {
+			r.Header.Set("Accept-Encoding", tt.encoding)
+		}
+		w := Serve(h, r)
+		if got, want := w.Header().Get("Content-Encoding"), tt.encoding; got != want {
+			t.Errorf("%d: content-encoding: want %q, got %q", i, want, got)
+		}
+		e, ok := w.APIError()
+		if tt.err != "" {
+			if !ok {
+				t.Errorf("%d: want error, got none", i)
+			} else if e.Message != tt.err || e.Status != w.Code {
+				t.Errorf("%d: want %q, got %+v", i, tt.err, e)
+			}
+			continue
+		}
+		if ok {
+			t.Errorf("%d: want no error, got %v", i, e)
+		}
+		var thing Thing
+		if err := w.DecodeJSON(&thing); err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if thing.Name != tt.name {
+			t.Errorf("%d: want name of length %d, got %d", i, len(tt.name), len(thing.Name))
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+// Error codes sent to the client when authentication fails. They
+// follow the error codes defined for bearer tokens in RFC 6750.
+const (
+	CodeInvalidRequest    = "invalid_request"
+	CodeInvalidToken      = "invalid_token"
+	CodeInsufficientScope = "insufficient_scope"
+)
+
+// BearerAuth returns middleware that authenticates requests using the bearer
+// token in the Authorization header. The validate function is called with
+// the token, and returns the principal (eg a user or a set of claims) that
+// the token represents. The principal is stored in the request context, and
+// can be obtained using PrincipalFromContext.
+//
+// If the request has no bearer token, or validate returns an error, an error
+// is sent to the client using WriteError and the handler is not called. The
+// error has a 401 (Unauthorized) status with a WWW-Authenticate header, which
+// has no error code if the request has no bearer credentials at all, unless
+// the error returned by validate has a public status of 403 (Forbidden), eg
+// because the token does not grant access to the resource, or a public 5xx
+// status, eg because the service that verifies tokens is not available.
+func BearerAuth(validate func(ctx context.Context, token string) (principal interface{}, err error)) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				// RFC 6750 says that the challenge should not have an error
+				// code when the request has no authentication information
+				writeAuthError(w, r, AuthError("missing bearer token", http.StatusUnauthorized, ""))
+				return
+			}
+			if token == "" {
+				writeAuthError(w, r, AuthError("missing bearer token", http.StatusUnauthorized, CodeInvalidRequest))
+				return
+			}
+			principal, err := validate(r.Context(), token)
+			if err != nil {
+				writeAuthError(w, r, err)
+				return
+			}
+			ctx := context.WithValue(r.Context(), principalKey, principal)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// PrincipalFromContext returns the principal stored in the context by
+// BearerAuth, or nil if there is none.
+func PrincipalFromContext(ctx context.Context) interface{} {
+	return ctx.Value(principalKey)
+}
+
+// bearerToken returns the bearer token in the Authorization header, and
+// false if the header is missing or uses another scheme. The token is
+// empty if the header uses the bearer scheme without a token.
+func bearerToken(r *http.Request) (string, bool) {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	scheme, token, _ := strings.Cut(auth, " ")
+	if !strings.EqualFold(scheme, "bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// writeAuthError sends an authentication error to the client. Errors
+// without a public status of 403 or 5xx are sent as 401 errors, with the
+// WWW-Authenticate header required by RFC 7235. An error code that is
+// not defined by RFC 6750 is sent in the challenge as "invalid_token".
+func writeAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	cause := errors.Cause(err)
+	if _, ok := cause.(interface{ PublicStatusCode() }); ok {
+		if status := errkind.StatusCode(cause); status == http.StatusForbidden || status >= 500 {
+			WriteError(w, r, err)
+			return
+		}
+	}
+	if _, ok := cause.(interface{ PublicStatusCode() }); !ok || errkind.StatusCode(cause) != http.StatusUnauthorized {
+		err = AuthError("invalid bearer token", http.StatusUnauthorized, CodeInvalidToken)
+	}
+	challenge := "Bearer"
+	switch code := errkind.Code(errors.Cause(err)); code {
+	case "":
+	case CodeInvalidRequest, CodeInvalidToken, CodeInsufficientScope:
+		challenge += ` error="` + code + `"`
+	default:
+		// more specific codes are sent in the body, eg "token_expired",
+		// but the challenge only allows the codes defined in RFC 6750
+		challenge += ` error="` + CodeInvalidToken + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	WriteError(w, r, err)
+}
+
+// AuthError returns an error for a failed authentication or authorization,
+// whose message, status and code are all sent to the client by WriteError.
+// It is intended for the validate function passed to BearerAuth, eg:
+//  return nil, httpapi.AuthError("token has expired", http.StatusUnauthorized, "token_expired")
+func AuthError(msg string, status int, code string) error {
+	return &publicError{msg: msg, status: status, code: code}
+}
+
+// publicError is an error whose message, status and code
+// can all be sent to the client.
+type publicError struct {
+	msg    string
+	status int
+	code   string
+}
+
+func (e *publicError) Error() string     { return e.msg }
+func (e *publicError) Message() string   { return e.msg }
+func (e *publicError) StatusCode() int   { return e.status }
+func (e *publicError) Code() string      { return e.code }
+func (e *publicError) PublicMessage()    {}
+func (e *publicError) PublicStatusCode() {}
+func (e *publicError) PublicCode()       {}
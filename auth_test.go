@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+)
+
+func TestBearerAuth(t *testing.T) {
+	validate := func(ctx context.Context, token string) (interface{}, error) {
+		switch token {
+		case "good":
+			return "alice", nil
+		case "readonly":
+			return nil, errkind.Public("insufficient permissions", http.StatusForbidden)
+		}
+		return nil, errkind.BadRequest("token expired")
+	}
+	var principal interface{}
+	h := BearerAuth(validate)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = PrincipalFromContext(r.Context())
+	}))
+	tests := []struct {
+		auth      string
+		status    int
+		challenge string
+		code      string
+	}{
+		{auth: "Bearer good", status: http.StatusOK},
+		{auth: "bearer  good ", status: http.StatusOK},
+		{auth: "", status: http.StatusUnauthorized, challenge: "Bearer"},
+		{auth: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized, challenge: "Bearer"},
+		{auth: "Bearer ", status: http.StatusUnauthorized, challenge: `Bearer error="invalid_request"`, code: "invalid_request"},
+		{auth: "Bearer", status: http.StatusUnauthorized, challenge: `Bearer error="invalid_request"`, code: "invalid_request"},
+		{auth: "Bearer bad", status: http.StatusUnauthorized, challenge: `Bearer error="invalid_token"`, code: "invalid_token"},
+		{auth: "Bearer readonly", status: http.StatusForbidden},
+	}
+	for i, tt := range tests {
+		principal = nil
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("WWW-Authenticate"), tt.challenge; got != want {
+			t.Errorf("%d: challenge: want %q, got %q", i, want, got)
+		}
+		if tt.status == http.StatusOK {
+			if principal != "alice" {
+				t.Errorf("%d: principal: want alice, got %v", i, principal)
+			}
+			continue
+		}
+		if principal != nil {
+			t.Errorf("%d: handler called", i)
+		}
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if got, want := body.Error.Code, tt.code; got != want {
+			t.Errorf("%d: code: want %q, got %q", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package httpapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjeffery/errors"
+	"github.com/spkg/local"
+)
+
+// QueryAs returns a value of type T populated from the query string of
+// the request. T must be a struct type. Each exported field is populated
+// from the query string parameter named in its "query" struct tag, or
+// the field name if there is no tag. Fields with the tag `query:"-"`
+// are ignored. For example:
+//  type ListInput struct {
+//      Search string    `query:"q"`
+//      Since  time.Time `query:"since"`
+//      Limit  int       `query:"limit"`
+//      Active *bool     `query:"active"` // nil if not present
+//  }
+//
+//  input, err := httpapi.QueryAs[ListInput](r)
+//
+// See Values.Bind for the supported field types and tag options.
+//
+// If any parameters are invalid, the returned error is the bad request
+// error returned by Values.Err, which lists all of the invalid parameters.
+// If *T has a Validate() error method, it is called after the struct has
+// been populated, and any error it returns is returned.
+func QueryAs[T any](r *http.Request) (T, error) {
+	var v T
+	query := Query(r)
+	if err := query.bind(&v); err != nil {
+		return v, err
+	}
+	if err := query.Err(); err != nil {
+		return v, err
+	}
+	if validator, ok := interface{}(&v).(interface{ Validate() error }); ok {
+		if err := validator.Validate(); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	dateType     = reflect.TypeOf(local.Date{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Bind populates the struct pointed to by dst from the query string.
+// Each exported field is populated from the query string parameter named
+// in its "query" struct tag, or the field name if there is no tag. Fields
+// with the tag `query:"-"` are ignored, and the fields of embedded structs
+// without a tag are populated as if they were fields of the outer struct.
+//
+// Supported field types are string, bool, all integer and floating point
+// types, time.Duration, time.Time, local.Date and UUIDs (any [16]byte type),
+// and pointers to those types. Pointer fields are nil if the parameter is
+// not present in the query string. Fields can also be slices of the same
+// types, other than time.Time and local.Date, which are populated from
+// repeated parameters, eg "?id=1&id=2".
+//
+// The tag can include options after the name:
+//  `query:"ids,csv"`       // slice populated from comma-separated items, eg "?ids=1,2"
+//  `query:"name,required"` // parameter is reported as missing if not present
+//
+// Invalid and missing parameters are recorded in the same way as the other
+// methods of Values, and Bind returns the error returned by Err. Bind
+// returns a different error if dst is not a pointer to a struct, or if a
+// field has an unsupported type.
+func (v *Values) Bind(dst interface{}) error {
+	if err := v.bind(dst); err != nil {
+		return err
+	}
+	return v.Err()
+}
+
+// bind populates the struct pointed to by dst from the query string.
+// Invalid parameters are recorded for reporting by Err. The error
+// returned indicates a programming error, such as an unsupported
+// field type.
+func (v *Values) bind(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("bind requires a pointer to struct").
+			With("type", reflect.TypeOf(dst))
+	}
+	return v.bindStruct(rv.Elem())
+}
+
+func (v *Values) bindStruct(sv reflect.Value) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		tag, hasTag := field.Tag.Lookup("query")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && !hasTag && field.Type.Kind() == reflect.Struct {
+			if err := v.bindStruct(sv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			// unexported field
+			continue
+		}
+		name := field.Name
+		opts := strings.Split(tag, ",")
+		if opts[0] != "" {
+			name = opts[0]
+		}
+		var csv, required bool
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "csv":
+				csv = true
+			case "required":
+				required = true
+			}
+		}
+		if required && !v.require(name) {
+			continue
+		}
+		var err error
+		if field.Type.Kind() == reflect.Slice {
+			err = v.bindSlice(name, csv, sv.Field(i))
+		} else {
+			err = v.bindField(name, sv.Field(i))
+		}
+		if err != nil {
+			return errors.Wrap(err, "cannot bind query").With("field", field.Name)
+		}
+	}
+	return nil
+}
+
+// bindField sets the field value from the query string parameter
+// if the parameter is present and valid.
+func (v *Values) bindField(name string, fv reflect.Value) error {
+	ft := fv.Type()
+	isPtr := ft.Kind() == reflect.Ptr
+	if isPtr {
+		ft = ft.Elem()
+	}
+
+	var value interface{}
+	var ok bool
+
+	switch {
+	case ft == timeType:
+		value, ok = v.LookupTime(name)
+	case ft == dateType:
+		value, ok = v.LookupDate(name)
+	case ft == durationType:
+		value, ok = v.LookupDuration(name)
+	case isUUIDType(ft):
+		value, ok = v.LookupUUID(name)
+	case ft.Kind() == reflect.String:
+		value, ok = v.LookupString(name)
+	case ft.Kind() == reflect.Bool:
+		value, ok = v.LookupBool(name)
+	case isSignedKind(ft.Kind()):
+		value, ok = v.parseSigned(name, ft.Bits())
+	case isUnsignedKind(ft.Kind()):
+		value, ok = v.parseUnsigned(name, ft.Bits())
+	case ft.Kind() == reflect.Float32 || ft.Kind() == reflect.Float64:
+		value, ok = v.parseFloat(name, ft.Bits())
+	default:
+		return errors.New("unsupported field type").With("type", fv.Type())
+	}
+
+	if !ok {
+		return nil
+	}
+	rv := reflect.ValueOf(value).Convert(ft)
+	if isPtr {
+		ptr := reflect.New(ft)
+		ptr.Elem().Set(rv)
+		rv = ptr
+	}
+	fv.Set(rv)
+	return nil
+}
+
+// bindSlice sets the slice field value from the repeated query string
+// parameter, or from its comma-separated items if csv is true. If any
+// item is invalid, the parameter is reported by Err and the field is
+// not set.
+func (v *Values) bindSlice(name string, csv bool, fv reflect.Value) error {
+	et := fv.Type().Elem()
+	parse := sliceItemParser(et)
+	if parse == nil {
+		return errors.New("unsupported field type").With("type", fv.Type())
+	}
+	items := v.all(name)
+	if csv {
+		items = v.splitList(name)
+	}
+	values := parseAll(v, name, "invalid value", items, parse)
+	if values == nil {
+		return nil
+	}
+	slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+	for i, value := range values {
+		slice.Index(i).Set(reflect.ValueOf(value).Convert(et))
+	}
+	fv.Set(slice)
+	return nil
+}
+
+// sliceItemParser returns a function that parses an item of a slice
+// field with element type et, or nil if the type is not supported.
+func sliceItemParser(et reflect.Type) func(string) (interface{}, error) {
+	switch {
+	case et == durationType:
+		return func(s string) (interface{}, error) {
+			return parseDuration(s)
+		}
+	case isUUIDType(et):
+		return func(s string) (interface{}, error) {
+			u, ok := parseUUID(strings.TrimSpace(s))
+			if !ok {
+				return nil, errInvalidValue
+			}
+			return u, nil
+		}
+	case et.Kind() == reflect.String:
+		return func(s string) (interface{}, error) {
+			return s, nil
+		}
+	case et.Kind() == reflect.Bool:
+		return func(s string) (interface{}, error) {
+			b, ok := parseBool(s)
+			if !ok {
+				return nil, errInvalidValue
+			}
+			return b, nil
+		}
+	case isSignedKind(et.Kind()):
+		return func(s string) (interface{}, error) {
+			return strconv.ParseInt(s, 10, et.Bits())
+		}
+	case isUnsignedKind(et.Kind()):
+		return func(s string) (interface{}, error) {
+			return strconv.ParseUint(s, 10, et.Bits())
+		}
+	case et.Kind() == reflect.Float32 || et.Kind() == reflect.Float64:
+		return func(s string) (interface{}, error) {
+			return strconv.ParseFloat(s, et.Bits())
+		}
+	}
+	return nil
+}
+
+func isUUIDType(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8
+}
+
+func isSignedKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUnsignedKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
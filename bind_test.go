@@ -0,0 +1,149 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/errkind"
+	"github.com/spkg/local"
+)
+
+type queryAsPaging struct {
+	Limit  int `query:"limit"`
+	Offset int `query:"offset"`
+}
+
+type queryAsInput struct {
+	queryAsPaging
+	Search   string     `query:"q"`
+	Since    time.Time  `query:"since"`
+	Date     local.Date `query:"date"`
+	Active   *bool      `query:"active"`
+	Count    *int       `query:"count"`
+	Ignored  string     `query:"-"`
+	Untagged string
+}
+
+type queryAsValidated struct {
+	Limit int `query:"limit"`
+}
+
+func (v *queryAsValidated) Validate() error {
+	if v.Limit > 100 {
+		return errkind.BadRequest("limit too large")
+	}
+	return nil
+}
+
+func TestQueryAs(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?q=abc&limit=10&since=2020-01-02T03:04:05Z&date=2020-01-02&active=true&Untagged=u&Ignored=x", nil)
+	got, err := QueryAs[queryAsInput](r)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if got.Search != "abc" {
+		t.Errorf("search: want %q, got %q", "abc", got.Search)
+	}
+	if got.Limit != 10 || got.Offset != 0 {
+		t.Errorf("paging: want limit=10 offset=0, got %+v", got.queryAsPaging)
+	}
+	if want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC); !got.Since.Equal(want) {
+		t.Errorf("since: want %v, got %v", want, got.Since)
+	}
+	if want := local.DateFor(2020, 1, 2); !got.Date.Equal(want) {
+		t.Errorf("date: want %v, got %v", want, got.Date)
+	}
+	if got.Active == nil || *got.Active != true {
+		t.Errorf("active: want true, got %v", got.Active)
+	}
+	if got.Count != nil {
+		t.Errorf("count: want nil, got %v", *got.Count)
+	}
+	if got.Ignored != "" {
+		t.Errorf("ignored: want empty, got %q", got.Ignored)
+	}
+	if got.Untagged != "u" {
+		t.Errorf("untagged: want %q, got %q", "u", got.Untagged)
+	}
+}
+
+func TestQueryAsErrors(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?limit=x&since=yesterday", nil)
+	if _, err := QueryAs[queryAsInput](r); errkind.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("want bad request, got %v", err)
+	}
+
+	r = httptest.NewRequest("GET", "/?limit=1000", nil)
+	if _, err := QueryAs[queryAsValidated](r); errkind.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("want bad request, got %v", err)
+	}
+
+	r = httptest.NewRequest("GET", "/?limit=10", nil)
+	if _, err := QueryAs[queryAsValidated](r); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+
+	type unsupported struct {
+		C chan int `query:"c"`
+	}
+	r = httptest.NewRequest("GET", "/?c=1", nil)
+	if _, err := QueryAs[unsupported](r); err == nil || errkind.StatusCode(err) == http.StatusBadRequest {
+		t.Errorf("want internal error, got %v", err)
+	}
+}
+
+func TestBind(t *testing.T) {
+	type uuid [16]byte
+	type input struct {
+		ID      uuid          `query:"id,required"`
+		Name    string        `query:"name,required"`
+		Big     int64         `query:"big"`
+		Small   int8          `query:"small"`
+		Count   uint32        `query:"count"`
+		Ratio   float64       `query:"ratio"`
+		Wait    time.Duration `query:"wait"`
+		Tags    []string      `query:"tag"`
+		IDs     []int64       `query:"ids,csv"`
+		Flags   []bool        `query:"flags,csv"`
+		Timeout *float32      `query:"timeout"`
+	}
+	r := httptest.NewRequest("GET", "/?id=00000000-0000-0000-0000-000000000001&name=n&big=9007199254740993&small=-3&count=7&ratio=0.25&wait=1m&tag=a&tag=b&ids=1,2,3&flags=true,0,Yes&timeout=1.5", nil)
+	var got input
+	if err := Query(r).Bind(&got); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	want := input{
+		ID:    uuid{15: 1},
+		Name:  "n",
+		Big:   9007199254740993,
+		Small: -3,
+		Count: 7,
+		Ratio: 0.25,
+		Wait:  time.Minute,
+		Tags:  []string{"a", "b"},
+		IDs:   []int64{1, 2, 3},
+		Flags: []bool{true, false, true},
+	}
+	if got.Timeout == nil || *got.Timeout != 1.5 {
+		t.Errorf("timeout: want 1.5, got %v", got.Timeout)
+	}
+	got.Timeout = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	r = httptest.NewRequest("GET", "/?small=300&ids=1,x&flags=true,maybe", nil)
+	err := Query(r).Bind(&got)
+	if errkind.StatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("want bad request, got %v", err)
+	}
+	for _, s := range []string{"id", "name", "small", "ids", "flags"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("want %q in error, got %v", s, err)
+		}
+	}
+}
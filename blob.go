@@ -0,0 +1,303 @@
+package httpapi
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+// WriteBlob sends binary content, such as a PDF document or an image, to
+// the client. The headers and compression are the same as for WriteResponse:
+// the content is compressed if the client accepts compressed responses, and
+// the compression policy for the request allows it for the content type.
+// If the content type is not specified, it is "application/octet-stream".
+// As for WriteResponse, empty content is sent with a 204 (No Content) status.
+//
+// Use ServeBlob instead for large content that clients may
+// download in ranges.
+func WriteBlob(w http.ResponseWriter, r *http.Request, contentType string, content []byte) {
+	data := rawData{
+		ContentType:        contentType,
+		ContentEncoding:    ceIdentity,
+		Content:            content,
+		UncompressedLength: len(content),
+	}
+	if data.ContentType == "" {
+		data.ContentType = "application/octet-stream"
+	}
+	addVary(w.Header(), "Accept-Encoding")
+	if err := data.CompressResponse(r); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	_ = data.WriteResponse(w)
+}
+
+// WriteCompressedBlob is like WriteBlob, but the content is already
+// compressed using the content encoding, which is "gzip" or "deflate".
+// If the client accepts the content encoding, the content is sent as it
+// is. Otherwise it is decompressed before it is sent. For example, to send
+// JSON that was compressed when it was stored:
+//  httpapi.WriteCompressedBlob(w, r, "application/json", "gzip", data)
+func WriteCompressedBlob(w http.ResponseWriter, r *http.Request, contentType string, contentEncoding string, content []byte) {
+	data := rawData{
+		ContentType:     contentType,
+		ContentEncoding: contentEncoding,
+		Content:         content,
+	}
+	if data.ContentType == "" {
+		data.ContentType = "application/octet-stream"
+	}
+	addVary(w.Header(), "Accept-Encoding")
+	if data.IsCompressed() && acceptableEncoding(r, []string{contentEncoding}) == "" {
+		if err := data.Decompress(); err != nil {
+			WriteError(w, r, errors.Wrap(err, "cannot decompress blob"))
+			return
+		}
+	}
+	_ = data.WriteResponse(w)
+}
+
+// ReadBlob reads the request body without unmarshalling it, for handlers
+// that accept binary content, such as images. As for ReadRequest, a request
+// body that is too large is an error, and a body with a Content-Encoding of
+// gzip or deflate is decompressed. The content type is from the Content-Type
+// header of the request, or "application/octet-stream" if there is none.
+func ReadBlob(r *http.Request) (content []byte, contentType string, err error) {
+	var data rawData
+	if err := data.ReadRequest(r); err != nil {
+		return nil, "", err
+	}
+	if err := data.decompressRequest(); err != nil {
+		return nil, "", err
+	}
+	captureBody(r, data.Content)
+	return data.Content, data.ContentType, nil
+}
+
+// maxRanges is the maximum number of ranges in a Range header that are
+// served. If a request has more, the Range header is ignored, and the
+// whole content is sent.
+const maxRanges = 16
+
+// Blob is binary content, such as a file or a report, that is sent
+// to the client by ServeBlob.
+type Blob struct {
+	// Content is the content to send, which must be positioned
+	// at its start.
+	Content io.ReadSeeker
+
+	// ContentType is the media type of the content. If not specified,
+	// the content type is "application/octet-stream".
+	ContentType string
+
+	// ModTime is the optional time that the content was last modified.
+	// It is sent in the Last-Modified header, and compared with the
+	// If-Range header of the request.
+	ModTime time.Time
+
+	// ETag is the optional entity tag of the content, including the double
+	// quotes. It is sent in the ETag header, and compared with the If-Range
+	// header of the request.
+	ETag string
+
+	// Filename is the optional name of the file that the client should save
+	// the content as. If specified, it is sent in a Content-Disposition header.
+	Filename string
+}
+
+// ServeBlob sends binary content to the client, honouring the Range header
+// of the request, so that clients can resume interrupted downloads of large
+// content. Responses include an "Accept-Ranges: bytes" header.
+//
+// If the request has a Range header for a single range of bytes, the
+// response has a 206 (Partial Content) status, a Content-Range header, and
+// only the bytes in the range. A request for more than one range receives
+// a "multipart/byteranges" response that contains each range as a part. If
+// none of the ranges are satisfiable, a 416 (Range Not Satisfiable) error is
+// sent using WriteError. The whole content is sent if the Range header is
+// invalid, has too many ranges, or if the If-Range header does not match the
+// ModTime or ETag of the blob.
+func ServeBlob(w http.ResponseWriter, r *http.Request, blob *Blob) {
+	size, err := blob.Content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = blob.Content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		WriteError(w, r, errors.Wrap(err, "cannot seek blob content"))
+		return
+	}
+
+	contentType := blob.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := w.Header()
+	h.Set("Accept-Ranges", "bytes")
+	if !blob.ModTime.IsZero() {
+		h.Set("Last-Modified", blob.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if blob.ETag != "" {
+		h.Set("ETag", blob.ETag)
+	}
+	if blob.Filename != "" {
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": blob.Filename}))
+	}
+
+	var ranges []byteRange
+	if header := r.Header.Get("Range"); header != "" && blob.ifRange(r) {
+		var ok bool
+		if ranges, ok = parseRange(header, size); ok && len(ranges) == 0 {
+			h.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+			WriteError(w, r, errkind.Public("range not satisfiable", http.StatusRequestedRangeNotSatisfiable))
+			return
+		}
+	}
+
+	switch len(ranges) {
+	case 0:
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			_, _ = io.CopyN(w, blob.Content, size)
+		}
+	case 1:
+		rng := ranges[0]
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Range", rng.contentRange(size))
+		h.Set("Content-Length", strconv.FormatInt(rng.length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method != http.MethodHead {
+			if _, err := blob.Content.Seek(rng.start, io.SeekStart); err == nil {
+				_, _ = io.CopyN(w, blob.Content, rng.length)
+			}
+		}
+	default:
+		mw := multipart.NewWriter(w)
+		h.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method == http.MethodHead {
+			return
+		}
+		for _, rng := range ranges {
+			part, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":  {contentType},
+				"Content-Range": {rng.contentRange(size)},
+			})
+			if err != nil {
+				return
+			}
+			if _, err := blob.Content.Seek(rng.start, io.SeekStart); err != nil {
+				return
+			}
+			if _, err := io.CopyN(part, blob.Content, rng.length); err != nil {
+				return
+			}
+		}
+		_ = mw.Close()
+	}
+}
+
+// ifRange reports whether the Range header of the request should be
+// honoured, because there is no If-Range header, or it matches the blob.
+func (blob *Blob) ifRange(r *http.Request) bool {
+	ifRange := r.Header.Get("If-Range")
+	switch {
+	case ifRange == "":
+		return true
+	case strings.HasPrefix(ifRange, `"`):
+		// entity tags must match using the strong comparison
+		return blob.ETag != "" && !strings.HasPrefix(blob.ETag, "W/") && ifRange == blob.ETag
+	case strings.HasPrefix(ifRange, "W/"):
+		return false
+	}
+	t, err := http.ParseTime(ifRange)
+	return err == nil && !blob.ModTime.IsZero() && blob.ModTime.Truncate(time.Second).Equal(t)
+}
+
+// byteRange is a range of bytes in the content.
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange returns the value of the Content-Range header for the range.
+func (rng byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.start+rng.length-1, size)
+}
+
+// parseRange parses the Range header for content of the size. It returns
+// false if the header should be ignored, because it is invalid, or it asks
+// for too much. Otherwise it returns the satisfiable ranges, which is empty
+// if the request cannot be satisfied.
+func parseRange(header string, size int64) ([]byteRange, bool) {
+	specs, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, false
+	}
+	ranges := []byteRange{}
+	var count int
+	var total int64
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		count++
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, false
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+		var rng byteRange
+		if first == "" {
+			// suffix range, eg "-500" for the last 500 bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, false
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			rng = byteRange{start: size - n, length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, false
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, false
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			if start >= size {
+				continue
+			}
+			rng = byteRange{start: start, length: end - start + 1}
+		}
+		ranges = append(ranges, rng)
+		total += rng.length
+	}
+	if count == 0 || len(ranges) > maxRanges || total > size {
+		// send the whole content instead
+		return nil, false
+	}
+	return ranges, true
+}
@@ -0,0 +1,377 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/errkind"
+)
+
+func TestServeBlob(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		method       string
+		header       map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{
+			status: http.StatusOK,
+			body:   content,
+		},
+		{
+			header:       map[string]string{"Range": "bytes=0-4"},
+			status:       http.StatusPartialContent,
+			body:         "01234",
+			contentRange: "bytes 0-4/20",
+		},
+		{
+			header:       map[string]string{"Range": "bytes=15-"},
+			status:       http.StatusPartialContent,
+			body:         "fghij",
+			contentRange: "bytes 15-19/20",
+		},
+		{
+			header:       map[string]string{"Range": "bytes=-3"},
+			status:       http.StatusPartialContent,
+			body:         "hij",
+			contentRange: "bytes 17-19/20",
+		},
+		{
+			header:       map[string]string{"Range": "bytes=18-100"},
+			status:       http.StatusPartialContent,
+			body:         "ij",
+			contentRange: "bytes 18-19/20",
+		},
+		{
+			header:       map[string]string{"Range": "bytes=30-40, 5-5"},
+			status:       http.StatusPartialContent,
+			body:         "5",
+			contentRange: "bytes 5-5/20",
+		},
+		{
+			header:       map[string]string{"Range": "bytes=20-"},
+			status:       http.StatusRequestedRangeNotSatisfiable,
+			contentRange: "bytes */20",
+		},
+		{
+			// invalid header is ignored
+			header: map[string]string{"Range": "bytes=5-2"},
+			status: http.StatusOK,
+			body:   content,
+		},
+		{
+			header: map[string]string{"Range": "items=0-4"},
+			status: http.StatusOK,
+			body:   content,
+		},
+		{
+			// overlapping ranges that ask for more than the content
+			header: map[string]string{"Range": "bytes=0-15,5-19"},
+			status: http.StatusOK,
+			body:   content,
+		},
+		{
+			header: map[string]string{"Range": "bytes=0-0" + strings.Repeat(",0-0", maxRanges)},
+			status: http.StatusOK,
+			body:   content,
+		},
+		{
+			header:       map[string]string{"Range": "bytes=0-4", "If-Range": `"v1"`},
+			status:       http.StatusPartialContent,
+			body:         "01234",
+			contentRange: "bytes 0-4/20",
+		},
+		{
+			header: map[string]string{"Range": "bytes=0-4", "If-Range": `"v0"`},
+			status: http.StatusOK,
+			body:   content,
+		},
+		{
+			header:       map[string]string{"Range": "bytes=0-4", "If-Range": modTime.Format(http.TimeFormat)},
+			status:       http.StatusPartialContent,
+			body:         "01234",
+			contentRange: "bytes 0-4/20",
+		},
+		{
+			header: map[string]string{"Range": "bytes=0-4", "If-Range": modTime.Add(-time.Hour).Format(http.TimeFormat)},
+			status: http.StatusOK,
+			body:   content,
+		},
+		{
+			method:       "HEAD",
+			header:       map[string]string{"Range": "bytes=0-4"},
+			status:       http.StatusPartialContent,
+			contentRange: "bytes 0-4/20",
+		},
+	}
+	for i, tt := range tests {
+		method := tt.method
+		if method == "" {
+			method = "GET"
+		}
+		r := httptest.NewRequest(method, "/report", nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		ServeBlob(w, r, &Blob{
+			Content:     strings.NewReader(content),
+			ContentType: "text/plain",
+			ModTime:     modTime,
+			ETag:        `"v1"`,
+			Filename:    "report.txt",
+		})
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("Content-Range"), tt.contentRange; got != want {
+			t.Errorf("%d: content range: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Accept-Ranges"), "bytes"; got != want {
+			t.Errorf("%d: accept ranges: want %q, got %q", i, want, got)
+		}
+		if tt.status == http.StatusRequestedRangeNotSatisfiable {
+			continue
+		}
+		if got, want := w.Body.String(), tt.body; got != want {
+			t.Errorf("%d: body: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Content-Disposition"), `attachment; filename=report.txt`; got != want {
+			t.Errorf("%d: content disposition: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Last-Modified"), "Thu, 02 Jan 2020 03:04:05 GMT"; got != want {
+			t.Errorf("%d: last modified: want %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestServeBlobMultipart(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Range", "bytes=0-1, 10-12, -2")
+	w := httptest.NewRecorder()
+	ServeBlob(w, r, &Blob{Content: strings.NewReader("0123456789abcdefghij")})
+
+	if got, want := w.Code, http.StatusPartialContent; got != want {
+		t.Fatalf("want %d, got %d", want, got)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("want multipart/byteranges, got %q", w.Header().Get("Content-Type"))
+	}
+	want := []struct {
+		contentRange string
+		body         string
+	}{
+		{"bytes 0-1/20", "01"},
+		{"bytes 10-12/20", "abc"},
+		{"bytes 18-19/20", "ij"},
+	}
+	mr := multipart.NewReader(w.Body, params["boundary"])
+	for i, part := range want {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if got := p.Header.Get("Content-Range"); got != part.contentRange {
+			t.Errorf("%d: want %q, got %q", i, part.contentRange, got)
+		}
+		if got := p.Header.Get("Content-Type"); got != "application/octet-stream" {
+			t.Errorf("%d: want application/octet-stream, got %q", i, got)
+		}
+		body, _ := io.ReadAll(p)
+		if string(body) != part.body {
+			t.Errorf("%d: want %q, got %q", i, part.body, body)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("want EOF, got %v", err)
+	}
+}
+
+func TestWriteBlob(t *testing.T) {
+	large := strings.Repeat("the quick brown fox jumps over the lazy dog\n", 200)
+	tests := []struct {
+		contentType string
+		content     string
+		accept      string
+		status      int
+		encoding    string
+		wantType    string
+	}{
+		{
+			contentType: "text/csv",
+			content:     large,
+			accept:      "gzip",
+			status:      http.StatusOK,
+			encoding:    "gzip",
+			wantType:    "text/csv",
+		},
+		{
+			contentType: "text/csv",
+			content:     large,
+			status:      http.StatusOK,
+			wantType:    "text/csv",
+		},
+		{
+			content:  "small",
+			accept:   "gzip",
+			status:   http.StatusOK,
+			wantType: "application/octet-stream",
+		},
+		{
+			contentType: "application/pdf",
+			status:      http.StatusNoContent,
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		WriteBlob(w, r, tt.contentType, []byte(tt.content))
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("Content-Encoding"), tt.encoding; got != want {
+			t.Errorf("%d: encoding: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Content-Type"), tt.wantType; got != want {
+			t.Errorf("%d: content type: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Vary"), "Accept-Encoding"; got != want {
+			t.Errorf("%d: vary: want %q, got %q", i, want, got)
+		}
+		body, err := decodeBody(w)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		} else if string(body) != tt.content {
+			t.Errorf("%d: want body %.20q, got %.20q", i, tt.content, body)
+		}
+	}
+}
+
+func TestWriteCompressedBlob(t *testing.T) {
+	const content = `{"name":"thing"}`
+	var data rawData
+	data.ContentType = "application/json"
+	data.Content = []byte(strings.Repeat(content, 100))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	if err := data.CompressResponse(r); err != nil || data.ContentEncoding != "gzip" {
+		t.Fatalf("cannot compress: %v", err)
+	}
+	compressed := data.Content
+
+	tests := []struct {
+		accept   string
+		encoding string
+	}{
+		{accept: "gzip, deflate", encoding: "gzip"},
+		{accept: "deflate", encoding: ""},
+		{accept: "", encoding: ""},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		WriteCompressedBlob(w, r, "application/json", "gzip", compressed)
+		if got, want := w.Header().Get("Content-Encoding"), tt.encoding; got != want {
+			t.Errorf("%d: encoding: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+			t.Errorf("%d: content type: want %q, got %q", i, want, got)
+		}
+		body, err := decodeBody(w)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		} else if got, want := string(body), strings.Repeat(content, 100); got != want {
+			t.Errorf("%d: want body %.20q, got %.20q", i, want, got)
+		}
+	}
+}
+
+func TestReadBlob(t *testing.T) {
+	defer func(n int) { maxRequestLen = n }(maxRequestLen)
+	maxRequestLen = 100
+
+	gzipped := func(s string) string {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		io.WriteString(zw, s)
+		zw.Close()
+		return buf.String()
+	}
+	tests := []struct {
+		body        string
+		header      map[string]string
+		content     string
+		contentType string
+		status      int
+	}{
+		{
+			body:        "\x89PNG",
+			header:      map[string]string{"Content-Type": "image/png"},
+			content:     "\x89PNG",
+			contentType: "image/png",
+		},
+		{
+			body:        "data",
+			content:     "data",
+			contentType: "application/octet-stream",
+		},
+		{
+			body:        gzipped("compressed data"),
+			header:      map[string]string{"Content-Encoding": "gzip", "Content-Type": "text/plain"},
+			content:     "compressed data",
+			contentType: "text/plain",
+		},
+		{
+			body:   gzipped(strings.Repeat("x", 200)),
+			header: map[string]string{"Content-Encoding": "gzip"},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			body:   "not gzip",
+			header: map[string]string{"Content-Encoding": "gzip"},
+			status: http.StatusBadRequest,
+		},
+		{
+			body:   strings.Repeat("x", 200),
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		content, contentType, err := ReadBlob(r)
+		if tt.status != 0 {
+			if err == nil || errkind.StatusCode(err) != tt.status {
+				t.Errorf("%d: want status %d, got %v", i, tt.status, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if string(content) != tt.content || contentType != tt.contentType {
+			t.Errorf("%d: want %q %q, got %q %q", i, tt.content, tt.contentType, content, contentType)
+		}
+	}
+}
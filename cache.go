@@ -0,0 +1,440 @@
+package httpapi
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default values for Cache.
+const (
+	defaultCacheTTL     = time.Minute
+	defaultCacheMaxSize = 1 << 20
+)
+
+// cacheSweepInterval is the minimum time between scans of a
+// MemoryCacheStore for expired entries.
+const cacheSweepInterval = time.Minute
+
+// Cache is middleware that caches successful GET responses, so that
+// requests for frequently read resources can be served without calling the
+// handler. The zero value is ready to use, and caches responses in memory.
+// For example:
+//  var cache httpapi.Cache
+//  cache.TTL = 30 * time.Second
+//  stack := httpapi.Use(cache.Middleware(), httpapi.Compress())
+//
+// A response is cached if it is a 200 (OK) response to a GET request, and
+// it does not have a Set-Cookie header, or a Cache-Control header with
+// no-store, no-cache or private. Requests with an Authorization or Cookie
+// header are not cached, and are not served from the cache, as the response
+// is likely to depend on the client. HEAD
+// requests are served from the cached response to a GET request.
+//
+// Responses are cached by request URI and by the values of the request
+// headers named in the Vary header of the response. A response with a
+// Content-Encoding is also cached by the Accept-Encoding request header,
+// so when Cache is used before Compress in the stack, as in the example
+// above, the compressed variants are cached and need not be compressed
+// again.
+//
+// A client can bypass the cache using a Cache-Control request header of
+// no-cache (the response is cached for later requests) or no-store (the
+// response is not cached).
+//
+// A successful POST, PUT, PATCH or DELETE request removes the cached
+// response for the same request URI. Other changes to resources can be
+// reported using the Invalidate method.
+type Cache struct {
+	// Store holds the cached responses. If nil, responses are
+	// cached in memory using a MemoryCacheStore.
+	Store CacheStore
+
+	// TTL is the time that a response is cached. If zero,
+	// responses are cached for one minute.
+	TTL time.Duration
+
+	// MaxSize is the largest response body that is cached, in bytes.
+	// If zero, responses up to one megabyte are cached.
+	MaxSize int
+
+	once  sync.Once
+	store CacheStore
+}
+
+// CacheStore stores the responses cached by Cache. Implementations must be
+// safe for concurrent use. A store shared between servers, eg a Redis or
+// memcached client, allows the servers to share cached responses.
+type CacheStore interface {
+	// Get returns the entry with the key, and false if there
+	// is no entry or the entry has expired.
+	Get(key string) (*CacheEntry, bool)
+
+	// Set stores the entry with the key, replacing any existing
+	// entry. The entry expires after the time to live.
+	Set(key string, entry *CacheEntry, ttl time.Duration)
+
+	// Delete removes any entry with the key.
+	Delete(key string)
+}
+
+// CacheEntry is an entry in a CacheStore. An entry either contains a cached
+// response, or if the response varies by request header, the names of the
+// request headers (Vary), and the response is stored separately for
+// each set of header values.
+type CacheEntry struct {
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	Stored time.Time   `json:"stored,omitempty"`
+	Vary   []string    `json:"vary,omitempty"`
+}
+
+// Middleware returns middleware that serves cached responses, and caches
+// the responses sent by the handler.
+func (c *Cache) Middleware() Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead:
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+				rw := newResponseWriter(w)
+				h.ServeHTTP(rw, r)
+				if rw.status == 0 || rw.status >= 200 && rw.status < 400 {
+					c.Invalidate(r.URL.RequestURI())
+				}
+				return
+			default:
+				h.ServeHTTP(w, r)
+				return
+			}
+			if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			directives := cacheControl(r.Header)
+			if directives["no-store"] {
+				h.ServeHTTP(w, r)
+				return
+			}
+			key := cacheKey(r)
+			if !directives["no-cache"] {
+				if entry, ok := c.lookup(key, r); ok {
+					c.serve(w, r, entry)
+					return
+				}
+			}
+			if r.Method == http.MethodHead {
+				// the response to a HEAD request has no body to cache
+				h.ServeHTTP(w, r)
+				return
+			}
+			cw := &cacheWriter{ResponseWriter: w, maxSize: c.maxSize()}
+			h.ServeHTTP(cw, r)
+			c.save(key, r, cw)
+		})
+	}
+}
+
+// Invalidate removes the cached response for the request URI, which is the
+// path and query string of the request, eg "/items/123".
+func (c *Cache) Invalidate(uri string) {
+	c.getStore().Delete(cacheKeyPrefix + uri)
+}
+
+// lookup returns the cached response for the request.
+func (c *Cache) lookup(key string, r *http.Request) (*CacheEntry, bool) {
+	store := c.getStore()
+	entry, ok := store.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if len(entry.Vary) > 0 {
+		// a variant that is older than the entry listing the Vary
+		// headers was stored before the response was invalidated
+		variant, ok := store.Get(variantKey(key, entry.Vary, r))
+		if !ok || variant.Status == 0 || variant.Stored.Before(entry.Stored) {
+			return nil, false
+		}
+		entry = variant
+	}
+	return entry, true
+}
+
+// serve sends the cached response to the client.
+func (c *Cache) serve(w http.ResponseWriter, r *http.Request, entry *CacheEntry) {
+	h := w.Header()
+	for name, values := range entry.Header {
+		h[name] = append([]string(nil), values...)
+	}
+	if !entry.Stored.IsZero() {
+		age := int64(time.Since(entry.Stored) / time.Second)
+		if age < 0 {
+			age = 0
+		}
+		h.Set("Age", strconv.FormatInt(age, 10))
+	}
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(entry.Body)
+	}
+}
+
+// save caches the response written by the handler, if it can be cached.
+func (c *Cache) save(key string, r *http.Request, cw *cacheWriter) {
+	if !cw.cacheable() {
+		return
+	}
+	header := cw.Header().Clone()
+	vary, ok := varyHeaders(header)
+	if !ok {
+		return
+	}
+	entry := &CacheEntry{
+		Status: cw.status,
+		Header: header,
+		Body:   cw.buf,
+		Stored: time.Now(),
+	}
+	store := c.getStore()
+	ttl := c.ttl()
+	if len(vary) == 0 {
+		store.Set(key, entry, ttl)
+		return
+	}
+	varies := &CacheEntry{Vary: vary, Stored: entry.Stored}
+	if prev, ok := store.Get(key); ok && prev.Status == 0 && slices.Equal(prev.Vary, vary) {
+		// keep the time, so that other variants remain valid
+		varies.Stored = prev.Stored
+	}
+	store.Set(key, varies, ttl)
+	store.Set(variantKey(key, vary, r), entry, ttl)
+}
+
+func (c *Cache) getStore() CacheStore {
+	c.once.Do(func() {
+		c.store = c.Store
+		if c.store == nil {
+			c.store = NewMemoryCacheStore()
+		}
+	})
+	return c.store
+}
+
+func (c *Cache) ttl() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+	return defaultCacheTTL
+}
+
+func (c *Cache) maxSize() int {
+	if c.MaxSize > 0 {
+		return c.MaxSize
+	}
+	return defaultCacheMaxSize
+}
+
+// cacheKeyPrefix is the prefix for all keys in the store, so that a store
+// can be shared with other users.
+const cacheKeyPrefix = "httpapi:"
+
+// cacheKey returns the key for the request, ignoring any Vary headers.
+func cacheKey(r *http.Request) string {
+	return cacheKeyPrefix + r.URL.RequestURI()
+}
+
+// variantKey returns the key for the request, including the values of
+// the request headers named in vary.
+func variantKey(key string, vary []string, r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(key)
+	for _, name := range vary {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(": ")
+		sb.WriteString(strings.Join(r.Header.Values(name), ", "))
+	}
+	return sb.String()
+}
+
+// varyHeaders returns the sorted, canonical names of the request headers
+// that the response varies by. It returns false if the response varies by
+// "*", in which case it cannot be cached.
+func varyHeaders(h http.Header) ([]string, bool) {
+	names := make(map[string]bool)
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" {
+				return nil, false
+			}
+			if field != "" {
+				names[textproto.CanonicalMIMEHeaderKey(field)] = true
+			}
+		}
+	}
+	if h.Get("Content-Encoding") != "" {
+		// the response may have been compressed without adding a Vary header
+		names["Accept-Encoding"] = true
+	}
+	vary := make([]string, 0, len(names))
+	for name := range names {
+		vary = append(vary, name)
+	}
+	sort.Strings(vary)
+	return vary, true
+}
+
+// cacheControl returns the directives in the Cache-Control header, ignoring
+// any values, eg "max-age=60" is returned as "max-age".
+func cacheControl(h http.Header) map[string]bool {
+	directives := make(map[string]bool)
+	for _, v := range h.Values("Cache-Control") {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if i := strings.IndexByte(field, '='); i >= 0 {
+				field = field[:i]
+			}
+			if field != "" {
+				directives[strings.ToLower(field)] = true
+			}
+		}
+	}
+	return directives
+}
+
+// cacheWriter sends the response written by a handler to the client,
+// and keeps a copy of the response body for caching.
+type cacheWriter struct {
+	http.ResponseWriter
+	status   int
+	buf      []byte
+	maxSize  int
+	overflow bool
+	hijacked bool
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	if !w.overflow {
+		if len(w.buf)+n > w.maxSize {
+			w.overflow = true
+			w.buf = nil
+		} else {
+			w.buf = append(w.buf, p[:n]...)
+		}
+	}
+	return n, err
+}
+
+// Flush sends any buffered data to the client, if the underlying
+// writer, or a writer that it wraps, supports flushing.
+func (w *cacheWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying writer, for use by http.ResponseController.
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack lets the caller take over the connection, if the underlying
+// writer supports it, eg for WebSocket connections. A hijacked
+// response is not cached.
+func (w *cacheWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// cacheable reports whether the response can be cached.
+func (w *cacheWriter) cacheable() bool {
+	if w.status != http.StatusOK || w.overflow || w.hijacked {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+	directives := cacheControl(h)
+	return !directives["no-store"] && !directives["no-cache"] && !directives["private"]
+}
+
+// MemoryCacheStore is a CacheStore that keeps entries in memory.
+type MemoryCacheStore struct {
+	mutex   sync.Mutex
+	entries map[string]memoryCacheEntry
+	swept   time.Time
+}
+
+type memoryCacheEntry struct {
+	entry   *CacheEntry
+	expires time.Time
+}
+
+// NewMemoryCacheStore returns a new, empty in-memory store.
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{
+		entries: make(map[string]memoryCacheEntry),
+		swept:   time.Now(),
+	}
+}
+
+// Get implements the CacheStore interface.
+func (s *MemoryCacheStore) Get(key string) (*CacheEntry, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !time.Now().Before(e.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return e.entry, true
+}
+
+// Set implements the CacheStore interface. Expired entries are removed
+// from time to time as entries are added.
+func (s *MemoryCacheStore) Set(key string, entry *CacheEntry, ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(s.swept) >= cacheSweepInterval {
+		for k, e := range s.entries {
+			if !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+	s.entries[key] = memoryCacheEntry{entry: entry, expires: now.Add(ttl)}
+}
+
+// Delete implements the CacheStore interface.
+func (s *MemoryCacheStore) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, key)
+}
@@ -0,0 +1,189 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	type request struct {
+		method string
+		target string
+		header map[string]string
+		body   string // expected response body
+		calls  int    // expected number of handler calls so far
+	}
+	tests := []struct {
+		handler  func(w http.ResponseWriter, r *http.Request)
+		requests []request
+	}{
+		{
+			// GET responses are cached, and HEAD uses the cached response
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "hello")
+			},
+			requests: []request{
+				{method: "GET", target: "/a", body: "hello", calls: 1},
+				{method: "GET", target: "/a", body: "hello", calls: 1},
+				{method: "HEAD", target: "/a", body: "", calls: 1},
+				{method: "GET", target: "/a?x=1", body: "hello", calls: 2},
+			},
+		},
+		{
+			// unsafe methods invalidate the cached response
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, r.Method)
+			},
+			requests: []request{
+				{method: "GET", target: "/a", body: "GET", calls: 1},
+				{method: "GET", target: "/a", body: "GET", calls: 1},
+				{method: "PUT", target: "/a", body: "PUT", calls: 2},
+				{method: "GET", target: "/a", body: "GET", calls: 3},
+			},
+		},
+		{
+			// responses vary by the request headers named in Vary
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Vary", "Accept-Language")
+				fmt.Fprint(w, r.Header.Get("Accept-Language"))
+			},
+			requests: []request{
+				{method: "GET", target: "/a", header: map[string]string{"Accept-Language": "en"}, body: "en", calls: 1},
+				{method: "GET", target: "/a", header: map[string]string{"Accept-Language": "fr"}, body: "fr", calls: 2},
+				{method: "GET", target: "/a", header: map[string]string{"Accept-Language": "en"}, body: "en", calls: 2},
+				{method: "DELETE", target: "/a", body: "", calls: 3},
+				{method: "GET", target: "/a", header: map[string]string{"Accept-Language": "fr"}, body: "fr", calls: 4},
+				{method: "GET", target: "/a", header: map[string]string{"Accept-Language": "en"}, body: "en", calls: 5},
+			},
+		},
+		{
+			// responses that are not cacheable
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/private":
+					w.Header().Set("Cache-Control", "private, max-age=60")
+				case "/cookie":
+					w.Header().Set("Set-Cookie", "a=b")
+				case "/error":
+					w.WriteHeader(http.StatusNotFound)
+				case "/large":
+					fmt.Fprint(w, strings.Repeat("x", 11))
+					return
+				}
+				fmt.Fprint(w, "ok")
+			},
+			requests: []request{
+				{method: "GET", target: "/private", body: "ok", calls: 1},
+				{method: "GET", target: "/private", body: "ok", calls: 2},
+				{method: "GET", target: "/cookie", body: "ok", calls: 3},
+				{method: "GET", target: "/cookie", body: "ok", calls: 4},
+				{method: "GET", target: "/error", body: "ok", calls: 5},
+				{method: "GET", target: "/error", body: "ok", calls: 6},
+				{method: "GET", target: "/large", body: "xxxxxxxxxxx", calls: 7},
+				{method: "GET", target: "/large", body: "xxxxxxxxxxx", calls: 8},
+			},
+		},
+		{
+			// requests that bypass the cache
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "ok")
+			},
+			requests: []request{
+				{method: "GET", target: "/a", header: map[string]string{"Authorization": "Bearer x"}, body: "ok", calls: 1},
+				{method: "GET", target: "/a", header: map[string]string{"Cache-Control": "no-store"}, body: "ok", calls: 2},
+				{method: "GET", target: "/a", header: map[string]string{"Cache-Control": "no-cache"}, body: "ok", calls: 3},
+				{method: "GET", target: "/a", body: "ok", calls: 3},
+			},
+		},
+		{
+			// responses to requests with cookies depend on the session
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, r.Header.Get("Cookie"))
+			},
+			requests: []request{
+				{method: "GET", target: "/me", body: "", calls: 1},
+				{method: "GET", target: "/me", body: "", calls: 1},
+				{method: "GET", target: "/me", header: map[string]string{"Cookie": "session=alice"}, body: "session=alice", calls: 2},
+				{method: "GET", target: "/me", header: map[string]string{"Cookie": "session=bob"}, body: "session=bob", calls: 3},
+				{method: "GET", target: "/me", header: map[string]string{"Cookie": "session=alice"}, body: "session=alice", calls: 4},
+			},
+		},
+	}
+	for i, tt := range tests {
+		calls := 0
+		cache := Cache{MaxSize: 10}
+		h := cache.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			tt.handler(w, r)
+		}))
+		for j, req := range tt.requests {
+			r := httptest.NewRequest(req.method, req.target, nil)
+			for k, v := range req.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if got, want := w.Body.String(), req.body; got != want {
+				t.Errorf("%d/%d: body: want %q, got %q", i, j, want, got)
+			}
+			if got, want := calls, req.calls; got != want {
+				t.Errorf("%d/%d: calls: want %d, got %d", i, j, want, got)
+			}
+		}
+	}
+}
+
+func TestCacheCompressed(t *testing.T) {
+	large := strings.Repeat("the quick brown fox jumps over the lazy dog\n", 200)
+	calls := 0
+	var cache Cache
+	h := Use(cache.Middleware(), Compress()).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, large)
+	})
+	tests := []struct {
+		accept   string
+		encoding string
+		calls    int
+	}{
+		{accept: "gzip", encoding: "gzip", calls: 1},
+		{accept: "", encoding: "", calls: 2},
+		{accept: "gzip", encoding: "gzip", calls: 2},
+		{accept: "", encoding: "", calls: 2},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Header().Get("Content-Encoding"), tt.encoding; got != want {
+			t.Errorf("%d: content-encoding: want %q, got %q", i, want, got)
+		}
+		if got, want := calls, tt.calls; got != want {
+			t.Errorf("%d: calls: want %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	store := NewMemoryCacheStore()
+	store.Set("a", &CacheEntry{Status: 200}, time.Hour)
+	store.Set("b", &CacheEntry{Status: 200}, -time.Second)
+	if _, ok := store.Get("a"); !ok {
+		t.Errorf("a: want entry, got none")
+	}
+	if _, ok := store.Get("b"); ok {
+		t.Errorf("b: want expired, got entry")
+	}
+	store.Delete("a")
+	if _, ok := store.Get("a"); ok {
+		t.Errorf("a: want deleted, got entry")
+	}
+}
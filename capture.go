@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+)
+
+// CapturedBody is a request body captured by ReadRequest,
+// for requests handled by CaptureRequestBody.
+type CapturedBody struct {
+	Body      []byte // Body after decompression and truncation
+	Size      int    // Size of the body after decompression
+	Truncated bool   // Body was truncated
+}
+
+// bodyCapture is stored in the request context by CaptureRequestBody.
+type bodyCapture struct {
+	maxSize int
+	body    *CapturedBody
+}
+
+// CaptureRequestBody returns middleware that keeps a copy of the request body
+// when it is read by ReadRequest, or by the functions that call it, such as
+// Handle, or by ReadBlob. The body is decompressed, and truncated to maxSize
+// bytes, or 4096 bytes if maxSize is zero or negative. Middleware that runs
+// inside CaptureRequestBody, such as audit logging, can access exactly what
+// the client sent using CapturedRequestBody after the handler has returned,
+// without reading the request body again. For example:
+//  stack := httpapi.Use(
+//      httpapi.CaptureRequestBody(64*1024),
+//      audit, // calls httpapi.CapturedRequestBody(r) after the handler
+//  )
+//
+// Bodies read by other means, such as directly from r.Body, are not captured.
+func CaptureRequestBody(maxSize int) Middleware {
+	if maxSize <= 0 {
+		maxSize = defaultPayloadMaxSize
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), captureKey, &bodyCapture{maxSize: maxSize})
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// CapturedRequestBody returns the request body captured for the request,
+// or nil if the request is not handled by CaptureRequestBody, or the body
+// has not been read by ReadRequest.
+func CapturedRequestBody(r *http.Request) *CapturedBody {
+	if capture, ok := r.Context().Value(captureKey).(*bodyCapture); ok {
+		return capture.body
+	}
+	return nil
+}
+
+// captureBody keeps a copy of the decompressed request body, if the
+// request is handled by CaptureRequestBody. Only the first body read
+// is kept.
+func captureBody(r *http.Request, body []byte) {
+	capture, ok := r.Context().Value(captureKey).(*bodyCapture)
+	if !ok || capture.body != nil {
+		return
+	}
+	captured := &CapturedBody{Body: body, Size: len(body)}
+	if len(body) > capture.maxSize {
+		captured.Body = body[:capture.maxSize]
+		captured.Truncated = true
+	}
+	// the body belongs to the caller of ReadRequest
+	captured.Body = bytes.Clone(captured.Body)
+	capture.body = captured
+}
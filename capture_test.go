@@ -0,0 +1,92 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCaptureRequestBody(t *testing.T) {
+	gzipped := func(s string) string {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(s))
+		zw.Close()
+		return buf.String()
+	}
+	tests := []struct {
+		maxSize  int
+		body     string
+		encoding string
+		blob     bool
+		want     *CapturedBody
+	}{
+		{
+			body: `{"name":"one"}`,
+			want: &CapturedBody{Body: []byte(`{"name":"one"}`), Size: 14},
+		},
+		{
+			body:     gzipped(`{"name":"two"}`),
+			encoding: "gzip",
+			want:     &CapturedBody{Body: []byte(`{"name":"two"}`), Size: 14},
+		},
+		{
+			maxSize: 8,
+			body:    `{"name":"three"}`,
+			want:    &CapturedBody{Body: []byte(`{"name":`), Size: 16, Truncated: true},
+		},
+		{
+			body: `name: four`,
+			blob: true,
+			want: &CapturedBody{Body: []byte(`name: four`), Size: 10},
+		},
+		{
+			// body is captured even though it is not valid
+			body: `{"name":`,
+			want: &CapturedBody{Body: []byte(`{"name":`), Size: 8},
+		},
+	}
+	for i, tt := range tests {
+		var got *CapturedBody
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.blob {
+				ReadBlob(r)
+			} else {
+				var v map[string]interface{}
+				ReadRequest(r, &v)
+			}
+		})
+		audit := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.ServeHTTP(w, r)
+				got = CapturedRequestBody(r)
+			})
+		}
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		if tt.encoding != "" {
+			r.Header.Set("Content-Encoding", tt.encoding)
+		}
+		Use(CaptureRequestBody(tt.maxSize), audit).Handler(h).ServeHTTP(httptest.NewRecorder(), r)
+		if got == nil {
+			t.Errorf("%d: want captured body, got nil", i)
+			continue
+		}
+		if !bytes.Equal(got.Body, tt.want.Body) || got.Size != tt.want.Size || got.Truncated != tt.want.Truncated {
+			t.Errorf("%d: want %+v, got %+v", i, tt.want, got)
+		}
+	}
+}
+
+func TestCapturedRequestBodyNotCaptured(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{}`))
+	var v interface{}
+	if err := ReadRequest(r, &v); err != nil {
+		t.Fatal(err)
+	}
+	if got := CapturedRequestBody(r); got != nil {
+		t.Errorf("want nil, got %+v", got)
+	}
+}
@@ -0,0 +1,31 @@
+// Package chiparams makes the URL parameters set by the chi router
+// available using httpapi.PathValues. Import it for its side effects:
+//  import _ "github.com/jjeffery/httpapi/chiparams"
+package chiparams
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjeffery/httpapi"
+)
+
+func init() {
+	httpapi.RegisterPathParams(Params)
+}
+
+// Params returns the URL parameters set by the chi router. The "*"
+// parameter for a wildcard at the end of a route pattern is not included.
+func Params(r *http.Request) map[string]string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return nil
+	}
+	params := make(map[string]string, len(rctx.URLParams.Keys))
+	for i, name := range rctx.URLParams.Keys {
+		if name != "*" && i < len(rctx.URLParams.Values) {
+			params[name] = rctx.URLParams.Values[i]
+		}
+	}
+	return params
+}
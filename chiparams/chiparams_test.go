@@ -0,0 +1,25 @@
+package chiparams
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjeffery/httpapi"
+)
+
+func TestPathValues(t *testing.T) {
+	var id int
+	var name string
+	router := chi.NewRouter()
+	router.Get("/things/{id}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		path := httpapi.PathValues(r)
+		id = path.GetInt("id")
+		name = path.GetString("name")
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/things/14/carol", nil))
+	if id != 14 || name != "carol" {
+		t.Errorf("want 14 carol, got %d %s", id, name)
+	}
+}
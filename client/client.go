@@ -0,0 +1,143 @@
+// Package client provides a HTTP client for calling JSON Web APIs
+// implemented using the httpapi package.
+//
+// Requests sent by the client automatically include the headers that
+// are propagated from the incoming request (see httpapi.OutgoingHeaders),
+// so that trace IDs, tenant, locale and deadline information flow
+// consistently between services.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jjeffery/httpapi"
+)
+
+// maxErrorSize is the most bytes read from the body of an error response.
+const maxErrorSize = 1 << 20
+
+// Client sends HTTP requests to downstream services.
+// The zero value is ready to use.
+type Client struct {
+	// HTTPClient is used to send the HTTP requests.
+	// If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Retry determines whether failed requests are retried.
+	// The zero value does not retry requests.
+	Retry RetryPolicy
+}
+
+// Do sends the HTTP request and returns the HTTP response.
+//
+// Headers returned by httpapi.OutgoingHeaders for the request context
+// are added to the request, unless the request already has a value for
+// the header. The request passed to Do is not modified.
+//
+// Failed requests are retried according to the retry policy, waiting
+// between attempts. A request with a body is only retried if its GetBody
+// field is set, as it is by http.NewRequest for common body types. If the
+// request cannot be retried, the response or error from the last attempt
+// is returned.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for n := 0; ; n++ {
+		resp, err := c.attempt(req, n)
+		if n >= c.Retry.MaxRetries || !rewindable(req) || !c.Retry.retryable(req, resp, err) {
+			return resp, err
+		}
+		wait := c.Retry.backoff(n, resp)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// no time to retry
+			return resp, err
+		}
+		if resp != nil {
+			discard(resp)
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt sends the request, which is attempt number n, starting at zero.
+func (c *Client) attempt(req *http.Request, n int) (*http.Response, error) {
+	ctx := req.Context()
+	cancel := context.CancelFunc(func() {})
+	if c.Retry.AttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Retry.AttemptTimeout)
+	}
+	req = req.WithContext(ctx)
+	if n > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		req.Body = body
+	}
+	resp, err := c.httpClient().Do(propagateHeaders(req))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// propagateHeaders returns a shallow copy of req with the outgoing headers
+// from the request context added to its header.
+func propagateHeaders(req *http.Request) *http.Request {
+	outgoing := httpapi.OutgoingHeaders(req.Context())
+	if len(outgoing) == 0 {
+		return req
+	}
+	header := make(http.Header, len(req.Header)+len(outgoing))
+	for k, v := range req.Header {
+		header[k] = v
+	}
+	for k, v := range outgoing {
+		if _, ok := header[k]; !ok {
+			header[k] = v
+		}
+	}
+	req = req.WithContext(req.Context())
+	req.Header = header
+	return req
+}
+
+// ReadError returns nil if the response has a successful status. Otherwise
+// it reads and closes the response body, and returns a *httpapi.Error
+// containing the error sent by the server, so that the caller can use
+// errors.As to inspect the error. If the body does not contain an error
+// in a known format, the error has the response status, and a message
+// that is the text for the status.
+func ReadError(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	defer resp.Body.Close()
+	e := &httpapi.Error{}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorSize))
+	if err == nil && json.Unmarshal(data, e) == nil && e.Message != "" {
+		if e.Status == 0 {
+			e.Status = resp.StatusCode
+		}
+		return e
+	}
+	return &httpapi.Error{
+		Message: http.StatusText(resp.StatusCode),
+		Status:  resp.StatusCode,
+	}
+}
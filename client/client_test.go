@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi"
+)
+
+func TestClientPropagatesHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer srv.Close()
+
+	ctx := httpapi.WithPropagatedHeader(context.Background(), httpapi.HeaderTraceID, "trace-1")
+	ctx = httpapi.WithPropagatedHeader(ctx, httpapi.HeaderTenantID, "tenant-1")
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set(httpapi.HeaderTenantID, "explicit")
+
+	var c Client
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if v := got.Get(httpapi.HeaderTraceID); v != "trace-1" {
+		t.Errorf("trace: want %q, got %q", "trace-1", v)
+	}
+	if v := got.Get(httpapi.HeaderTenantID); v != "explicit" {
+		t.Errorf("tenant: want %q, got %q", "explicit", v)
+	}
+	if v := req.Header.Get(httpapi.HeaderTraceID); v != "" {
+		t.Errorf("original request modified: %q", v)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	tests := []struct {
+		method   string
+		header   http.Header
+		failures int // number of 503 responses before success
+		policy   RetryPolicy
+		attempts int
+		status   int
+	}{
+		{
+			method:   "GET",
+			failures: 2,
+			policy:   RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond},
+			attempts: 3,
+			status:   http.StatusOK,
+		},
+		{
+			method:   "GET",
+			failures: 5,
+			policy:   RetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond},
+			attempts: 3,
+			status:   http.StatusServiceUnavailable,
+		},
+		{
+			// zero policy does not retry
+			method:   "GET",
+			failures: 1,
+			attempts: 1,
+			status:   http.StatusServiceUnavailable,
+		},
+		{
+			// POST is not idempotent
+			method:   "POST",
+			failures: 1,
+			policy:   RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond},
+			attempts: 1,
+			status:   http.StatusServiceUnavailable,
+		},
+		{
+			method:   "POST",
+			header:   http.Header{"Idempotency-Key": {"abc"}},
+			failures: 1,
+			policy:   RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond},
+			attempts: 2,
+			status:   http.StatusOK,
+		},
+		{
+			method:   "POST",
+			failures: 1,
+			policy: RetryPolicy{
+				MaxRetries: 3,
+				MinBackoff: time.Millisecond,
+				Retryable: func(req *http.Request, resp *http.Response, err error) bool {
+					return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+				},
+			},
+			attempts: 2,
+			status:   http.StatusOK,
+		},
+	}
+	for i, tt := range tests {
+		attempts := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			body, _ := io.ReadAll(r.Body)
+			if r.Method == "POST" && string(body) != "body" {
+				t.Errorf("%d: attempt %d: want body, got %q", i, attempts, body)
+			}
+			if attempts <= tt.failures {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}))
+		req, err := http.NewRequest(tt.method, srv.URL, strings.NewReader("body"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range tt.header {
+			req.Header[k] = v
+		}
+		c := Client{Retry: tt.policy}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		} else {
+			resp.Body.Close()
+			if got, want := resp.StatusCode, tt.status; got != want {
+				t.Errorf("%d: status: want %d, got %d", i, want, got)
+			}
+		}
+		if got, want := attempts, tt.attempts; got != want {
+			t.Errorf("%d: attempts: want %d, got %d", i, want, got)
+		}
+		srv.Close()
+	}
+}
+
+func TestClientAttemptTimeout(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			// first attempt is too slow
+			<-r.Context().Done()
+			return
+		}
+		if r.Header.Get(httpapi.HeaderRequestTimeout) == "" {
+			t.Errorf("want request timeout header")
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{Retry: RetryPolicy{MaxRetries: 1, MinBackoff: time.Millisecond, AttemptTimeout: 200 * time.Millisecond}}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "ok"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := attempts, 2; got != want {
+		t.Errorf("attempts: want %d, got %d", want, got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{value: "", min: 0, max: 0},
+		{value: "3", min: 3 * time.Second, max: 3 * time.Second},
+		{value: "-1", min: 0, max: 0},
+		{value: "soon", min: 0, max: 0},
+		{value: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), min: 58 * time.Second, max: time.Minute},
+		{value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), min: 0, max: 0},
+	}
+	for i, tt := range tests {
+		if got := retryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("%d: want %v..%v, got %v", i, tt.min, tt.max, got)
+		}
+	}
+}
+
+func TestReadError(t *testing.T) {
+	tests := []struct {
+		handler http.HandlerFunc
+		want    *httpapi.Error
+	}{
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "ok")
+			},
+			want: nil,
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				httpapi.WriteError(w, r, errkind.Public("thing not found", http.StatusNotFound))
+			},
+			want: &httpapi.Error{Message: "thing not found", Status: http.StatusNotFound},
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			},
+			want: &httpapi.Error{Message: "Bad Gateway", Status: http.StatusBadGateway},
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.handler(w, httptest.NewRequest("GET", "/", nil))
+		err := ReadError(w.Result())
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%d: want nil, got %v", i, err)
+			}
+			continue
+		}
+		var got *httpapi.Error
+		if !errors.As(err, &got) {
+			t.Errorf("%d: want *httpapi.Error, got %v", i, err)
+			continue
+		}
+		if got.Message != tt.want.Message || got.Status != tt.want.Status {
+			t.Errorf("%d: want %+v, got %+v", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default values for RetryPolicy.
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+)
+
+// maxDrain is the most bytes read from the body of a response that is
+// discarded before retrying, so that the connection can be reused.
+const maxDrain = 4096
+
+// RetryPolicy determines whether and when a failed request is retried.
+// The zero value does not retry requests.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times a request is retried
+	// after the first attempt.
+	MaxRetries int
+
+	// MinBackoff and MaxBackoff are the limits of the time waited before
+	// retrying a request. The limit doubles for each retry, starting at
+	// MinBackoff, and the time waited is a random duration up to the limit.
+	// If zero, MinBackoff is 100ms and MaxBackoff is 10s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// AttemptTimeout is the time allowed for each attempt, including
+	// reading the response body. If zero, the only limit is the deadline
+	// of the request context.
+	AttemptTimeout time.Duration
+
+	// Retryable reports whether a request should be retried, given the
+	// response or error from the previous attempt. If nil,
+	// DefaultRetryable is used.
+	Retryable func(req *http.Request, resp *http.Response, err error) bool
+}
+
+// DefaultRetryable reports whether a request should be retried. Only
+// idempotent requests are retried, which are those with an idempotent
+// method (GET, HEAD, OPTIONS, TRACE, PUT and DELETE) or an Idempotency-Key
+// header. They are retried if there was an error sending the request, other
+// than the request context being done, or if the response status is
+// 429 (Too Many Requests), 502 (Bad Gateway), 503 (Service Unavailable)
+// or 504 (Gateway Timeout).
+func DefaultRetryable(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+	default:
+		if req.Header.Get("Idempotency-Key") == "" {
+			return false
+		}
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the time to wait before retry number n, starting at zero.
+// If the response has a Retry-After header that specifies a longer time,
+// that time is used instead.
+func (p *RetryPolicy) backoff(n int, resp *http.Response) time.Duration {
+	minBackoff, maxBackoff := p.MinBackoff, p.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	limit := minBackoff
+	for i := 0; i < n && limit < maxBackoff; i++ {
+		limit *= 2
+	}
+	if limit > maxBackoff {
+		limit = maxBackoff
+	}
+	d := time.Duration(rand.Int63n(int64(limit) + 1))
+	if resp != nil {
+		if ra := retryAfter(resp.Header.Get("Retry-After")); ra > d {
+			d = ra
+		}
+	}
+	return d
+}
+
+func (p *RetryPolicy) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(req, resp, err)
+	}
+	return DefaultRetryable(req, resp, err)
+}
+
+// retryAfter returns the time specified by a Retry-After header, which is
+// either a number of seconds or a HTTP date. It returns zero if the header
+// is empty or invalid.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseInt(v, 10, 32); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// rewindable reports whether the request body can be sent again.
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// discard reads a little of the response body, so that the
+// connection can be reused, and closes it.
+func discard(resp *http.Response) {
+	_, _ = io.CopyN(io.Discard, resp.Body, maxDrain)
+	_ = resp.Body.Close()
+}
+
+// sleep waits for the duration d, or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cancelBody is a response body that cancels the context of the
+// attempt when it is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/httpapi"
+)
+
+// Headers sent with webhooks, in addition to the signature.
+const (
+	HeaderWebhookID    = "X-Webhook-Id"
+	HeaderWebhookEvent = "X-Webhook-Event"
+)
+
+// defaultWebhookSignatureHeader is the header that contains the
+// signature, unless another header is specified.
+const defaultWebhookSignatureHeader = "X-Hub-Signature-256"
+
+// WebhookDelivery is a webhook sent by a WebhookSender.
+type WebhookDelivery struct {
+	ID        string          // Unique ID, sent in the X-Webhook-Id and Idempotency-Key headers
+	URL       string          // URL the webhook is sent to
+	Event     string          // Optional event type, sent in the X-Webhook-Event header
+	Payload   json.RawMessage // JSON payload sent as the request body
+	Attempts  int             // Number of attempts made so far
+	Status    int             // Status of the last response, or zero if there was none
+	Err       error           // Error from the last attempt, or nil if it succeeded
+	Delivered bool            // The webhook has been delivered
+}
+
+// WebhookStore persists webhook deliveries that have failed after all
+// retries, so that they can be delivered later using WebhookSender.Deliver.
+type WebhookStore interface {
+	SaveFailed(ctx context.Context, d *WebhookDelivery) error
+}
+
+// WebhookSender sends webhooks, which are POST requests with a JSON payload,
+// signed using HMAC-SHA256 in the format checked by httpapi.VerifyWebhook.
+// Failed deliveries are retried with exponential backoff. The zero value is
+// not ready to use, as Secret must be set.
+//
+// For example:
+//  sender := &client.WebhookSender{
+//      Secret: secret,
+//      Retry:  client.RetryPolicy{MaxRetries: 5, MinBackoff: time.Second, MaxBackoff: time.Minute},
+//      Store:  failedStore,
+//  }
+//  d, err := sender.Send(ctx, subscription.URL, "order.created", order)
+type WebhookSender struct {
+	// HTTPClient is used to send the HTTP requests.
+	// If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Secret is the secret shared with the receiver of the
+	// webhooks, which is used to sign the payload.
+	Secret []byte
+
+	// SignatureHeader is the request header that contains the signature.
+	// If empty, the header is "X-Hub-Signature-256".
+	SignatureHeader string
+
+	// TimestampHeader is an optional request header that contains the time
+	// of each attempt, in seconds since the Unix epoch. If it is set, the
+	// timestamp is included in the signature (see httpapi.SignWebhook).
+	TimestampHeader string
+
+	// Retry determines whether failed deliveries are retried. The zero value
+	// does not retry. If Retry.Retryable is nil, deliveries are retried if
+	// there was an error sending the request, other than the context being
+	// done, or the response status is 408, 429 or 5xx.
+	Retry RetryPolicy
+
+	// OnAttempt is an optional callback that is called after each attempt
+	// with the status of the delivery, eg for logging or metrics.
+	OnAttempt func(ctx context.Context, d *WebhookDelivery)
+
+	// Store is an optional store for deliveries that have failed after all
+	// retries. It is not called if the context is done.
+	Store WebhookStore
+}
+
+// Send marshals the payload as JSON, and delivers it to the URL as a new
+// webhook. The event is optional. The delivery is returned, even if it fails,
+// in which case the error is the error from the last attempt. The error has the
+// status of the response, if there was one (see ReadError).
+func (s *WebhookSender) Send(ctx context.Context, url string, event string, payload interface{}) (*WebhookDelivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal webhook payload")
+	}
+	id, err := newWebhookID()
+	if err != nil {
+		return nil, err
+	}
+	d := &WebhookDelivery{
+		ID:      id,
+		URL:     url,
+		Event:   event,
+		Payload: body,
+	}
+	return d, s.Deliver(ctx, d)
+}
+
+// Deliver delivers the webhook, retrying according to the retry policy. It is
+// used by Send, and to deliver webhooks again after they have failed, eg from
+// the WebhookStore. The delivery is updated with the status of each attempt.
+func (s *WebhookSender) Deliver(ctx context.Context, d *WebhookDelivery) error {
+	if len(s.Secret) == 0 {
+		return errors.New("webhook secret not specified")
+	}
+	c := &Client{HTTPClient: s.HTTPClient}
+	for n := 0; ; n++ {
+		req, resp, err := s.attempt(ctx, c, d)
+		if s.OnAttempt != nil {
+			s.OnAttempt(ctx, d)
+		}
+		if d.Delivered {
+			return nil
+		}
+		if n >= s.Retry.MaxRetries || !s.retryable(req, resp, err) {
+			break
+		}
+		wait := s.Retry.backoff(n, resp)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// no time to retry
+			break
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return d.Err
+		}
+	}
+	if s.Store != nil && ctx.Err() == nil {
+		if err := s.Store.SaveFailed(ctx, d); err != nil {
+			return errors.Wrap(err, "cannot save failed webhook").With("id", d.ID)
+		}
+	}
+	return d.Err
+}
+
+// attempt sends the webhook once, and updates the delivery. The response body
+// has been closed. The request, response and error are for deciding whether
+// to retry.
+func (s *WebhookSender) attempt(ctx context.Context, c *Client, d *WebhookDelivery) (*http.Request, *http.Response, error) {
+	d.Attempts++
+	d.Status = 0
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		// not worth retrying
+		d.Err = errors.Wrap(err, "cannot create webhook request").With("url", d.URL)
+		return nil, nil, d.Err
+	}
+	var timestamp string
+	if s.TimestampHeader != "" {
+		timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(s.TimestampHeader, timestamp)
+	}
+	signatureHeader := s.SignatureHeader
+	if signatureHeader == "" {
+		signatureHeader = defaultWebhookSignatureHeader
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookID, d.ID)
+	req.Header.Set("Idempotency-Key", d.ID)
+	if d.Event != "" {
+		req.Header.Set(HeaderWebhookEvent, d.Event)
+	}
+	req.Header.Set(signatureHeader, httpapi.SignWebhook(s.Secret, timestamp, d.Payload))
+
+	resp, err := c.Do(req)
+	if err != nil {
+		d.Err = err
+		return req, nil, err
+	}
+	d.Status = resp.StatusCode
+	if resp.StatusCode/100 == 2 {
+		discard(resp)
+		d.Err = nil
+		d.Delivered = true
+		return req, resp, nil
+	}
+	if d.Err = ReadError(resp); d.Err == nil {
+		// eg a redirect that was not followed
+		discard(resp)
+		d.Err = &httpapi.Error{
+			Message: http.StatusText(resp.StatusCode),
+			Status:  resp.StatusCode,
+		}
+	}
+	return req, resp, nil
+}
+
+func (s *WebhookSender) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req == nil {
+		return false
+	}
+	if s.Retry.Retryable != nil {
+		return s.Retry.Retryable(req, resp, err)
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return true
+	}
+	return false
+}
+
+// newWebhookID returns a random ID for a webhook delivery.
+func newWebhookID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", errors.Wrap(err, "cannot generate webhook ID")
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi"
+)
+
+type testWebhookStore struct {
+	saved []*WebhookDelivery
+}
+
+func (s *testWebhookStore) SaveFailed(ctx context.Context, d *WebhookDelivery) error {
+	s.saved = append(s.saved, d)
+	return nil
+}
+
+func TestWebhookSender(t *testing.T) {
+	secret := []byte("secret")
+	tests := []struct {
+		failures  int // number of failed responses before success
+		status    int // status of failed responses
+		retries   int
+		attempts  int
+		delivered bool
+		saved     bool
+	}{
+		{attempts: 1, delivered: true},
+		{failures: 2, status: http.StatusServiceUnavailable, retries: 3, attempts: 3, delivered: true},
+		{failures: 5, status: http.StatusInternalServerError, retries: 2, attempts: 3, saved: true},
+		{failures: 1, status: http.StatusTooManyRequests, retries: 2, attempts: 2, delivered: true},
+		{failures: 1, status: http.StatusBadRequest, retries: 2, attempts: 1, saved: true},
+		{failures: 1, status: http.StatusServiceUnavailable, attempts: 1, saved: true},
+	}
+	for i, tt := range tests {
+		attempts := 0
+		var ids []string
+		var payload map[string]string
+		verify := httpapi.VerifyWebhook(httpapi.WebhookConfig{Secret: secret, TimestampHeader: "X-Timestamp"})
+		srv := httptest.NewServer(verify(httpapi.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			attempts++
+			ids = append(ids, r.Header.Get(HeaderWebhookID))
+			if got, want := r.Header.Get(HeaderWebhookEvent), "thing.created"; got != want {
+				t.Errorf("%d: event: want %q, got %q", i, want, got)
+			}
+			if attempts <= tt.failures {
+				return errkind.Public("failed", tt.status)
+			}
+			return httpapi.ReadRequest(r, &payload)
+		})))
+
+		store := &testWebhookStore{}
+		var callbacks int
+		sender := &WebhookSender{
+			Secret:          secret,
+			TimestampHeader: "X-Timestamp",
+			Retry:           RetryPolicy{MaxRetries: tt.retries, MinBackoff: time.Millisecond},
+			OnAttempt:       func(ctx context.Context, d *WebhookDelivery) { callbacks++ },
+			Store:           store,
+		}
+		d, err := sender.Send(context.Background(), srv.URL, "thing.created", map[string]string{"id": "1"})
+		srv.Close()
+
+		if tt.delivered {
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+			}
+			if payload["id"] != "1" {
+				t.Errorf("%d: payload: got %v", i, payload)
+			}
+		} else if got, want := errkind.StatusCode(err), tt.status; got != want {
+			t.Errorf("%d: error status: want %d, got %v", i, want, err)
+		}
+		if got, want := d.Delivered, tt.delivered; got != want {
+			t.Errorf("%d: delivered: want %v, got %v", i, want, got)
+		}
+		if got, want := attempts, tt.attempts; got != want {
+			t.Errorf("%d: attempts: want %d, got %d", i, want, got)
+		}
+		if got, want := d.Attempts, tt.attempts; got != want {
+			t.Errorf("%d: delivery attempts: want %d, got %d", i, want, got)
+		}
+		if got, want := callbacks, tt.attempts; got != want {
+			t.Errorf("%d: callbacks: want %d, got %d", i, want, got)
+		}
+		if got, want := len(store.saved) == 1, tt.saved; got != want {
+			t.Errorf("%d: saved: want %v, got %v", i, want, got)
+		}
+		for _, id := range ids {
+			if id == "" || id != d.ID {
+				t.Errorf("%d: id: want %q, got %q", i, d.ID, id)
+			}
+		}
+	}
+}
+
+func TestWebhookSenderRedeliver(t *testing.T) {
+	secret := []byte("secret")
+	var got json.RawMessage
+	srv := httptest.NewServer(httpapi.VerifyWebhook(httpapi.WebhookConfig{Secret: secret})(httpapi.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) error {
+			return httpapi.ReadRequest(r, &got)
+		})))
+	defer srv.Close()
+
+	d := &WebhookDelivery{
+		ID:       "1",
+		URL:      srv.URL,
+		Payload:  json.RawMessage(`{"id":"1"}`),
+		Attempts: 3,
+		Err:      errkind.Public("failed", http.StatusServiceUnavailable),
+	}
+	sender := &WebhookSender{Secret: secret}
+	if err := sender.Deliver(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Delivered || d.Err != nil || d.Attempts != 4 || d.Status != http.StatusOK {
+		t.Errorf("unexpected delivery: %+v", d)
+	}
+	if string(got) != `{"id":"1"}` {
+		t.Errorf("payload: got %s", got)
+	}
+
+	if err := (&WebhookSender{}).Deliver(context.Background(), d); err == nil {
+		t.Error("want error for missing secret")
+	}
+}
@@ -0,0 +1,240 @@
+package httpapi
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jjeffery/errors"
+)
+
+// compressBufferSize is the number of bytes buffered by Compress before
+// deciding whether to compress a response of unknown length.
+const compressBufferSize = 4096
+
+// Compress returns middleware that compresses responses that handlers write
+// directly to the http.ResponseWriter, using a content encoding that the
+// client accepts. WriteResponse compresses its own responses, but this
+// middleware is useful for handlers that stream a response, or write a
+// response in a format other than JSON.
+//
+// Whether a response is compressed is decided by the compression policy
+// for the request (see Route and DefaultCompressionPolicy), based on the
+// Content-Type and Content-Length headers set by the handler. If the handler
+// does not set Content-Length, the start of the response is buffered to
+// determine its length. Responses that already have a Content-Encoding
+// header, such as those compressed by WriteResponse, are sent unchanged,
+// as are responses with an "Accept-Ranges: bytes" header, such as those
+// sent by ServeBlob, because ranges refer to the uncompressed content.
+//
+// Compressed responses do not have a Content-Length header, and a
+// "Vary: Accept-Encoding" header is added to all responses that could
+// have been compressed. A strong ETag header on a compressed response is
+// made weak, because the compressed bytes differ from the content that it
+// identifies. If the handler flushes the response, compressed data is
+// flushed to the client.
+//
+// If the handler panics, any buffered data is discarded, so that middleware
+// such as Recover can still send an error response.
+func Compress() Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cw := &compressWriter{ResponseWriter: w, r: r}
+			h.ServeHTTP(cw, r)
+			cw.Close()
+		})
+	}
+}
+
+// compressWriter compresses the response written by a handler.
+type compressWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	status  int
+	buf     []byte
+	decided bool
+	encoder interface {
+		io.WriteCloser
+		Flush() error
+	}
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		// informational responses are sent immediately
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < compressBufferSize {
+			return len(p), nil
+		}
+		if err := w.decide(-1); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush sends any buffered data to the client, if the underlying
+// writer, or a writer that it wraps, supports flushing.
+func (w *compressWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		if err := w.decide(-1); err != nil {
+			return
+		}
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			return
+		}
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying writer, for use by http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack lets the caller take over the connection, if the
+// underlying writer supports it, eg for WebSocket connections.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	w.decided = true
+	return hj.Hijack()
+}
+
+// Close sends any buffered data to the client, and finishes the compressed
+// stream. It is called after the handler returns.
+func (w *compressWriter) Close() error {
+	if w.status == 0 {
+		// nothing written, so leave it to the server to send the response
+		return nil
+	}
+	if !w.decided {
+		if err := w.decide(len(w.buf)); err != nil {
+			return err
+		}
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+// decide decides whether to compress the response, sends the response
+// header, and writes any buffered data. The length of the response is
+// -1 if it is not known.
+func (w *compressWriter) decide(length int) error {
+	w.decided = true
+	h := w.Header()
+	if compressionAllowed && w.r.Method != http.MethodHead && bodyAllowed(w.status) && h.Get("Content-Encoding") == "" && !rangesAllowed(w.status, h) {
+		addVary(h, "Accept-Encoding")
+		if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil {
+			length = n
+		}
+		contentType := h.Get("Content-Type")
+		if contentType == "" && len(w.buf) > 0 {
+			// sniff the content type now, because the server
+			// would otherwise sniff the compressed data
+			contentType = http.DetectContentType(w.buf)
+			h.Set("Content-Type", contentType)
+		}
+		decision := compressionPolicy(w.r).Decide(w.r, contentType, length)
+		var err error
+		switch decision.Encoding {
+		case "":
+		case ceDeflate:
+			w.encoder, err = zlib.NewWriterLevel(w.ResponseWriter, decision.Level)
+		case ceGzip:
+			w.encoder, err = gzip.NewWriterLevel(w.ResponseWriter, decision.Level)
+		default:
+			err = errors.New("unsupported content-encoding").
+				With("content-encoding", decision.Encoding)
+		}
+		if err != nil {
+			return err
+		}
+		if w.encoder != nil {
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", decision.Encoding)
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// rangesAllowed reports whether the response is, or could be, a range of
+// the content, which cannot be compressed because ranges refer to the
+// bytes of the uncompressed content.
+func rangesAllowed(status int, h http.Header) bool {
+	return status == http.StatusPartialContent || h.Get("Accept-Ranges") == "bytes"
+}
+
+// bodyAllowed reports whether a response with the status can have a body.
+func bodyAllowed(status int) bool {
+	switch {
+	case status >= 100 && status < 200:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// addVary adds the header name to the Vary header, unless it is already
+// present. Existing values are merged into a single Vary header.
+func addVary(h http.Header, name string) {
+	var fields []string
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, name) {
+				return
+			}
+			if field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	h.Set("Vary", strings.Join(append(fields, name), ", "))
+}
@@ -0,0 +1,297 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("the quick brown fox jumps over the lazy dog\n", 200)
+	largeJSON, _ := json.Marshal(map[string]string{"text": large})
+	tests := []struct {
+		accept      string
+		method      string
+		handler     func(w http.ResponseWriter, r *http.Request)
+		encoding    string
+		body        string
+		contentType string
+		vary        string
+		etag        string
+	}{
+		{
+			// large response of unknown length is compressed
+			accept: "gzip, deflate",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				io.WriteString(w, large[:1000])
+				io.WriteString(w, large[1000:])
+			},
+			encoding:    "gzip",
+			body:        large,
+			contentType: "text/plain",
+			vary:        "Accept-Encoding",
+		},
+		{
+			// small response is not compressed
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "hello")
+			},
+			body:        "hello",
+			contentType: "text/plain; charset=utf-8",
+			vary:        "Accept-Encoding",
+		},
+		{
+			// content type is sniffed before compressing
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "<html><body>"+large+"</body></html>")
+			},
+			encoding:    "gzip",
+			body:        "<html><body>" + large + "</body></html>",
+			contentType: "text/html; charset=utf-8",
+			vary:        "Accept-Encoding",
+		},
+		{
+			// client does not accept compression
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				io.WriteString(w, large)
+			},
+			body:        large,
+			contentType: "text/plain",
+			vary:        "Accept-Encoding",
+		},
+		{
+			// already compressed content type is not compressed
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				io.WriteString(w, large)
+			},
+			body:        large,
+			contentType: "image/png",
+			vary:        "Accept-Encoding",
+		},
+		{
+			// WriteResponse compresses its own response
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				WriteResponse(w, r, map[string]string{"text": large})
+			},
+			encoding:    "gzip",
+			body:        string(largeJSON),
+			contentType: "application/json",
+			vary:        "Accept, Accept-Encoding",
+		},
+		{
+			// content that supports ranges is not compressed
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				ServeBlob(w, r, &Blob{Content: strings.NewReader(large), ContentType: "text/plain"})
+			},
+			body:        large,
+			contentType: "text/plain",
+		},
+		{
+			// strong ETag is weakened when compressed
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("ETag", `"v1"`)
+				io.WriteString(w, large)
+			},
+			encoding:    "gzip",
+			body:        large,
+			contentType: "text/plain",
+			vary:        "Accept-Encoding",
+			etag:        `W/"v1"`,
+		},
+		{
+			// ETag is unchanged when not compressed
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("ETag", `"v1"`)
+				io.WriteString(w, large)
+			},
+			body:        large,
+			contentType: "text/plain",
+			vary:        "Accept-Encoding",
+			etag:        `"v1"`,
+		},
+		{
+			// no body for 204
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		Compress()(http.HandlerFunc(tt.handler)).ServeHTTP(w, r)
+
+		if got, want := w.Header().Get("Content-Encoding"), tt.encoding; got != want {
+			t.Errorf("%d: encoding: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Vary"), tt.vary; got != want {
+			t.Errorf("%d: vary: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Content-Type"), tt.contentType; got != want {
+			t.Errorf("%d: content type: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("ETag"), tt.etag; got != want {
+			t.Errorf("%d: etag: want %q, got %q", i, want, got)
+		}
+		body := w.Body.Bytes()
+		if tt.encoding == "gzip" {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+				continue
+			}
+			body, _ = io.ReadAll(zr)
+		}
+		if got, want := strings.TrimSpace(string(body)), strings.TrimSpace(tt.body); got != want {
+			t.Errorf("%d: body: want %d bytes, got %d bytes", i, len(want), len(got))
+		}
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	Compress()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		http.NewResponseController(w).Flush()
+		if !w.(*compressWriter).decided {
+			t.Error("want response sent on flush")
+		}
+		io.WriteString(w, "data: second\n\n")
+	})).ServeHTTP(w, r)
+
+	if !w.Flushed {
+		t.Error("want flushed")
+	}
+	if got, want := w.Header().Get("Content-Encoding"), "gzip"; got != want {
+		t.Fatalf("encoding: want %q, got %q", want, got)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(zr)
+	if got, want := string(body), "data: first\n\ndata: second\n\n"; got != want {
+		t.Errorf("body: want %q, got %q", want, got)
+	}
+}
+
+func TestCompressDeflate(t *testing.T) {
+	// HTTP deflate is the zlib format, which clients such as
+	// the Go HTTP transport expect
+	large := strings.Repeat("the quick brown fox jumps over the lazy dog\n", 200)
+	route := &Route{Compression: &Compression{Encodings: []string{"deflate"}}}
+	handlers := []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, large)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			WriteResponse(w, r, large)
+		},
+	}
+	for i, handler := range handlers {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "deflate")
+		w := httptest.NewRecorder()
+		route.Handler(Compress()(handler)).ServeHTTP(w, r)
+
+		if got, want := w.Header().Get("Content-Encoding"), "deflate"; got != want {
+			t.Errorf("%d: encoding: want %q, got %q", i, want, got)
+			continue
+		}
+		zr, err := zlib.NewReader(w.Body)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+		if !strings.Contains(string(body), "lazy dog") {
+			t.Errorf("%d: unexpected body %q", i, body)
+		}
+	}
+}
+
+func TestCompressPanic(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h := Use(Recover(), Compress()).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "partial response")
+		panic("oops")
+	}))
+	h.ServeHTTP(w, r)
+
+	if got, want := w.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("status: want %d, got %d", want, got)
+	}
+	if got := w.Body.String(); strings.Contains(got, "partial") {
+		t.Errorf("want partial response discarded, got %q", got)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Errorf("want JSON error, got %q: %v", w.Body.String(), err)
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	tests := []struct {
+		vary []string
+		name string
+		want string
+	}{
+		{name: "Accept-Encoding", want: "Accept-Encoding"},
+		{vary: []string{"Accept"}, name: "Accept-Encoding", want: "Accept, Accept-Encoding"},
+		{vary: []string{"Origin", "Accept"}, name: "Accept-Encoding", want: "Origin, Accept, Accept-Encoding"},
+		{vary: []string{"Origin, accept-encoding"}, name: "Accept-Encoding", want: "Origin, accept-encoding"},
+		{vary: []string{"*"}, name: "Accept-Encoding", want: "*"},
+	}
+	for i, tt := range tests {
+		h := make(http.Header)
+		for _, v := range tt.vary {
+			h.Add("Vary", v)
+		}
+		addVary(h, tt.name)
+		if got := strings.Join(h.Values("Vary"), ", "); got != tt.want {
+			t.Errorf("%d: want %q, got %q", i, tt.want, got)
+		}
+	}
+}
+
+func TestWriteResponseVary(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	w.Header().Set("Vary", "Origin")
+	WriteResponse(w, r, map[string]string{"a": "b"})
+	if got, want := w.Header().Values("Vary"), []string{"Origin, Accept, Accept-Encoding"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
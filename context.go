@@ -0,0 +1,13 @@
+package httpapi
+
+type contextKey int
+
+// Keys for storing values in the context.
+const (
+	propagateKey contextKey = iota
+	routeKey
+	routeHolderKey
+	principalKey
+	requestKey
+	captureKey
+)
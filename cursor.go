@@ -0,0 +1,91 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+// CursorCodec encodes and decodes opaque cursors for keyset pagination.
+// A cursor contains arbitrary state (typically the sort key values of the
+// last item returned), marshalled as JSON, signed using HMAC-SHA256, and
+// encoded using URL-safe base64. The signature prevents clients from
+// tampering with the cursor contents.
+//
+// Note that the cursor state is signed, but not encrypted, so it should not
+// contain any information that the client should not see.
+type CursorCodec struct {
+	// Key is the secret key used to sign cursors. It must not be empty.
+	Key []byte
+}
+
+// Encode returns an opaque cursor containing v.
+func (c *CursorCodec) Encode(v interface{}) (string, error) {
+	if len(c.Key) == 0 {
+		return "", errors.New("cursor key not specified")
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot marshal cursor")
+	}
+	token := append(payload, c.sign(payload)...)
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// Decode verifies the cursor and unmarshals its contents into the value pointed
+// to by v. If the cursor is not valid, the error returned has a bad request status.
+func (c *CursorCodec) Decode(cursor string, v interface{}) error {
+	if len(c.Key) == 0 {
+		return errors.New("cursor key not specified")
+	}
+	token, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(token) < sha256.Size {
+		return errkind.BadRequest("invalid cursor")
+	}
+	payload := token[:len(token)-sha256.Size]
+	signature := token[len(token)-sha256.Size:]
+	if !hmac.Equal(signature, c.sign(payload)) {
+		return errkind.BadRequest("invalid cursor")
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return errkind.BadRequest("invalid cursor")
+	}
+	return nil
+}
+
+func (c *CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// GetCursor decodes the cursor in the query string parameter into the value
+// pointed to by dest, returning true if the cursor is present and valid. An invalid
+// cursor is reported by the Err method.
+//
+// GetCursor panics if codec is nil or has no key, as this is a programming
+// error rather than a problem with the request.
+func (v *Values) GetCursor(name string, codec *CursorCodec, dest interface{}) bool {
+	if codec == nil {
+		panic("httpapi: GetCursor called with a nil codec")
+	}
+	if len(codec.Key) == 0 {
+		panic("httpapi: GetCursor called with a codec that has no key")
+	}
+	if !v.exists(name) {
+		return false
+	}
+	s := v.get(name)
+	if s == "" {
+		return false
+	}
+	if err := codec.Decode(s, dest); err != nil {
+		v.invalid(name, "not a valid cursor")
+		return false
+	}
+	return true
+}
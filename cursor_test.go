@@ -0,0 +1,103 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+)
+
+func TestCursorCodec(t *testing.T) {
+	type State struct {
+		ID      int    `json:"id"`
+		Created string `json:"created"`
+	}
+	codec := &CursorCodec{Key: []byte("secret")}
+	want := State{ID: 42, Created: "2020-01-02T03:04:05Z"}
+
+	cursor, err := codec.Encode(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got State
+	if err := codec.Decode(cursor, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	// a different key should not validate
+	other := &CursorCodec{Key: []byte("other")}
+	if err := other.Decode(cursor, &got); errkind.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("want bad request, got %v", err)
+	}
+
+	// tampered cursors should not validate
+	for _, bad := range []string{"", "abc", cursor[1:], "x" + cursor[1:]} {
+		if err := codec.Decode(bad, &got); errkind.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("%q: want bad request, got %v", bad, err)
+		}
+	}
+
+	if _, err := (&CursorCodec{}).Encode(want); err == nil {
+		t.Errorf("want error for missing key, got nil")
+	}
+}
+
+func TestGetCursor(t *testing.T) {
+	codec := &CursorCodec{Key: []byte("secret")}
+	cursor, err := codec.Encode(map[string]int{"id": 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := Query(httptest.NewRequest("GET", "/?cursor="+url.QueryEscape(cursor), nil))
+	var state map[string]int
+	if !query.GetCursor("cursor", codec, &state) {
+		t.Errorf("want cursor, got none")
+	}
+	if state["id"] != 7 {
+		t.Errorf("want id=7, got %v", state)
+	}
+	if err := query.Err(); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+
+	query = Query(httptest.NewRequest("GET", "/?cursor=invalid", nil))
+	if query.GetCursor("cursor", codec, &state) {
+		t.Errorf("want no cursor, got one")
+	}
+	if err := query.Err(); err == nil {
+		t.Errorf("want error, got nil")
+	}
+}
+
+func TestGetCursorNoCodec(t *testing.T) {
+	tests := []struct {
+		codec *CursorCodec
+		want  string
+	}{
+		{
+			codec: nil,
+			want:  "httpapi: GetCursor called with a nil codec",
+		},
+		{
+			codec: &CursorCodec{},
+			want:  "httpapi: GetCursor called with a codec that has no key",
+		},
+	}
+	for i, tt := range tests {
+		func() {
+			defer func() {
+				if got, _ := recover().(string); got != tt.want {
+					t.Errorf("%d: want panic %q, got %q", i, tt.want, got)
+				}
+			}()
+			var state map[string]int
+			Query(httptest.NewRequest("GET", "/", nil)).GetCursor("cursor", tt.codec, &state)
+		}()
+	}
+}
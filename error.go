@@ -0,0 +1,93 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+// Error is an error response sent by a server using WriteError. It has the
+// same fields as the JSON written by WriteError, so a client can unmarshal
+// the response body into an Error and inspect the error using errors.As,
+// rather than matching strings. See the client package for a function that
+// reads the Error from a HTTP response.
+//
+// Error unmarshals the default JSON format (see writeerror.Config) and RFC
+// 7807 problem details (see writeerror.MarshalProblem).
+type Error struct {
+	Message string                  `json:"message"`
+	Status  int                     `json:"status"`
+	Code    string                  `json:"code,omitempty"`
+	Trace   string                  `json:"trace,omitempty"`
+	Detail  string                  `json:"detail,omitempty"` // Only sent to trusted clients
+	Fields  []writeerror.FieldError `json:"errors,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Message)
+	sb.WriteString(" status=")
+	sb.WriteString(strconv.Itoa(e.Status))
+	if e.Code != "" {
+		sb.WriteString(" code=")
+		sb.WriteString(e.Code)
+	}
+	return sb.String()
+}
+
+// StatusCode returns the HTTP status of the error response.
+func (e *Error) StatusCode() int {
+	return e.Status
+}
+
+// MarshalJSON marshals the error in the default JSON format
+// written by WriteError.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	type errorJSON Error
+	return json.Marshal(struct {
+		Error *errorJSON `json:"error"`
+	}{
+		Error: (*errorJSON)(e),
+	})
+}
+
+// UnmarshalJSON unmarshals the error from the default JSON format written
+// by WriteError, or from RFC 7807 problem details.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	type errorJSON Error
+	var payload struct {
+		Error *errorJSON `json:"error"`
+
+		// RFC 7807 problem details
+		Title  string                  `json:"title"`
+		Status int                     `json:"status"`
+		Detail string                  `json:"detail"`
+		Code   string                  `json:"code"`
+		Trace  string                  `json:"trace"`
+		Debug  string                  `json:"debug"`
+		Errors []writeerror.FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	if payload.Error != nil {
+		*e = Error(*payload.Error)
+		return nil
+	}
+	*e = Error{
+		Message: payload.Detail,
+		Status:  payload.Status,
+		Code:    payload.Code,
+		Trace:   payload.Trace,
+		Detail:  payload.Debug,
+		Fields:  payload.Errors,
+	}
+	if e.Message == "" {
+		// the detail is omitted when it is the same as the title
+		e.Message = payload.Title
+	}
+	return nil
+}
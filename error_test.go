@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+func TestErrorRoundTrip(t *testing.T) {
+	verr := &ValidationError{Summary: "bad input", Status: http.StatusBadRequest}
+	verr.Add("name", "is required", "required")
+	want := &Error{
+		Message: "bad input",
+		Status:  http.StatusBadRequest,
+		Trace:   "trace-1",
+		Detail:  verr.Error(),
+		Fields: []writeerror.FieldError{
+			{Field: "name", Message: "is required", Code: "required"},
+		},
+	}
+	trusted := func(r *http.Request) bool { return true }
+	getTrace := func(r *http.Request) string { return "trace-1" }
+	tests := []writeerror.Config{
+		{IsTrusted: trusted, GetTrace: getTrace},
+		{
+			IsTrusted:      trusted,
+			GetTrace:       getTrace,
+			MarshalContent: writeerror.MarshalProblem,
+			ContentType:    writeerror.ProblemContentType,
+		},
+	}
+	for i, config := range tests {
+		w := httptest.NewRecorder()
+		h := writeerror.Middleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, verr)
+		}))
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		var got Error
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&got, want) {
+			t.Errorf("%d: want %+v, got %+v", i, want, &got)
+		}
+	}
+
+	// marshalled in the same format as WriteError
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Error
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("want %+v, got %+v", want, &got)
+	}
+	if got, want := want.Error(), "bad input status=400"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
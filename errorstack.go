@@ -0,0 +1,94 @@
+package httpapi
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// errorStack returns a formatted stack trace for the error, or the empty
+// string if none of the errors in its chain of causes carries a stack trace.
+// If more than one error in the chain carries a stack trace, the stack trace
+// closest to the root cause is used, as it is the most informative.
+//
+// Errors carry a stack trace if they have one of the following methods:
+//  StackTrace() T           // where T is a slice of uintptr, eg github.com/pkg/errors
+//  StackTrace() []runtime.Frame
+//  Callers() []uintptr
+func errorStack(err error) string {
+	var frames []runtime.Frame
+	for err != nil {
+		if f := stackFrames(err); len(f) > 0 {
+			frames = f
+		}
+		switch e := err.(type) {
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			err = nil
+		}
+	}
+	if len(frames) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, frame := range frames {
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	return sb.String()
+}
+
+var frameSliceType = reflect.TypeOf([]runtime.Frame(nil))
+
+// stackFrames returns the stack frames carried by err, if any.
+func stackFrames(err error) []runtime.Frame {
+	if callers, ok := err.(interface{ Callers() []uintptr }); ok {
+		return framesFromPCs(callers.Callers())
+	}
+
+	// Use reflection for the StackTrace method, because packages such as
+	// github.com/pkg/errors return their own named slice types.
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() {
+		return nil
+	}
+	mt := method.Type()
+	if mt.NumIn() != 0 || mt.NumOut() != 1 {
+		return nil
+	}
+	out := mt.Out(0)
+	switch {
+	case out.ConvertibleTo(frameSliceType):
+		return method.Call(nil)[0].Convert(frameSliceType).Interface().([]runtime.Frame)
+	case out.Kind() == reflect.Slice && out.Elem().Kind() == reflect.Uintptr:
+		trace := method.Call(nil)[0]
+		pcs := make([]uintptr, trace.Len())
+		for i := range pcs {
+			pcs[i] = uintptr(trace.Index(i).Uint())
+		}
+		return framesFromPCs(pcs)
+	}
+	return nil
+}
+
+func framesFromPCs(pcs []uintptr) []runtime.Frame {
+	if len(pcs) == 0 {
+		return nil
+	}
+	var frames []runtime.Frame
+	iter := runtime.CallersFrames(pcs)
+	for {
+		frame, more := iter.Next()
+		if frame.Function != "" || frame.File != "" {
+			frames = append(frames, frame)
+		}
+		if !more {
+			break
+		}
+	}
+	return frames
+}
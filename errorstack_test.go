@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+// stackTrace is similar to the named slice type used by github.com/pkg/errors.
+type stackTrace []uintptr
+
+type stackError struct {
+	msg   string
+	stack []uintptr
+}
+
+func newStackError(msg string) *stackError {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	return &stackError{msg: msg, stack: pcs[:n]}
+}
+
+func (e *stackError) Error() string          { return e.msg }
+func (e *stackError) StackTrace() stackTrace { return stackTrace(e.stack) }
+
+type wrappedError struct {
+	msg   string
+	cause error
+}
+
+func (e *wrappedError) Error() string { return e.msg + ": " + e.cause.Error() }
+func (e *wrappedError) Unwrap() error { return e.cause }
+
+func TestErrorStack(t *testing.T) {
+	if got := errorStack(errors.New("no stack")); got != "" {
+		t.Errorf("want no stack, got %q", got)
+	}
+
+	err := &wrappedError{msg: "wrapped", cause: newStackError("failed")}
+	stack := errorStack(err)
+	if !strings.Contains(stack, "TestErrorStack") {
+		t.Errorf("want stack containing test function, got %q", stack)
+	}
+}
+
+func TestWriteErrorStack(t *testing.T) {
+	err := newStackError("failed")
+	for _, trusted := range []bool{false, true} {
+		trusted := trusted
+		config := writeerror.Config{
+			IsTrusted: func(r *http.Request) bool { return trusted },
+		}
+		h := writeerror.Middleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, err)
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		hasStack := strings.Contains(w.Body.String(), `"stack"`)
+		if hasStack != trusted {
+			t.Errorf("trusted=%v: want stack=%v, got %v", trusted, trusted, hasStack)
+		}
+	}
+}
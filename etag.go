@@ -0,0 +1,243 @@
+package httpapi
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jjeffery/errkind"
+)
+
+// ETag returns middleware that adds a strong ETag header to successful
+// responses to GET and HEAD requests, and answers requests with a matching
+// If-None-Match header with 304 (Not Modified), so the client does not
+// receive a response body that it already has.
+//
+// The ETag is computed from the response body, so this is useful for
+// handlers that do not know the version of the resource in advance, whether
+// they write the response directly to the http.ResponseWriter or use
+// WriteResponse. If the handler sets its own ETag header, that ETag is used
+// instead. An ETag is not computed for HEAD requests, because handlers
+// usually do not write a body for them, so the ETag would not match the
+// response to a GET request. HEAD responses only have the ETag set by the
+// handler, if any.
+//
+// The response is buffered until the handler finishes, so this middleware
+// is not suitable for large or streaming responses. If the handler flushes
+// the response, the buffered data is sent immediately, and the response
+// does not have an ETag.
+func ETag() Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				h.ServeHTTP(w, r)
+				return
+			}
+			ew := &etagWriter{ResponseWriter: w}
+			h.ServeHTTP(ew, r)
+			ew.finish(r)
+		})
+	}
+}
+
+// etagWriter buffers the response written by a handler, so that an
+// ETag can be computed from the response body.
+type etagWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     bytes.Buffer
+	started bool // response header sent, no longer buffering
+}
+
+func (w *etagWriter) WriteHeader(status int) {
+	if w.started {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		// informational responses are sent immediately
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+	if w.status != http.StatusOK {
+		// only successful responses have an ETag
+		w.start()
+	}
+}
+
+func (w *etagWriter) Write(p []byte) (int, error) {
+	if w.started {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(p)
+}
+
+// Flush sends any buffered data to the client, if the underlying
+// writer, or a writer that it wraps, supports flushing.
+func (w *etagWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.started {
+		w.start()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying writer, for use by http.ResponseController.
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack lets the caller take over the connection, if the
+// underlying writer supports it, eg for WebSocket connections.
+func (w *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.started = true
+	}
+	return conn, rw, err
+}
+
+// start sends the response header and any buffered data to the client,
+// and stops buffering.
+func (w *etagWriter) start() {
+	w.started = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// finish sends the buffered response, with an ETag header, or a 304 (Not Modified)
+// response if the request has a matching If-None-Match header.
+func (w *etagWriter) finish(r *http.Request) {
+	if w.started || w.status == 0 {
+		// nothing buffered, or nothing written, in which case
+		// leave it to the server to send the response
+		return
+	}
+	h := w.Header()
+	etag := h.Get("ETag")
+	if etag == "" && r.Method == http.MethodHead {
+		// the body, if any, is not the body of the GET response
+		w.start()
+		return
+	}
+	if etag == "" {
+		sum := sha256.Sum256(w.buf.Bytes())
+		etag = `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+		h.Set("ETag", etag)
+	}
+	if etagMatch(r.Header.Values("If-None-Match"), etag) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		h.Del("Content-Encoding")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.start()
+}
+
+// etagMatch reports whether an If-None-Match header matches the ETag,
+// using the weak comparison function (RFC 9110, section 13.1.2).
+func etagMatch(ifNoneMatch []string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range ifNoneMatch {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.TrimPrefix(field, "W/") == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckIfMatch checks the If-Match header of a request that changes a
+// resource, such as PUT, PATCH or DELETE, against the current ETag of the
+// resource, so that a client cannot overwrite changes that it has not seen.
+// If the header does not match, the error has a status of 412 (Precondition
+// Failed), and can be sent to the client using WriteError. If the request
+// does not have an If-Match header, the check passes.
+//
+// The etag can be a quoted entity tag, eg `"abc"`, or a version, eg "3",
+// which is quoted in the same way as SetETag. If the resource does not exist,
+// pass an empty etag, and only a missing If-Match header passes the check.
+// For example:
+//  if err := httpapi.CheckIfMatch(r, strconv.Itoa(thing.Version)); err != nil {
+//      return err
+//  }
+//  thing.Version++
+//  // ... save the thing ...
+//  httpapi.SetETag(w, strconv.Itoa(thing.Version))
+//  httpapi.WriteResponse(w, r, thing)
+func CheckIfMatch(r *http.Request, etag string) error {
+	ifMatch := r.Header.Values("If-Match")
+	if len(ifMatch) == 0 {
+		return nil
+	}
+	if !etagStrongMatch(ifMatch, formatETag(etag)) {
+		return errkind.Public("precondition failed", http.StatusPreconditionFailed)
+	}
+	return nil
+}
+
+// RequireIfMatch is like CheckIfMatch, except that the request must have
+// an If-Match header. If it does not, the error has a status of 428
+// (Precondition Required).
+func RequireIfMatch(r *http.Request, etag string) error {
+	if len(r.Header.Values("If-Match")) == 0 {
+		return errkind.Public("precondition required: missing If-Match header", http.StatusPreconditionRequired)
+	}
+	return CheckIfMatch(r, etag)
+}
+
+// SetETag sets the ETag header of the response, usually to the new ETag of
+// a resource after it has been changed. The etag can be a quoted entity tag,
+// eg `"abc"` or `W/"abc"`, or a version, eg "3", which is quoted.
+func SetETag(w http.ResponseWriter, etag string) {
+	if etag = formatETag(etag); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+}
+
+// formatETag quotes the etag, unless it is already a quoted entity tag.
+func formatETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return strconv.Quote(etag)
+}
+
+// etagStrongMatch reports whether an If-Match header matches the ETag, using
+// the strong comparison function (RFC 9110, section 13.1.1). An empty ETag
+// means that the resource does not exist, so it does not even match "*",
+// and a weak ETag only matches "*".
+func etagStrongMatch(ifMatch []string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	weak := strings.HasPrefix(etag, "W/")
+	for _, v := range ifMatch {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || (!weak && field == etag) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	hello := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "hello")
+	}
+	// ETag of "hello"
+	const helloETag = `"LPJNul-wow4m6Dsqxbning"`
+
+	tests := []struct {
+		method      string
+		ifNoneMatch string
+		handler     func(w http.ResponseWriter, r *http.Request)
+		status      int
+		etag        string
+		body        string
+	}{
+		{
+			method:  "GET",
+			handler: hello,
+			status:  http.StatusOK,
+			etag:    helloETag,
+			body:    "hello",
+		},
+		{
+			method:      "GET",
+			ifNoneMatch: `"other", ` + helloETag,
+			handler:     hello,
+			status:      http.StatusNotModified,
+			etag:        helloETag,
+		},
+		{
+			method:      "GET",
+			ifNoneMatch: "W/" + helloETag,
+			handler:     hello,
+			status:      http.StatusNotModified,
+			etag:        helloETag,
+		},
+		{
+			method:      "GET",
+			ifNoneMatch: `"other"`,
+			handler:     hello,
+			status:      http.StatusOK,
+			etag:        helloETag,
+			body:        "hello",
+		},
+		{
+			// handler sets its own ETag
+			method:      "GET",
+			ifNoneMatch: `"v1"`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprint(w, "hello")
+			},
+			status: http.StatusNotModified,
+			etag:   `"v1"`,
+		},
+		{
+			// error responses do not have an ETag
+			method:      "GET",
+			ifNoneMatch: "*",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, "not found")
+			},
+			status: http.StatusNotFound,
+			body:   "not found",
+		},
+		{
+			// flushed responses do not have an ETag
+			method: "GET",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "hel")
+				w.(http.Flusher).Flush()
+				fmt.Fprint(w, "lo")
+			},
+			status: http.StatusOK,
+			body:   "hello",
+		},
+		{
+			// no ETag is computed for HEAD, as the handler may not write the body
+			method:      "HEAD",
+			ifNoneMatch: "*",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusOK)
+			},
+			status: http.StatusOK,
+		},
+		{
+			method:      "HEAD",
+			ifNoneMatch: `"v1"`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				w.WriteHeader(http.StatusOK)
+			},
+			status: http.StatusNotModified,
+			etag:   `"v1"`,
+		},
+		{
+			// other methods are not affected
+			method:      "POST",
+			ifNoneMatch: "*",
+			handler:     hello,
+			status:      http.StatusOK,
+			body:        "hello",
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/", nil)
+		if tt.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		ETag()(http.HandlerFunc(tt.handler)).ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("ETag"), tt.etag; got != want {
+			t.Errorf("%d: etag: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Body.String(), tt.body; got != want {
+			t.Errorf("%d: body: want %q, got %q", i, want, got)
+		}
+		if tt.status == http.StatusNotModified && w.Header().Get("Content-Type") != "" {
+			t.Errorf("%d: want no content-type, got %q", i, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		etag    string
+		require bool
+		status  int
+	}{
+		{etag: "3"},
+		{etag: "3", require: true, status: http.StatusPreconditionRequired},
+		{ifMatch: `"3"`, etag: "3", require: true},
+		{ifMatch: `"1", "3"`, etag: `"3"`},
+		{ifMatch: `"2"`, etag: "3", status: http.StatusPreconditionFailed},
+		{ifMatch: `W/"3"`, etag: "3", status: http.StatusPreconditionFailed},
+		{ifMatch: `W/"3"`, etag: `W/"3"`, status: http.StatusPreconditionFailed},
+		{ifMatch: "*", etag: `W/"3"`},
+		{ifMatch: "*", etag: "3"},
+		{ifMatch: "*", etag: "", status: http.StatusPreconditionFailed},
+		{etag: ""},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("PUT", "/", nil)
+		if tt.ifMatch != "" {
+			r.Header.Set("If-Match", tt.ifMatch)
+		}
+		check := CheckIfMatch
+		if tt.require {
+			check = RequireIfMatch
+		}
+		err := check(r, tt.etag)
+		if tt.status == 0 {
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+			}
+			continue
+		}
+		w := httptest.NewRecorder()
+		WriteError(w, r, err)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestSetETag(t *testing.T) {
+	tests := []struct {
+		etag string
+		want string
+	}{
+		{etag: "3", want: `"3"`},
+		{etag: `"abc"`, want: `"abc"`},
+		{etag: `W/"abc"`, want: `W/"abc"`},
+		{etag: "", want: ""},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		SetETag(w, tt.etag)
+		if got := w.Header().Get("ETag"); got != tt.want {
+			t.Errorf("%d: want %q, got %q", i, tt.want, got)
+		}
+	}
+}
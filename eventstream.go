@@ -0,0 +1,154 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjeffery/errors"
+)
+
+// Event is a single server-sent event.
+type Event struct {
+	ID    string        // Optional event ID, sent as the "id" field
+	Event string        // Optional event type, sent as the "event" field
+	Data  interface{}   // Marshalled as JSON and sent as the "data" field
+	Retry time.Duration // Optional client reconnection time
+}
+
+// EventStream writes Server-Sent Events (SSE) to the HTTP client.
+// Each event is flushed to the client as soon as it is sent.
+type EventStream struct {
+	w  http.ResponseWriter
+	r  *http.Request
+	rc *http.ResponseController
+}
+
+// NewEventStream prepares the response for sending Server-Sent Events
+// to the HTTP client. The response headers are written immediately, so
+// any error returned by this function can still be sent using WriteError.
+//
+// An error is returned if the response writer does not support flushing.
+// Response writers that wrap another writer, such as those used by the
+// middleware in this package, support flushing if the writer that they
+// wrap does, as for http.ResponseController.
+func NewEventStream(w http.ResponseWriter, r *http.Request) (*EventStream, error) {
+	if !canFlush(w) {
+		return nil, errors.New("response writer does not support flushing")
+	}
+	if err := r.Context().Err(); err != nil {
+		return nil, errors.Wrap(err, "client disconnected")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering in nginx
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return nil, errors.Wrap(err, "cannot flush response")
+	}
+
+	stream := &EventStream{
+		w:  w,
+		r:  r,
+		rc: rc,
+	}
+	return stream, nil
+}
+
+// canFlush reports whether the response writer, or a writer that it
+// wraps, supports flushing. It looks for the same methods as
+// http.ResponseController, without flushing the response.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case http.Flusher, interface{ FlushError() error }:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// Done returns a channel that is closed when the client disconnects.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.r.Context().Done()
+}
+
+// Send writes an event to the client. The event data is marshalled
+// as JSON. Returns an error if the client has disconnected.
+func (s *EventStream) Send(event Event) error {
+	if err := s.r.Context().Err(); err != nil {
+		return errors.Wrap(err, "client disconnected")
+	}
+	if strings.ContainsAny(event.ID, "\r\n\x00") {
+		return errors.New("invalid event id").With("id", event.ID)
+	}
+	if strings.ContainsAny(event.Event, "\r\n") {
+		return errors.New("invalid event type").With("event", event.Event)
+	}
+
+	var buf bytes.Buffer
+	if event.ID != "" {
+		buf.WriteString("id: ")
+		buf.WriteString(event.ID)
+		buf.WriteByte('\n')
+	}
+	if event.Event != "" {
+		buf.WriteString("event: ")
+		buf.WriteString(event.Event)
+		buf.WriteByte('\n')
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: ")
+		buf.WriteString(strconv.FormatInt(int64(event.Retry/time.Millisecond), 10))
+		buf.WriteByte('\n')
+	}
+	if event.Data != nil {
+		// JSON marshalling does not produce newlines, so the
+		// data always fits on a single line.
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal event data")
+		}
+		buf.WriteString("data: ")
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	return s.write(buf.Bytes())
+}
+
+// Comment writes a comment line to the client. Comments are ignored by
+// clients, but are useful for keeping idle connections alive.
+func (s *EventStream) Comment(text string) error {
+	if err := s.r.Context().Err(); err != nil {
+		return errors.Wrap(err, "client disconnected")
+	}
+	var buf bytes.Buffer
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(": ")
+		buf.WriteString(strings.TrimSuffix(line, "\r"))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+func (s *EventStream) write(p []byte) error {
+	if _, err := s.w.Write(p); err != nil {
+		return errors.Wrap(err, "cannot write event")
+	}
+	if err := s.rc.Flush(); err != nil {
+		return errors.Wrap(err, "cannot flush event")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/events", nil)
+
+	stream, err := NewEventStream(w, r)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if got, want := w.Header().Get("Content-Type"), "text/event-stream"; got != want {
+		t.Errorf("content-type: want %q, got %q", want, got)
+	}
+	if got := w.Header().Get("Connection"); got != "" {
+		// hop-by-hop headers are not allowed in HTTP/2 responses
+		t.Errorf("connection: want none, got %q", got)
+	}
+
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{
+			event: Event{Data: map[string]int{"n": 1}},
+			want:  "data: {\"n\":1}\n\n",
+		},
+		{
+			event: Event{ID: "2", Event: "update", Data: "text"},
+			want:  "id: 2\nevent: update\ndata: \"text\"\n\n",
+		},
+		{
+			event: Event{Retry: 1500 * time.Millisecond},
+			want:  "retry: 1500\n\n",
+		},
+	}
+	for i, tt := range tests {
+		w.Body.Reset()
+		if err := stream.Send(tt.event); err != nil {
+			t.Errorf("%d: want no error, got %v", i, err)
+			continue
+		}
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%d: want %q, got %q", i, tt.want, got)
+		}
+	}
+
+	if err := stream.Send(Event{ID: "a\nb"}); err == nil {
+		t.Errorf("want error for invalid id, got nil")
+	}
+}
+
+func TestEventStreamDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+
+	stream, err := NewEventStream(w, r)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	cancel()
+	<-stream.Done()
+	if err := stream.Send(Event{Data: 1}); err == nil {
+		t.Errorf("want error after disconnect, got nil")
+	}
+}
+
+type noFlushWriter struct {
+	http.ResponseWriter
+}
+
+func TestEventStreamNoFlusher(t *testing.T) {
+	w := noFlushWriter{httptest.NewRecorder()}
+	r := httptest.NewRequest("GET", "/events", nil)
+	if _, err := NewEventStream(w, r); err == nil {
+		t.Errorf("want error, got nil")
+	}
+}
+
+// unwrapWriter wraps a response writer without implementing
+// http.Flusher, like many middleware response writers.
+type unwrapWriter struct {
+	http.ResponseWriter
+}
+
+func (w unwrapWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestEventStreamWrapped(t *testing.T) {
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/events", nil)
+	stream, err := NewEventStream(unwrapWriter{rec}, r)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if err := stream.Send(Event{Data: 1}); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if !rec.Flushed {
+		t.Error("want flushed")
+	}
+}
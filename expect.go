@@ -0,0 +1,90 @@
+package httpapi
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/jjeffery/errkind"
+)
+
+// ExpectContinueConfig contains the configuration for ExpectContinue.
+type ExpectContinueConfig struct {
+	// MaxSize is the maximum size in bytes of a request body. If zero,
+	// the limit is the same as for ReadRequest.
+	MaxSize int64
+
+	// ContentTypes are the media types of request bodies that are accepted,
+	// eg "application/json". A media type of the form "image/*" matches any
+	// subtype. If empty, any content type is accepted.
+	ContentTypes []string
+}
+
+// ExpectContinue returns middleware that rejects requests whose bodies would
+// be rejected anyway, using only the request headers, before the body is read.
+// A request with a Content-Length larger than the maximum size is rejected
+// with a 413 (Payload Too Large) error, and a request with a body with a
+// content type that is not accepted, or a Content-Encoding other than gzip or
+// deflate, is rejected with a 415 (Unsupported Media Type) error. Errors are
+// sent using WriteError.
+//
+// This is most useful for clients that send an "Expect: 100-continue" header,
+// which wait for the server to send a 100 (Continue) response before sending
+// the body. The server sends the 100 (Continue) response when the handler
+// first reads the body, so a request that is rejected by this middleware
+// receives the final status without the client uploading the body.
+//
+// For example:
+//  upload := httpapi.ExpectContinue(httpapi.ExpectContinueConfig{
+//      MaxSize:      100 << 20,
+//      ContentTypes: []string{"image/*", "application/pdf"},
+//  })
+//  http.Handle("PUT /documents/{id}", upload(handler))
+func ExpectContinue(config ExpectContinueConfig) Middleware {
+	maxSize := config.MaxSize
+	if maxSize <= 0 {
+		maxSize = int64(maxRequestLen)
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasBody(r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > maxSize {
+				WriteError(w, r, errkind.Public("payload too large", http.StatusRequestEntityTooLarge))
+				return
+			}
+			switch r.Header.Get("Content-Encoding") {
+			case "", ceIdentity, ceGzip, ceDeflate:
+			default:
+				WriteError(w, r, errkind.Public("unsupported content-encoding", http.StatusUnsupportedMediaType))
+				return
+			}
+			if len(config.ContentTypes) > 0 && !matchContentType(r.Header.Get("Content-Type"), config.ContentTypes) {
+				WriteError(w, r, errkind.Public("unsupported content type", http.StatusUnsupportedMediaType))
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// matchContentType reports whether the content type matches
+// one of the media types, which can include wildcards.
+func matchContentType(contentType string, mediaTypes []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, mt := range mediaTypes {
+		mt = strings.ToLower(mt)
+		if mt == mediaType || mt == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(mt, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package httpapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExpectContinue(t *testing.T) {
+	tests := []struct {
+		config   ExpectContinueConfig
+		method   string
+		header   map[string]string
+		body     string
+		chunked  bool
+		status   int
+		bodyRead bool
+	}{
+		{
+			method:   "POST",
+			header:   map[string]string{"Content-Type": "application/json"},
+			body:     `{"a":1}`,
+			status:   http.StatusOK,
+			bodyRead: true,
+		},
+		{
+			config: ExpectContinueConfig{MaxSize: 4},
+			method: "PUT",
+			body:   "too large",
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			// length of chunked body is not known in advance
+			config:   ExpectContinueConfig{MaxSize: 4},
+			method:   "PUT",
+			body:     "too large",
+			chunked:  true,
+			status:   http.StatusOK,
+			bodyRead: true,
+		},
+		{
+			config:   ExpectContinueConfig{ContentTypes: []string{"image/*", "application/pdf"}},
+			method:   "PUT",
+			header:   map[string]string{"Content-Type": "image/png"},
+			body:     "png",
+			status:   http.StatusOK,
+			bodyRead: true,
+		},
+		{
+			config:   ExpectContinueConfig{ContentTypes: []string{"image/*", "application/pdf"}},
+			method:   "PUT",
+			header:   map[string]string{"Content-Type": "Application/PDF; name=x.pdf"},
+			body:     "pdf",
+			status:   http.StatusOK,
+			bodyRead: true,
+		},
+		{
+			config: ExpectContinueConfig{ContentTypes: []string{"image/*", "application/pdf"}},
+			method: "PUT",
+			header: map[string]string{"Content-Type": "text/plain"},
+			body:   "text",
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			config: ExpectContinueConfig{ContentTypes: []string{"image/*"}},
+			method: "PUT",
+			body:   "no content type",
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			method: "POST",
+			header: map[string]string{"Content-Encoding": "br"},
+			body:   "data",
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			// requests without a body are not checked
+			config: ExpectContinueConfig{ContentTypes: []string{"image/*"}},
+			method: "GET",
+			status: http.StatusOK,
+		},
+	}
+	for i, tt := range tests {
+		var bodyRead bool
+		h := ExpectContinue(tt.config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+			bodyRead = true
+		}))
+		var body io.Reader
+		if tt.body != "" {
+			body = strings.NewReader(tt.body)
+		}
+		r := httptest.NewRequest(tt.method, "/", body)
+		if tt.chunked {
+			r.ContentLength = -1
+		}
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if tt.body != "" && bodyRead != tt.bodyRead {
+			t.Errorf("%d: body read: want %v, got %v", i, tt.bodyRead, bodyRead)
+		}
+	}
+}
+
+// uploadReader is a request body that records whether it has been read.
+type uploadReader struct {
+	r    io.Reader
+	read *int32
+}
+
+func (u uploadReader) Read(p []byte) (int, error) {
+	atomic.StoreInt32(u.read, 1)
+	return u.r.Read(p)
+}
+
+func TestExpectContinueServer(t *testing.T) {
+	h := ExpectContinue(ExpectContinueConfig{MaxSize: 1000})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	client := &http.Client{
+		Transport: &http.Transport{ExpectContinueTimeout: 10 * time.Second},
+	}
+
+	tests := []struct {
+		size   int
+		status int
+		sent   bool
+	}{
+		{size: 100, status: http.StatusOK, sent: true},
+		{size: 100000, status: http.StatusRequestEntityTooLarge, sent: false},
+	}
+	for i, tt := range tests {
+		var read int32
+		body := uploadReader{r: strings.NewReader(strings.Repeat("x", tt.size)), read: &read}
+		req, err := http.NewRequest("PUT", srv.URL, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.ContentLength = int64(tt.size)
+		req.Header.Set("Expect", "100-continue")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := atomic.LoadInt32(&read) == 1, tt.sent; got != want {
+			t.Errorf("%d: body sent: want %v, got %v", i, want, got)
+		}
+	}
+}
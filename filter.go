@@ -0,0 +1,34 @@
+package httpapi
+
+import (
+	"strconv"
+
+	"github.com/jjeffery/httpapi/filter"
+)
+
+// GetFilter returns the filter expression in the named query parameter,
+// eg "?filter=status eq 'open' and age gt 30". See the filter package
+// for the syntax. If any allowed fields are specified, the expression can
+// only compare those fields. If the expression cannot be parsed, or compares
+// a field that is not allowed, the parameter is reported by Err and nil is
+// returned. Returns nil if the parameter is not present or is blank.
+func (v *Values) GetFilter(name string, allowed ...string) filter.Expr {
+	s := v.GetString(name)
+	if s == "" {
+		return nil
+	}
+	expr, err := filter.Parse(s)
+	if err != nil {
+		v.invalid(name, err.Error())
+		return nil
+	}
+	if len(allowed) > 0 {
+		for _, field := range filter.Fields(expr) {
+			if !containsString(allowed, field) {
+				v.invalid(name, "cannot filter by "+strconv.Quote(field))
+				return nil
+			}
+		}
+	}
+	return expr
+}
@@ -0,0 +1,318 @@
+// Package filter parses filter expressions passed in query strings, eg
+//  ?filter=status eq 'open' and (age gt 30 or vip eq true)
+// into an abstract syntax tree that handlers can translate into SQL
+// or search queries.
+//
+// The syntax is similar to OData filter expressions. Comparisons have a
+// field name on the left, one of the operators eq, ne, gt, ge, lt and le,
+// and a literal value on the right. Literal values are strings in single
+// quotes (with two single quotes for a quote character), numbers, true,
+// false and null. Comparisons can be combined using and, or, not and
+// parentheses. Keywords are case-insensitive, field names are not.
+//
+// The httpapi.Values.GetFilter method parses a filter expression in a query
+// string parameter, and reports invalid expressions in the same way as
+// other invalid parameters.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Op is a comparison operator.
+type Op string
+
+// Comparison operators.
+const (
+	Eq Op = "eq"
+	Ne Op = "ne"
+	Gt Op = "gt"
+	Ge Op = "ge"
+	Lt Op = "lt"
+	Le Op = "le"
+)
+
+// Expr is a node in the abstract syntax tree of a filter expression.
+// It is one of *And, *Or, *Not or *Compare.
+type Expr interface {
+	fmt.Stringer
+	expr()
+}
+
+// And is true if both Left and Right are true.
+type And struct {
+	Left, Right Expr
+}
+
+// Or is true if either Left or Right is true.
+type Or struct {
+	Left, Right Expr
+}
+
+// Not is true if Expr is false.
+type Not struct {
+	Expr Expr
+}
+
+// Compare compares the value of a field with a literal value. The
+// value is a string, float64, bool, or nil for null.
+type Compare struct {
+	Field string
+	Op    Op
+	Value interface{}
+}
+
+func (*And) expr()     {}
+func (*Or) expr()      {}
+func (*Not) expr()     {}
+func (*Compare) expr() {}
+
+func (e *And) String() string {
+	return "(" + e.Left.String() + " and " + e.Right.String() + ")"
+}
+
+func (e *Or) String() string {
+	return "(" + e.Left.String() + " or " + e.Right.String() + ")"
+}
+
+func (e *Not) String() string {
+	return "not " + e.Expr.String()
+}
+
+func (e *Compare) String() string {
+	var value string
+	switch v := e.Value.(type) {
+	case string:
+		value = "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case float64:
+		value = strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		value = strconv.FormatBool(v)
+	default:
+		value = "null"
+	}
+	return e.Field + " " + string(e.Op) + " " + value
+}
+
+// Fields returns the names of the fields compared in the expression,
+// in the order they first appear.
+func Fields(e Expr) []string {
+	var fields []string
+	seen := make(map[string]bool)
+	var walk func(Expr)
+	walk = func(e Expr) {
+		switch e := e.(type) {
+		case *And:
+			walk(e.Left)
+			walk(e.Right)
+		case *Or:
+			walk(e.Left)
+			walk(e.Right)
+		case *Not:
+			walk(e.Expr)
+		case *Compare:
+			if !seen[e.Field] {
+				seen[e.Field] = true
+				fields = append(fields, e.Field)
+			}
+		}
+	}
+	walk(e)
+	return fields
+}
+
+// SyntaxError describes a filter expression that cannot be parsed.
+type SyntaxError struct {
+	Pos int    // Byte offset in the expression
+	Msg string // Description of the error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Parse parses a filter expression. If the expression cannot
+// be parsed, the error returned is a *SyntaxError.
+func Parse(s string) (Expr, error) {
+	p := &parser{lex: lexer{input: s}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return e, nil
+}
+
+// maxDepth is the maximum nesting of parentheses and "not" operators
+// in an expression. Filter expressions are supplied by clients, so the
+// limit prevents deeply nested input from exhausting the stack.
+const maxDepth = 32
+
+type parser struct {
+	lex   lexer
+	tok   token
+	depth int
+}
+
+// enter is called when parsing a nested expression. It returns an error
+// if the expression is nested too deeply. The caller must call leave when
+// the nested expression has been parsed.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return p.errorf("expression is nested too deeply")
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) next() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	return p.tok.kind == tokIdent && strings.EqualFold(p.tok.text, kw)
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.isKeyword("not") || p.tok.kind == tokLParen {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+	}
+	if p.isKeyword("not") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: e}, nil
+	}
+	if p.tok.kind == tokLParen {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ')', found %s", p.tok)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (Expr, error) {
+	if p.tok.kind != tokIdent || isReserved(p.tok.text) {
+		return nil, p.errorf("expected field name, found %s", p.tok)
+	}
+	c := &Compare{Field: p.tok.text}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokIdent {
+		return nil, p.errorf("expected operator, found %s", p.tok)
+	}
+	switch op := Op(strings.ToLower(p.tok.text)); op {
+	case Eq, Ne, Gt, Ge, Lt, Le:
+		c.Op = op
+	default:
+		return nil, p.errorf("unknown operator %q", p.tok.text)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.tok.kind == tokString:
+		c.Value = p.tok.text
+	case p.tok.kind == tokNumber:
+		f, err := strconv.ParseFloat(p.tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", p.tok.text)
+		}
+		c.Value = f
+	case p.isKeyword("true"):
+		c.Value = true
+	case p.isKeyword("false"):
+		c.Value = false
+	case p.isKeyword("null"):
+		c.Value = nil
+	default:
+		return nil, p.errorf("expected value, found %s", p.tok)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func isReserved(s string) bool {
+	switch strings.ToLower(s) {
+	case "and", "or", "not", "true", "false", "null":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		fields []string
+	}{
+		{
+			input:  "status eq 'open'",
+			want:   "status eq 'open'",
+			fields: []string{"status"},
+		},
+		{
+			input:  "status eq 'open' and age gt 30 or vip eq true",
+			want:   "((status eq 'open' and age gt 30) or vip eq true)",
+			fields: []string{"status", "age", "vip"},
+		},
+		{
+			input:  "status EQ 'open' AND (age ge -1.5e2 OR NOT deleted ne null)",
+			want:   "(status eq 'open' and (age ge -150 or not deleted ne null))",
+			fields: []string{"status", "age", "deleted"},
+		},
+		{
+			input:  "name eq 'O''Brien' and address.city eq 'x' and name ne ''",
+			want:   "((name eq 'O''Brien' and address.city eq 'x') and name ne '')",
+			fields: []string{"name", "address.city"},
+		},
+	}
+	for i, tt := range tests {
+		e, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if got := e.String(); got != tt.want {
+			t.Errorf("%d: want %s, got %s", i, tt.want, got)
+		}
+		if got := Fields(e); !reflect.DeepEqual(got, tt.fields) {
+			t.Errorf("%d: fields: want %v, got %v", i, tt.fields, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{input: "", pos: 0},
+		{input: "status", pos: 6},
+		{input: "status like 'x'", pos: 7},
+		{input: "status eq 'open", pos: 10},
+		{input: "status eq open", pos: 10},
+		{input: "(a eq 1", pos: 7},
+		{input: "a eq 1 b eq 2", pos: 7},
+		{input: "a eq 1 and", pos: 10},
+		{input: "a eq 1-", pos: 5},
+		{input: "a eq 1 ; drop", pos: 7},
+		{input: "and eq 1", pos: 0},
+	}
+	for i, tt := range tests {
+		_, err := Parse(tt.input)
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%d: want syntax error, got %v", i, err)
+			continue
+		}
+		if serr.Pos != tt.pos {
+			t.Errorf("%d: want position %d, got %d (%v)", i, tt.pos, serr.Pos, serr)
+		}
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{input: strings.Repeat("(", maxDepth) + "a eq 1" + strings.Repeat(")", maxDepth), valid: true},
+		{input: strings.Repeat("not ", maxDepth) + "a eq 1", valid: true},
+		{input: strings.Repeat("(", maxDepth+1) + "a eq 1" + strings.Repeat(")", maxDepth+1)},
+		{input: strings.Repeat("not ", maxDepth+1) + "a eq 1"},
+		{input: strings.Repeat("(", 1<<20)},
+		{input: strings.Repeat("not ", 1<<18) + "a eq 1"},
+		{input: strings.Repeat("(not ", 1<<18) + "a eq 1"},
+	}
+	for i, tt := range tests {
+		_, err := Parse(tt.input)
+		if tt.valid {
+			if err != nil {
+				t.Errorf("%d: want no error, got %v", i, err)
+			}
+			continue
+		}
+		if _, ok := err.(*SyntaxError); !ok {
+			t.Errorf("%d: want syntax error, got %v", i, err)
+		}
+	}
+}
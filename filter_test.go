@@ -0,0 +1,35 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestGetFilter(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		invalid bool
+	}{
+		{query: "filter=" + url.QueryEscape("status eq 'open' and age gt 30"), want: "(status eq 'open' and age gt 30)"},
+		{query: "filter=" + url.QueryEscape("password eq 'x'"), invalid: true},
+		{query: "filter=" + url.QueryEscape("status eq"), invalid: true},
+		{query: "filter="},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		expr := query.GetFilter("filter", "status", "age")
+		var got string
+		if expr != nil {
+			got = expr.String()
+		}
+		if got != tt.want {
+			t.Errorf("%d: want %q, got %q", i, tt.want, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/stringset"
+)
+
+// maxFormMemory is the maximum size of a multipart form that is kept in
+// memory. Files in the form that do not fit are stored in temporary files.
+var maxFormMemory int64 = 1024 * 1024 * 32
+
+// Form parses the form in the request body, and returns the form values,
+// with the same typed getters and error reporting as Query. Both URL-encoded
+// and multipart forms are supported. Values in the query string are not included.
+//
+// The request body is limited to the same maximum size as for ReadRequest.
+// The error returned if the body is too large, or cannot be parsed, has a
+// suitable status and message for passing to WriteError.
+func Form(r *http.Request) (*Values, error) {
+	if r.PostForm == nil {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(nil, r.Body, int64(maxRequestLen))
+		}
+		var err error
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "multipart/form-data" {
+			err = r.ParseMultipartForm(maxFormMemory)
+		} else {
+			err = r.ParseForm()
+		}
+		if err != nil {
+			// a partially parsed form must not be mistaken for a parsed
+			// form by later calls, which parse the body again and
+			// receive the same error
+			r.PostForm = nil
+			r.Form = nil
+			r.Body = formErrorBody{err: err}
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return nil, errkind.Public("payload too large", http.StatusRequestEntityTooLarge)
+			}
+			return nil, errkind.BadRequest("invalid form data")
+		}
+	}
+	return &Values{
+		values:        r.PostForm,
+		invalidParams: stringset.New(),
+		missingParams: stringset.New(),
+		source:        "form",
+	}, nil
+}
+
+// formErrorBody replaces the body of a request whose form could not
+// be parsed, and returns the error from parsing the form.
+type formErrorBody struct {
+	err error
+}
+
+func (b formErrorBody) Read([]byte) (int, error) { return 0, b.err }
+func (b formErrorBody) Close() error             { return nil }
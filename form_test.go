@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+)
+
+func TestForm(t *testing.T) {
+	r := httptest.NewRequest("POST", "/?limit=5", strings.NewReader("name=bob&age=42&age2=x"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	form, err := Form(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := form.GetString("name"), "bob"; got != want {
+		t.Errorf("name: want %q, got %q", want, got)
+	}
+	if got, want := form.GetInt("age"), 42; got != want {
+		t.Errorf("age: want %v, got %v", want, got)
+	}
+	if _, ok := form.LookupInt("limit"); ok {
+		t.Error("limit: want query string values excluded")
+	}
+	form.GetInt("age2")
+	if err := form.Err(); err == nil || !strings.Contains(err.Error(), "invalid value(s) in form: age2") {
+		t.Errorf("want invalid form error, got %v", err)
+	}
+}
+
+func TestFormMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "hello")
+	mw.Close()
+	r := httptest.NewRequest("POST", "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	form, err := Form(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := form.GetString("title"), "hello"; got != want {
+		t.Errorf("title: want %q, got %q", want, got)
+	}
+}
+
+func TestFormTooLarge(t *testing.T) {
+	defer func(n int) { maxRequestLen = n }(maxRequestLen)
+	maxRequestLen = 10
+	r := httptest.NewRequest("POST", "/", strings.NewReader("name=0123456789012345"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for i := 0; i < 2; i++ {
+		if _, err := Form(r); errkind.StatusCode(err) != http.StatusRequestEntityTooLarge {
+			t.Errorf("%d: want payload too large, got %v", i, err)
+		}
+	}
+}
+
+func TestFormInvalid(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader("name=bob&age=%zz"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// later calls report the same error, rather than the partially parsed form
+	for i := 0; i < 2; i++ {
+		if _, err := Form(r); errkind.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("%d: want bad request, got %v", i, err)
+		}
+	}
+	if r.PostForm != nil {
+		t.Errorf("want no form, got %v", r.PostForm)
+	}
+}
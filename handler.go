@@ -1,6 +1,9 @@
 package httpapi
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // HandlerFunc is similar to http.HandlerFunc, but it returns an error.
 type HandlerFunc func(http.ResponseWriter, *http.Request) error
@@ -11,3 +14,90 @@ func (fn HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, r, err)
 	}
 }
+
+// ValueHandlerFunc is a handler function that returns the response body
+// and an error. If the error is nil, the response body is sent to the client
+// using WriteResponse, or with a 204 (No Content) status if the response body
+// is nil, as for Handle. Otherwise the error is sent using WriteError.
+//
+// This removes the need to finish every handler with the same few lines:
+//  h := httpapi.ValueHandlerFunc(func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+//      thing, err := getThing(r.Context(), httpapi.PathValues(r).GetString("id"))
+//      if err != nil {
+//          return nil, err
+//      }
+//      return thing, nil
+//  })
+type ValueHandlerFunc func(http.ResponseWriter, *http.Request) (interface{}, error)
+
+// ServeHTTP implements the http.Handler interface.
+func (fn ValueHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v, err := fn(w, r)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	WriteResponse(w, r, v)
+}
+
+// Handle returns a handler that calls fn with the request body, and sends
+// the response returned by fn to the client. The request body is read as
+// JSON into a new Req using ReadRequest, unless the request has no body, eg
+// for a GET request. If *Req has a Validate() error method, it is called
+// before fn. The response is sent using WriteResponse, or with a 204 (No
+// Content) status if fn returns a nil response. Any error, whether reading
+// the request or returned by fn, is sent to the client using WriteError.
+// For example:
+//  type CreateThingRequest struct {
+//      Name string `json:"name"`
+//  }
+//
+//  http.Handle("POST /things", httpapi.Handle(func(ctx context.Context, req *CreateThingRequest) (*Thing, error) {
+//      return createThing(ctx, req.Name)
+//  }))
+//
+// Path and query string parameters are available to fn using the request
+// in the context (see RequestFromContext).
+func Handle[Req, Resp any](fn func(ctx context.Context, req *Req) (*Resp, error)) http.Handler {
+	return HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		req := new(Req)
+		if hasBody(r) {
+			if err := ReadRequest(r, req); err != nil {
+				return err
+			}
+		}
+		if validator, ok := interface{}(req).(interface{ Validate() error }); ok {
+			if err := validator.Validate(); err != nil {
+				return err
+			}
+		}
+		ctx := context.WithValue(r.Context(), requestKey, r)
+		resp, err := fn(ctx, req)
+		if err != nil {
+			return err
+		}
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+		WriteResponse(w, r, resp)
+		return nil
+	})
+}
+
+// RequestFromContext returns the HTTP request stored in the context by
+// Handle, or nil if there is none. It is used by functions called by Handle
+// to access the request's path and query string parameters.
+func RequestFromContext(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(requestKey).(*http.Request)
+	return r
+}
+
+// hasBody reports whether the request has a body.
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
@@ -0,0 +1,174 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+)
+
+func TestHandlerFunc(t *testing.T) {
+	tests := []struct {
+		fn     HandlerFunc
+		status int
+	}{
+		{
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusAccepted)
+				return nil
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			fn: func(w http.ResponseWriter, r *http.Request) error {
+				return errkind.BadRequest("bad request")
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.fn.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestValueHandlerFunc(t *testing.T) {
+	tests := []struct {
+		fn     ValueHandlerFunc
+		status int
+		body   string
+	}{
+		{
+			fn: func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+				return map[string]int{"id": 1}, nil
+			},
+			status: http.StatusOK,
+			body:   `{"id":1}`,
+		},
+		{
+			fn: func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+				return map[string]int{"id": 1}, errkind.Public("not found", http.StatusNotFound)
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			fn: func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+				return nil, nil
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			// an error returned as the value is also sent using WriteError
+			fn: func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+				return errkind.BadRequest("bad request"), nil
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.fn.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if tt.body != "" {
+			if got, want := strings.TrimSpace(w.Body.String()), tt.body; got != want {
+				t.Errorf("%d: body: want %q, got %q", i, want, got)
+			}
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	type request struct {
+		Name string `json:"name"`
+	}
+	type response struct {
+		Greeting string `json:"greeting"`
+	}
+	h := Handle(func(ctx context.Context, req *request) (*response, error) {
+		switch req.Name {
+		case "":
+			if r := RequestFromContext(ctx); r != nil {
+				if name := Query(r).GetString("name"); name != "" {
+					return &response{Greeting: "hello " + name}, nil
+				}
+			}
+			return nil, nil
+		case "error":
+			return nil, errkind.Public("cannot greet", http.StatusConflict)
+		}
+		return &response{Greeting: "hello " + req.Name}, nil
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{method: "POST", path: "/", body: `{"name":"alice"}`, status: http.StatusOK, want: `{"greeting":"hello alice"}`},
+		{method: "GET", path: "/?name=bob", status: http.StatusOK, want: `{"greeting":"hello bob"}`},
+		{method: "GET", path: "/", status: http.StatusNoContent},
+		{method: "POST", path: "/", body: `{"name":"error"}`, status: http.StatusConflict},
+		{method: "POST", path: "/", body: `{"name":`, status: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		var r *http.Request
+		if tt.body == "" {
+			r = httptest.NewRequest(tt.method, tt.path, nil)
+		} else {
+			r = httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if tt.want != "" {
+			if got, want := strings.TrimSpace(w.Body.String()), tt.want; got != want {
+				t.Errorf("%d: body: want %q, got %q", i, want, got)
+			}
+		}
+	}
+}
+
+type validatedRequest struct {
+	Count int `json:"count"`
+}
+
+func (v *validatedRequest) Validate() error {
+	if v.Count < 1 {
+		var verr ValidationError
+		verr.Add("count", "must be at least 1", "min")
+		return verr.Err()
+	}
+	return nil
+}
+
+func TestHandleValidate(t *testing.T) {
+	h := Handle(func(ctx context.Context, req *validatedRequest) (*validatedRequest, error) {
+		return req, nil
+	})
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{body: `{"count":1}`, status: http.StatusOK},
+		{body: `{"count":0}`, status: http.StatusUnprocessableEntity},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(tt.body)))
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+	}
+}
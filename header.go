@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jjeffery/stringset"
+)
+
+// HeaderValues provides convenient methods for extracting arguments from
+// request headers, with the same error reporting as Query. Header names
+// are case-insensitive.
+type HeaderValues struct {
+	v *Values
+}
+
+// Headers returns values from the headers of the request.
+func Headers(r *http.Request) *HeaderValues {
+	values := make(url.Values, len(r.Header))
+	for name, vals := range r.Header {
+		key := http.CanonicalHeaderKey(name)
+		values[key] = append(values[key], vals...)
+	}
+	return &HeaderValues{
+		v: &Values{
+			values:        values,
+			invalidParams: stringset.New(),
+			missingParams: stringset.New(),
+			source:        "header",
+		},
+	}
+}
+
+// Err returns nil if no errors have been encountered, otherwise it
+// returns a bad request error that lists the header(s) that are
+// not in the correct format.
+func (h *HeaderValues) Err() error {
+	return h.v.Err()
+}
+
+// LookupString returns a string, with an indication of whether the
+// header was present.
+func (h *HeaderValues) LookupString(name string) (s string, ok bool) {
+	return h.v.LookupString(http.CanonicalHeaderKey(name))
+}
+
+// GetString returns a string. Returns "" if the header is not present.
+func (h *HeaderValues) GetString(name string) string {
+	return h.v.GetString(http.CanonicalHeaderKey(name))
+}
+
+// GetStrings returns all of the values for a header, splitting
+// comma-separated values into separate items, eg for If-Match.
+// Returns nil if the header is not present.
+func (h *HeaderValues) GetStrings(name string) []string {
+	return h.v.splitList(http.CanonicalHeaderKey(name))
+}
+
+// LookupInt returns an integer, with an indication of whether the
+// header was present.
+func (h *HeaderValues) LookupInt(name string) (n int, ok bool) {
+	return h.v.LookupInt(http.CanonicalHeaderKey(name))
+}
+
+// GetInt returns an int. Returns 0 if the header is not present.
+func (h *HeaderValues) GetInt(name string) int {
+	return h.v.GetInt(http.CanonicalHeaderKey(name))
+}
+
+// LookupInt64 returns an int64, with an indication of whether the
+// header was present.
+func (h *HeaderValues) LookupInt64(name string) (n int64, ok bool) {
+	return h.v.LookupInt64(http.CanonicalHeaderKey(name))
+}
+
+// GetInt64 returns an int64. Returns 0 if the header is not present.
+func (h *HeaderValues) GetInt64(name string) int64 {
+	return h.v.GetInt64(http.CanonicalHeaderKey(name))
+}
+
+// LookupBool returns a bool, with an indication of whether the
+// header was present.
+func (h *HeaderValues) LookupBool(name string) (b bool, ok bool) {
+	return h.v.LookupBool(http.CanonicalHeaderKey(name))
+}
+
+// GetBool returns a bool. Returns false if the header is not present.
+func (h *HeaderValues) GetBool(name string) bool {
+	return h.v.GetBool(http.CanonicalHeaderKey(name))
+}
+
+// LookupTime returns a time, with an indication of whether the header
+// was present. The time should be in HTTP date format, eg
+// "Mon, 02 Jan 2006 15:04:05 GMT", as used by If-Modified-Since.
+func (h *HeaderValues) LookupTime(name string) (t time.Time, ok bool) {
+	return h.parseTime(http.CanonicalHeaderKey(name))
+}
+
+// GetTime returns a time. The time should be in HTTP date format.
+// Returns zero if the header is not present.
+func (h *HeaderValues) GetTime(name string) time.Time {
+	t, _ := h.parseTime(http.CanonicalHeaderKey(name))
+	return t
+}
+
+func (h *HeaderValues) parseTime(name string) (time.Time, bool) {
+	if !h.v.exists(name) {
+		return time.Time{}, false
+	}
+	s := strings.TrimSpace(h.v.get(name))
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(s)
+	if err != nil {
+		h.v.invalid(name, "not a valid HTTP date")
+		return time.Time{}, false
+	}
+	return t, true
+}
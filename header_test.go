@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-Id", "abc")
+	r.Header.Set("X-Count", "12")
+	r.Header.Set("If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT")
+	r.Header.Add("If-Match", `"a", "b"`)
+	r.Header.Add("If-Match", `"c"`)
+	r.Header.Set("X-Bad-Count", "twelve")
+
+	h := Headers(r)
+	if got, want := h.GetString("x-request-id"), "abc"; got != want {
+		t.Errorf("request id: want %q, got %q", want, got)
+	}
+	if got, want := h.GetInt("X-COUNT"), 12; got != want {
+		t.Errorf("count: want %v, got %v", want, got)
+	}
+	if got, want := h.GetTime("If-Modified-Since"), time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("time: want %v, got %v", want, got)
+	}
+	if got, want := h.GetStrings("If-Match"), []string{`"a"`, `"b"`, `"c"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("if-match: want %v, got %v", want, got)
+	}
+	if _, ok := h.LookupInt("X-Missing"); ok {
+		t.Error("missing: want not ok")
+	}
+	if err := h.Err(); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+	h.GetInt("x-bad-count")
+	if err := h.Err(); err == nil || !strings.Contains(err.Error(), "invalid value(s) in header: X-Bad-Count") {
+		t.Errorf("want invalid header error, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+// defaultHealthTimeout is the time allowed for each health check, unless
+// a different timeout is specified.
+const defaultHealthTimeout = 5 * time.Second
+
+// Health status values
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// Health is a registry of health checks, and provides handlers for liveness
+// and readiness endpoints. The zero value is ready to use, and it is safe for
+// concurrent use. For example:
+//  var health httpapi.Health
+//  health.AddReadiness("db", db.PingContext)
+//  health.AddReadiness("queue", queue.Ping)
+//  http.Handle("GET /healthz", health.LiveHandler())
+//  http.Handle("GET /readyz", health.ReadyHandler())
+type Health struct {
+	// Timeout is the time allowed for each check. If a check does not finish
+	// in time, it fails. If zero, the timeout is five seconds.
+	Timeout time.Duration
+
+	mutex     sync.Mutex
+	liveness  []healthCheck
+	readiness []healthCheck
+}
+
+// healthCheck is a named check function.
+type healthCheck struct {
+	name  string
+	check func(context.Context) error
+}
+
+// HealthReport is the JSON response sent by the health handlers.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthCheckResult is the result of an individual health check.
+type HealthCheckResult struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"` // Only sent to trusted clients
+}
+
+// AddLiveness adds a check that is run by LiveHandler. Liveness checks
+// should only fail if the process cannot recover without being restarted,
+// so they should not check the availability of other services.
+func (h *Health) AddLiveness(name string, check func(ctx context.Context) error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.liveness = append(h.liveness, healthCheck{name: name, check: check})
+}
+
+// AddReadiness adds a check that is run by ReadyHandler, eg a check that a
+// database or message queue is reachable.
+func (h *Health) AddReadiness(name string, check func(ctx context.Context) error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.readiness = append(h.readiness, healthCheck{name: name, check: check})
+}
+
+// LiveHandler returns a handler that runs the liveness checks. If there
+// are no liveness checks, it always reports that the service is healthy.
+// See ReadyHandler for details of the response.
+func (h *Health) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mutex.Lock()
+		checks := append([]healthCheck(nil), h.liveness...)
+		h.mutex.Unlock()
+		h.serve(w, r, checks)
+	})
+}
+
+// ReadyHandler returns a handler that runs the readiness checks concurrently,
+// and sends a JSON report of the status of each check. The status is 200 (OK)
+// if all checks pass, and 503 (Service Unavailable) otherwise. The error
+// message of each failed check is only included in the report if the client
+// is trusted (see writeerror.Config), because it may contain implementation
+// details. For example:
+//  {
+//      "status": "fail",
+//      "checks": {
+//          "db": {"status": "ok", "duration": "1.2ms"},
+//          "queue": {"status": "fail", "duration": "5s", "error": "context deadline exceeded"}
+//      }
+//  }
+func (h *Health) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mutex.Lock()
+		checks := append([]healthCheck(nil), h.readiness...)
+		h.mutex.Unlock()
+		h.serve(w, r, checks)
+	})
+}
+
+func (h *Health) serve(w http.ResponseWriter, r *http.Request, checks []healthCheck) {
+	report := h.run(r.Context(), checks)
+	if !writeerror.ConfigFromRequest(r).IsTrusted(r) {
+		for name, result := range report.Checks {
+			result.Error = ""
+			report.Checks[name] = result
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report) // should not fail
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// run runs the checks concurrently and returns the report.
+func (h *Health) run(ctx context.Context, checks []healthCheck) *HealthReport {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+
+	report := &HealthReport{Status: HealthOK}
+	if len(checks) == 0 {
+		return report
+	}
+	report.Checks = make(map[string]HealthCheckResult, len(checks))
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	for _, hc := range checks {
+		wg.Add(1)
+		go func(hc healthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := runCheck(ctx, timeout, hc.check)
+			result := HealthCheckResult{
+				Status:   HealthOK,
+				Duration: time.Since(start).Round(100 * time.Microsecond).String(),
+			}
+			if err != nil {
+				result.Status = HealthFail
+				result.Error = err.Error()
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Checks[hc.name] = result
+			if err != nil {
+				report.Status = HealthFail
+			}
+		}(hc)
+	}
+	wg.Wait()
+	return report
+}
+
+// runCheck runs the check, returning an error if it fails, panics,
+// or does not finish before the timeout.
+func runCheck(ctx context.Context, timeout time.Duration, check func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- errors.New("health check panicked").With("panic", p)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// the check does not honour the context, so leave it running
+		return ctx.Err()
+	}
+}
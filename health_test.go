@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	health := Health{Timeout: 50 * time.Millisecond}
+	health.AddReadiness("db", func(ctx context.Context) error { return nil })
+	health.AddReadiness("queue", func(ctx context.Context) error { return errors.New("connection refused") })
+	health.AddReadiness("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	health.AddReadiness("panic", func(ctx context.Context) error { panic("oops") })
+
+	tests := []struct {
+		handler http.Handler
+		remote  string
+		status  int
+		checks  map[string]HealthCheckResult
+	}{
+		{
+			handler: health.LiveHandler(),
+			status:  http.StatusOK,
+		},
+		{
+			handler: health.ReadyHandler(),
+			remote:  "127.0.0.1:1234",
+			status:  http.StatusServiceUnavailable,
+			checks: map[string]HealthCheckResult{
+				"db":    {Status: HealthOK},
+				"queue": {Status: HealthFail, Error: "connection refused"},
+				"slow":  {Status: HealthFail, Error: "context deadline exceeded"},
+				"panic": {Status: HealthFail, Error: "health check panicked"},
+			},
+		},
+		{
+			handler: health.ReadyHandler(),
+			remote:  "203.0.113.1:1234",
+			status:  http.StatusServiceUnavailable,
+			checks: map[string]HealthCheckResult{
+				"db":    {Status: HealthOK},
+				"queue": {Status: HealthFail},
+				"slow":  {Status: HealthFail},
+				"panic": {Status: HealthFail},
+			},
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.remote != "" {
+			r.RemoteAddr = tt.remote
+		}
+		w := httptest.NewRecorder()
+		tt.handler.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		var report HealthReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if got, want := len(report.Checks), len(tt.checks); got != want {
+			t.Errorf("%d: checks: want %d, got %d", i, want, got)
+		}
+		for name, want := range tt.checks {
+			got := report.Checks[name]
+			if got.Status != want.Status || !strings.HasPrefix(got.Error, want.Error) || (want.Error == "" && got.Error != "") {
+				t.Errorf("%d: %s: want %+v, got %+v", i, name, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package httpapi
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Index is a HTTP handler that describes the API to clients, so that
+// clients and gateways can discover its capabilities programmatically.
+// It is typically served from the root path of the API.
+//
+// The response is a JSON object similar to the following:
+//  {
+//      "name": "things",
+//      "versions": ["v1", "v2"],
+//      "docsUrl": "https://example.com/docs",
+//      "healthUrl": "/health",
+//      "mediaTypes": ["application/json"],
+//      "routes": [
+//          {"method": "GET", "path": "/v1/things", "version": "v1"}
+//      ]
+//  }
+type Index struct {
+	Name       string   // Name of the API
+	Versions   []string // API versions, if not specified they are obtained from the routes
+	DocsURL    string   // Optional URL of the API documentation
+	HealthURL  string   // Optional URL of the health check endpoint
+	MediaTypes []string // Supported media types, defaults to application/json
+	Routes     *Routes  // Routes to describe
+}
+
+// indexRoute is the description of a route in the index.
+type indexRoute struct {
+	Name        string `json:"name,omitempty"`
+	Method      string `json:"method,omitempty"`
+	Path        string `json:"path"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// indexContent is the JSON content sent to the client.
+type indexContent struct {
+	Name       string       `json:"name,omitempty"`
+	Versions   []string     `json:"versions,omitempty"`
+	DocsURL    string       `json:"docsUrl,omitempty"`
+	HealthURL  string       `json:"healthUrl,omitempty"`
+	MediaTypes []string     `json:"mediaTypes"`
+	Routes     []indexRoute `json:"routes"`
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (idx *Index) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	WriteResponse(w, r, idx.content())
+}
+
+func (idx *Index) content() *indexContent {
+	content := &indexContent{
+		Name:       idx.Name,
+		Versions:   idx.Versions,
+		DocsURL:    idx.DocsURL,
+		HealthURL:  idx.HealthURL,
+		MediaTypes: idx.MediaTypes,
+		Routes:     []indexRoute{},
+	}
+	if len(content.MediaTypes) == 0 {
+		content.MediaTypes = []string{"application/json"}
+	}
+
+	var routes []*Route
+	if idx.Routes != nil {
+		routes = idx.Routes.List()
+	}
+	versions := make(map[string]bool)
+	for _, rt := range routes {
+		content.Routes = append(content.Routes, indexRoute{
+			Name:        rt.Name,
+			Method:      rt.Method,
+			Path:        rt.Path,
+			Version:     rt.Version,
+			Description: rt.Description,
+		})
+		if rt.Version != "" {
+			versions[rt.Version] = true
+		}
+	}
+	if len(content.Versions) == 0 && len(versions) > 0 {
+		for v := range versions {
+			content.Versions = append(content.Versions, v)
+		}
+		sort.Strings(content.Versions)
+	}
+
+	return content
+}
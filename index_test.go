@@ -0,0 +1,42 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	var routes Routes
+	emptyFunc := func(w http.ResponseWriter, r *http.Request) {}
+	routes.Handle(&Route{Method: "GET", Path: "/v2/things", Version: "v2"}, http.HandlerFunc(emptyFunc))
+	routes.Handle(&Route{Method: "GET", Path: "/v1/things", Version: "v1", Description: "list things"}, http.HandlerFunc(emptyFunc))
+
+	idx := &Index{
+		Name:      "things",
+		HealthURL: "/health",
+		Routes:    &routes,
+	}
+	w := httptest.NewRecorder()
+	idx.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	var got indexContent
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := indexContent{
+		Name:       "things",
+		Versions:   []string{"v1", "v2"},
+		HealthURL:  "/health",
+		MediaTypes: []string{"application/json"},
+		Routes: []indexRoute{
+			{Method: "GET", Path: "/v2/things", Version: "v2"},
+			{Method: "GET", Path: "/v1/things", Version: "v1", Description: "list things"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v\ngot  %+v", want, got)
+	}
+}
@@ -0,0 +1,192 @@
+// Package jsonapi provides support for writing responses that conform
+// to the JSON:API specification (https://jsonapi.org), which uses the
+// media type "application/vnd.api+json".
+//
+// Errors are written as a JSON:API errors array, using the same rules
+// as httpapi.WriteError for deciding which details are sent to the client.
+// The writeerror configuration associated with the request determines
+// whether the client is trusted, and provides the trace ID.
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/jjeffery/httpapi"
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+// MediaType is the JSON:API media type.
+const MediaType = "application/vnd.api+json"
+
+// Document is a top-level JSON:API document.
+type Document struct {
+	// Data is the primary data, which is either a single *Resource,
+	// a slice of resources, or nil. Unless the document has errors, data
+	// is always sent: nil is sent as null, and a nil slice as an empty array.
+	Data     interface{}            `json:"data,omitempty"`
+	Errors   []*Error               `json:"errors,omitempty"`
+	Included []*Resource            `json:"included,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+	Links    map[string]string      `json:"links,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. A document must contain
+// data, errors or meta, so the data of a document without errors is always
+// sent, even if it is null or an empty array.
+func (d Document) MarshalJSON() ([]byte, error) {
+	// document has the fields of Document, without this method
+	type document Document
+	if len(d.Errors) > 0 {
+		return json.Marshal((*document)(&d))
+	}
+	data := d.Data
+	if v := reflect.ValueOf(data); v.Kind() == reflect.Slice && v.IsNil() {
+		data = []interface{}{}
+	}
+	return json.Marshal(struct {
+		Data interface{} `json:"data"`
+		*document
+	}{
+		Data:     data,
+		document: (*document)(&d),
+	})
+}
+
+// Resource is a JSON:API resource object.
+type Resource struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+
+	// Attributes are marshalled as a JSON object, so they are usually
+	// a struct or a map.
+	Attributes    interface{}              `json:"attributes,omitempty"`
+	Relationships map[string]*Relationship `json:"relationships,omitempty"`
+	Links         map[string]string        `json:"links,omitempty"`
+	Meta          map[string]interface{}   `json:"meta,omitempty"`
+}
+
+// ResourceIdentifier identifies a resource.
+type ResourceIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Relationship is a JSON:API relationship object. Use the ToOne and
+// ToMany functions to create relationships with resource linkage.
+type Relationship struct {
+	Data  interface{}            `json:"data"`
+	Links map[string]string      `json:"links,omitempty"`
+	Meta  map[string]interface{} `json:"meta,omitempty"`
+}
+
+// ToOne returns a to-one relationship. If id is nil, the relationship
+// is empty and its data is null.
+func ToOne(id *ResourceIdentifier) *Relationship {
+	if id == nil {
+		return &Relationship{}
+	}
+	return &Relationship{Data: id}
+}
+
+// ToMany returns a to-many relationship. If there are no identifiers,
+// the relationship is empty and its data is an empty array.
+func ToMany(ids ...ResourceIdentifier) *Relationship {
+	if ids == nil {
+		ids = []ResourceIdentifier{}
+	}
+	return &Relationship{Data: ids}
+}
+
+// Error is a JSON:API error object.
+type Error struct {
+	ID     string                 `json:"id,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	Code   string                 `json:"code,omitempty"`
+	Title  string                 `json:"title,omitempty"`
+	Detail string                 `json:"detail,omitempty"`
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+}
+
+// Write sends the document to the client with the JSON:API media type
+// and a 200 status.
+func Write(w http.ResponseWriter, r *http.Request, doc *Document) {
+	WriteStatus(w, r, http.StatusOK, doc)
+}
+
+// WriteStatus sends the document to the client with the JSON:API media
+// type and the specified status.
+func WriteStatus(w http.ResponseWriter, r *http.Request, status int, doc *Document) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	write(w, status, data)
+}
+
+// NewError returns the JSON:API error object for err. The error object
+// contains the same information that httpapi.WriteError sends to the client:
+// the status, public message and code, and the trace as the error ID. If the
+// client is trusted, the full error message is included in the meta object
+// with the key "detail", along with any stack trace with the key "stack".
+func NewError(r *http.Request, err error) *Error {
+	content := httpapi.ErrorContent(r, err)
+	return newError(content)
+}
+
+func newError(content *writeerror.Content) *Error {
+	e := &Error{
+		ID:     content.Trace,
+		Status: strconv.Itoa(content.StatusCode),
+		Code:   content.Code,
+		Title:  http.StatusText(content.StatusCode),
+		Detail: content.Message,
+	}
+	if e.Detail == e.Title {
+		e.Detail = ""
+	}
+	if content.Err != nil {
+		e.Meta = map[string]interface{}{
+			"detail": content.Err.Error(),
+		}
+		if content.Stack != "" {
+			e.Meta["stack"] = content.Stack
+		}
+	}
+	return e
+}
+
+// WriteError sends err to the client as a JSON:API document containing an
+// errors array. See NewError for details. The ErrorWritten callback of the
+// writeerror configuration is called after the error has been written.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	config := writeerror.ConfigFromRequest(r)
+	content := httpapi.ErrorContent(r, err)
+	doc := Document{
+		Errors: []*Error{newError(content)},
+	}
+
+	// should not fail, as the error object only contains strings
+	data, _ := json.Marshal(&doc)
+	if cacheControl := config.CacheControlFor(content.StatusCode); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	httpapi.SetRetryAfter(w.Header(), content.RetryAfter)
+	write(w, content.StatusCode, data)
+
+	// populate Err for logging/tracing/diagnostics
+	content.Err = err
+	config.Redact(content)
+	config.ErrorWritten(r, content)
+}
+
+func write(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("Content-Type", MediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(data)
+}
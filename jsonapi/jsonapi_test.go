@@ -0,0 +1,129 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+func TestWrite(t *testing.T) {
+	type Article struct {
+		Title string `json:"title"`
+	}
+	doc := &Document{
+		Data: &Resource{
+			Type:       "articles",
+			ID:         "1",
+			Attributes: Article{Title: "JSON:API"},
+			Relationships: map[string]*Relationship{
+				"author":   ToOne(&ResourceIdentifier{Type: "people", ID: "9"}),
+				"editor":   ToOne(nil),
+				"comments": ToMany(),
+			},
+		},
+	}
+	w := httptest.NewRecorder()
+	Write(w, httptest.NewRequest("GET", "/articles/1", nil), doc)
+
+	if got, want := w.Header().Get("Content-Type"), MediaType; got != want {
+		t.Errorf("content-type: want %q, got %q", want, got)
+	}
+	want := `{"data":{"type":"articles","id":"1","attributes":{"title":"JSON:API"},` +
+		`"relationships":{"author":{"data":{"type":"people","id":"9"}},` +
+		`"comments":{"data":[]},"editor":{"data":null}}}}`
+	if got := w.Body.String(); got != want {
+		t.Errorf("want %s\ngot  %s", want, got)
+	}
+}
+
+func TestDocumentData(t *testing.T) {
+	tests := []struct {
+		doc  Document
+		want string
+	}{
+		{
+			doc:  Document{Data: []*Resource{}},
+			want: `{"data":[]}`,
+		},
+		{
+			doc:  Document{Data: []*Resource(nil)},
+			want: `{"data":[]}`,
+		},
+		{
+			doc:  Document{},
+			want: `{"data":null}`,
+		},
+		{
+			doc:  Document{Meta: map[string]interface{}{"total": 0}},
+			want: `{"data":null,"meta":{"total":0}}`,
+		},
+		{
+			doc:  Document{Errors: []*Error{{Status: "404"}}},
+			want: `{"errors":[{"status":"404"}]}`,
+		},
+	}
+	for i, tt := range tests {
+		for _, v := range []interface{}{tt.doc, &tt.doc} {
+			data, err := json.Marshal(v)
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+				continue
+			}
+			if got, want := string(data), tt.want; got != want {
+				t.Errorf("%d: want %s, got %s", i, want, got)
+			}
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	config := writeerror.Config{
+		GetTrace:  func(r *http.Request) string { return "trace-1" },
+		IsTrusted: func(r *http.Request) bool { return r.Header.Get("X-Trusted") != "" },
+	}
+	tests := []struct {
+		err     error
+		trusted bool
+		want    string
+		status  int
+	}{
+		{
+			err:    errkind.Public("no such article", http.StatusNotFound),
+			want:   `{"errors":[{"id":"trace-1","status":"404","title":"Not Found","detail":"no such article"}]}`,
+			status: http.StatusNotFound,
+		},
+		{
+			err:     errkind.Public("no such article", http.StatusNotFound),
+			trusted: true,
+			want:    `{"errors":[{"id":"trace-1","status":"404","title":"Not Found","detail":"no such article","meta":{"detail":"no such article"}}]}`,
+			status:  http.StatusNotFound,
+		},
+		{
+			err:    errkind.BadRequest("invalid filter"),
+			want:   `{"errors":[{"id":"trace-1","status":"400","title":"Bad Request","detail":"invalid filter"}]}`,
+			status: http.StatusBadRequest,
+		},
+	}
+	for i, tt := range tests {
+		var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, tt.err)
+		})
+		h = writeerror.Middleware(config)(h)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/articles/1", nil)
+		if tt.trusted {
+			r.Header.Set("X-Trusted", "1")
+		}
+		h.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%d: want status %d, got %d", i, tt.status, w.Code)
+		}
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%d: want %s\ngot  %s", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+const (
+	defaultJWKSRefresh = time.Hour
+
+	// minJWKSInterval is the minimum time between fetching the key
+	// set, so that tokens with unknown key IDs cannot be used to
+	// send a flood of requests to the key set URL.
+	minJWKSInterval = time.Minute
+
+	// jwksRetryInterval is the minimum time between attempts to fetch
+	// the key set when no keys have been fetched successfully.
+	jwksRetryInterval = 5 * time.Second
+
+	// jwksFetchTimeout is the time allowed for fetching the key set.
+	jwksFetchTimeout = 30 * time.Second
+
+	// maxJWKSSize is the maximum size of the key set, so that a broken
+	// or hostile key set URL cannot exhaust memory.
+	maxJWKSSize = 1 << 20
+)
+
+// jwksCache fetches and caches the public keys in a JSON Web Key Set.
+//
+// The key set is fetched without holding the lock, and concurrent requests
+// share a single fetch. The fetch does not use the context of the request
+// that started it, so a cancelled request does not abort the fetch for the
+// other requests waiting for it.
+type jwksCache struct {
+	url     string
+	client  *http.Client
+	refresh time.Duration
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey // nil until fetched successfully
+	err      error                       // error from the last fetch
+	fetched  time.Time                   // time of the last fetch
+	fetching chan struct{}               // closed when the current fetch finishes
+}
+
+func newJWKSCache(url string, client *http.Client, refresh time.Duration) *jwksCache {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if refresh <= 0 {
+		refresh = defaultJWKSRefresh
+	}
+	return &jwksCache{
+		url:     url,
+		client:  client,
+		refresh: refresh,
+	}
+}
+
+// key returns the key with the key ID, or nil if there is no such key.
+//
+// If the key set is older than the refresh interval, the cached keys are
+// used while the key set is fetched in the background. The caller only waits
+// for the key set to be fetched if there are no cached keys, or the key ID
+// is unknown. If the key set cannot be fetched and there are no cached keys,
+// the error has a public status of 503 (Service Unavailable).
+func (c *jwksCache) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	key, ok := c.keys[kid]
+	age := time.Since(c.fetched)
+	switch {
+	case c.keys == nil && c.err != nil && age < jwksRetryInterval:
+		// failed recently, so do not try again yet
+		err := c.err
+		c.mu.Unlock()
+		return nil, keysUnavailable(err)
+	case c.keys == nil || (!ok && age > minJWKSInterval):
+		// need to wait for the key set
+	case age > c.refresh:
+		// use the cached key, and refresh the key set in the background
+		c.startFetch(ctx)
+		c.mu.Unlock()
+		return key, nil
+	default:
+		c.mu.Unlock()
+		return key, nil
+	}
+	done := c.startFetch(ctx)
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		return nil, keysUnavailable(c.err)
+	}
+	return c.keys[kid], nil
+}
+
+// startFetch starts fetching the key set, unless a fetch is already in
+// progress. It returns a channel that is closed when the fetch finishes.
+// The caller must hold the lock.
+func (c *jwksCache) startFetch(ctx context.Context) <-chan struct{} {
+	if c.fetching != nil {
+		return c.fetching
+	}
+	done := make(chan struct{})
+	c.fetching = done
+
+	// keep the context values, eg for tracing, but not its cancellation
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+	go func() {
+		defer cancel()
+		keys, err := c.fetch(ctx)
+		c.mu.Lock()
+		if err == nil {
+			c.keys = keys
+		}
+		c.err = err
+		c.fetched = time.Now()
+		c.fetching = nil
+		c.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// keysUnavailable returns the error for a key set that cannot be fetched.
+// The problem is with the server, not the token, so it is not a 401 error.
+func keysUnavailable(err error) error {
+	return errors.Wrap(errkind.Public("cannot verify token", http.StatusServiceUnavailable), "cannot fetch JWKS").
+		With("error", err)
+}
+
+func (c *jwksCache) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create JWKS request")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot fetch JWKS")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("cannot fetch JWKS").With("status", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&set); err != nil {
+		return nil, errors.Wrap(err, "cannot decode JWKS")
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key := k.publicKey(); key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// jwk is a JSON Web Key. Only the fields for RSA and EC public keys are used.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the public key, or nil if the key is not
+// a supported type or cannot be decoded.
+func (k *jwk) publicKey() crypto.PublicKey {
+	switch k.Kty {
+	case "RSA":
+		n, ok1 := decodeBigInt(k.N)
+		e, ok2 := decodeBigInt(k.E)
+		if !ok1 || !ok2 || !e.IsInt64() {
+			return nil
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil
+		}
+		// the coordinates are encoded with the full size of the curve,
+		// so they form an uncompressed point, which is checked to be
+		// on the curve when it is parsed
+		size := (curve.Params().BitSize + 7) / 8
+		x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+		if err1 != nil || err2 != nil || len(x) != size || len(y) != size {
+			return nil
+		}
+		point := make([]byte, 0, 1+2*size)
+		point = append(point, 4)
+		point = append(point, x...)
+		point = append(point, y...)
+		key, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+		if err != nil {
+			return nil
+		}
+		return key
+	}
+	return nil
+}
+
+func decodeBigInt(s string) (*big.Int, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+	return new(big.Int).SetBytes(data), true
+}
@@ -0,0 +1,336 @@
+// Package jwtauth provides HTTP middleware that authenticates requests
+// using JSON Web Tokens (JWT) sent as bearer tokens.
+//
+// Tokens signed with HMAC (HS256, HS384, HS512), RSA (RS256, RS384, RS512,
+// PS256, PS384, PS512) and ECDSA (ES256, ES384, ES512) are supported. Keys
+// can be configured directly, or obtained from a JSON Web Key Set (JWKS) URL.
+//
+// Requests without a valid token are rejected with a 401 response sent
+// using httpapi.WriteError, so the response has the same shape as any other
+// error response. The error code identifies the problem, eg "token_expired".
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jjeffery/httpapi"
+)
+
+// Error codes sent to the client when a token is rejected.
+const (
+	CodeMalformedToken   = "malformed_token"
+	CodeInvalidToken     = "invalid_token"
+	CodeInvalidSignature = "invalid_signature"
+	CodeTokenExpired     = "token_expired"
+	CodeTokenNotYetValid = "token_not_yet_valid"
+	CodeInvalidIssuer    = "invalid_issuer"
+	CodeInvalidAudience  = "invalid_audience"
+	CodeUnknownKey       = "unknown_key"
+)
+
+// Config contains the configuration for verifying tokens.
+type Config struct {
+	// HMACKey is the secret key for tokens signed with HMAC.
+	HMACKey []byte
+
+	// PublicKeys contains the RSA and ECDSA public keys for verifying
+	// tokens, indexed by key ID ("kid" header). The key with an empty
+	// ID is used for tokens that do not have a key ID.
+	PublicKeys map[string]crypto.PublicKey
+
+	// JWKSURL is the URL of a JSON Web Key Set containing public keys.
+	// The key set is fetched when first needed, and again when a token
+	// has an unknown key ID or the key set is older than JWKSRefresh.
+	JWKSURL string
+
+	// JWKSRefresh is the maximum age of the key set fetched from JWKSURL.
+	// If not specified, the key set is refreshed every hour.
+	JWKSRefresh time.Duration
+
+	// HTTPClient is used to fetch the key set. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Issuer, if specified, must match the "iss" claim of the token.
+	Issuer string
+
+	// Audience, if specified, must be one of the values of the "aud" claim.
+	Audience string
+
+	// Leeway allows for clock skew when checking the "exp" and "nbf" claims.
+	Leeway time.Duration
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Claims are the claims in a verified token.
+type Claims map[string]interface{}
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// Issuer returns the "iss" claim.
+func (c Claims) Issuer() string {
+	s, _ := c["iss"].(string)
+	return s
+}
+
+// Audience returns the "aud" claim, which can be a string or an array of strings.
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		var list []string
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// time returns a NumericDate claim as a time.
+func (c Claims) time(name string) (time.Time, bool) {
+	switch v := c[name].(type) {
+	case float64:
+		sec, frac := splitFloat(v)
+		return time.Unix(sec, frac), true
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			sec, frac := splitFloat(f)
+			return time.Unix(sec, frac), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ExpiresAt returns the "exp" claim, or zero if there is none.
+func (c Claims) ExpiresAt() time.Time {
+	t, _ := c.time("exp")
+	return t
+}
+
+// ClaimsFromContext returns the claims of the token that was verified
+// by the middleware, or nil if there are none.
+func ClaimsFromContext(ctx context.Context) Claims {
+	claims, _ := httpapi.PrincipalFromContext(ctx).(Claims)
+	return claims
+}
+
+// Verifier verifies tokens.
+type Verifier struct {
+	config Config
+	jwks   *jwksCache
+}
+
+// NewVerifier returns a verifier for the configuration.
+func NewVerifier(config Config) *Verifier {
+	v := &Verifier{config: config}
+	if config.JWKSURL != "" {
+		v.jwks = newJWKSCache(config.JWKSURL, config.HTTPClient, config.JWKSRefresh)
+	}
+	return v
+}
+
+// Middleware returns middleware that verifies the bearer token of each
+// request using the configuration. See Verifier.Middleware.
+func Middleware(config Config) httpapi.Middleware {
+	return NewVerifier(config).Middleware()
+}
+
+// Middleware returns middleware that verifies the bearer token of each
+// request. The claims of a valid token are available to the handler using
+// ClaimsFromContext. Requests without a valid token are rejected with a
+// 401 error response.
+func (v *Verifier) Middleware() httpapi.Middleware {
+	return httpapi.BearerAuth(func(ctx context.Context, token string) (interface{}, error) {
+		return v.Verify(ctx, token)
+	})
+}
+
+// Verify verifies the signature and claims of the token, and returns its claims.
+// The error returned if the token is not valid has a 401 status and an error
+// code that can be sent to the client.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, tokenError("malformed token", CodeMalformedToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, tokenError("malformed token header", CodeMalformedToken)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, tokenError("malformed token signature", CodeMalformedToken)
+	}
+	if err := v.verifySignature(ctx, header.Alg, header.Kid, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, tokenError("malformed token claims", CodeMalformedToken)
+	}
+	if err := v.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) verifySignature(ctx context.Context, alg, kid string, signed string, sig []byte) error {
+	if len(alg) != 5 || hashes[alg[2:]] == 0 {
+		return tokenError("unsupported signing algorithm", CodeInvalidSignature)
+	}
+	hash := hashes[alg[2:]]
+	if strings.HasPrefix(alg, "HS") {
+		if len(v.config.HMACKey) == 0 {
+			return tokenError("unsupported signing algorithm", CodeInvalidSignature)
+		}
+		mac := hmac.New(hash.New, v.config.HMACKey)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return tokenError("invalid token signature", CodeInvalidSignature)
+		}
+		return nil
+	}
+
+	key, err := v.publicKey(ctx, kid)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	valid := false
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			valid = rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil
+		case "PS":
+			valid = rsa.VerifyPSS(key, hash, digest, sig, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		// each algorithm uses one curve, so that eg a
+		// P-384 key cannot verify an ES256 token
+		params := key.Curve.Params()
+		size := (params.BitSize + 7) / 8
+		if params.Name == ecdsaCurves[alg] && len(sig) == 2*size {
+			r := new(big.Int).SetBytes(sig[:size])
+			s := new(big.Int).SetBytes(sig[size:])
+			valid = ecdsa.Verify(key, digest, r, s)
+		}
+	}
+	if !valid {
+		return tokenError("invalid token signature", CodeInvalidSignature)
+	}
+	return nil
+}
+
+func (v *Verifier) publicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := v.config.PublicKeys[kid]; ok {
+		return key, nil
+	}
+	if v.jwks != nil {
+		key, err := v.jwks.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			return key, nil
+		}
+	}
+	return nil, tokenError("unknown signing key", CodeUnknownKey)
+}
+
+func (v *Verifier) verifyClaims(claims Claims) error {
+	now := time.Now()
+	if v.config.Now != nil {
+		now = v.config.Now()
+	}
+	leeway := v.config.Leeway
+	for _, name := range []string{"exp", "nbf"} {
+		// RFC 7519 requires a NumericDate, and a token with
+		// a date in another format must not be treated as
+		// if it does not expire
+		if _, ok := claims[name]; ok {
+			if _, ok := claims.time(name); !ok {
+				return tokenError("invalid "+name+" claim", CodeInvalidToken)
+			}
+		}
+	}
+	if exp, ok := claims.time("exp"); ok && !now.Before(exp.Add(leeway)) {
+		return tokenError("token has expired", CodeTokenExpired)
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(leeway).Before(nbf) {
+		return tokenError("token is not valid yet", CodeTokenNotYetValid)
+	}
+	if v.config.Issuer != "" && claims.Issuer() != v.config.Issuer {
+		return tokenError("invalid token issuer", CodeInvalidIssuer)
+	}
+	if v.config.Audience != "" {
+		found := false
+		for _, aud := range claims.Audience() {
+			if aud == v.config.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return tokenError("invalid token audience", CodeInvalidAudience)
+		}
+	}
+	return nil
+}
+
+// ecdsaCurves contains the curve for each ECDSA algorithm.
+var ecdsaCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+var hashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func splitFloat(f float64) (int64, int64) {
+	sec := int64(f)
+	return sec, int64((f - float64(sec)) * 1e9)
+}
+
+// tokenError returns an error for an invalid token. It has a public
+// status of 401 (Unauthorized), and a public message and code.
+func tokenError(msg string, code string) error {
+	return httpapi.AuthError(msg, http.StatusUnauthorized, code)
+}
@@ -0,0 +1,461 @@
+package jwtauth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/errkind"
+)
+
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signingInput(alg, kid string, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	return b64(h) + "." + b64(c)
+}
+
+func hmacToken(key []byte, claims map[string]interface{}) string {
+	input := signingInput("HS256", "", claims)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(input))
+	return input + "." + b64(mac.Sum(nil))
+}
+
+func rsaToken(key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := signingInput("RS256", kid, claims)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return input + "." + b64(sig)
+}
+
+func ecToken(key *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	return ecTokenAlg(key, "ES256", kid, claims)
+}
+
+// ecTokenAlg returns a token for the algorithm, signed with
+// a signature of the size for the curve of the key.
+func ecTokenAlg(key *ecdsa.PrivateKey, alg, kid string, claims map[string]interface{}) string {
+	input := signingInput(alg, kid, claims)
+	h := hashes[alg[2:]].New()
+	h.Write([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+	if err != nil {
+		panic(err)
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
+	return input + "." + b64(sig)
+}
+
+func TestVerify(t *testing.T) {
+	hmacKey := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(Config{
+		HMACKey: hmacKey,
+		PublicKeys: map[string]crypto.PublicKey{
+			"rsa":  &rsaKey.PublicKey,
+			"ec":   &ecKey.PublicKey,
+			"p384": &p384Key.PublicKey,
+		},
+		Issuer:   "https://issuer.example.com",
+		Audience: "api",
+		Leeway:   time.Minute,
+		Now:      func() time.Time { return now },
+	})
+	claims := func(m map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "alice",
+			"iss": "https://issuer.example.com",
+			"aud": []string{"other", "api"},
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range m {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	tests := []struct {
+		token string
+		code  string
+	}{
+		{token: hmacToken(hmacKey, claims(nil))},
+		{token: rsaToken(rsaKey, "rsa", claims(nil))},
+		{token: ecToken(ecKey, "ec", claims(nil))},
+		{token: ecTokenAlg(p384Key, "ES384", "p384", claims(nil))},
+		{token: ecTokenAlg(p384Key, "ES256", "p384", claims(nil)), code: CodeInvalidSignature},
+		{token: ecTokenAlg(p384Key, "ES512", "p384", claims(nil)), code: CodeInvalidSignature},
+		{token: hmacToken(hmacKey, claims(map[string]interface{}{"aud": "api", "exp": nil}))},
+		{token: hmacToken(hmacKey, claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}))},
+		{token: hmacToken(hmacKey, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), code: CodeTokenExpired},
+		{token: hmacToken(hmacKey, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})), code: CodeTokenNotYetValid},
+		{token: hmacToken(hmacKey, claims(map[string]interface{}{"exp": "2000-01-01"})), code: CodeInvalidToken},
+		{token: hmacToken(hmacKey, claims(map[string]interface{}{"exp": true})), code: CodeInvalidToken},
+		{token: hmacToken(hmacKey, claims(map[string]interface{}{"nbf": map[string]interface{}{}})), code: CodeInvalidToken},
+		{token: hmacToken(hmacKey, claims(map[string]interface{}{"iss": "https://evil.example.com"})), code: CodeInvalidIssuer},
+		{token: hmacToken(hmacKey, claims(map[string]interface{}{"aud": "other"})), code: CodeInvalidAudience},
+		{token: hmacToken([]byte("wrong"), claims(nil)), code: CodeInvalidSignature},
+		{token: ecToken(otherKey, "ec", claims(nil)), code: CodeInvalidSignature},
+		{token: ecToken(otherKey, "unknown", claims(nil)), code: CodeUnknownKey},
+		{token: signingInput("none", "", claims(nil)) + ".", code: CodeInvalidSignature},
+		{token: "not-a-token", code: CodeMalformedToken},
+		{token: "a.b.c", code: CodeMalformedToken},
+	}
+	for i, tt := range tests {
+		c, err := v.Verify(context.Background(), tt.token)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("%d: want no error, got %v", i, err)
+				continue
+			}
+			if got, want := c.Subject(), "alice"; got != want {
+				t.Errorf("%d: subject: want %q, got %q", i, want, got)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%d: want error, got nil", i)
+			continue
+		}
+		if got, want := errkind.Code(err), tt.code; got != want {
+			t.Errorf("%d: code: want %q, got %q", i, want, got)
+		}
+		if got, want := errkind.StatusCode(err), http.StatusUnauthorized; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "rsa",
+					"use": "sig",
+					"n":   b64(rsaKey.N.Bytes()),
+					"e":   b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+				},
+				{
+					"kty": "EC",
+					"kid": "ec",
+					"crv": "P-256",
+					"x":   b64(ecKey.X.FillBytes(make([]byte, 32))),
+					"y":   b64(ecKey.Y.FillBytes(make([]byte, 32))),
+				},
+				{
+					// not on the curve
+					"kty": "EC",
+					"kid": "invalid",
+					"crv": "P-256",
+					"x":   b64(ecKey.X.FillBytes(make([]byte, 32))),
+					"y":   b64(new(big.Int).Add(ecKey.Y, big.NewInt(1)).FillBytes(make([]byte, 32))),
+				},
+				{
+					"kty": "oct",
+					"kid": "ignored",
+					"k":   "c2VjcmV0",
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	v := NewVerifier(Config{JWKSURL: server.URL})
+	claims := map[string]interface{}{"sub": "alice"}
+	for i, token := range []string{
+		rsaToken(rsaKey, "rsa", claims),
+		ecToken(ecKey, "ec", claims),
+	} {
+		c, err := v.Verify(context.Background(), token)
+		if err != nil {
+			t.Errorf("%d: want no error, got %v", i, err)
+			continue
+		}
+		if got, want := c.Subject(), "alice"; got != want {
+			t.Errorf("%d: subject: want %q, got %q", i, want, got)
+		}
+	}
+
+	// unknown key IDs do not cause the key set to be fetched again immediately
+	_, err = v.Verify(context.Background(), rsaToken(rsaKey, "unknown", claims))
+	if got, want := errkind.Code(err), CodeUnknownKey; got != want {
+		t.Errorf("code: want %q, got %q", want, got)
+	}
+	_, err = v.Verify(context.Background(), ecToken(ecKey, "invalid", claims))
+	if got, want := errkind.Code(err), CodeUnknownKey; got != want {
+		t.Errorf("invalid key: code: want %q, got %q", want, got)
+	}
+	if got, want := atomic.LoadInt32(&fetches), int32(1); got != want {
+		t.Errorf("fetches: want %d, got %d", want, got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	key := []byte("secret")
+	var claims Claims
+	h := Middleware(Config{HMACKey: key})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims = ClaimsFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		token     string
+		status    int
+		challenge string
+		code      string
+	}{
+		{
+			token:  hmacToken(key, map[string]interface{}{"sub": "alice"}),
+			status: http.StatusOK,
+		},
+		{
+			token:     hmacToken(key, map[string]interface{}{"sub": "alice", "exp": 1000}),
+			status:    http.StatusUnauthorized,
+			challenge: `Bearer error="invalid_token"`,
+			code:      CodeTokenExpired,
+		},
+		{
+			status:    http.StatusUnauthorized,
+			challenge: "Bearer",
+		},
+	}
+	for i, tt := range tests {
+		claims = nil
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("WWW-Authenticate"), tt.challenge; got != want {
+			t.Errorf("%d: challenge: want %q, got %q", i, want, got)
+		}
+		if tt.status == http.StatusOK {
+			if got, want := claims.Subject(), "alice"; got != want {
+				t.Errorf("%d: subject: want %q, got %q", i, want, got)
+			}
+			continue
+		}
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if got, want := body.Error.Code, tt.code; got != want {
+			t.Errorf("%d: code: want %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestJWKSConcurrentFetch(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(50 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   b64(key.X.FillBytes(make([]byte, 32))),
+				"y":   b64(key.Y.FillBytes(make([]byte, 32))),
+			}},
+		})
+	}))
+	defer server.Close()
+
+	v := NewVerifier(Config{JWKSURL: server.URL})
+	token := ecToken(key, "ec", map[string]interface{}{"sub": "alice"})
+
+	// a cancelled request does not abort the fetch for other requests
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := v.Verify(ctx, token); err == nil {
+		t.Error("cancelled: want error, got nil")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := v.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("want no error, got %v", err)
+		}
+	}
+	if got, want := atomic.LoadInt32(&fetches), int32(1); got != want {
+		t.Errorf("fetches: want %d, got %d", want, got)
+	}
+}
+
+func TestJWKSUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Middleware(Config{JWKSURL: server.URL})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called")
+	}))
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+ecToken(key, "ec", map[string]interface{}{"sub": "alice"}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, http.StatusServiceUnavailable; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got := w.Header().Get("WWW-Authenticate"); got != "" {
+			t.Errorf("%d: want no challenge, got %q", i, got)
+		}
+	}
+}
+
+func TestJWKSTooLarge(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"EC","kid":"ec","crv":"P-256","x":%q,"y":%q}]`,
+			b64(key.X.FillBytes(make([]byte, 32))), b64(key.Y.FillBytes(make([]byte, 32))))
+		w.Write(bytes.Repeat([]byte(" "), maxJWKSSize))
+		io.WriteString(w, "}")
+	}))
+	defer server.Close()
+
+	v := NewVerifier(Config{JWKSURL: server.URL})
+	_, err = v.Verify(context.Background(), ecToken(key, "ec", map[string]interface{}{"sub": "alice"}))
+	if got, want := errkind.StatusCode(err), http.StatusServiceUnavailable; got != want {
+		t.Errorf("status: want %d, got %d (%v)", want, got, err)
+	}
+}
+
+func TestJWKSStale(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			// refresh is slow
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   b64(key.X.FillBytes(make([]byte, 32))),
+				"y":   b64(key.Y.FillBytes(make([]byte, 32))),
+			}},
+		})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	v := NewVerifier(Config{JWKSURL: server.URL, JWKSRefresh: time.Millisecond})
+	token := ecToken(key, "ec", map[string]interface{}{"sub": "alice"})
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// the key set is stale, but the cached keys are used while it is refreshed
+	done := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(context.Background(), token)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("want no error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("verify waited for refresh")
+	}
+}
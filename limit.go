@@ -0,0 +1,161 @@
+package httpapi
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jjeffery/errkind"
+)
+
+// concurrencyRetryAfter is the time that a client is asked to wait
+// before retrying a request rejected by ConcurrencyLimit.
+const concurrencyRetryAfter = time.Second
+
+// ConcurrencyLimitConfig is the configuration for ConcurrencyLimit.
+type ConcurrencyLimitConfig struct {
+	// Limit is the maximum number of requests from each client that are
+	// handled at the same time. It must be positive.
+	Limit int
+
+	// MaxWait is the time that a request waits for one of the client's
+	// other requests to finish when the limit is reached. If zero, the
+	// request is rejected immediately.
+	MaxWait time.Duration
+
+	// MaxQueue is the maximum number of requests from each client that
+	// wait at the same time. If zero, there is no limit on the number of
+	// waiting requests. It has no effect if MaxWait is zero.
+	MaxQueue int
+
+	// Key returns the key that identifies the client that sent the
+	// request, eg an API key. If nil, clients are identified by the
+	// IP address of the remote end of the connection.
+	Key func(r *http.Request) string
+}
+
+// ConcurrencyLimit returns middleware that limits the number of requests
+// from each client that are handled at the same time, which protects
+// handlers that are expensive to run from clients that send many requests
+// in parallel. When the limit is reached, a request waits for up to
+// MaxWait for another request to finish, and if it cannot be handled in
+// time, a 429 (Too Many Requests) error with a Retry-After header is sent
+// to the client using WriteError.
+//
+// ConcurrencyLimit panics if config.Limit is not positive.
+func ConcurrencyLimit(config ConcurrencyLimitConfig) Middleware {
+	if config.Limit < 1 {
+		panic("httpapi: ConcurrencyLimit requires a positive limit")
+	}
+	key := config.Key
+	if key == nil {
+		key = remoteIP
+	}
+	limiter := &concurrencyLimiter{
+		config:  config,
+		clients: make(map[string]*clientSlots),
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := limiter.get(key(r))
+			defer limiter.put(c)
+			if err := limiter.acquire(r, c); err != nil {
+				if r.Context().Err() != nil {
+					// the client has gone away, or the request has
+					// timed out, and the request was never handled
+					return
+				}
+				WriteError(w, r, err)
+				return
+			}
+			defer func() { <-c.slots }()
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// concurrencyLimiter keeps track of the requests being handled for each client.
+type concurrencyLimiter struct {
+	config  ConcurrencyLimitConfig
+	mutex   sync.Mutex
+	clients map[string]*clientSlots
+}
+
+// clientSlots is the state for one client. It is removed from the map
+// when there are no requests for the client.
+type clientSlots struct {
+	key     string
+	slots   chan struct{} // one entry per request being handled
+	refs    int           // requests being handled or waiting
+	waiting int           // requests waiting
+}
+
+// get returns the state for the client, creating it if necessary.
+func (l *concurrencyLimiter) get(key string) *clientSlots {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	c := l.clients[key]
+	if c == nil {
+		c = &clientSlots{key: key, slots: make(chan struct{}, l.config.Limit)}
+		l.clients[key] = c
+	}
+	c.refs++
+	return c
+}
+
+// put releases the state for the client obtained using get.
+func (l *concurrencyLimiter) put(c *clientSlots) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	c.refs--
+	if c.refs == 0 {
+		delete(l.clients, c.key)
+	}
+}
+
+// acquire waits for a slot to handle the request.
+func (l *concurrencyLimiter) acquire(r *http.Request, c *clientSlots) error {
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	tooMany := RetryAfter(errkind.Public("too many concurrent requests", http.StatusTooManyRequests), concurrencyRetryAfter)
+	if l.config.MaxWait <= 0 {
+		return tooMany
+	}
+
+	l.mutex.Lock()
+	if l.config.MaxQueue > 0 && c.waiting >= l.config.MaxQueue {
+		l.mutex.Unlock()
+		return tooMany
+	}
+	c.waiting++
+	l.mutex.Unlock()
+	defer func() {
+		l.mutex.Lock()
+		c.waiting--
+		l.mutex.Unlock()
+	}()
+
+	timer := time.NewTimer(l.config.MaxWait)
+	defer timer.Stop()
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return tooMany
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
+
+// remoteIP returns the IP address of the remote end of the connection.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
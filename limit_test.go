@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		config ConcurrencyLimitConfig
+		addr   string // remote address of the second request
+		status int    // status of the second request
+		third  int    // status of a third request, if sent
+	}{
+		{
+			config: ConcurrencyLimitConfig{Limit: 1},
+			addr:   "192.0.2.1:1234",
+			status: http.StatusTooManyRequests,
+		},
+		{
+			config: ConcurrencyLimitConfig{Limit: 1},
+			addr:   "192.0.2.2:1234",
+			status: http.StatusOK,
+		},
+		{
+			config: ConcurrencyLimitConfig{Limit: 2},
+			addr:   "192.0.2.1:5678",
+			status: http.StatusOK,
+		},
+		{
+			config: ConcurrencyLimitConfig{Limit: 1, MaxWait: time.Minute, MaxQueue: 1},
+			addr:   "192.0.2.1:1234",
+			status: http.StatusOK,
+			third:  http.StatusTooManyRequests,
+		},
+		{
+			config: ConcurrencyLimitConfig{
+				Limit: 1,
+				Key:   func(r *http.Request) string { return r.Header.Get("X-Api-Key") },
+			},
+			addr:   "192.0.2.2:1234",
+			status: http.StatusTooManyRequests,
+		},
+	}
+	for i, tt := range tests {
+		release := make(chan struct{})
+		started := make(chan struct{}, 3)
+		h := ConcurrencyLimit(tt.config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			if r.Header.Get("X-Block") != "" {
+				<-release
+			}
+		}))
+		send := func(addr string, block bool) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = addr
+			r.Header.Set("X-Api-Key", "key")
+			if block {
+				r.Header.Set("X-Block", "1")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+
+		// first request blocks until released
+		first := make(chan *httptest.ResponseRecorder)
+		go func() { first <- send("192.0.2.1:1000", true) }()
+		<-started
+
+		second := make(chan *httptest.ResponseRecorder)
+		go func() { second <- send(tt.addr, false) }()
+		if tt.third != 0 {
+			// wait for the second request to be queued
+			time.Sleep(50 * time.Millisecond)
+			if got, want := send(tt.addr, false).Code, tt.third; got != want {
+				t.Errorf("%d: third: want %d, got %d", i, want, got)
+			}
+		}
+		if tt.status != http.StatusOK || tt.config.MaxWait == 0 {
+			w := <-second
+			if got, want := w.Code, tt.status; got != want {
+				t.Errorf("%d: want %d, got %d", i, want, got)
+			}
+			if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+				t.Errorf("%d: want Retry-After header", i)
+			}
+			close(release)
+		} else {
+			// second request waits for the first to finish
+			close(release)
+			if got, want := (<-second).Code, tt.status; got != want {
+				t.Errorf("%d: want %d, got %d", i, want, got)
+			}
+		}
+		if got, want := (<-first).Code, http.StatusOK; got != want {
+			t.Errorf("%d: first: want %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestConcurrencyLimitCanceled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	h := ConcurrencyLimit(ConcurrencyLimitConfig{Limit: 1, MaxWait: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	time.Sleep(50 * time.Millisecond)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	ctx, cancel := context.WithCancel(r.Context())
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r.WithContext(ctx))
+		done <- w
+	}()
+	cancel()
+	select {
+	case w := <-done:
+		if w.Body.Len() != 0 {
+			t.Errorf("want no response, got %q", w.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request did not return when canceled")
+	}
+}
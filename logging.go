@@ -0,0 +1,91 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+// RequestLog contains the details of a request that has been handled.
+type RequestLog struct {
+	Request  *http.Request
+	Method   string
+	Path     string
+	Status   int           // Status sent to the client
+	Bytes    int64         // Number of body bytes sent to the client
+	Duration time.Duration // Time taken to handle the request
+	Trace    string        // Trace ID, from the GetTrace callback of the writeerror configuration
+}
+
+// LogRequests returns middleware that calls log once for every request,
+// after the request has been handled. If log is nil, each request is
+// logged using the default slog logger (see SlogRequestLog).
+//
+// If the handler panics before sending a response, the request is logged
+// with a 500 status. If the request was handled by Route.Handler, the
+// route is available to log using RouteFromRequest(rl.Request).
+//
+// The trace ID is obtained from the same GetTrace callback that WriteError
+// uses for error responses, so access log entries can be correlated with
+// the errors sent to clients. For this to work, the writeerror middleware
+// must come before this middleware in the stack.
+func LogRequests(log func(*RequestLog)) Middleware {
+	if log == nil {
+		log = SlogRequestLog(nil)
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r = withRouteHolder(r)
+			rw := newResponseWriter(w)
+			completed := false
+			defer func() {
+				status := rw.Status()
+				switch {
+				case !completed && status == 0:
+					// the handler panicked, and the Recover middleware
+					// (if any) is outside this middleware
+					status = http.StatusInternalServerError
+				case status == 0:
+					status = http.StatusOK
+				}
+				log(&RequestLog{
+					Request:  r,
+					Method:   r.Method,
+					Path:     r.URL.Path,
+					Status:   status,
+					Bytes:    rw.bytes,
+					Duration: time.Since(start),
+					Trace:    writeerror.ConfigFromRequest(r).GetTrace(r),
+				})
+			}()
+			h.ServeHTTP(rw, r)
+			completed = true
+		})
+	}
+}
+
+// SlogRequestLog returns a function for use with LogRequests that logs
+// each request at info level. If logger is nil, the default slog logger
+// is used when the request is logged.
+func SlogRequestLog(logger *slog.Logger) func(*RequestLog) {
+	return func(rl *RequestLog) {
+		l := logger
+		if l == nil {
+			l = slog.Default()
+		}
+		attrs := []slog.Attr{
+			slog.String("method", rl.Method),
+			slog.String("path", rl.Path),
+			slog.Int("status", rl.Status),
+			slog.Int64("bytes", rl.Bytes),
+			slog.Duration("duration", rl.Duration),
+		}
+		if rl.Trace != "" {
+			attrs = append(attrs, slog.String("trace", rl.Trace))
+		}
+		l.LogAttrs(rl.Request.Context(), slog.LevelInfo, "request", attrs...)
+	}
+}
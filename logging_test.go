@@ -0,0 +1,138 @@
+package httpapi
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+func TestLogRequests(t *testing.T) {
+	var got *RequestLog
+	config := writeerror.Config{
+		GetTrace: func(*http.Request) string { return "trace-1" },
+	}
+	tests := []struct {
+		handler http.HandlerFunc
+		status  int
+		bytes   int64
+	}{
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("hello")) },
+			status:  http.StatusOK,
+			bytes:   5,
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			status:  http.StatusNoContent,
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+			status:  http.StatusOK,
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				WriteError(w, r, errkind.Public("no such thing", http.StatusNotFound))
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Link", "</style.css>; rel=preload")
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusAccepted)
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			status:  http.StatusInternalServerError,
+		},
+	}
+	for i, tt := range tests {
+		got = nil
+		h := Use(writeerror.Middleware(config), Recover(), LogRequests(func(rl *RequestLog) { got = rl })).Handler(tt.handler)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/things", nil))
+		if got == nil {
+			t.Errorf("%d: not logged", i)
+			continue
+		}
+		if got.Status != tt.status {
+			t.Errorf("%d: status: want %d, got %d", i, tt.status, got.Status)
+		}
+		if tt.bytes == 0 && tt.status != http.StatusInternalServerError {
+			// the panic response is written by Recover, outside LogRequests
+			tt.bytes = int64(w.Body.Len())
+		}
+		if got.Bytes != tt.bytes {
+			t.Errorf("%d: bytes: want %d, got %d", i, tt.bytes, got.Bytes)
+		}
+		if got.Method != "GET" || got.Path != "/things" || got.Trace != "trace-1" {
+			t.Errorf("%d: unexpected entry %+v", i, got)
+		}
+	}
+}
+
+func TestSlogRequestLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	h := LogRequests(SlogRequestLog(logger))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/things", nil))
+	for _, want := range []string{"method=POST", "path=/things", "status=201"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %q in %s", want, buf.String())
+		}
+	}
+}
+
+func TestLogRequestsHijack(t *testing.T) {
+	logged := make(chan *RequestLog, 1)
+	h := LogRequests(func(rl *RequestLog) { logged <- rl })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("cannot hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+		rw.Flush()
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusSwitchingProtocols; got != want {
+		t.Errorf("status: want %d, got %d", want, got)
+	}
+	if got, want := (<-logged).Status, http.StatusSwitchingProtocols; got != want {
+		t.Errorf("logged status: want %d, got %d", want, got)
+	}
+}
+
+func TestLogRequestsFlush(t *testing.T) {
+	var got *RequestLog
+	h := LogRequests(func(rl *RequestLog) { got = rl })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+	}))
+	// the flush reaches the recorder through a writer that only has Unwrap
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(unwrapWriter{rec}, httptest.NewRequest("GET", "/", nil))
+	if !rec.Flushed {
+		t.Error("want flushed")
+	}
+	if got == nil || got.Status != http.StatusOK {
+		t.Errorf("want status 200, got %+v", got)
+	}
+}
@@ -0,0 +1,215 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+// MergePatchContentType is the content type of JSON Merge Patch documents.
+const MergePatchContentType = "application/merge-patch+json"
+
+// MergePatch is a JSON Merge Patch document (RFC 7386). A merge patch
+// looks like the document that it changes, and only contains the members
+// that change. A member with a null value is removed from the document.
+// For example, the following merge patch changes the name and removes
+// the description:
+//  {"name": "new name", "description": null}
+//
+// A handler for a PATCH request typically reads the patch, checks that
+// it only changes the members that clients are allowed to change, and
+// applies the patch to the resource:
+//  patch, err := httpapi.ReadMergePatch(r)
+//  if err != nil {
+//      return err
+//  }
+//  if err := patch.Allow("/name", "/description"); err != nil {
+//      return err
+//  }
+//  changed, err := patch.ApplyTo(&thing)
+//  if err != nil {
+//      return err
+//  }
+type MergePatch json.RawMessage
+
+// ReadMergePatch reads a JSON Merge Patch document from the request body.
+// The request must have a content type of "application/merge-patch+json",
+// otherwise the error has a status of 415 (Unsupported Media Type).
+func ReadMergePatch(r *http.Request) (MergePatch, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MergePatchContentType {
+		return nil, errkind.Public("content type must be "+MergePatchContentType, http.StatusUnsupportedMediaType)
+	}
+	var patch json.RawMessage
+	if err := ReadRequest(r, &patch); err != nil {
+		return nil, err
+	}
+	return MergePatch(patch), nil
+}
+
+// Allow checks that the patch only changes the locations in the document
+// that match the patterns, which are the same as for Patch.Allow, eg
+// "/name" or "/addresses/*/postcode". If the patch changes any other location,
+// the error is a *ValidationError with a status of 422 (Unprocessable Entity),
+// with a field error for each location, eg "id: cannot be changed".
+func (p MergePatch) Allow(patterns ...string) error {
+	var patch interface{}
+	if err := decodeJSON(p, &patch); err != nil {
+		return errkind.BadRequest("invalid JSON payload")
+	}
+	var allowed [][]string
+	for _, pattern := range patterns {
+		if tokens, ok := parsePointer(pattern); ok {
+			allowed = append(allowed, tokens)
+		}
+	}
+	verr := ValidationError{Summary: "JSON merge patch not allowed"}
+	for _, tokens := range mergePatchLocations(nil, patch) {
+		ok := false
+		for _, pattern := range allowed {
+			if matchPointer(pattern, tokens) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			verr.Add(strings.Join(tokens, "."), "cannot be changed", "forbidden")
+		}
+	}
+	return verr.Err()
+}
+
+// Apply applies the patch to the JSON document, and returns the patched document.
+func (p MergePatch) Apply(doc []byte) ([]byte, error) {
+	patched, _, err := p.apply(doc)
+	return patched, err
+}
+
+// ApplyTo applies the patch to the value pointed to by v, by marshalling the
+// value as JSON, applying the patch to the JSON, and unmarshalling the patched
+// JSON into a new value, which replaces the value pointed to by v. It returns
+// the JSON Pointers of the locations in the document that changed, in sorted
+// order, eg "/name" and "/address/postcode". If the patched document cannot
+// be unmarshalled, v is not changed, and the error has a status of 422
+// (Unprocessable Entity).
+func (p MergePatch) ApplyTo(v interface{}) (changed []string, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, errors.New("httpapi: ApplyTo requires a non-nil pointer")
+	}
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal JSON document")
+	}
+	doc, changed, err = p.apply(doc)
+	if err != nil {
+		return nil, err
+	}
+	patched := reflect.New(rv.Elem().Type())
+	if err := json.Unmarshal(doc, patched.Interface()); err != nil {
+		return nil, errkind.Public("patched document is invalid", http.StatusUnprocessableEntity)
+	}
+	rv.Elem().Set(patched.Elem())
+	return changed, nil
+}
+
+// apply applies the patch to the document, and returns
+// the patched document and the locations that changed.
+func (p MergePatch) apply(doc []byte) ([]byte, []string, error) {
+	var patch, original, target interface{}
+	if err := decodeJSON(p, &patch); err != nil {
+		return nil, nil, errkind.BadRequest("invalid JSON payload")
+	}
+	if err := decodeJSON(doc, &original); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot unmarshal JSON document")
+	}
+	target = mergePatch(deepCopy(original), patch)
+	patched, err := json.Marshal(target)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot marshal JSON document")
+	}
+	var changed []string
+	diffLocations("", original, target, &changed)
+	sort.Strings(changed)
+	return patched, changed, nil
+}
+
+// mergePatch applies the patch to the target, as specified by RFC 7386.
+func mergePatch(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for key, value := range members {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], value)
+		}
+	}
+	return t
+}
+
+// mergePatchLocations returns the reference tokens of the
+// locations in the document that the patch changes, in order.
+func mergePatchLocations(prefix []string, patch interface{}) [][]string {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return [][]string{prefix}
+	}
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var locations [][]string
+	for _, key := range keys {
+		tokens := append(append([]string(nil), prefix...), key)
+		if _, ok := members[key].(map[string]interface{}); ok {
+			locations = append(locations, mergePatchLocations(tokens, members[key])...)
+		} else {
+			locations = append(locations, tokens)
+		}
+	}
+	return locations
+}
+
+// diffLocations appends the JSON Pointers of the locations
+// where the values a and b differ.
+func diffLocations(pointer string, a, b interface{}, locations *[]string) {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if !jsonEqual(a, b) {
+			*locations = append(*locations, pointer)
+		}
+		return
+	}
+	for key, value := range am {
+		if other, ok := bm[key]; ok {
+			diffLocations(pointer+"/"+escapePointer(key), value, other, locations)
+		} else {
+			*locations = append(*locations, pointer+"/"+escapePointer(key))
+		}
+	}
+	for key := range bm {
+		if _, ok := am[key]; !ok {
+			*locations = append(*locations, pointer+"/"+escapePointer(key))
+		}
+	}
+}
+
+// escapePointer escapes a reference token for a JSON Pointer.
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
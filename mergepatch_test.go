@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+)
+
+func TestMergePatchApply(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		// examples from RFC 7386 appendix A
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{doc: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{doc: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+		{doc: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{doc: `{"a":"foo"}`, patch: `null`, want: `null`},
+		{doc: `{"a":"foo"}`, patch: `"bar"`, want: `"bar"`},
+		{doc: `{"e":null}`, patch: `{"a":1}`, want: `{"a":1,"e":null}`},
+		{doc: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+
+		// numbers are not changed
+		{doc: `{"a":12345678901234567890}`, patch: `{"b":1.50}`, want: `{"a":12345678901234567890,"b":1.50}`},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("PATCH", "/", strings.NewReader(tt.patch))
+		r.Header.Set("Content-Type", MergePatchContentType)
+		patch, err := ReadMergePatch(r)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		doc, err := patch.Apply([]byte(tt.doc))
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if string(doc) != tt.want {
+			t.Errorf("%d: want %s, got %s", i, tt.want, doc)
+		}
+	}
+}
+
+func TestReadMergePatch(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{contentType: "application/merge-patch+json; charset=utf-8", body: `{"a":1}`},
+		{contentType: "application/json", body: `{"a":1}`, status: http.StatusUnsupportedMediaType},
+		{contentType: MergePatchContentType, body: `{"a":`, status: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("PATCH", "/", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+		_, err := ReadMergePatch(r)
+		if tt.status == 0 {
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+			}
+			continue
+		}
+		if got := errkind.StatusCode(err); got != tt.status {
+			t.Errorf("%d: want %d, got %d", i, tt.status, got)
+		}
+	}
+}
+
+func TestMergePatchAllow(t *testing.T) {
+	tests := []struct {
+		patch string
+		want  string
+	}{
+		{
+			patch: `{"name":"x","tags":["a"],"addresses":{"home":{"postcode":null}}}`,
+		},
+		{
+			patch: `{"id":"2","name":"x","addresses":{"home":{"street":"y","postcode":"z"}}}`,
+			want:  "JSON merge patch not allowed: addresses.home.street: cannot be changed; id: cannot be changed",
+		},
+	}
+	for i, tt := range tests {
+		err := MergePatch(tt.patch).Allow("/name", "/tags", "/addresses/*/postcode")
+		var got string
+		if err != nil {
+			got = err.Error()
+			if errkind.StatusCode(err) != http.StatusUnprocessableEntity {
+				t.Errorf("%d: want 422, got %d", i, errkind.StatusCode(err))
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%d: want %q, got %q", i, tt.want, got)
+		}
+	}
+}
+
+func TestMergePatchApplyTo(t *testing.T) {
+	type Address struct {
+		Street   string `json:"street"`
+		Postcode string `json:"postcode,omitempty"`
+	}
+	type Thing struct {
+		Name    string   `json:"name"`
+		Tags    []string `json:"tags,omitempty"`
+		Count   int      `json:"count"`
+		Address Address  `json:"address"`
+	}
+	thing := Thing{
+		Name:    "one",
+		Tags:    []string{"a"},
+		Count:   1,
+		Address: Address{Street: "main", Postcode: "1234"},
+	}
+	patch := MergePatch(`{"name":"two","count":1,"tags":null,"address":{"postcode":null,"street":"main"}}`)
+	changed, err := patch.ApplyTo(&thing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Thing{Name: "two", Count: 1, Address: Address{Street: "main"}}
+	if !reflect.DeepEqual(thing, want) {
+		t.Errorf("want %+v, got %+v", want, thing)
+	}
+	if want := []string{"/address/postcode", "/name", "/tags"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("want changed %q, got %q", want, changed)
+	}
+
+	changed, err = MergePatch(`{"name":"two"}`).ApplyTo(&thing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("want nothing changed, got %q", changed)
+	}
+
+	if _, err := MergePatch(`{"count":"many"}`).ApplyTo(&thing); errkind.StatusCode(err) != http.StatusUnprocessableEntity {
+		t.Errorf("want 422, got %v", err)
+	}
+	if thing.Count != 1 {
+		t.Errorf("want unchanged, got %+v", thing)
+	}
+}
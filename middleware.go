@@ -1,6 +1,13 @@
 package httpapi
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
 
 // Middleware is a function that filters a request coming into
 // the application and responses going back to the client. Middleware
@@ -10,8 +17,13 @@ type Middleware func(http.Handler) http.Handler
 // A Stack is a stack of middleware functions that are common to one or more
 // HTTP handlers. A middleware function is any function that accepts a Handler as a
 // parameter and returns a Handler.
+//
+// Each middleware function in the stack has a name, which is used by
+// List. Middleware added using UseNamed has the name specified. Other
+// middleware is named after its function, eg "httpapi.Recover".
 type Stack struct {
 	middleware Middleware
+	name       string
 	previous   *Stack
 }
 
@@ -48,18 +60,150 @@ func (s *Stack) Use(f ...Middleware) *Stack {
 	return stack
 }
 
+// UseNamed creates a Stack containing a named middleware function.
+func UseNamed(name string, m Middleware) *Stack {
+	return (*Stack)(nil).UseNamed(name, m)
+}
+
+// UseNamed creates a new stack by appending a named middleware function
+// to the existing stack.
+func (s *Stack) UseNamed(name string, m Middleware) *Stack {
+	if m == nil {
+		return s
+	}
+	return &Stack{
+		middleware: m,
+		name:       name,
+		previous:   s,
+	}
+}
+
+// Clone returns a copy of the stack. Stacks are not modified by adding
+// middleware, so a stack can be shared without cloning it, but a clone
+// does not share any memory with the original stack.
+func (s *Stack) Clone() *Stack {
+	return (*Stack)(nil).Concat(s)
+}
+
+// Concat creates a new stack by appending the middleware functions in
+// the other stacks to the existing stack, keeping their names. This
+// allows a shared base stack to be combined with a stack for a group of
+// routes, for example:
+//  api := base.Concat(httpapi.Use(authMiddleware, httpapi.Compress()))
+func (s *Stack) Concat(others ...*Stack) *Stack {
+	stack := s
+
+	for _, other := range others {
+		for _, entry := range other.entries() {
+			stack = &Stack{
+				middleware: entry.middleware,
+				name:       entry.name,
+				previous:   stack,
+			}
+		}
+	}
+
+	return stack
+}
+
+// Insert creates a new stack by inserting the middleware functions in the
+// other stack at the index, keeping their names. The index is the position
+// in the list returned by List, so an index of zero inserts the middleware
+// before all others, and an index equal to the length of the list appends
+// the middleware to the end. Insert panics if the index is out of range.
+func (s *Stack) Insert(index int, other *Stack) *Stack {
+	entries := s.entries()
+	if index < 0 || index > len(entries) {
+		panic(fmt.Sprintf("httpapi: Stack.Insert: index %d out of range [0:%d]", index, len(entries)))
+	}
+	var stack *Stack
+	if index > 0 {
+		// the middleware before the index can be shared
+		stack = entries[index-1]
+	}
+	stack = stack.Concat(other)
+	for _, entry := range entries[index:] {
+		stack = &Stack{
+			middleware: entry.middleware,
+			name:       entry.name,
+			previous:   stack,
+		}
+	}
+	return stack
+}
+
+// entries returns the entries in the stack that have a middleware
+// function, in the order that they were added.
+func (s *Stack) entries() []*Stack {
+	var entries []*Stack
+	for stack := s; stack != nil; stack = stack.previous {
+		if stack.middleware != nil {
+			entries = append(entries, stack)
+		}
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries
+}
+
+// List returns the names of the middleware functions in the stack, in the
+// order that they handle a request. This is the order that they were added,
+// so the first name is the outermost middleware.
+func (s *Stack) List() []string {
+	var names []string
+	for _, entry := range s.entries() {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// Name returns the name of the middleware function most recently added
+// to the stack.
+func (s *Stack) Name() string {
+	if s == nil || s.middleware == nil {
+		return ""
+	}
+	if s.name != "" {
+		return s.name
+	}
+	return funcName(s.middleware)
+}
+
 // Handler creates a http.Handler from a stack of middleware
 // functions and a httpctx.Handler.
+//
+// If a middleware function returns a nil handler, Handler panics with a
+// message that identifies the middleware by the name given to UseNamed, or
+// by its position in the stack if it has no name.
 func (s *Stack) Handler(h http.Handler) http.Handler {
 	for stack := s; stack != nil; stack = stack.previous {
 		if stack.middleware != nil {
 			h = stack.middleware(h)
+			if h == nil {
+				panic(fmt.Sprintf("middleware %s returned a nil handler", stack.describe()))
+			}
 		}
 	}
 
 	return h
 }
 
+// describe identifies the middleware function most recently added to the
+// stack for error messages.
+func (s *Stack) describe() string {
+	if s.name != "" {
+		return strconv.Quote(s.name)
+	}
+	n := 0
+	for stack := s; stack != nil; stack = stack.previous {
+		if stack.middleware != nil {
+			n++
+		}
+	}
+	return "#" + strconv.Itoa(n)
+}
+
 // HandlerFunc returns a http.Handler (compatible with the standard library http package), which
 // calls the middleware handlers in the stack s, followed by  the handler function f.
 func (s *Stack) HandlerFunc(f func(http.ResponseWriter, *http.Request)) http.Handler {
@@ -68,3 +212,124 @@ func (s *Stack) HandlerFunc(f func(http.ResponseWriter, *http.Request)) http.Han
 	}
 	return s.Handler(http.HandlerFunc(f))
 }
+
+// funcName returns the name of the function f, without its package path or
+// any suffix for closures, eg "httpapi.Recover" for the middleware function
+// returned by Recover.
+func funcName(f interface{}) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	// remove suffixes for method values and closures, eg "httpapi.Recover.func1.2"
+	name = strings.TrimSuffix(name, "-fm")
+	for {
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		suffix := strings.TrimPrefix(name[i+1:], "func")
+		if suffix == "" || strings.Trim(suffix, "0123456789") != "" {
+			break
+		}
+		name = name[:i]
+	}
+	return name
+}
+
+// A Predicate reports whether a request matches a condition. Predicates
+// are used with UseIf to apply middleware to some requests but not others.
+type Predicate func(r *http.Request) bool
+
+// UseIf creates a Stack of middleware functions that are only applied to
+// requests that match the predicate.
+func UseIf(predicate Predicate, f ...Middleware) *Stack {
+	return (*Stack)(nil).UseIf(predicate, f...)
+}
+
+// UseIf creates a new stack by appending middleware functions that are only
+// applied to requests that match the predicate. Requests that do not match
+// skip the middleware functions, and are passed to the next handler. This
+// allows one stack to serve different kinds of routes, for example:
+//  stack := httpapi.Use(httpapi.Recover()).
+//      UseIf(httpapi.Not(httpapi.PathPrefix("/internal/")), authMiddleware)
+// In the list of names returned by List, each middleware function has the
+// suffix " (conditional)".
+func (s *Stack) UseIf(predicate Predicate, f ...Middleware) *Stack {
+	stack := s
+
+	for _, m := range f {
+		if m != nil {
+			stack = stack.UseNamed(funcName(m)+" (conditional)", conditional(predicate, m))
+		}
+	}
+
+	return stack
+}
+
+// conditional returns middleware that applies m to requests that match the predicate.
+func conditional(predicate Predicate, m Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		mh := m(h)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if predicate(r) {
+				mh.ServeHTTP(w, r)
+			} else {
+				h.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// PathPrefix returns a predicate that matches requests whose URL path
+// starts with the prefix.
+func PathPrefix(prefix string) Predicate {
+	return func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, prefix)
+	}
+}
+
+// Methods returns a predicate that matches requests with one of
+// the HTTP methods, eg "GET".
+func Methods(methods ...string) Predicate {
+	return func(r *http.Request) bool {
+		for _, method := range methods {
+			if strings.EqualFold(r.Method, method) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HeaderMatches returns a predicate that matches requests that have the
+// header with the value. If value is empty, it matches requests that
+// have the header with any value.
+func HeaderMatches(name, value string) Predicate {
+	return func(r *http.Request) bool {
+		values, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return false
+		}
+		if value == "" {
+			return true
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a predicate that matches requests that do not match the predicate.
+func Not(predicate Predicate) Predicate {
+	return func(r *http.Request) bool {
+		return !predicate(r)
+	}
+}
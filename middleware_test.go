@@ -3,8 +3,10 @@
 package httpapi
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -34,3 +36,159 @@ func middleware1(f http.Handler) http.Handler {
 func middleware2(f http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
 }
+
+func TestStackList(t *testing.T) {
+	tests := []struct {
+		stack *Stack
+		want  []string
+	}{
+		{stack: nil, want: nil},
+		{stack: &Stack{}, want: nil},
+		{
+			stack: Use(middleware1, nil, middleware2),
+			want:  []string{"httpapi.middleware1", "httpapi.middleware2"},
+		},
+		{
+			stack: UseNamed("auth", middleware1).Use(Recover()).UseNamed("tracing", middleware2),
+			want:  []string{"auth", "httpapi.Recover", "tracing"},
+		},
+		{
+			stack: Use(middleware1).UseNamed("ignored", nil),
+			want:  []string{"httpapi.middleware1"},
+		},
+	}
+	for i, tt := range tests {
+		got := tt.stack.List()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestStackCompose(t *testing.T) {
+	base := UseNamed("a", middleware1).UseNamed("b", middleware2)
+	group := UseNamed("x", middleware1).UseNamed("y", middleware2)
+	tests := []struct {
+		stack *Stack
+		want  []string
+	}{
+		{stack: base.Clone(), want: []string{"a", "b"}},
+		{stack: (*Stack)(nil).Clone(), want: nil},
+		{stack: base.Concat(group), want: []string{"a", "b", "x", "y"}},
+		{stack: base.Concat(nil, group, base), want: []string{"a", "b", "x", "y", "a", "b"}},
+		{stack: (*Stack)(nil).Concat(group), want: []string{"x", "y"}},
+		{stack: base.Insert(0, group), want: []string{"x", "y", "a", "b"}},
+		{stack: base.Insert(1, group), want: []string{"a", "x", "y", "b"}},
+		{stack: base.Insert(2, group), want: []string{"a", "b", "x", "y"}},
+		{stack: base.Insert(1, nil), want: []string{"a", "b"}},
+		{stack: (*Stack)(nil).Insert(0, group), want: []string{"x", "y"}},
+	}
+	for i, tt := range tests {
+		got := tt.stack.List()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+	}
+	if got, want := base.List(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("base modified: want %v, got %v", want, got)
+	}
+
+	var order []string
+	mark := func(name string) Middleware {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	stack := Use(mark("1"), mark("3")).Insert(1, Use(mark("2")))
+	stack.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got, want := order, []string{"1", "2", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("order: want %v, got %v", want, got)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("want panic for index out of range")
+			}
+		}()
+		base.Insert(3, group)
+	}()
+}
+
+func TestStackNilHandler(t *testing.T) {
+	tests := []struct {
+		stack *Stack
+		want  string
+	}{
+		{
+			stack: UseNamed("nil", func(h http.Handler) http.Handler { return nil }),
+			want:  `middleware "nil" returned a nil handler`,
+		},
+		{
+			stack: Use(middleware1).Use(func(h http.Handler) http.Handler { return nil }).Use(middleware2),
+			want:  `middleware #2 returned a nil handler`,
+		},
+	}
+	for i, tt := range tests {
+		func() {
+			defer func() {
+				p := recover()
+				if got := fmt.Sprint(p); got != tt.want {
+					t.Errorf("%d: want %q, got %q", i, tt.want, got)
+				}
+			}()
+			tt.stack.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+		}()
+	}
+}
+
+func TestUseIf(t *testing.T) {
+	var applied []string
+	mark := func(name string) Middleware {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				applied = append(applied, name)
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+	stack := Use(mark("all")).
+		UseIf(Not(PathPrefix("/internal/")), mark("public")).
+		UseIf(Methods("POST", "PUT"), mark("write")).
+		UseIf(HeaderMatches("X-Debug", ""), mark("debug")).
+		UseIf(HeaderMatches("X-Tenant", "acme"), mark("acme"))
+	h := stack.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		method string
+		path   string
+		header map[string]string
+		want   []string
+	}{
+		{method: "GET", path: "/things", want: []string{"all", "public"}},
+		{method: "GET", path: "/internal/things", want: []string{"all"}},
+		{method: "post", path: "/internal/things", want: []string{"all", "write"}},
+		{method: "GET", path: "/things", header: map[string]string{"x-debug": "1"}, want: []string{"all", "public", "debug"}},
+		{method: "GET", path: "/things", header: map[string]string{"X-Tenant": "acme"}, want: []string{"all", "public", "acme"}},
+		{method: "GET", path: "/things", header: map[string]string{"X-Tenant": "other"}, want: []string{"all", "public"}},
+	}
+	for i, tt := range tests {
+		applied = nil
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if !reflect.DeepEqual(applied, tt.want) {
+			t.Errorf("%d: want %v, got %v", i, tt.want, applied)
+		}
+	}
+
+	if got, want := UseIf(PathPrefix("/"), middleware1).List(), []string{"httpapi.middleware1 (conditional)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list: want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,124 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+// MultiStatus contains the results of a batch operation, where each item
+// in the batch succeeds or fails independently. Use WriteMultiStatus to
+// send the results to the client. It is safe for concurrent use, so the
+// items can be processed using Parallel.
+//
+// For example:
+//  var results httpapi.MultiStatus
+//  for _, thing := range things {
+//      if err := save(ctx, thing); err != nil {
+//          results.AddError(thing.ID, err)
+//          continue
+//      }
+//      results.Add(thing.ID, http.StatusCreated, thing)
+//  }
+//  httpapi.WriteMultiStatus(w, r, &results)
+type MultiStatus struct {
+	mutex sync.Mutex
+	items []multiStatusItem
+}
+
+type multiStatusItem struct {
+	id     string
+	status int
+	body   interface{}
+	err    error
+}
+
+// Add records the successful result of the item with the ID. The body is
+// optional, and is marshalled as JSON.
+func (ms *MultiStatus) Add(id string, status int, body interface{}) {
+	ms.add(multiStatusItem{id: id, status: status, body: body})
+}
+
+// AddError records that the item with the ID failed. The status and body of
+// the item are the same as WriteError would send for err.
+func (ms *MultiStatus) AddError(id string, err error) {
+	if err == nil {
+		err = errkind.Public("no information available", http.StatusInternalServerError)
+	}
+	ms.add(multiStatusItem{id: id, err: err})
+}
+
+func (ms *MultiStatus) add(item multiStatusItem) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.items = append(ms.items, item)
+}
+
+// WriteMultiStatus sends the results of a batch operation to the client
+// with a 207 (Multi-Status) status, in the following format:
+//  {
+//      "results": [
+//          {"id": "1", "status": 201, "body": {"id": "1", "name": "thing"}},
+//          {"id": "2", "status": 404, "body": {"error": {"message": "not found", "status": 404}}}
+//      ]
+//  }
+// The results are in the order that they were added. The body of a failed
+// item is the error that WriteError would send for its error, so clients can
+// handle failed items in the same way as error responses. The writeerror
+// ErrorWritten callback is called for each failed item.
+//
+// Like WriteResponse, the response is compressed if the client
+// accepts compressed responses.
+func WriteMultiStatus(w http.ResponseWriter, r *http.Request, ms *MultiStatus) {
+	type result struct {
+		ID     string      `json:"id"`
+		Status int         `json:"status"`
+		Body   interface{} `json:"body,omitempty"`
+	}
+	ms.mutex.Lock()
+	items := append([]multiStatusItem(nil), ms.items...)
+	ms.mutex.Unlock()
+
+	config := writeerror.ConfigFromRequest(r)
+	results := make([]result, len(items))
+	var failed []*writeerror.Content
+	for i, item := range items {
+		results[i] = result{
+			ID:     item.id,
+			Status: item.status,
+			Body:   item.body,
+		}
+		if item.err != nil {
+			content := ErrorContent(r, item.err)
+			results[i].Status = content.StatusCode
+			results[i].Body = errorJSON(config.Marshal(content))
+			content.Err = item.err
+			failed = append(failed, content)
+		}
+	}
+	writeResponse(w, r, http.StatusMultiStatus, struct {
+		Results []result `json:"results"`
+	}{
+		Results: results,
+	})
+
+	for _, content := range failed {
+		config.Redact(content)
+		config.ErrorWritten(r, content)
+	}
+}
+
+// errorJSON returns the marshalled error as a value that can be included
+// in a JSON document. If the error was not marshalled as JSON, it is
+// included as a string.
+func errorJSON(data []byte) interface{} {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return string(data)
+	}
+	return json.RawMessage(buf.Bytes())
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+func TestWriteMultiStatus(t *testing.T) {
+	var written []*writeerror.Content
+	config := writeerror.Config{
+		ErrorWritten: func(r *http.Request, c *writeerror.Content) {
+			written = append(written, c)
+		},
+	}
+	var ms MultiStatus
+	ms.Add("1", http.StatusCreated, map[string]string{"name": "one"})
+	ms.AddError("2", errkind.Public("thing not found", http.StatusNotFound))
+	ms.Add("3", http.StatusNoContent, nil)
+	ms.AddError("4", errors.New("secret database failure"))
+
+	r := httptest.NewRequest("POST", "/things/batch", nil)
+	r.RemoteAddr = "192.0.2.1:1234" // untrusted
+	w := httptest.NewRecorder()
+	writeerror.Middleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteMultiStatus(w, r, &ms)
+	})).ServeHTTP(w, r)
+
+	if got, want := w.Code, http.StatusMultiStatus; got != want {
+		t.Errorf("want status %d, got %d", want, got)
+	}
+	if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("want content type %q, got %q", want, got)
+	}
+	want := `{"results":[` +
+		`{"id":"1","status":201,"body":{"name":"one"}},` +
+		`{"id":"2","status":404,"body":{"error":{"message":"thing not found","status":404}}},` +
+		`{"id":"3","status":204},` +
+		`{"id":"4","status":500,"body":{"error":{"message":"Internal Server Error","status":500}}}` +
+		`]}`
+	if got := w.Body.String(); got != want {
+		t.Errorf("want %s\ngot  %s", want, got)
+	}
+	if len(written) != 2 || written[0].StatusCode != 404 || written[1].Err == nil {
+		t.Errorf("want 2 errors written, got %+v", written)
+	}
+}
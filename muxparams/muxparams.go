@@ -0,0 +1,13 @@
+// Package muxparams makes the path variables set by the gorilla/mux
+// router available using httpapi.PathValues. Import it for its side effects:
+//  import _ "github.com/jjeffery/httpapi/muxparams"
+package muxparams
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jjeffery/httpapi"
+)
+
+func init() {
+	httpapi.RegisterPathParams(mux.Vars)
+}
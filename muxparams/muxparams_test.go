@@ -0,0 +1,25 @@
+package muxparams
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jjeffery/httpapi"
+)
+
+func TestPathValues(t *testing.T) {
+	var id int
+	var name string
+	router := mux.NewRouter()
+	router.HandleFunc("/things/{id}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		path := httpapi.PathValues(r)
+		id = path.GetInt("id")
+		name = path.GetString("name")
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/things/13/alice", nil))
+	if id != 13 || name != "alice" {
+		t.Errorf("want 13 alice, got %d %s", id, name)
+	}
+}
@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NormalizePathConfig contains the configuration for NormalizePath.
+type NormalizePathConfig struct {
+	// TrailingSlash adds a trailing slash to paths that do not have one,
+	// for routes that are registered with a trailing slash. By default,
+	// trailing slashes are removed.
+	TrailingSlash bool
+
+	// Rewrite passes the request with the normalized path to the next
+	// handler, instead of redirecting the client to the normalized path
+	// with a 308 (Permanent Redirect) status.
+	Rewrite bool
+}
+
+// NormalizePath returns middleware that normalizes the path of the request,
+// by replacing duplicate slashes with a single slash, and removing any trailing
+// slash (or adding one, see NormalizePathConfig), so that routes registered as
+// "/things/{id}" match requests for "/things//123/" from sloppy clients. The
+// root path "/" is never changed.
+//
+// By default, the client is redirected to the normalized path, with the same
+// query string, using a 308 (Permanent Redirect) status so that the method and
+// body of the request are not changed. If config.Rewrite is set, the request is
+// passed to the next handler with the normalized path instead.
+//
+// When used with http.ServeMux, the trailing slash configuration must match
+// the patterns, otherwise the redirects that http.ServeMux sends to add a
+// trailing slash to paths of subtree patterns, eg "/files/", never end.
+func NormalizePath(config NormalizePathConfig) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			escaped := r.URL.EscapedPath()
+			normalized := normalizePath(escaped, config.TrailingSlash)
+			if normalized == escaped {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if !config.Rewrite {
+				location := normalized
+				if r.URL.RawQuery != "" {
+					location += "?" + r.URL.RawQuery
+				}
+				w.Header().Set("Location", location)
+				w.WriteHeader(http.StatusPermanentRedirect)
+				return
+			}
+			path, err := url.PathUnescape(normalized)
+			if err != nil {
+				// cannot happen, because the escaped path was valid
+				h.ServeHTTP(w, r)
+				return
+			}
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = path
+			r2.URL.RawPath = normalized
+			h.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// normalizePath removes empty segments from the escaped path, and
+// adds or removes the trailing slash. Paths that do not start with
+// a slash, such as "*", are not changed.
+func normalizePath(escaped string, trailingSlash bool) string {
+	if !strings.HasPrefix(escaped, "/") {
+		return escaped
+	}
+	segments := strings.FieldsFunc(escaped, func(c rune) bool { return c == '/' })
+	if len(segments) == 0 {
+		return "/"
+	}
+	normalized := "/" + strings.Join(segments, "/")
+	if trailingSlash {
+		normalized += "/"
+	}
+	return normalized
+}
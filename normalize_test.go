@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizePath(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "path=%s escaped=%s", r.URL.Path, r.URL.EscapedPath())
+	})
+	tests := []struct {
+		config   NormalizePathConfig
+		target   string
+		status   int
+		location string
+		body     string
+	}{
+		{target: "/things/123", status: http.StatusOK, body: "path=/things/123 escaped=/things/123"},
+		{target: "/", status: http.StatusOK, body: "path=/ escaped=/"},
+		{target: "/things/123/", status: http.StatusPermanentRedirect, location: "/things/123"},
+		{target: "//things///123?a=1&b=2", status: http.StatusPermanentRedirect, location: "/things/123?a=1&b=2"},
+		{target: "//", status: http.StatusPermanentRedirect, location: "/"},
+		{target: "//example.com/", status: http.StatusPermanentRedirect, location: "/example.com"},
+		{
+			config:   NormalizePathConfig{TrailingSlash: true},
+			target:   "/things",
+			status:   http.StatusPermanentRedirect,
+			location: "/things/",
+		},
+		{
+			config: NormalizePathConfig{TrailingSlash: true},
+			target: "/things/",
+			status: http.StatusOK,
+			body:   "path=/things/ escaped=/things/",
+		},
+		{
+			config: NormalizePathConfig{Rewrite: true},
+			target: "/files//a%2Fb/",
+			status: http.StatusOK,
+			body:   "path=/files/a/b escaped=/files/a%2Fb",
+		},
+		{
+			config: NormalizePathConfig{Rewrite: true, TrailingSlash: true},
+			target: "/things//123?x=1",
+			status: http.StatusOK,
+			body:   "path=/things/123/ escaped=/things/123/",
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("POST", tt.target, nil)
+		w := httptest.NewRecorder()
+		NormalizePath(tt.config)(handler).ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("Location"), tt.location; got != want {
+			t.Errorf("%d: location: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Body.String(), tt.body; got != want {
+			t.Errorf("%d: body: want %q, got %q", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package httpapi
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jjeffery/errkind"
+)
+
+// NotFoundHandler returns a handler that sends a 404 (Not Found) error
+// to the client using WriteError. It can be used by routers that otherwise
+// send a plain text or HTML response when no route matches the request:
+//  router := mux.NewRouter()                          // gorilla/mux
+//  router.NotFoundHandler = httpapi.NotFoundHandler()
+//
+//  r := chi.NewRouter()                               // chi
+//  r.NotFound(httpapi.NotFoundHandler().ServeHTTP)
+//
+// For http.ServeMux, use WrapServeMux.
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, errkind.Public("not found", http.StatusNotFound))
+	})
+}
+
+// MethodNotAllowedHandler returns a handler that sends a 405 (Method Not
+// Allowed) error to the client using WriteError. The Allow header is set to
+// the allowed methods, with HEAD added if GET is allowed. If no methods are
+// specified, any Allow header already set by the router is sent unchanged.
+// For example:
+//  r := chi.NewRouter()
+//  r.MethodNotAllowed(httpapi.MethodNotAllowedHandler().ServeHTTP)
+func MethodNotAllowedHandler(allowed ...string) http.Handler {
+	allow := AllowHeader(allowed...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allow != "" {
+			w.Header().Set("Allow", allow)
+		}
+		WriteError(w, r, errkind.Public("method not allowed", http.StatusMethodNotAllowed))
+	})
+}
+
+// WrapServeMux returns a handler that passes requests to mux, but sends
+// the 404 (Not Found) and 405 (Method Not Allowed) responses for requests
+// that do not match any pattern using WriteError, instead of the plain text
+// responses that http.ServeMux sends. The Allow header is sent with 405
+// responses. Redirects sent by mux, eg to add a trailing slash, are unchanged.
+func WrapServeMux(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// No pattern matched, so find out what mux would send. The
+		// handler is internal to http.ServeMux, and only sets headers.
+		hw := &headerWriter{header: make(http.Header)}
+		h.ServeHTTP(hw, r)
+		switch hw.status {
+		case http.StatusNotFound:
+			NotFoundHandler().ServeHTTP(w, r)
+		case http.StatusMethodNotAllowed:
+			MethodNotAllowedHandler(hw.header.Values("Allow")...).ServeHTTP(w, r)
+		default:
+			mux.ServeHTTP(w, r)
+		}
+	})
+}
+
+// headerWriter records the status and header of a response,
+// and discards the body.
+type headerWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *headerWriter) Header() http.Header { return w.header }
+
+func (w *headerWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(p), nil
+}
+
+func (w *headerWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// AllowHeader returns the value of the Allow header for the methods, which
+// can include comma-separated lists of methods. The methods are sorted, and
+// HEAD is added if GET is allowed. Routers can use it so that the format of
+// the header is consistent with MethodNotAllowedHandler.
+func AllowHeader(methods ...string) string {
+	set := make(map[string]bool)
+	for _, m := range methods {
+		for _, method := range strings.Split(m, ",") {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if method == "" {
+				continue
+			}
+			set[method] = true
+			if method == http.MethodGet {
+				set[http.MethodHead] = true
+			}
+		}
+	}
+	list := make([]string, 0, len(set))
+	for method := range set {
+		list = append(list, method)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrapServeMux(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("DELETE /things/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /dir/", func(w http.ResponseWriter, r *http.Request) {})
+	h := WrapServeMux(mux)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		allow  string
+		json   bool
+
+		// status is 301 or 307, depending on the Go version
+		redirect bool
+	}{
+		{method: "GET", path: "/things/1", status: http.StatusOK},
+		{method: "GET", path: "/other", status: http.StatusNotFound, json: true},
+		{method: "PUT", path: "/things/1", status: http.StatusMethodNotAllowed, allow: "DELETE, GET, HEAD", json: true},
+		{method: "GET", path: "/dir", redirect: true},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if tt.redirect {
+			if w.Code/100 != 3 {
+				t.Errorf("%d: status: want redirect, got %d", i, w.Code)
+			}
+		} else if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("Allow"), tt.allow; got != want {
+			t.Errorf("%d: allow: want %q, got %q", i, want, got)
+		}
+		if tt.json {
+			var body struct {
+				Error struct {
+					Status int `json:"status"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Errorf("%d: %v", i, err)
+			} else if got, want := body.Error.Status, tt.status; got != want {
+				t.Errorf("%d: body status: want %d, got %d", i, want, got)
+			}
+		}
+	}
+}
+
+func TestMethodNotAllowedHandler(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		preset  string
+		want    string
+	}{
+		{allowed: []string{"GET", "post"}, want: "GET, HEAD, POST"},
+		{allowed: []string{"PUT, DELETE"}, want: "DELETE, PUT"},
+		{preset: "GET, OPTIONS", want: "GET, OPTIONS"},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		if tt.preset != "" {
+			w.Header().Set("Allow", tt.preset)
+		}
+		MethodNotAllowedHandler(tt.allowed...).ServeHTTP(w, httptest.NewRequest("PATCH", "/", nil))
+		if got, want := w.Code, http.StatusMethodNotAllowed; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("Allow"), tt.want; got != want {
+			t.Errorf("%d: allow: want %q, got %q", i, want, got)
+		}
+	}
+}
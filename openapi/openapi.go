@@ -0,0 +1,301 @@
+// Package openapi generates an OpenAPI 3 document describing the
+// operations of a JSON API, from the Go types of the handlers that
+// implement the operations.
+//
+// Operations are registered using Handle, which returns a handler in the
+// same way as httpapi.Handle, and records the method, path, request and
+// response types of the operation. For example:
+//  var api = &openapi.API{Title: "Things", Version: "1.0"}
+//
+//  mux.Handle("GET /things", openapi.Handle(api, "GET /things", listThings,
+//      openapi.Summary("List things")))
+//  mux.Handle("POST /things", openapi.Handle(api, "POST /things", createThing))
+//  mux.Handle("GET /things/{id}", openapi.Handle(api, "GET /things/{id}", getThing))
+//  mux.Handle("GET /openapi.json", api.Handler())
+//
+// The document describes the path parameters in the pattern, the request
+// body or query string parameters (see Handle), the response body, and the
+// error response sent by httpapi.WriteError, which is described by the
+// "ErrorResponse" schema.
+package openapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jjeffery/httpapi"
+)
+
+// Version is the version of the OpenAPI specification
+// that the generated document conforms to.
+const Version = "3.0.3"
+
+// API describes a JSON API. The fields are used in the info object
+// of the generated document. It is safe for concurrent use.
+type API struct {
+	Title       string
+	Version     string
+	Description string
+
+	mutex      sync.Mutex
+	operations []*Operation
+}
+
+// Operation describes an operation registered using Handle.
+type Operation struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+
+	reqType  reflect.Type
+	respType reflect.Type
+	query    bool // request type describes the query string
+}
+
+// An Option sets optional details of an operation.
+type Option func(*Operation)
+
+// Summary returns an option that sets the summary of the operation.
+func Summary(summary string) Option {
+	return func(op *Operation) {
+		op.Summary = summary
+	}
+}
+
+// Description returns an option that sets the description of the operation.
+func Description(description string) Option {
+	return func(op *Operation) {
+		op.Description = description
+	}
+}
+
+// OperationID returns an option that sets the unique ID of the operation.
+func OperationID(id string) Option {
+	return func(op *Operation) {
+		op.OperationID = id
+	}
+}
+
+// Tags returns an option that sets the tags of the operation, which are
+// used to group operations in documentation.
+func Tags(tags ...string) Option {
+	return func(op *Operation) {
+		op.Tags = tags
+	}
+}
+
+// Handle registers the operation with the API, and returns a handler that
+// calls fn. The pattern has the same format as patterns for http.ServeMux,
+// and must include the method, eg "GET /things/{id}".
+//
+// For POST, PUT and PATCH operations, the handler is the same as the
+// handler returned by httpapi.Handle, and Req describes the JSON request
+// body. For other methods, which do not usually have a request body, Req
+// is populated from the query string in the same way as httpapi.QueryAs,
+// and its fields describe the query string parameters.
+//
+// Handle panics if the pattern does not have a method, or if an
+// operation is already registered with the same method and path.
+func Handle[Req, Resp any](api *API, pattern string, fn func(ctx context.Context, req *Req) (*Resp, error), options ...Option) http.Handler {
+	method, path, ok := strings.Cut(strings.TrimSpace(pattern), " ")
+	if !ok || method == "" || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+		panic(fmt.Sprintf("openapi: pattern %q must have a method and a path", pattern))
+	}
+	op := &Operation{
+		Method:   strings.ToUpper(method),
+		Path:     openAPIPath(strings.TrimSpace(path)),
+		reqType:  reflect.TypeOf((*Req)(nil)).Elem(),
+		respType: reflect.TypeOf((*Resp)(nil)).Elem(),
+		query:    !hasRequestBody(method),
+	}
+	for _, opt := range options {
+		opt(op)
+	}
+	api.add(op)
+
+	if !op.query {
+		return httpapi.Handle(fn)
+	}
+	return httpapi.Handle(func(ctx context.Context, _ *struct{}) (*Resp, error) {
+		req, err := httpapi.QueryAs[Req](httpapi.RequestFromContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		return fn(ctx, &req)
+	})
+}
+
+// Operations returns the operations registered with the API,
+// sorted by path and method.
+func (api *API) Operations() []*Operation {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	ops := append([]*Operation(nil), api.operations...)
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops
+}
+
+// Document returns the OpenAPI document for the API, which
+// can be marshalled to JSON.
+func (api *API) Document() map[string]interface{} {
+	g := newSchemaGen()
+
+	// httpapi.Error has a MarshalJSON method that wraps the error in an
+	// object, so its schema cannot be generated in the usual way
+	errorType := reflect.TypeOf(httpapi.Error{})
+	g.names[errorType] = "Error"
+	g.schemas["Error"] = g.structSchema(errorType)
+	g.schemas["ErrorResponse"] = map[string]interface{}{
+		"type":     "object",
+		"required": []string{"error"},
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{"$ref": refPrefix + "Error"},
+		},
+	}
+
+	paths := make(map[string]interface{})
+	for _, op := range api.Operations() {
+		item, _ := paths[op.Path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = op.document(g)
+	}
+
+	info := map[string]interface{}{
+		"title":   api.Title,
+		"version": api.Version,
+	}
+	if api.Description != "" {
+		info["description"] = api.Description
+	}
+	return map[string]interface{}{
+		"openapi": Version,
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+		},
+	}
+}
+
+// Handler returns a handler that sends the OpenAPI document as JSON.
+func (api *API) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpapi.WriteResponse(w, r, api.Document())
+	})
+}
+
+func (api *API) add(op *Operation) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	for _, existing := range api.operations {
+		if existing.Method == op.Method && existing.Path == op.Path {
+			panic(fmt.Sprintf("openapi: %s %s already registered", op.Method, op.Path))
+		}
+	}
+	api.operations = append(api.operations, op)
+}
+
+// document returns the operation object for the operation.
+func (op *Operation) document(g *schemaGen) map[string]interface{} {
+	doc := make(map[string]interface{})
+	if op.OperationID != "" {
+		doc["operationId"] = op.OperationID
+	}
+	if op.Summary != "" {
+		doc["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		doc["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		doc["tags"] = op.Tags
+	}
+
+	var params []interface{}
+	for _, name := range pathParams(op.Path) {
+		params = append(params, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	if op.query {
+		params = append(params, g.queryParams(op.reqType)...)
+	} else {
+		doc["requestBody"] = map[string]interface{}{
+			"content": jsonContent(g.schema(op.reqType)),
+		}
+	}
+	if len(params) > 0 {
+		doc["parameters"] = params
+	}
+
+	errorResponse := map[string]interface{}{
+		"description": "Error",
+		"content":     jsonContent(map[string]interface{}{"$ref": refPrefix + "ErrorResponse"}),
+	}
+	doc["responses"] = map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "Success",
+			"content":     jsonContent(g.schema(op.respType)),
+		},
+		"204": map[string]interface{}{
+			"description": "No content",
+		},
+		"default": errorResponse,
+	}
+	return doc
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": schema,
+		},
+	}
+}
+
+// hasRequestBody reports whether requests with the method have a JSON body.
+func hasRequestBody(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+// openAPIPath converts a http.ServeMux path pattern to an OpenAPI path.
+// OpenAPI has no equivalent to a wildcard that matches the remainder of
+// the path, eg "{path...}", so it is described as a single parameter.
+func openAPIPath(path string) string {
+	path = strings.TrimSuffix(path, "{$}")
+	return strings.ReplaceAll(path, "...}", "}")
+}
+
+// pathParams returns the names of the parameters in the path.
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, segment[1:len(segment)-1])
+		}
+	}
+	return names
+}
@@ -0,0 +1,188 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type thing struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name,omitempty"`
+	Created  time.Time         `json:"created"`
+	Count    int32             `json:"count,string"`
+	Parent   *thing            `json:"parent,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Data     []byte            `json:"data,omitempty"`
+	internal string
+	Ignored  string `json:"-"`
+	audit
+}
+
+type audit struct {
+	UpdatedBy string `json:"updatedBy,omitempty"`
+}
+
+type listThingsRequest struct {
+	Search string   `query:"q"`
+	Limit  *int     `query:"limit"`
+	IDs    []string `query:"ids,csv"`
+	Kind   string   `query:"kind,required"`
+}
+
+type createThingRequest struct {
+	Name string `json:"name"`
+}
+
+type thingList struct {
+	Items []*thing `json:"items"`
+}
+
+func newTestAPI() (*API, http.Handler) {
+	api := &API{Title: "Things", Version: "1.0"}
+	mux := http.NewServeMux()
+	mux.Handle("GET /things", Handle(api, "GET /things", func(ctx context.Context, req *listThingsRequest) (*thingList, error) {
+		return &thingList{Items: []*thing{{ID: req.Kind + ":" + req.Search}}}, nil
+	}, Summary("List things"), Tags("things"), OperationID("listThings")))
+	mux.Handle("POST /things", Handle(api, "POST /things", func(ctx context.Context, req *createThingRequest) (*thing, error) {
+		return &thing{ID: "1", Name: req.Name}, nil
+	}))
+	mux.Handle("GET /things/{id}", Handle(api, "GET /things/{id}", func(ctx context.Context, req *struct{}) (*thing, error) {
+		return nil, nil
+	}, Description("Get a thing")))
+	mux.Handle("GET /openapi.json", api.Handler())
+	return api, mux
+}
+
+// lookup returns the value at the path of keys in the document.
+func lookup(doc interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = m[key]
+	}
+	return doc
+}
+
+func TestDocument(t *testing.T) {
+	_, h := newTestAPI()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path []string
+		want interface{}
+	}{
+		{path: []string{"openapi"}, want: Version},
+		{path: []string{"info", "title"}, want: "Things"},
+		{path: []string{"paths", "/things", "get", "summary"}, want: "List things"},
+		{path: []string{"paths", "/things", "get", "operationId"}, want: "listThings"},
+		{path: []string{"paths", "/things", "get", "requestBody"}, want: nil},
+		{
+			path: []string{"paths", "/things", "post", "requestBody", "content", "application/json", "schema", "$ref"},
+			want: "#/components/schemas/createThingRequest",
+		},
+		{
+			path: []string{"paths", "/things", "get", "responses", "200", "content", "application/json", "schema", "$ref"},
+			want: "#/components/schemas/thingList",
+		},
+		{
+			path: []string{"paths", "/things", "get", "responses", "default", "content", "application/json", "schema", "$ref"},
+			want: "#/components/schemas/ErrorResponse",
+		},
+		{path: []string{"paths", "/things/{id}", "get", "description"}, want: "Get a thing"},
+		{path: []string{"components", "schemas", "thing", "properties", "created", "format"}, want: "date-time"},
+		{path: []string{"components", "schemas", "thing", "properties", "count", "type"}, want: "string"},
+		{path: []string{"components", "schemas", "thing", "properties", "parent", "$ref"}, want: "#/components/schemas/thing"},
+		{path: []string{"components", "schemas", "thing", "properties", "labels", "additionalProperties", "type"}, want: "string"},
+		{path: []string{"components", "schemas", "thing", "properties", "data", "format"}, want: "byte"},
+		{path: []string{"components", "schemas", "thing", "properties", "updatedBy", "type"}, want: "string"},
+		{path: []string{"components", "schemas", "thing", "properties", "internal"}, want: nil},
+		{path: []string{"components", "schemas", "thing", "properties", "Ignored"}, want: nil},
+		{path: []string{"components", "schemas", "thing", "required"}, want: []interface{}{"id", "created", "count"}},
+		{path: []string{"components", "schemas", "Error", "properties", "errors", "items", "$ref"}, want: "#/components/schemas/FieldError"},
+		{path: []string{"components", "schemas", "ErrorResponse", "properties", "error", "$ref"}, want: "#/components/schemas/Error"},
+	}
+	for i, tt := range tests {
+		got := lookup(doc, tt.path...)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: %s: want %v, got %v", i, strings.Join(tt.path, "."), tt.want, got)
+		}
+	}
+
+	params, _ := lookup(doc, "paths", "/things", "get", "parameters").([]interface{})
+	var names []string
+	for _, p := range params {
+		names = append(names, lookup(p, "name").(string))
+	}
+	if want := []string{"q", "limit", "ids", "kind"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("query parameters: want %v, got %v", want, names)
+	}
+	if got := lookup(params[3], "required"); got != true {
+		t.Errorf("kind: want required, got %v", got)
+	}
+	if got := lookup(params[2], "explode"); got != false {
+		t.Errorf("ids: want explode false, got %v", got)
+	}
+	params, _ = lookup(doc, "paths", "/things/{id}", "get", "parameters").([]interface{})
+	if len(params) != 1 || lookup(params[0], "in") != "path" || lookup(params[0], "name") != "id" {
+		t.Errorf("path parameters: got %v", params)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	_, h := newTestAPI()
+	tests := []struct {
+		method string
+		target string
+		body   string
+		status int
+		want   string
+	}{
+		{method: "GET", target: "/things?kind=a&q=b", status: http.StatusOK, want: `"id":"a:b"`},
+		{method: "GET", target: "/things?q=b", status: http.StatusBadRequest},
+		{method: "POST", target: "/things", body: `{"name":"widget"}`, status: http.StatusOK, want: `"name":"widget"`},
+		{method: "GET", target: "/things/1", status: http.StatusNoContent},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d: %s", i, want, got, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%d: want body containing %s, got %s", i, tt.want, w.Body.String())
+		}
+	}
+}
+
+func TestHandlePanics(t *testing.T) {
+	fn := func(ctx context.Context, req *struct{}) (*struct{}, error) { return nil, nil }
+	api := &API{}
+	Handle(api, "GET /things", fn)
+	for i, pattern := range []string{"/things", "GET things", "GET /things"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%d: %q: want panic", i, pattern)
+				}
+			}()
+			Handle(api, pattern, fn)
+		}()
+	}
+}
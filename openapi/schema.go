@@ -0,0 +1,233 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// refPrefix is the prefix of references to schemas in the components object.
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// invalidNameChars matches characters that are not allowed in component names.
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// schemaGen generates schemas for Go types. Named struct types are added
+// to the components of the document, and referred to by name.
+type schemaGen struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func newSchemaGen() *schemaGen {
+	return &schemaGen{
+		schemas: make(map[string]interface{}),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schema returns the schema for values of type t marshalled as JSON.
+func (g *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if name, ok := g.names[t]; ok {
+		return map[string]interface{}{"$ref": refPrefix + name}
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// cannot tell what the JSON looks like
+		return map[string]interface{}{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// marshalled as base64
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": refPrefix + g.component(t)}
+	}
+	// interface and other types can have any value
+	return map[string]interface{}{}
+}
+
+// component adds the schema for the named struct type to the components
+// of the document, if it is not already present, and returns its name.
+func (g *schemaGen) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := invalidNameChars.ReplaceAllString(t.Name(), "_")
+	if _, ok := g.schemas[name]; ok {
+		// another type with the same name, so qualify with the package
+		pkg := t.PkgPath()
+		if i := strings.LastIndex(pkg, "/"); i >= 0 {
+			pkg = pkg[i+1:]
+		}
+		name = invalidNameChars.ReplaceAllString(pkg, "_") + "." + name
+	}
+	// register the name first, in case the type refers to itself
+	g.names[t] = name
+	g.schemas[name] = nil
+	g.schemas[name] = g.structSchema(t)
+	return name
+}
+
+// structSchema returns the schema for the struct type.
+func (g *schemaGen) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	g.addFields(t, properties, &required)
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the properties for the fields of the struct type,
+// including the fields of embedded structs, as encoding/json does.
+func (g *schemaGen) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		ft := field.Type
+		if field.Anonymous && opts[0] == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(ft, properties, required)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			// unexported field
+			continue
+		}
+		name := field.Name
+		if opts[0] != "" {
+			name = opts[0]
+		}
+		var omitempty, asString bool
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "omitempty", "omitzero":
+				omitempty = true
+			case "string":
+				asString = true
+			}
+		}
+		if asString {
+			properties[name] = map[string]interface{}{"type": "string"}
+		} else {
+			properties[name] = g.schema(ft)
+		}
+		if !omitempty && ft.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// queryParams returns the parameter objects for the query string
+// parameters described by the struct type t, which has the same
+// format as the types used with httpapi.QueryAs.
+func (g *schemaGen) queryParams(t reflect.Type) []interface{} {
+	var params []interface{}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, hasTag := field.Tag.Lookup("query")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && !hasTag && field.Type.Kind() == reflect.Struct {
+			params = append(params, g.queryParams(field.Type)...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := field.Name
+		if opts[0] != "" {
+			name = opts[0]
+		}
+		param := map[string]interface{}{
+			"name":   name,
+			"in":     "query",
+			"schema": g.querySchema(field.Type),
+		}
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "csv":
+				param["style"] = "form"
+				param["explode"] = false
+			case "required":
+				param["required"] = true
+			}
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// querySchema returns the schema for a query string parameter of type t.
+func (g *schemaGen) querySchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(time.Duration(0)):
+		return map[string]interface{}{"type": "string", "example": "1m30s"}
+	case t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8:
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case t.Kind() == reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": g.querySchema(t.Elem())}
+	}
+	return g.schema(t)
+}
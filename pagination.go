@@ -0,0 +1,185 @@
+package httpapi
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Pagination contains the pagination parameters of a request for a
+// list of items. Clients can specify either a page number or an offset,
+// together with a limit on the number of items returned.
+type Pagination struct {
+	Offset int // Number of items to skip
+	Limit  int // Maximum number of items to return
+	Page   int // One-based page number, or zero if the client specified an offset
+}
+
+// GetPagination returns the pagination parameters from the query string.
+// The parameters are "limit" and either "page" (starting at one) or "offset"
+// (starting at zero). If the client specifies a page, then Offset is calculated
+// from the page number and the limit.
+//
+// If the limit is not specified, defaultLimit is used. A limit less than one or
+// greater than maxLimit is invalid, as is a page number so large that the
+// offset cannot be represented. Invalid values, including specifying both
+// "page" and "offset", are reported by the Err method.
+func (v *Values) GetPagination(defaultLimit, maxLimit int) Pagination {
+	p := Pagination{Limit: defaultLimit}
+
+	if limit, ok := v.parseInt("limit"); ok {
+		if limit < 1 || limit > maxLimit {
+			v.invalid("limit", fmt.Sprintf("must be between 1 and %d", maxLimit))
+		} else {
+			p.Limit = limit
+		}
+	}
+
+	page, hasPage := v.parseInt("page")
+	offset, hasOffset := v.parseInt("offset")
+	switch {
+	case hasPage && hasOffset:
+		v.invalid("page", "cannot be used with offset")
+		v.invalid("offset", "cannot be used with page")
+	case hasPage:
+		if page < 1 {
+			v.invalid("page", "must be at least 1")
+		} else if p.Limit > 0 && page-1 > math.MaxInt/p.Limit {
+			// the offset would overflow
+			v.invalid("page", "is too large")
+		} else {
+			p.Page = page
+			p.Offset = (page - 1) * p.Limit
+		}
+	case hasOffset:
+		if offset < 0 {
+			v.invalid("offset", "must not be negative")
+		} else {
+			p.Offset = offset
+		}
+	}
+
+	return p
+}
+
+// LinkHeader returns the value of an RFC 5988 Link header with links to
+// the first, previous, next and last pages. The links are relative to the
+// request URL and preserve the other query string parameters.
+//
+// The total parameter is the total number of items available. If total is
+// negative, the total is unknown: the "last" link is omitted, and the "next"
+// link is always included.
+func (p Pagination) LinkHeader(r *http.Request, total int) string {
+	limit := p.Limit
+	if limit < 1 {
+		limit = 1
+	}
+
+	var links []string
+	addLink := func(rel string, offset int) {
+		query := r.URL.Query()
+		if p.Page > 0 {
+			query.Set("page", strconv.Itoa(offset/limit+1))
+			query.Del("offset")
+		} else {
+			query.Set("offset", strconv.Itoa(offset))
+			query.Del("page")
+		}
+		query.Set("limit", strconv.Itoa(limit))
+		u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", u.String(), rel))
+	}
+
+	addLink("first", 0)
+	if p.Offset > 0 {
+		prev := p.Offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		addLink("prev", prev)
+	}
+	// compare without calculating the next offset, which
+	// can overflow for very large offsets
+	if p.Offset < math.MaxInt-limit && (total < 0 || p.Offset < total-limit) {
+		addLink("next", p.Offset+limit)
+	}
+	if total >= 0 {
+		last := 0
+		if total > 0 {
+			last = ((total - 1) / limit) * limit
+		}
+		addLink("last", last)
+	}
+
+	return strings.Join(links, ", ")
+}
+
+// PageInfo contains pagination metadata for a list of items.
+type PageInfo struct {
+	Total   int `json:"total"`    // Total number of items available
+	Page    int `json:"page"`     // One-based page number
+	PerPage int `json:"per_page"` // Maximum number of items per page
+}
+
+// PageInfo returns the pagination metadata for the pagination parameters,
+// given the total number of items available. If the client specified an
+// offset, the page number is the page containing the item at that offset.
+func (p Pagination) PageInfo(total int) PageInfo {
+	info := PageInfo{
+		Total:   total,
+		Page:    p.Page,
+		PerPage: p.Limit,
+	}
+	if info.Page == 0 && p.Limit > 0 {
+		info.Page = p.Offset/p.Limit + 1
+	}
+	return info
+}
+
+// SetHeaders sets the X-Total-Count, X-Page and X-Per-Page headers.
+func (info PageInfo) SetHeaders(h http.Header) {
+	h.Set("X-Total-Count", strconv.Itoa(info.Total))
+	h.Set("X-Page", strconv.Itoa(info.Page))
+	h.Set("X-Per-Page", strconv.Itoa(info.PerPage))
+}
+
+// WritePage sends the body as JSON to the HTTP client in the same way as
+// WriteResponse, and includes a Link header with links to the first, previous,
+// next and last pages. See Pagination.LinkHeader for the meaning of total.
+// If total is not negative, the pagination metadata headers are also included
+// (see PageInfo.SetHeaders).
+func WritePage(w http.ResponseWriter, r *http.Request, p Pagination, total int, body interface{}) {
+	if _, ok := body.(error); !ok {
+		w.Header().Set("Link", p.LinkHeader(r, total))
+		if total >= 0 {
+			p.PageInfo(total).SetHeaders(w.Header())
+		}
+	}
+	WriteResponse(w, r, body)
+}
+
+// WritePageEnvelope sends the items to the HTTP client inside a JSON envelope
+// that includes the pagination metadata. The pagination metadata headers are
+// also included (see PageInfo.SetHeaders). The JSON is similar to:
+//  {
+//      "data": [ ... ],
+//      "pagination": {"total": 95, "page": 2, "per_page": 20}
+//  }
+func WritePageEnvelope(w http.ResponseWriter, r *http.Request, info PageInfo, items interface{}) {
+	if err, ok := items.(error); ok {
+		WriteError(w, r, err)
+		return
+	}
+	envelope := struct {
+		Data       interface{} `json:"data"`
+		Pagination PageInfo    `json:"pagination"`
+	}{
+		Data:       items,
+		Pagination: info,
+	}
+	info.SetHeaders(w.Header())
+	WriteResponse(w, r, envelope)
+}
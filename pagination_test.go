@@ -0,0 +1,190 @@
+package httpapi
+
+import (
+	"math"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestGetPagination(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    Pagination
+		wantErr bool
+	}{
+		{
+			url:  "/items",
+			want: Pagination{Offset: 0, Limit: 20},
+		},
+		{
+			url:  "/items?page=3&limit=10",
+			want: Pagination{Offset: 20, Limit: 10, Page: 3},
+		},
+		{
+			url:  "/items?offset=15",
+			want: Pagination{Offset: 15, Limit: 20},
+		},
+		{
+			url:     "/items?limit=1000",
+			want:    Pagination{Limit: 20},
+			wantErr: true,
+		},
+		{
+			url:     "/items?page=0",
+			want:    Pagination{Limit: 20},
+			wantErr: true,
+		},
+		{
+			url:     "/items?page=1&offset=0",
+			want:    Pagination{Limit: 20},
+			wantErr: true,
+		},
+		{
+			url:     "/items?offset=-1",
+			want:    Pagination{Limit: 20},
+			wantErr: true,
+		},
+		{
+			// offset would overflow
+			url:     "/items?page=" + strconv.Itoa(math.MaxInt/10+2) + "&limit=10",
+			want:    Pagination{Limit: 10},
+			wantErr: true,
+		},
+		{
+			url:  "/items?page=" + strconv.Itoa(math.MaxInt/10+1) + "&limit=10",
+			want: Pagination{Offset: math.MaxInt / 10 * 10, Limit: 10, Page: math.MaxInt/10 + 1},
+		},
+	}
+	for i, tt := range tests {
+		query := Query(httptest.NewRequest("GET", tt.url, nil))
+		got := query.GetPagination(20, 100)
+		if got != tt.want {
+			t.Errorf("%d: want %+v, got %+v", i, tt.want, got)
+		}
+		if err := query.Err(); (err != nil) != tt.wantErr {
+			t.Errorf("%d: want error %v, got %v", i, tt.wantErr, err)
+		}
+	}
+}
+
+func TestLinkHeader(t *testing.T) {
+	tests := []struct {
+		url   string
+		p     Pagination
+		total int
+		want  string
+	}{
+		{
+			url:   "/items?q=x&page=2&limit=10",
+			p:     Pagination{Offset: 10, Limit: 10, Page: 2},
+			total: 35,
+			want: `</items?limit=10&page=1&q=x>; rel="first", ` +
+				`</items?limit=10&page=1&q=x>; rel="prev", ` +
+				`</items?limit=10&page=3&q=x>; rel="next", ` +
+				`</items?limit=10&page=4&q=x>; rel="last"`,
+		},
+		{
+			url:   "/items?offset=0&limit=10",
+			p:     Pagination{Offset: 0, Limit: 10},
+			total: 10,
+			want: `</items?limit=10&offset=0>; rel="first", ` +
+				`</items?limit=10&offset=0>; rel="last"`,
+		},
+		{
+			url:   "/items?offset=5&limit=10",
+			p:     Pagination{Offset: 5, Limit: 10},
+			total: -1,
+			want: `</items?limit=10&offset=0>; rel="first", ` +
+				`</items?limit=10&offset=0>; rel="prev", ` +
+				`</items?limit=10&offset=15>; rel="next"`,
+		},
+		{
+			// next offset would overflow
+			url:   "/items?offset=" + strconv.Itoa(math.MaxInt-5) + "&limit=10",
+			p:     Pagination{Offset: math.MaxInt - 5, Limit: 10},
+			total: -1,
+			want: `</items?limit=10&offset=0>; rel="first", ` +
+				`</items?limit=10&offset=` + strconv.Itoa(math.MaxInt-15) + `>; rel="prev"`,
+		},
+		{
+			url:   "/items?offset=" + strconv.Itoa(math.MaxInt-5) + "&limit=10",
+			p:     Pagination{Offset: math.MaxInt - 5, Limit: 10},
+			total: math.MaxInt,
+			want: `</items?limit=10&offset=0>; rel="first", ` +
+				`</items?limit=10&offset=` + strconv.Itoa(math.MaxInt-15) + `>; rel="prev", ` +
+				`</items?limit=10&offset=` + strconv.Itoa((math.MaxInt-1)/10*10) + `>; rel="last"`,
+		},
+		{
+			// largest page accepted by GetPagination
+			url:   "/items?page=" + strconv.Itoa(math.MaxInt/10+1) + "&limit=10",
+			p:     Pagination{Offset: math.MaxInt / 10 * 10, Limit: 10, Page: math.MaxInt/10 + 1},
+			total: -1,
+			want: `</items?limit=10&page=1>; rel="first", ` +
+				`</items?limit=10&page=` + strconv.Itoa(math.MaxInt/10) + `>; rel="prev"`,
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		if got := tt.p.LinkHeader(r, tt.total); got != tt.want {
+			t.Errorf("%d: want\n%s\ngot\n%s", i, tt.want, got)
+		}
+	}
+}
+
+func TestWritePage(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/items", nil)
+	WritePage(w, r, Pagination{Limit: 10}, 5, []int{1, 2, 3, 4, 5})
+	if got := w.Header().Get("Link"); got == "" {
+		t.Errorf("want link header, got none")
+	}
+	if got, want := w.Body.String(), "[1,2,3,4,5]"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestPageInfo(t *testing.T) {
+	tests := []struct {
+		p     Pagination
+		total int
+		want  PageInfo
+	}{
+		{
+			p:     Pagination{Offset: 20, Limit: 10, Page: 3},
+			total: 95,
+			want:  PageInfo{Total: 95, Page: 3, PerPage: 10},
+		},
+		{
+			p:     Pagination{Offset: 25, Limit: 10},
+			total: 95,
+			want:  PageInfo{Total: 95, Page: 3, PerPage: 10},
+		},
+	}
+	for i, tt := range tests {
+		if got := tt.p.PageInfo(tt.total); got != tt.want {
+			t.Errorf("%d: want %+v, got %+v", i, tt.want, got)
+		}
+	}
+}
+
+func TestWritePageEnvelope(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/items", nil)
+	WritePageEnvelope(w, r, PageInfo{Total: 3, Page: 1, PerPage: 10}, []int{1, 2, 3})
+
+	want := map[string]string{
+		"X-Total-Count": "3",
+		"X-Page":        "1",
+		"X-Per-Page":    "10",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s: want %q, got %q", name, value, got)
+		}
+	}
+	wantBody := `{"data":[1,2,3],"pagination":{"total":3,"page":1,"per_page":10}}`
+	if got := w.Body.String(); got != wantBody {
+		t.Errorf("want %s, got %s", wantBody, got)
+	}
+}
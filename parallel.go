@@ -0,0 +1,148 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+// defaultParallelLimit is the maximum number of tasks run concurrently by Parallel.
+const defaultParallelLimit = 8
+
+// Parallel runs the tasks concurrently, with at most 8 tasks running at
+// any one time, and waits for them to complete. It is intended for handlers
+// that compose their response from several independent sub-queries.
+//
+// See ParallelLimit for details of how errors are handled.
+func Parallel(ctx context.Context, tasks ...func(context.Context) error) error {
+	return ParallelLimit(ctx, defaultParallelLimit, tasks...)
+}
+
+// ParallelLimit runs the tasks concurrently, with at most limit tasks running
+// at any one time, and waits for them to complete. If limit is less than one,
+// there is no limit on the number of tasks running concurrently.
+//
+// Each task is passed a context derived from ctx. If a task fails with a hard
+// failure, the context is cancelled so that the remaining tasks can finish early,
+// and any tasks that have not started are not run. A hard failure is any error
+// that does not have a public 4xx status (see the errkind package).
+//
+// If more than one task fails, the returned error contains all of the error
+// messages, and its cause is the most severe of the errors. Errors without a
+// public status are treated as 500 errors, and the highest status code is the
+// most severe. This means that the returned error can be passed directly to
+// WriteError, which will respond with the status and message of the most severe error.
+func ParallelLimit(ctx context.Context, limit int, tasks ...func(context.Context) error) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	if limit < 1 || limit > len(tasks) {
+		limit = len(tasks)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		mutex     sync.Mutex
+		errs      = make([]error, len(tasks))
+		cancelled bool
+		skipped   bool
+		sem       = make(chan struct{}, limit)
+	)
+
+	for i, task := range tasks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			skipped = true
+			break
+		}
+		wg.Add(1)
+		go func(i int, task func(context.Context) error) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := task(ctx)
+			if err == nil {
+				return
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			if cancelled && errors.Cause(err) == context.Canceled {
+				// Ignore errors caused by cancelling after a hard failure
+				// in another task. They only obscure the original error.
+				return
+			}
+			errs[i] = err
+			if parallelSeverity(err) >= 500 {
+				cancelled = true
+				cancel()
+			}
+		}(i, task)
+	}
+	wg.Wait()
+
+	var pe parallelError
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		pe.errs = append(pe.errs, err)
+		if pe.cause == nil || parallelSeverity(err) > parallelSeverity(pe.cause) {
+			pe.cause = err
+		}
+	}
+	switch len(pe.errs) {
+	case 0:
+		if skipped && !cancelled {
+			// parent context done before all tasks could be started
+			return ctx.Err()
+		}
+		return nil
+	case 1:
+		return pe.errs[0]
+	}
+	return &pe
+}
+
+// parallelSeverity returns the severity of an error returned by a parallel
+// task, which is its public status code, or 500 if the error does not have one.
+func parallelSeverity(err error) int {
+	cause := errors.Cause(err)
+	if _, ok := cause.(interface{ PublicStatusCode() }); ok {
+		if status := errkind.StatusCode(cause); status >= 400 && status <= 599 {
+			return status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// parallelError is returned when more than one parallel task fails.
+type parallelError struct {
+	errs  []error // errors in task order
+	cause error   // most severe error
+}
+
+// Error implements the error interface. The message includes the
+// messages of all of the errors.
+func (e *parallelError) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d errors: %s", len(e.errs), strings.Join(msgs, "; "))
+}
+
+// Cause returns the most severe error, which determines
+// the response sent by WriteError.
+func (e *parallelError) Cause() error {
+	return e.cause
+}
@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/errkind"
+)
+
+func TestParallel(t *testing.T) {
+	var count int32
+	task := func(ctx context.Context) error {
+		atomic.AddInt32(&count, 1)
+		return nil
+	}
+	if err := Parallel(context.Background(), task, task, task); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if count != 3 {
+		t.Errorf("want 3 tasks run, got %d", count)
+	}
+	if err := Parallel(context.Background()); err != nil {
+		t.Errorf("want no error for no tasks, got %v", err)
+	}
+}
+
+func TestParallelLimit(t *testing.T) {
+	var running, maxRunning int32
+	task := func(ctx context.Context) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+	var tasks []func(context.Context) error
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, task)
+	}
+	if err := ParallelLimit(context.Background(), 2, tasks...); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	if maxRunning > 2 {
+		t.Errorf("want at most 2 running, got %d", maxRunning)
+	}
+}
+
+func TestParallelErrors(t *testing.T) {
+	notFound := errkind.Public("not found", http.StatusNotFound)
+	badRequest := errkind.BadRequest("bad request")
+	internal := errors.New("database unavailable")
+
+	tests := []struct {
+		errs       []error
+		wantStatus int
+		wantMsg    string
+	}{
+		{
+			errs:       []error{nil, notFound},
+			wantStatus: http.StatusNotFound,
+			wantMsg:    "not found",
+		},
+		{
+			errs:       []error{badRequest, notFound},
+			wantStatus: http.StatusNotFound,
+			wantMsg:    "2 errors: bad request; not found",
+		},
+		{
+			errs:       []error{notFound, internal, nil},
+			wantStatus: http.StatusInternalServerError,
+			wantMsg:    "2 errors: not found; database unavailable",
+		},
+	}
+	for i, tt := range tests {
+		var tasks []func(context.Context) error
+		for _, err := range tt.errs {
+			err := err
+			tasks = append(tasks, func(ctx context.Context) error { return err })
+		}
+		err := ParallelLimit(context.Background(), 1, tasks...)
+		if err == nil {
+			t.Errorf("%d: want error, got nil", i)
+			continue
+		}
+		if got := err.Error(); got != tt.wantMsg {
+			t.Errorf("%d: want %q, got %q", i, tt.wantMsg, got)
+		}
+		if got := parallelSeverity(err); got != tt.wantStatus {
+			t.Errorf("%d: want status %d, got %d", i, tt.wantStatus, got)
+		}
+	}
+}
+
+func TestParallelCancelsOnHardFailure(t *testing.T) {
+	failure := errors.New("failed")
+	err := Parallel(context.Background(),
+		func(ctx context.Context) error {
+			return failure
+		},
+		func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+				return errors.New("not cancelled")
+			}
+		},
+	)
+	if err != failure {
+		t.Errorf("want %v, got %v", failure, err)
+	}
+}
@@ -0,0 +1,53 @@
+package httpapi
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/jjeffery/stringset"
+)
+
+// Params returns the parameters of the request from the path, the query
+// string and the form in the request body, with the same typed getters and
+// error reporting as Query. If a parameter is present in more than one place,
+// path parameters take precedence over form values, which take precedence
+// over values in the query string.
+//
+// The form is only parsed if the request has a form content type, ie
+// "application/x-www-form-urlencoded" or "multipart/form-data". The error
+// returned is that returned by Form if the form cannot be parsed.
+func Params(r *http.Request) (*Values, error) {
+	values := make(url.Values)
+	merge := func(src url.Values) {
+		for name, vals := range src {
+			values[name] = vals
+		}
+	}
+
+	merge(r.URL.Query())
+	if hasFormContentType(r) {
+		form, err := Form(r)
+		if err != nil {
+			return nil, err
+		}
+		merge(form.values)
+	}
+	merge(PathValues(r).values)
+
+	return &Values{
+		values:        values,
+		invalidParams: stringset.New(),
+		missingParams: stringset.New(),
+		source:        "request",
+	}, nil
+}
+
+func hasFormContentType(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		return true
+	}
+	return false
+}
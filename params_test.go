@@ -0,0 +1,46 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	var got struct {
+		id, limit int
+		name      string
+		err       error
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		params, err := Params(r)
+		if err != nil {
+			got.err = err
+			return
+		}
+		got.id = params.GetInt("id")
+		got.limit = params.GetInt("limit")
+		got.name = params.GetString("name")
+		params.GetInt("name")
+		got.err = params.Err()
+	})
+
+	r := httptest.NewRequest("POST", "/things/3?id=1&limit=10&name=query", strings.NewReader("id=2&name=form"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got.id != 3 {
+		t.Errorf("id: want path value 3, got %d", got.id)
+	}
+	if got.limit != 10 {
+		t.Errorf("limit: want query value 10, got %d", got.limit)
+	}
+	if got.name != "form" {
+		t.Errorf("name: want form value, got %q", got.name)
+	}
+	if got.err == nil || !strings.Contains(got.err.Error(), "invalid value(s) in request: name") {
+		t.Errorf("want invalid name error, got %v", got.err)
+	}
+}
@@ -0,0 +1,492 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+// PatchContentType is the content type of JSON Patch documents.
+const PatchContentType = "application/json-patch+json"
+
+// PatchOperation is an operation in a JSON Patch document (RFC 6902).
+type PatchOperation struct {
+	Op    string          `json:"op"`              // "add", "remove", "replace", "move", "copy" or "test"
+	Path  string          `json:"path"`            // JSON Pointer (RFC 6901) to the target location
+	From  string          `json:"from,omitempty"`  // JSON Pointer to the source location, for "move" and "copy"
+	Value json.RawMessage `json:"value,omitempty"` // Value for "add", "replace" and "test"
+}
+
+// Patch is a JSON Patch document (RFC 6902), which is a list of operations
+// to apply to a JSON document.
+//
+// A handler for a PATCH request typically reads the patch, checks that
+// the operations only modify the paths that clients are allowed to change,
+// and applies the patch to the resource:
+//  patch, err := httpapi.ReadPatch(r)
+//  if err != nil {
+//      return err
+//  }
+//  if err := patch.Allow("/name", "/tags"); err != nil {
+//      return err
+//  }
+//  if err := patch.ApplyTo(&thing); err != nil {
+//      return err
+//  }
+type Patch []PatchOperation
+
+// ReadPatch reads a JSON Patch document from the request body. The request
+// must have a content type of "application/json-patch+json", otherwise the
+// error has a status of 415 (Unsupported Media Type). If any of the operations
+// are invalid, the error is a *ValidationError with a status of 400 (Bad Request),
+// with a field error for each problem, eg "1.path: invalid JSON pointer".
+func ReadPatch(r *http.Request) (Patch, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != PatchContentType {
+		return nil, errkind.Public("content type must be "+PatchContentType, http.StatusUnsupportedMediaType)
+	}
+	var patch Patch
+	if err := ReadRequest(r, &patch); err != nil {
+		return nil, err
+	}
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// Validate checks that the operations of the patch are well formed. It is
+// called by ReadPatch. If there are invalid operations, the error is a
+// *ValidationError with a status of 400 (Bad Request).
+func (p Patch) Validate() error {
+	verr := ValidationError{Summary: "invalid JSON patch", Status: http.StatusBadRequest}
+	for i, op := range p {
+		if _, ok := parsePointer(op.Path); !ok {
+			verr.Add(opField(i, "path"), "invalid JSON pointer", "invalid")
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				verr.Add(opField(i, "value"), "is required", "required")
+			}
+		case "remove":
+		case "move", "copy":
+			if _, ok := parsePointer(op.From); !ok {
+				verr.Add(opField(i, "from"), "invalid JSON pointer", "invalid")
+			} else if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+				verr.Add(opField(i, "from"), "cannot move a value into itself", "invalid")
+			}
+		case "":
+			verr.Add(opField(i, "op"), "is required", "required")
+		default:
+			verr.Add(opField(i, "op"), "unknown operation", "invalid")
+		}
+	}
+	return verr.Err()
+}
+
+// Allow checks that the patch only modifies the locations in the document
+// that match the patterns. A pattern is a JSON Pointer, and "*" matches any
+// single reference token, eg "/addresses/*/postcode". A pattern also matches
+// all of the locations within the value that it refers to, so "/tags" allows
+// operations on "/tags/0" and "/tags/-". Operations that only read a location,
+// ie "test" and the source of "copy", are not checked.
+//
+// If any operation is not allowed, the error is a *ValidationError
+// with a status of 422 (Unprocessable Entity).
+func (p Patch) Allow(patterns ...string) error {
+	var allowed [][]string
+	for _, pattern := range patterns {
+		if tokens, ok := parsePointer(pattern); ok {
+			allowed = append(allowed, tokens)
+		}
+	}
+	isAllowed := func(path string) bool {
+		tokens, _ := parsePointer(path)
+		for _, pattern := range allowed {
+			if matchPointer(pattern, tokens) {
+				return true
+			}
+		}
+		return false
+	}
+	verr := ValidationError{Summary: "JSON patch not allowed"}
+	for i, op := range p {
+		if op.Op == "test" {
+			continue
+		}
+		if !isAllowed(op.Path) {
+			verr.Add(opField(i, "path"), "cannot be changed", "forbidden")
+		}
+		if op.Op == "move" && !isAllowed(op.From) {
+			verr.Add(opField(i, "from"), "cannot be changed", "forbidden")
+		}
+	}
+	return verr.Err()
+}
+
+// Apply applies the patch to the JSON document, and returns the patched
+// document. The patch is applied atomically: if any operation fails, including
+// a "test" operation, the error is a *ValidationError with a status of 422
+// (Unprocessable Entity) that describes the operation that failed.
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	var d patchDoc
+	if err := decodeJSON(doc, &d.root); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal JSON document")
+	}
+	for i, op := range p {
+		if err := d.apply(op); err != nil {
+			verr := ValidationError{Summary: "cannot apply JSON patch"}
+			field := "path"
+			if err == errPatchFrom {
+				field = "from"
+			}
+			code := "invalid"
+			if op.Op == "test" && err == errPatchTest {
+				code = "test_failed"
+			}
+			verr.Add(opField(i, field), err.Error(), code)
+			return nil, &verr
+		}
+	}
+	return json.Marshal(d.root)
+}
+
+// ApplyTo applies the patch to the value pointed to by v, by marshalling the
+// value as JSON, applying the patch to the JSON, and unmarshalling the patched
+// JSON into a new value, which replaces the value pointed to by v. If the
+// patch cannot be applied, or the patched document cannot be unmarshalled,
+// v is not changed, and the error has a status of 422 (Unprocessable Entity).
+func (p Patch) ApplyTo(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("httpapi: ApplyTo requires a non-nil pointer")
+	}
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal JSON document")
+	}
+	if doc, err = p.Apply(doc); err != nil {
+		return err
+	}
+	patched := reflect.New(rv.Elem().Type())
+	if err := json.Unmarshal(doc, patched.Interface()); err != nil {
+		return errkind.Public("patched document is invalid", http.StatusUnprocessableEntity)
+	}
+	rv.Elem().Set(patched.Elem())
+	return nil
+}
+
+// Errors applying patch operations, which are used as field error messages.
+var (
+	errPatchPath = errors.New("path does not exist")
+	errPatchFrom = errors.New("from does not exist")
+	errPatchTest = errors.New("test failed")
+	errPatchRoot = errors.New("cannot remove the whole document")
+)
+
+// patchDoc is a JSON document that patch operations are applied to.
+type patchDoc struct {
+	root interface{}
+}
+
+func (d *patchDoc) apply(op PatchOperation) error {
+	path, _ := parsePointer(op.Path)
+	switch op.Op {
+	case "add":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return err
+		}
+		return d.add(path, value)
+	case "remove":
+		_, err := d.remove(path)
+		return err
+	case "replace":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return err
+		}
+		if _, ok := d.get(path); !ok {
+			return errPatchPath
+		}
+		d.set(path, value)
+		return nil
+	case "move", "copy":
+		from, _ := parsePointer(op.From)
+		value, ok := d.get(from)
+		if !ok {
+			return errPatchFrom
+		}
+		if op.Op == "move" {
+			if op.Path == op.From {
+				// moving a value to where it is changes nothing
+				return nil
+			}
+			if _, err := d.remove(from); err != nil {
+				return errPatchFrom
+			}
+		} else {
+			value = deepCopy(value)
+		}
+		return d.add(path, value)
+	case "test":
+		value, err := decodeValue(op.Value)
+		if err != nil {
+			return err
+		}
+		actual, ok := d.get(path)
+		if !ok {
+			return errPatchPath
+		}
+		if !jsonEqual(actual, value) {
+			return errPatchTest
+		}
+	}
+	return nil
+}
+
+// get returns the value at the location.
+func (d *patchDoc) get(tokens []string) (interface{}, bool) {
+	v := d.root
+	for _, token := range tokens {
+		switch c := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = c[token]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, ok := arrayIndex(token, len(c))
+			if !ok {
+				return nil, false
+			}
+			v = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// set replaces the value at a location that exists.
+func (d *patchDoc) set(tokens []string, value interface{}) {
+	if len(tokens) == 0 {
+		d.root = value
+		return
+	}
+	parent, _ := d.get(tokens[:len(tokens)-1])
+	last := tokens[len(tokens)-1]
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		c[last] = value
+	case []interface{}:
+		i, _ := arrayIndex(last, len(c))
+		c[i] = value
+	}
+}
+
+// add adds the value at the location, as specified for the "add" operation.
+func (d *patchDoc) add(tokens []string, value interface{}) error {
+	if len(tokens) == 0 {
+		d.root = value
+		return nil
+	}
+	parent, ok := d.get(tokens[:len(tokens)-1])
+	if !ok {
+		return errPatchPath
+	}
+	last := tokens[len(tokens)-1]
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		c[last] = value
+	case []interface{}:
+		i := len(c)
+		if last != "-" {
+			if i, ok = arrayIndex(last, len(c)+1); !ok {
+				return errPatchPath
+			}
+		}
+		c = append(c, nil)
+		copy(c[i+1:], c[i:])
+		c[i] = value
+		d.set(tokens[:len(tokens)-1], c)
+	default:
+		return errPatchPath
+	}
+	return nil
+}
+
+// remove removes the value at the location, and returns it.
+func (d *patchDoc) remove(tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, errPatchRoot
+	}
+	parent, ok := d.get(tokens[:len(tokens)-1])
+	if !ok {
+		return nil, errPatchPath
+	}
+	last := tokens[len(tokens)-1]
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		value, ok := c[last]
+		if !ok {
+			return nil, errPatchPath
+		}
+		delete(c, last)
+		return value, nil
+	case []interface{}:
+		i, ok := arrayIndex(last, len(c))
+		if !ok {
+			return nil, errPatchPath
+		}
+		value := c[i]
+		d.set(tokens[:len(tokens)-1], append(c[:i:i], c[i+1:]...))
+		return value, nil
+	}
+	return nil, errPatchPath
+}
+
+// parsePointer returns the reference tokens of a JSON Pointer (RFC 6901).
+func parsePointer(pointer string) ([]string, bool) {
+	if pointer == "" {
+		return nil, true
+	}
+	if pointer[0] != '/' {
+		return nil, false
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' {
+				if j+1 >= len(token) || (token[j+1] != '0' && token[j+1] != '1') {
+					return nil, false
+				}
+				j++
+			}
+		}
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, true
+}
+
+// matchPointer reports whether the tokens of a pattern match the start of
+// the tokens of a location.
+func matchPointer(pattern, tokens []string) bool {
+	if len(pattern) > len(tokens) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses the token as an index of an array, which must be less than n.
+func arrayIndex(token string, n int) (int, bool) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i >= n {
+		return 0, false
+	}
+	return i, true
+}
+
+func opField(i int, name string) string {
+	return strconv.Itoa(i) + "." + name
+}
+
+// decodeJSON unmarshals the JSON, keeping numbers as json.Number
+// so that they are not changed by the patch.
+func decodeJSON(data []byte, v *interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func decodeValue(data json.RawMessage) (interface{}, error) {
+	var v interface{}
+	if err := decodeJSON(data, &v); err != nil {
+		return nil, errors.New("invalid value")
+	}
+	return v, nil
+}
+
+// deepCopy returns a copy of a value decoded from JSON.
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = deepCopy(value)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, value := range v {
+			a[i] = deepCopy(value)
+		}
+		return a
+	}
+	return v
+}
+
+// jsonEqual reports whether two values decoded from JSON are equal,
+// as specified for the "test" operation.
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			if other, ok := b[key]; !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		x, errx := a.Float64()
+		y, erry := b.Float64()
+		return errx == nil && erry == nil && x == y
+	}
+	return a == b
+}
@@ -0,0 +1,217 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+)
+
+func TestPatchApply(t *testing.T) {
+	tests := []struct {
+		doc     string
+		patch   string
+		want    string
+		wantErr string
+	}{
+		// examples from RFC 6902 appendix A
+		{
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:  `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			want:  `{"foo":"bar"}`,
+		},
+		{
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			// moving a value to the same location changes nothing
+			doc:   `{"foo":["all","grass"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/1"},{"op":"move","from":"","path":""}]`,
+			want:  `{"foo":["all","grass"]}`,
+		},
+		{
+			doc:   `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			doc:     `{"baz":"qux"}`,
+			patch:   `[{"op":"test","path":"/baz","value":"bar"}]`,
+			wantErr: "cannot apply JSON patch: 0.path: test failed",
+		},
+		{
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:  `{"child":{"grandchild":{}},"foo":"bar"}`,
+		},
+		{
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			wantErr: "cannot apply JSON patch: 0.path: path does not exist",
+		},
+		{
+			doc:   `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":10}]`,
+			want:  `{"/":9,"~1":10}`,
+		},
+		{
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:  `{"foo":["bar",["abc","def"]]}`,
+		},
+		// other cases
+		{
+			// copied values are independent of the original
+			doc:   `{"a":{"b":1}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`,
+			want:  `{"a":{"b":1},"c":{"b":2}}`,
+		},
+		{
+			// large numbers are not changed
+			doc:   `{"id":12345678901234567890}`,
+			patch: `[{"op":"add","path":"/x","value":1}]`,
+			want:  `{"id":12345678901234567890,"x":1}`,
+		},
+		{
+			doc:     `{"a":[1,2]}`,
+			patch:   `[{"op":"remove","path":"/a/01"}]`,
+			wantErr: "cannot apply JSON patch: 0.path: path does not exist",
+		},
+		{
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"move","from":"/b","path":"/c"}]`,
+			wantErr: "cannot apply JSON patch: 0.from: from does not exist",
+		},
+		{
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"remove","path":""}]`,
+			wantErr: "cannot apply JSON patch: 0.path: cannot remove the whole document",
+		},
+		{
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"add","path":"a"},{"op":"move","from":"/a","path":"/a/b"},{"op":"jump","path":"/a"}]`,
+			wantErr: "invalid JSON patch: 0.path: invalid JSON pointer; 0.value: is required; 1.from: cannot move a value into itself; 2.op: unknown operation",
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("PATCH", "/", strings.NewReader(tt.patch))
+		r.Header.Set("Content-Type", PatchContentType)
+		patch, err := ReadPatch(r)
+		if err == nil {
+			var doc []byte
+			doc, err = patch.Apply([]byte(tt.doc))
+			if err == nil && string(doc) != tt.want {
+				t.Errorf("%d: want %s, got %s", i, tt.want, doc)
+			}
+		}
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%d: want error %q, got %v", i, tt.wantErr, err)
+			}
+		} else if err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+	}
+}
+
+func TestReadPatchContentType(t *testing.T) {
+	r := httptest.NewRequest("PATCH", "/", strings.NewReader(`[]`))
+	r.Header.Set("Content-Type", "application/json")
+	if _, err := ReadPatch(r); errkind.StatusCode(err) != http.StatusUnsupportedMediaType {
+		t.Errorf("want 415, got %v", err)
+	}
+}
+
+func TestPatchAllow(t *testing.T) {
+	tests := []struct {
+		patch Patch
+		want  string
+	}{
+		{
+			patch: Patch{
+				{Op: "replace", Path: "/name"},
+				{Op: "add", Path: "/tags/-"},
+				{Op: "remove", Path: "/addresses/0/postcode"},
+				{Op: "test", Path: "/id"},
+				{Op: "copy", From: "/id", Path: "/tags/0"},
+			},
+		},
+		{
+			patch: Patch{
+				{Op: "replace", Path: "/id"},
+				{Op: "move", From: "/id", Path: "/name"},
+				{Op: "remove", Path: "/addresses/0"},
+			},
+			want: "JSON patch not allowed: 0.path: cannot be changed; 1.from: cannot be changed; 2.path: cannot be changed",
+		},
+	}
+	for i, tt := range tests {
+		err := tt.patch.Allow("/name", "/tags", "/addresses/*/postcode")
+		var got string
+		if err != nil {
+			got = err.Error()
+			if errkind.StatusCode(err) != http.StatusUnprocessableEntity {
+				t.Errorf("%d: want 422, got %d", i, errkind.StatusCode(err))
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%d: want %q, got %q", i, tt.want, got)
+		}
+	}
+}
+
+func TestPatchApplyTo(t *testing.T) {
+	type Thing struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags,omitempty"`
+	}
+	thing := Thing{Name: "one", Tags: []string{"a"}}
+	patch := Patch{
+		{Op: "replace", Path: "/name", Value: []byte(`"two"`)},
+		{Op: "remove", Path: "/tags"},
+	}
+	if err := patch.ApplyTo(&thing); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Thing{Name: "two"}); !reflect.DeepEqual(thing, want) {
+		t.Errorf("want %+v, got %+v", want, thing)
+	}
+
+	patch = Patch{{Op: "replace", Path: "/name", Value: []byte(`42`)}}
+	if err := patch.ApplyTo(&thing); errkind.StatusCode(err) != http.StatusUnprocessableEntity {
+		t.Errorf("want 422, got %v", err)
+	}
+	if thing.Name != "two" {
+		t.Errorf("want unchanged, got %+v", thing)
+	}
+}
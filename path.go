@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jjeffery/stringset"
+)
+
+// pathParamsFuncs are functions that return the path parameters set
+// by other routers. See RegisterPathParams.
+var (
+	pathParamsMutex sync.RWMutex
+	pathParamsFuncs []func(*http.Request) map[string]string
+)
+
+// RegisterPathParams registers a function that returns the path parameters
+// set by a router, so that they are available using PathValues. This keeps
+// the httpapi package independent of third-party routers. Adapters for
+// gorilla/mux and chi are registered by importing the muxparams and
+// chiparams packages respectively:
+//  import _ "github.com/jjeffery/httpapi/chiparams"
+func RegisterPathParams(fn func(r *http.Request) map[string]string) {
+	pathParamsMutex.Lock()
+	defer pathParamsMutex.Unlock()
+	pathParamsFuncs = append(pathParamsFuncs, fn)
+}
+
+// PathValues returns the parameters in the path of the request URL,
+// with the same typed getters and error reporting as Query. Path
+// parameters are found in the following places, in order of precedence:
+//
+//  - wildcards in the http.ServeMux pattern that matched the request (Go 1.22+)
+//  - wildcards in the path of the Route associated with the request
+//  - parameters set by other routers, such as gorilla/mux and chi,
+//    in the order that their adapters were registered (see RegisterPathParams)
+func PathValues(r *http.Request) *Values {
+	values := make(url.Values)
+	add := func(name, value string) {
+		if _, ok := values[name]; !ok {
+			values[name] = []string{value}
+		}
+	}
+
+	for _, name := range patternWildcards(r.Pattern) {
+		add(name, r.PathValue(name))
+	}
+	if rt := RouteFromRequest(r); rt != nil {
+		for _, name := range patternWildcards(rt.Path) {
+			if value := r.PathValue(name); value != "" {
+				add(name, value)
+			}
+		}
+	}
+	pathParamsMutex.RLock()
+	funcs := pathParamsFuncs
+	pathParamsMutex.RUnlock()
+	for _, fn := range funcs {
+		params := fn(r)
+		names := make([]string, 0, len(params))
+		for name := range params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(name, params[name])
+		}
+	}
+
+	return &Values{
+		values:        values,
+		invalidParams: stringset.New(),
+		missingParams: stringset.New(),
+		source:        "path",
+	}
+}
+
+var wildcardRE = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)(\.\.\.)?\}`)
+
+// patternWildcards returns the names of the wildcards in an
+// http.ServeMux pattern, eg "GET /things/{id}".
+func patternWildcards(pattern string) []string {
+	if !strings.Contains(pattern, "{") {
+		return nil
+	}
+	var names []string
+	for _, m := range wildcardRE.FindAllStringSubmatch(pattern, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPathValues(t *testing.T) {
+	type result struct {
+		id   int
+		name string
+		err  error
+	}
+	var got result
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		path := PathValues(r)
+		got = result{
+			id:   path.GetInt("id"),
+			name: path.GetString("name"),
+			err:  path.Err(),
+		}
+	}
+
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("GET /things/{id}/{name...}", handler)
+	// a router that sets its parameters in the request context
+	type paramsKey struct{}
+	RegisterPathParams(func(r *http.Request) map[string]string {
+		params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+		return params
+	})
+	other := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		params := map[string]string{"id": parts[2], "name": parts[3]}
+		handler(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, params)))
+	})
+
+	tests := []struct {
+		router http.Handler
+		path   string
+		want   result
+		err    string
+	}{
+		{router: serveMux, path: "/things/12/bob", want: result{id: 12, name: "bob"}},
+		{router: serveMux, path: "/things/x/bob", want: result{name: "bob"}, err: "invalid value(s) in path: id"},
+		{router: other, path: "/things/13/alice", want: result{id: 13, name: "alice"}},
+	}
+	for i, tt := range tests {
+		got = result{}
+		tt.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+		if got.id != tt.want.id || got.name != tt.want.name {
+			t.Errorf("%d: want %+v, got %+v", i, tt.want, got)
+		}
+		if tt.err == "" {
+			if got.err != nil {
+				t.Errorf("%d: want no error, got %v", i, got.err)
+			}
+		} else if got.err == nil || !strings.Contains(got.err.Error(), tt.err) {
+			t.Errorf("%d: want error %q, got %v", i, tt.err, got.err)
+		}
+	}
+}
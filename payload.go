@@ -0,0 +1,155 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// defaultPayloadMaxSize is the maximum size of a logged body,
+// unless a different size is specified.
+const defaultPayloadMaxSize = 4096
+
+// redacted replaces the values of redacted JSON members.
+const redacted = "[REDACTED]"
+
+// Payload kinds
+const (
+	PayloadRequest  = "request"
+	PayloadResponse = "response"
+)
+
+// PayloadLog specifies how request and response bodies are logged for a
+// route (see Route). Bodies are logged by ReadRequest, WriteResponse and
+// the functions that call them, such as Handle, when they read or write
+// the body, so the body does not need to be read again. Bodies read or
+// written by other means are not logged.
+//
+// For example, to log the bodies for a route without logging passwords:
+//  route := &httpapi.Route{
+//      Method: "POST",
+//      Path:   "/users",
+//      PayloadLog: &httpapi.PayloadLog{
+//          Log: func(r *http.Request, p *httpapi.Payload) {
+//              slog.InfoContext(r.Context(), "payload", "kind", p.Kind, "body", string(p.Body))
+//          },
+//          Redact: []string{"password", "credentials.*"},
+//      },
+//  }
+type PayloadLog struct {
+	// Log is called with each request and response body.
+	Log func(r *http.Request, p *Payload)
+
+	// MaxSize is the maximum size in bytes of a logged body. Larger bodies
+	// are truncated after redaction. If zero, the size is 4096 bytes.
+	MaxSize int
+
+	// Redact contains the paths of JSON values that are replaced with
+	// "[REDACTED]" before the body is logged. A path is a list of object
+	// member names separated by dots, eg "user.password", and "*" matches
+	// any member or array element. Arrays are searched element by element,
+	// so "items.token" redacts the token member of each object in the items
+	// array. If the body is not valid JSON, and there are paths to redact,
+	// the body is not logged.
+	//
+	// The members of objects in a redacted body are in sorted order.
+	Redact []string
+}
+
+// Payload is a request or response body passed to the PayloadLog callback.
+type Payload struct {
+	Kind      string // PayloadRequest or PayloadResponse
+	Status    int    // HTTP status of a response
+	Body      []byte // Body after redaction and truncation
+	Size      int    // Size of the body before redaction and truncation
+	Truncated bool   // Body was truncated
+}
+
+// logPayload calls the payload log callback for the route of the request,
+// if there is one. The body is not compressed.
+func logPayload(r *http.Request, kind string, status int, body []byte) {
+	rt := RouteFromRequest(r)
+	if rt == nil || rt.PayloadLog == nil || rt.PayloadLog.Log == nil {
+		return
+	}
+	config := rt.PayloadLog
+	p := &Payload{
+		Kind:   kind,
+		Status: status,
+		Body:   body,
+		Size:   len(body),
+	}
+	redact := len(config.Redact) > 0 && len(body) > 0
+	if redact {
+		var err error
+		if p.Body, err = redactJSON(body, config.Redact); err != nil {
+			p.Body = nil
+		}
+	}
+	maxSize := config.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultPayloadMaxSize
+	}
+	if len(p.Body) > maxSize {
+		p.Body = p.Body[:maxSize]
+		p.Truncated = true
+	}
+	if !redact {
+		// the body can be in a buffer that is reused
+		p.Body = bytes.Clone(p.Body)
+	}
+	config.Log(r, p)
+}
+
+// redactJSON returns the JSON document with the values at the paths redacted.
+func redactJSON(data []byte, paths []string) ([]byte, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		doc = redactPath(doc, strings.Split(path, "."))
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// redactPath redacts the values at the path in v.
+func redactPath(v interface{}, path []string) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		rest := path
+		if path[0] == "*" {
+			if len(path) == 1 {
+				for i := range v {
+					v[i] = redacted
+				}
+				return v
+			}
+			rest = path[1:]
+		}
+		for i := range v {
+			v[i] = redactPath(v[i], rest)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				v[key] = redacted
+			} else {
+				v[key] = redactPath(value, path[1:])
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPayloadLog(t *testing.T) {
+	var logged []*Payload
+	route := &Route{
+		PayloadLog: &PayloadLog{
+			Log: func(r *http.Request, p *Payload) {
+				logged = append(logged, p)
+			},
+			Redact:  []string{"password"},
+			MaxSize: 40,
+		},
+	}
+	h := route.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := ReadRequest(r, &body); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		body["text"] = strings.Repeat("x", 50)
+		WriteResponse(w, r, body)
+	}))
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"a","password":"secret"}`))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(logged) != 2 {
+		t.Fatalf("want 2 payloads, got %d", len(logged))
+	}
+	if got, want := logged[0].Kind, PayloadRequest; got != want {
+		t.Errorf("kind: want %q, got %q", want, got)
+	}
+	if got, want := string(logged[0].Body), `{"name":"a","password":"[REDACTED]"}`; got != want {
+		t.Errorf("request: want %s, got %s", want, got)
+	}
+	if got, want := logged[0].Size, 32; got != want {
+		t.Errorf("request size: want %d, got %d", want, got)
+	}
+	if got, want := logged[1].Kind, PayloadResponse; got != want {
+		t.Errorf("kind: want %q, got %q", want, got)
+	}
+	if got, want := logged[1].Status, http.StatusOK; got != want {
+		t.Errorf("status: want %d, got %d", want, got)
+	}
+	if !logged[1].Truncated || len(logged[1].Body) != 40 {
+		t.Errorf("response: want truncated to 40 bytes, got %d bytes", len(logged[1].Body))
+	}
+	if strings.Contains(string(logged[1].Body), "secret") {
+		t.Errorf("response: want redacted, got %s", logged[1].Body)
+	}
+
+	// no route, nothing logged
+	logged = nil
+	var body map[string]interface{}
+	ReadRequest(httptest.NewRequest("POST", "/", strings.NewReader(`{}`)), &body)
+	if len(logged) != 0 {
+		t.Errorf("want nothing logged, got %d", len(logged))
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		data  string
+		paths []string
+		want  string
+		err   bool
+	}{
+		{
+			data:  `{"a":1,"b":{"c":2,"d":3}}`,
+			paths: []string{"b.c"},
+			want:  `{"a":1,"b":{"c":"[REDACTED]","d":3}}`,
+		},
+		{
+			data:  `{"a":1,"b":{"c":2,"d":3}}`,
+			paths: []string{"b.*"},
+			want:  `{"a":1,"b":{"c":"[REDACTED]","d":"[REDACTED]"}}`,
+		},
+		{
+			data:  `{"items":[{"token":"x","id":1},{"token":"y","id":2}]}`,
+			paths: []string{"items.token"},
+			want:  `{"items":[{"id":1,"token":"[REDACTED]"},{"id":2,"token":"[REDACTED]"}]}`,
+		},
+		{
+			data:  `{"items":[{"token":"x"},{"token":"y"}]}`,
+			paths: []string{"items.*.token"},
+			want:  `{"items":[{"token":"[REDACTED]"},{"token":"[REDACTED]"}]}`,
+		},
+		{
+			data:  `{"items":["x","y"]}`,
+			paths: []string{"items.*"},
+			want:  `{"items":["[REDACTED]","[REDACTED]"]}`,
+		},
+		{
+			data:  `[{"password":"x"}]`,
+			paths: []string{"password", "missing.path"},
+			want:  `[{"password":"[REDACTED]"}]`,
+		},
+		{
+			data:  `{"n":12345678901234567890,"html":"<b>"}`,
+			paths: []string{"x"},
+			want:  `{"html":"<b>","n":12345678901234567890}`,
+		},
+		{
+			data:  `not json`,
+			paths: []string{"x"},
+			err:   true,
+		},
+	}
+	for i, tt := range tests {
+		got, err := redactJSON([]byte(tt.data), tt.paths)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%d: want error, got nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%d: want %s, got %s", i, tt.want, got)
+		}
+	}
+}
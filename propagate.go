@@ -0,0 +1,90 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers that are propagated from incoming requests to outgoing requests.
+const (
+	HeaderTraceID   = "X-Trace-Id"
+	HeaderTenantID  = "X-Tenant-Id"
+	HeaderLocale    = "Accept-Language"
+	HeaderTestFlags = "X-Test-Flags"
+
+	// HeaderRequestTimeout is not copied from the incoming request. It is
+	// calculated from the context deadline when the outgoing request is made,
+	// and contains the number of milliseconds remaining.
+	HeaderRequestTimeout = "X-Request-Timeout"
+)
+
+// PropagatedHeaders is the set of headers that the Propagate middleware
+// copies from incoming requests so that they can be sent on outgoing
+// requests to downstream services. It should only be modified during
+// program initialization.
+var PropagatedHeaders = []string{
+	HeaderTraceID,
+	HeaderTenantID,
+	HeaderLocale,
+	HeaderTestFlags,
+}
+
+// Propagate is middleware that stores the propagated headers (see
+// PropagatedHeaders) of the incoming request in the request context.
+// Use OutgoingHeaders to retrieve them when calling downstream services.
+// The client subdirectory package does this automatically.
+func Propagate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := make(http.Header)
+		for _, name := range PropagatedHeaders {
+			if values := r.Header[http.CanonicalHeaderKey(name)]; len(values) > 0 {
+				header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+			}
+		}
+		if len(header) > 0 {
+			ctx := context.WithValue(r.Context(), propagateKey, header)
+			r = r.WithContext(ctx)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// WithPropagatedHeader returns a copy of ctx with a header value that will be
+// included in OutgoingHeaders. It is useful for setting propagated values such as
+// the trace ID when the context did not originate from an incoming HTTP request.
+func WithPropagatedHeader(ctx context.Context, name, value string) context.Context {
+	header := propagatedFromContext(ctx)
+	header.Set(name, value)
+	return context.WithValue(ctx, propagateKey, header)
+}
+
+// OutgoingHeaders returns the headers that should be sent on requests made
+// to downstream services while handling the request associated with ctx.
+// The returned header is a copy and can be modified by the caller.
+//
+// If ctx has a deadline, the X-Request-Timeout header contains the number
+// of milliseconds remaining until the deadline.
+func OutgoingHeaders(ctx context.Context) http.Header {
+	header := propagatedFromContext(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		ms := int64(deadline.Sub(time.Now()) / time.Millisecond)
+		if ms < 0 {
+			ms = 0
+		}
+		header.Set(HeaderRequestTimeout, strconv.FormatInt(ms, 10))
+	}
+	return header
+}
+
+// propagatedFromContext returns a copy of the propagated headers in ctx.
+func propagatedFromContext(ctx context.Context) http.Header {
+	header := make(http.Header)
+	if h, ok := ctx.Value(propagateKey).(http.Header); ok {
+		for k, v := range h {
+			header[k] = append([]string(nil), v...)
+		}
+	}
+	return header
+}
@@ -0,0 +1,61 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPropagate(t *testing.T) {
+	var outgoing http.Header
+	h := Propagate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outgoing = OutgoingHeaders(r.Context())
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Trace-Id", "trace-1")
+	r.Header.Set("X-Tenant-Id", "tenant-1")
+	r.Header.Set("Accept-Language", "en-AU")
+	r.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	want := map[string]string{
+		"X-Trace-Id":      "trace-1",
+		"X-Tenant-Id":     "tenant-1",
+		"Accept-Language": "en-AU",
+		"Authorization":   "",
+		"X-Test-Flags":    "",
+	}
+	for name, value := range want {
+		if got := outgoing.Get(name); got != value {
+			t.Errorf("%s: want %q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestOutgoingHeadersDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = WithPropagatedHeader(ctx, HeaderTraceID, "trace-2")
+
+	header := OutgoingHeaders(ctx)
+	if got, want := header.Get(HeaderTraceID), "trace-2"; got != want {
+		t.Errorf("trace: want %q, got %q", want, got)
+	}
+	ms, err := strconv.Atoi(header.Get(HeaderRequestTimeout))
+	if err != nil {
+		t.Fatalf("timeout: %v", err)
+	}
+	if ms <= 9000 || ms > 10000 {
+		t.Errorf("timeout: want approx 10000, got %d", ms)
+	}
+
+	// modifying the returned header does not affect the context
+	header.Set(HeaderTraceID, "changed")
+	if got, want := OutgoingHeaders(ctx).Get(HeaderTraceID), "trace-2"; got != want {
+		t.Errorf("trace: want %q, got %q", want, got)
+	}
+}
@@ -3,9 +3,14 @@
 package httpapi
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,9 +21,78 @@ import (
 )
 
 // Values provides convenient methods for extracting arguments from the query string.
+// It is also used for extracting arguments from other parts of the request, such
+// as the path (see PathValues).
 type Values struct {
 	values        url.Values
 	invalidParams stringset.Set
+	missingParams stringset.Set
+	reasons       map[string]string // why each invalid parameter is invalid
+	source        string            // where the values came from, eg "query string"
+	timeLayouts   []string          // additional time layouts, if not the default
+	timeLocation  *time.Location    // location for times without a zone, if not the default
+	nameMatching  *NameMatching     // how names are matched, if not the default
+	index         map[string]string // normalized name to key, for inexact matching
+	rawQuery      string            // query string that has not been parsed yet
+	lazy          bool              // values are scanned from rawQuery on demand
+}
+
+// NameMatching specifies how parameter names are matched with the
+// names in the request.
+type NameMatching int
+
+// Values for NameMatching. In all cases an exact match takes precedence.
+const (
+	MatchExact           NameMatching = iota // names must match exactly
+	MatchCaseInsensitive                     // names match if equal ignoring case
+	MatchLoose                               // names match ignoring case, underscores and hyphens
+)
+
+// DefaultNameMatching specifies how parameter names are matched by default.
+// With MatchLoose, "created_at", "createdAt" and "CreatedAt" all match.
+// Use Values.SetNameMatching to override this for a single Values.
+var DefaultNameMatching = MatchExact
+
+// SetNameMatching sets how parameter names are matched, overriding
+// DefaultNameMatching. Returns v so it can be chained with Query.
+func (v *Values) SetNameMatching(m NameMatching) *Values {
+	v.nameMatching = &m
+	v.index = nil
+	return v
+}
+
+func (m NameMatching) normalize(name string) string {
+	name = strings.ToLower(name)
+	if m == MatchLoose {
+		name = strings.NewReplacer("_", "", "-", "").Replace(name)
+	}
+	return name
+}
+
+// TimeLayouts contains additional layouts, as used by time.Parse, that are
+// accepted for time values by default. Times in RFC3339 format are always
+// accepted. For example, to also accept times such as "2006-01-02 15:04":
+//  httpapi.TimeLayouts = append(httpapi.TimeLayouts, "2006-01-02 15:04")
+// Use Values.SetTimeLayouts to override this for a single Values.
+var TimeLayouts []string
+
+// TimeLocation is the default location for time values in one of the
+// TimeLayouts that do not include a time zone. Use Values.SetTimeLocation to
+// override this for a single Values.
+var TimeLocation = time.UTC
+
+// SetTimeLayouts sets the additional layouts accepted for time values,
+// overriding TimeLayouts. Returns v so it can be chained with Query.
+func (v *Values) SetTimeLayouts(layouts ...string) *Values {
+	v.timeLayouts = append([]string{}, layouts...)
+	return v
+}
+
+// SetTimeLocation sets the location for time values that do not include
+// a time zone, overriding TimeLocation. Returns v so it can be chained with Query.
+func (v *Values) SetTimeLocation(loc *time.Location) *Values {
+	v.timeLocation = loc
+	return v
 }
 
 // Query returns values from the query string part of the request URL.
@@ -26,33 +100,97 @@ func Query(r *http.Request) *Values {
 	return &Values{
 		values:        r.URL.Query(),
 		invalidParams: stringset.New(),
+		missingParams: stringset.New(),
+		source:        "query string",
+	}
+}
+
+// LazyQuery is like Query, but the query string is not parsed in advance.
+// Instead each parameter is found by scanning the raw query string when
+// it is requested, which avoids allocating a map and slices for handlers
+// on hot paths that only read a few parameters, eg:
+//  query := httpapi.LazyQuery(r)
+//  limit := query.GetIntDefault("limit", 100)
+//  if err := query.Err(); err != nil {
+//      return err
+//  }
+// The values are the same as for Query. The query string is parsed in full
+// the first time that it is needed, ie by methods that return all of the
+// values of a parameter, such as GetStrings and GetIntList, or when the
+// name matching is not MatchExact.
+func LazyQuery(r *http.Request) *Values {
+	return &Values{
+		rawQuery: r.URL.RawQuery,
+		lazy:     true,
+		source:   "query string",
 	}
 }
 
 // Err returns nil if no errors have been encountered, otherwise it
 // returns a bad request error that lists the parameter(s) that are
-// not in the correct format.
+// not in the correct format, and the required parameter(s) that are missing.
+//
+// The error is a *ValidationError with a status of 400 (Bad Request), so
+// WriteError sends the reason for each parameter to the client in an
+// "errors" array, eg "limit: not an integer".
 func (v *Values) Err() error {
-	if v.invalidParams.Len() == 0 {
+	if v.invalidParams.Len() == 0 && v.missingParams.Len() == 0 {
 		return nil
 	}
 	// We want the client to know which parameters, so we have to format them
 	// in the error message.
-	msg := fmt.Sprintf("invalid value(s) in query string: %s", strings.Join(v.invalidParams.Values(), ","))
-	err := errkind.BadRequest(msg)
+	var msgs []string
+	err := &ValidationError{Status: http.StatusBadRequest}
+	if v.missingParams.Len() > 0 {
+		names := v.missingParams.Values()
+		msgs = append(msgs, fmt.Sprintf("missing value(s) in %s: %s", v.source, strings.Join(names, ",")))
+		for _, name := range names {
+			err.Add(name, "is required", "required")
+		}
+	}
+	if v.invalidParams.Len() > 0 {
+		names := v.invalidParams.Values()
+		msgs = append(msgs, fmt.Sprintf("invalid value(s) in %s: %s", v.source, strings.Join(names, ",")))
+		for _, name := range names {
+			reason := v.reasons[name]
+			if reason == "" {
+				reason = "invalid value"
+			}
+			err.Add(name, reason, "invalid")
+		}
+	}
+	err.Summary = strings.Join(msgs, "; ")
 	return err
 }
 
+// invalid records that the named parameter has an invalid value. If the
+// parameter is invalid for more than one reason, the first reason is kept.
+func (v *Values) invalid(name string, reason string) {
+	if v.invalidParams == nil {
+		v.invalidParams = stringset.New()
+	}
+	v.invalidParams.Add(name)
+	if v.reasons == nil {
+		v.reasons = make(map[string]string)
+	}
+	if _, ok := v.reasons[name]; !ok {
+		v.reasons[name] = reason
+	}
+}
+
+// missing records that the named parameter is required but not present.
+func (v *Values) missing(name string) {
+	if v.missingParams == nil {
+		v.missingParams = stringset.New()
+	}
+	v.missingParams.Add(name)
+}
+
 // validate runs a validation function over all parameters with the
 // specified names. Returns the first error encountered, or nil if no errors.
 func (v *Values) validate(names []string, validator func(string)) {
-	m := map[string][]string(v.values)
 	for _, name := range names {
-		vals, ok := m[name]
-		if !ok {
-			continue
-		}
-		for _, val := range vals {
+		for _, val := range v.all(name) {
 			validator(val)
 		}
 	}
@@ -71,18 +209,183 @@ func (v *Values) GetInt(name string) int {
 	return n
 }
 
-// LookupTime returns a time. The time should be in RFC3339 format.
+// LookupInt64 returns an int64, with an indication of whether the
+// query value was present.
+func (v *Values) LookupInt64(name string) (n int64, ok bool) {
+	return v.parseSigned(name, 64)
+}
+
+// GetInt64 returns an int64. Returns 0 if the query value is not
+// present in the query.
+func (v *Values) GetInt64(name string) int64 {
+	n, _ := v.parseSigned(name, 64)
+	return n
+}
+
+// LookupInt32 returns an int32, with an indication of whether the
+// query value was present.
+func (v *Values) LookupInt32(name string) (n int32, ok bool) {
+	n64, ok := v.parseSigned(name, 32)
+	return int32(n64), ok
+}
+
+// GetInt32 returns an int32. Returns 0 if the query value is not
+// present in the query.
+func (v *Values) GetInt32(name string) int32 {
+	n, _ := v.parseSigned(name, 32)
+	return int32(n)
+}
+
+// LookupUint returns a uint, with an indication of whether the
+// query value was present.
+func (v *Values) LookupUint(name string) (n uint, ok bool) {
+	n64, ok := v.parseUnsigned(name, strconv.IntSize)
+	return uint(n64), ok
+}
+
+// GetUint returns a uint. Returns 0 if the query value is not
+// present in the query.
+func (v *Values) GetUint(name string) uint {
+	n, _ := v.parseUnsigned(name, strconv.IntSize)
+	return uint(n)
+}
+
+// LookupUint64 returns a uint64, with an indication of whether the
+// query value was present.
+func (v *Values) LookupUint64(name string) (n uint64, ok bool) {
+	return v.parseUnsigned(name, 64)
+}
+
+// GetUint64 returns a uint64. Returns 0 if the query value is not
+// present in the query.
+func (v *Values) GetUint64(name string) uint64 {
+	n, _ := v.parseUnsigned(name, 64)
+	return n
+}
+
+// LookupFloat64 returns a float64, with an indication of whether the
+// query value was present.
+func (v *Values) LookupFloat64(name string) (f float64, ok bool) {
+	return v.parseFloat(name, 64)
+}
+
+// GetFloat64 returns a float64. Returns 0 if the query value is not
+// present in the query.
+func (v *Values) GetFloat64(name string) float64 {
+	f, _ := v.parseFloat(name, 64)
+	return f
+}
+
+// LookupFloat32 returns a float32, with an indication of whether the
+// query value was present.
+func (v *Values) LookupFloat32(name string) (f float32, ok bool) {
+	f64, ok := v.parseFloat(name, 32)
+	return float32(f64), ok
+}
+
+// GetFloat32 returns a float32. Returns 0 if the query value is not
+// present in the query.
+func (v *Values) GetFloat32(name string) float32 {
+	f, _ := v.parseFloat(name, 32)
+	return float32(f)
+}
+
+// LookupTime returns a time. The time should be in RFC3339 format, or
+// one of the additional layouts (see TimeLayouts).
 func (v *Values) LookupTime(name string) (t time.Time, ok bool) {
 	return v.parseTime(name)
 }
 
-// GetTime returns a time. The time should be in RFC3339 format.
-// Returns zero if the time value if not present in the query.
+// GetTime returns a time. The time should be in RFC3339 format, or
+// one of the additional layouts (see TimeLayouts). Returns zero if the time value if not present in the query.
 func (v *Values) GetTime(name string) time.Time {
 	t, _ := v.parseTime(name)
 	return t
 }
 
+// LookupTimeUnix returns a time that is specified as a Unix timestamp, with
+// an indication of whether the query value was present. The timestamp can be
+// in seconds, which can have a fractional part, or in milliseconds, as used by
+// JavaScript. Timestamps with an absolute value of 1e11 or more are treated as
+// milliseconds. Values in the formats accepted by LookupTime are also accepted.
+func (v *Values) LookupTimeUnix(name string) (t time.Time, ok bool) {
+	return v.parseTimeUnix(name)
+}
+
+// GetTimeUnix returns a time that is specified as a Unix timestamp in seconds
+// or milliseconds. See LookupTimeUnix for details. Returns zero if the time value
+// is not present in the query.
+func (v *Values) GetTimeUnix(name string) time.Time {
+	t, _ := v.parseTimeUnix(name)
+	return t
+}
+
+// LookupBytes returns the bytes encoded in a base64 query value, with an
+// indication of whether the query value was present. Both the standard
+// and the URL-safe base64 alphabets are accepted, with or without padding.
+func (v *Values) LookupBytes(name string) (b []byte, ok bool) {
+	return v.parseBytes(name)
+}
+
+// GetBytes returns the bytes encoded in a base64 query value. See
+// LookupBytes for the accepted formats. Returns nil if the query
+// value is not present in the query.
+func (v *Values) GetBytes(name string) []byte {
+	b, _ := v.parseBytes(name)
+	return b
+}
+
+// GetJSON unmarshals the JSON document in the query value into the value
+// pointed to by dest, returning true if the query value is present and
+// valid. This is useful for complex filter objects passed as a query
+// parameter, eg "?where={"status":"open"}". If the value is not valid JSON,
+// or cannot be unmarshalled into dest, the parameter is reported by Err.
+func (v *Values) GetJSON(name string, dest interface{}) bool {
+	if !v.exists(name) {
+		return false
+	}
+	s := strings.TrimSpace(v.get(name))
+	if s == "" {
+		return false
+	}
+	if err := json.Unmarshal([]byte(s), dest); err != nil {
+		v.invalid(name, "not valid JSON")
+		return false
+	}
+	return true
+}
+
+// LookupDuration returns a duration, with an indication of whether
+// the query value was present. The duration is in the format accepted
+// by time.ParseDuration, eg "30s" or "5m", or is a plain number of seconds.
+func (v *Values) LookupDuration(name string) (d time.Duration, ok bool) {
+	return v.parseDuration(name)
+}
+
+// GetDuration returns a duration. The duration is in the format accepted
+// by time.ParseDuration, eg "30s" or "5m", or is a plain number of seconds.
+// Returns zero if the duration is not present in the query.
+func (v *Values) GetDuration(name string) time.Duration {
+	d, _ := v.parseDuration(name)
+	return d
+}
+
+// LookupUUID returns a UUID, with an indication of whether the query
+// value was present. The UUID should be in the canonical format, eg
+// "6ba7b810-9dad-11d1-80b4-00c04fd430c8", or the same without hyphens.
+// The result can be converted directly to UUID types that are based on
+// [16]byte, such as the UUID type in github.com/google/uuid.
+func (v *Values) LookupUUID(name string) (u [16]byte, ok bool) {
+	return v.parseUUID(name)
+}
+
+// GetUUID returns a UUID. See LookupUUID for the format. Returns a zero UUID
+// if the query value is not present in the query.
+func (v *Values) GetUUID(name string) [16]byte {
+	u, _ := v.parseUUID(name)
+	return u
+}
+
 // LookupDate returns a date. The date should be in ISO8601 format.
 func (v *Values) LookupDate(name string) (d local.Date, ok bool) {
 	return v.parseDate(name)
@@ -112,7 +415,7 @@ func (v *Values) GetBool(name string) bool {
 // query value was present in the query.
 func (v *Values) LookupString(name string) (s string, ok bool) {
 	if v.exists(name) {
-		return v.values.Get(name), true
+		return v.get(name), true
 	}
 	return "", false
 }
@@ -121,21 +424,480 @@ func (v *Values) LookupString(name string) (s string, ok bool) {
 // present in the query.
 func (v *Values) GetString(name string) string {
 	if v.exists(name) {
-		return v.values.Get(name)
+		return v.get(name)
+	}
+	return ""
+}
+
+// GetStrings returns all of the values for a query parameter that can be
+// repeated, eg "?id=1&id=2". Returns nil if the query value is not present.
+func (v *Values) GetStrings(name string) []string {
+	vals := v.all(name)
+	if len(vals) == 0 {
+		return nil
+	}
+	return append([]string(nil), vals...)
+}
+
+// GetInts returns all of the values for a query parameter that can be
+// repeated, eg "?id=1&id=2". If any value is not a valid integer, the
+// parameter is reported by Err, and nil is returned.
+func (v *Values) GetInts(name string) []int {
+	return parseAll(v, name, "not an integer", v.all(name), strconv.Atoi)
+}
+
+// GetInt64s returns all of the values for a query parameter that can be
+// repeated, eg "?id=1&id=2". If any value is not a valid 64-bit integer,
+// the parameter is reported by Err, and nil is returned.
+func (v *Values) GetInt64s(name string) []int64 {
+	return parseAll(v, name, "not an integer in range", v.all(name), func(s string) (int64, error) {
+		return strconv.ParseInt(s, 10, 64)
+	})
+}
+
+// GetUUIDs returns all of the values for a query parameter that can be
+// repeated, eg "?id=...&id=...". See LookupUUID for the format. If any value
+// is not a valid UUID, the parameter is reported by Err, and nil is returned.
+func (v *Values) GetUUIDs(name string) [][16]byte {
+	return parseAll(v, name, "not a valid UUID", v.all(name), func(s string) ([16]byte, error) {
+		u, ok := parseUUID(strings.TrimSpace(s))
+		if !ok {
+			return u, errInvalidValue
+		}
+		return u, nil
+	})
+}
+
+// GetStringList returns the items of a comma-separated query parameter,
+// eg "?status=open,closed". Spaces around items are removed, and empty items
+// are ignored. If the parameter is repeated, the items of all values are
+// returned. If any valid functions are specified, each item must satisfy
+// all of them, otherwise the parameter is reported by Err, and nil is returned.
+func (v *Values) GetStringList(name string, valid ...func(string) bool) []string {
+	return parseAll(v, name, "invalid value", v.splitList(name), func(s string) (string, error) {
+		for _, fn := range valid {
+			if !fn(s) {
+				return "", errInvalidValue
+			}
+		}
+		return s, nil
+	})
+}
+
+// GetIntList returns the items of a comma-separated query parameter,
+// eg "?id=1,2,3". If any item is not a valid integer, the parameter
+// is reported by Err, and nil is returned.
+func (v *Values) GetIntList(name string) []int {
+	return parseAll(v, name, "not an integer", v.splitList(name), strconv.Atoi)
+}
+
+// GetInt64List returns the items of a comma-separated query parameter,
+// eg "?id=1,2,3". If any item is not a valid 64-bit integer, the parameter
+// is reported by Err, and nil is returned.
+func (v *Values) GetInt64List(name string) []int64 {
+	return parseAll(v, name, "not an integer in range", v.splitList(name), func(s string) (int64, error) {
+		return strconv.ParseInt(s, 10, 64)
+	})
+}
+
+// GetUUIDList returns the items of a comma-separated query parameter.
+// See LookupUUID for the format. If any item is not a valid UUID, the
+// parameter is reported by Err, and nil is returned.
+func (v *Values) GetUUIDList(name string) [][16]byte {
+	return parseAll(v, name, "not a valid UUID", v.splitList(name), func(s string) ([16]byte, error) {
+		u, ok := parseUUID(s)
+		if !ok {
+			return u, errInvalidValue
+		}
+		return u, nil
+	})
+}
+
+// splitList returns the comma-separated items in
+// all of the values of the named parameter.
+func (v *Values) splitList(name string) []string {
+	var items []string
+	for _, val := range v.all(name) {
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// errInvalidValue is returned by parse functions that
+// do not otherwise return an error.
+var errInvalidValue = errkind.BadRequest("invalid value")
+
+// parseAll parses each of the values for the named parameter. If any of the
+// values cannot be parsed, the parameter is marked as invalid and nil is returned.
+func parseAll[T any](v *Values, name string, reason string, vals []string, parse func(string) (T, error)) []T {
+	if len(vals) == 0 {
+		return nil
+	}
+	list := make([]T, 0, len(vals))
+	for _, s := range vals {
+		item, err := parse(s)
+		if err != nil {
+			v.invalid(name, reason)
+			return nil
+		}
+		list = append(list, item)
+	}
+	return list
+}
+
+// GetIntDefault returns an int, or def if the query value is not present
+// or is invalid. Invalid values are reported by Err.
+func (v *Values) GetIntDefault(name string, def int) int {
+	if n, ok := v.parseInt(name); ok {
+		return n
+	}
+	return def
+}
+
+// GetInt64Default returns an int64, or def if the query value is not
+// present or is invalid. Invalid values are reported by Err.
+func (v *Values) GetInt64Default(name string, def int64) int64 {
+	if n, ok := v.parseSigned(name, 64); ok {
+		return n
+	}
+	return def
+}
+
+// GetFloat64Default returns a float64, or def if the query value is not
+// present or is invalid. Invalid values are reported by Err.
+func (v *Values) GetFloat64Default(name string, def float64) float64 {
+	if f, ok := v.parseFloat(name, 64); ok {
+		return f
+	}
+	return def
+}
+
+// GetBoolDefault returns a bool, or def if the query value is not present
+// or is invalid. Invalid values are reported by Err.
+func (v *Values) GetBoolDefault(name string, def bool) bool {
+	if b, ok := v.parseBool(name); ok {
+		return b
+	}
+	return def
+}
+
+// GetStringDefault returns a string, or def if the query value is not present.
+func (v *Values) GetStringDefault(name string, def string) string {
+	if s, ok := v.LookupString(name); ok {
+		return s
+	}
+	return def
+}
+
+// GetDurationDefault returns a duration, or def if the query value is not
+// present or is invalid. Invalid values are reported by Err.
+func (v *Values) GetDurationDefault(name string, def time.Duration) time.Duration {
+	if d, ok := v.parseDuration(name); ok {
+		return d
+	}
+	return def
+}
+
+// GetTimeDefault returns a time, or def if the query value is not present
+// or is invalid. Invalid values are reported by Err.
+func (v *Values) GetTimeDefault(name string, def time.Time) time.Time {
+	if t, ok := v.parseTime(name); ok {
+		return t
+	}
+	return def
+}
+
+// GetDateDefault returns a date, or def if the query value is not present
+// or is invalid. Invalid values are reported by Err.
+func (v *Values) GetDateDefault(name string, def local.Date) local.Date {
+	if d, ok := v.parseDate(name); ok {
+		return d
+	}
+	return def
+}
+
+// RequireInt returns an int. If the query value is not present,
+// the parameter is reported as missing by Err.
+func (v *Values) RequireInt(name string) int {
+	if !v.require(name) {
+		return 0
+	}
+	n, _ := v.parseInt(name)
+	return n
+}
+
+// RequireInt64 returns an int64. If the query value is not present,
+// the parameter is reported as missing by Err.
+func (v *Values) RequireInt64(name string) int64 {
+	if !v.require(name) {
+		return 0
+	}
+	n, _ := v.parseSigned(name, 64)
+	return n
+}
+
+// RequireFloat64 returns a float64. If the query value is not present,
+// the parameter is reported as missing by Err.
+func (v *Values) RequireFloat64(name string) float64 {
+	if !v.require(name) {
+		return 0
 	}
+	f, _ := v.parseFloat(name, 64)
+	return f
+}
+
+// RequireBool returns a bool. If the query value is not present,
+// the parameter is reported as missing by Err.
+func (v *Values) RequireBool(name string) bool {
+	if !v.require(name) {
+		return false
+	}
+	b, _ := v.parseBool(name)
+	return b
+}
+
+// RequireString returns a string. If the query value is not present
+// or is empty, the parameter is reported as missing by Err.
+func (v *Values) RequireString(name string) string {
+	if !v.require(name) {
+		return ""
+	}
+	return v.get(name)
+}
+
+// RequireDuration returns a duration. If the query value is not present,
+// the parameter is reported as missing by Err.
+func (v *Values) RequireDuration(name string) time.Duration {
+	if !v.require(name) {
+		return 0
+	}
+	d, _ := v.parseDuration(name)
+	return d
+}
+
+// RequireTime returns a time. If the query value is not present,
+// the parameter is reported as missing by Err.
+func (v *Values) RequireTime(name string) time.Time {
+	if !v.require(name) {
+		return time.Time{}
+	}
+	t, _ := v.parseTime(name)
+	return t
+}
+
+// RequireDate returns a date. If the query value is not present,
+// the parameter is reported as missing by Err.
+func (v *Values) RequireDate(name string) local.Date {
+	if !v.require(name) {
+		return local.Date{}
+	}
+	d, _ := v.parseDate(name)
+	return d
+}
+
+// RequireUUID returns a UUID. If the query value is not present,
+// the parameter is reported as missing by Err.
+func (v *Values) RequireUUID(name string) [16]byte {
+	if !v.require(name) {
+		return [16]byte{}
+	}
+	u, _ := v.parseUUID(name)
+	return u
+}
+
+// require reports whether the named parameter has a non-blank
+// value. If not, the parameter is recorded as missing.
+func (v *Values) require(name string) bool {
+	s := strings.TrimSpace(v.get(name))
+	if s == "" || s == "undefined" || s == "null" {
+		v.missing(name)
+		return false
+	}
+	return true
+}
+
+// GetStringIn returns a string that must be one of the allowed values.
+// Returns "" if the query value is not present. If the value is not
+// one of the allowed values, the parameter is reported by Err, and
+// "" is returned.
+func (v *Values) GetStringIn(name string, allowed ...string) string {
+	return v.parseStringIn(name, allowed, func(a, b string) bool { return a == b })
+}
+
+// GetStringInFold is like GetStringIn, but the comparison with the allowed
+// values is case-insensitive. The matching allowed value is returned, so
+// "?sort=ASC" returns "asc" if "asc" is an allowed value.
+func (v *Values) GetStringInFold(name string, allowed ...string) string {
+	return v.parseStringIn(name, allowed, strings.EqualFold)
+}
+
+func (v *Values) parseStringIn(name string, allowed []string, equal func(a, b string) bool) string {
+	if !v.exists(name) {
+		return ""
+	}
+	s := v.get(name)
+	for _, a := range allowed {
+		if equal(s, a) {
+			return a
+		}
+	}
+	v.invalid(name, "not one of: "+strings.Join(allowed, ", "))
 	return ""
 }
 
+// LookupIntPtr returns a pointer to an int, or nil if the query
+// value is not present or is invalid. Invalid values are reported by Err.
+func (v *Values) LookupIntPtr(name string) *int {
+	return ptrIf(v.parseInt(name))
+}
+
+// LookupInt64Ptr returns a pointer to an int64, or nil if the query
+// value is not present or is invalid. Invalid values are reported by Err.
+func (v *Values) LookupInt64Ptr(name string) *int64 {
+	return ptrIf(v.parseSigned(name, 64))
+}
+
+// LookupFloat64Ptr returns a pointer to a float64, or nil if the query
+// value is not present or is invalid. Invalid values are reported by Err.
+func (v *Values) LookupFloat64Ptr(name string) *float64 {
+	return ptrIf(v.parseFloat(name, 64))
+}
+
+// LookupBoolPtr returns a pointer to a bool, or nil if the query
+// value is not present or is invalid. Invalid values are reported by Err.
+func (v *Values) LookupBoolPtr(name string) *bool {
+	return ptrIf(v.parseBool(name))
+}
+
+// LookupStringPtr returns a pointer to a string, or nil if the
+// query value is not present.
+func (v *Values) LookupStringPtr(name string) *string {
+	return ptrIf(v.LookupString(name))
+}
+
+// LookupDurationPtr returns a pointer to a duration, or nil if the query
+// value is not present or is invalid. Invalid values are reported by Err.
+func (v *Values) LookupDurationPtr(name string) *time.Duration {
+	return ptrIf(v.parseDuration(name))
+}
+
+// LookupTimePtr returns a pointer to a time, or nil if the query
+// value is not present or is invalid. Invalid values are reported by Err.
+func (v *Values) LookupTimePtr(name string) *time.Time {
+	return ptrIf(v.parseTime(name))
+}
+
+// LookupDatePtr returns a pointer to a date, or nil if the query
+// value is not present or is invalid. Invalid values are reported by Err.
+func (v *Values) LookupDatePtr(name string) *local.Date {
+	return ptrIf(v.parseDate(name))
+}
+
+// LookupUUIDPtr returns a pointer to a UUID, or nil if the query
+// value is not present or is invalid. Invalid values are reported by Err.
+func (v *Values) LookupUUIDPtr(name string) *[16]byte {
+	return ptrIf(v.parseUUID(name))
+}
+
+// ptrIf returns a pointer to value if ok is true, otherwise nil.
+func ptrIf[T any](value T, ok bool) *T {
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
 func (v *Values) exists(name string) bool {
-	_, ok := v.values[name]
+	if v.scannable() {
+		_, ok := scanQuery(v.rawQuery, name)
+		return ok
+	}
+	_, ok := v.key(name)
 	return ok
 }
 
+// all returns all of the values for the named parameter.
+func (v *Values) all(name string) []string {
+	if key, ok := v.key(name); ok {
+		return v.values[key]
+	}
+	return nil
+}
+
+// get returns the first value for the named parameter, or "" if none.
+func (v *Values) get(name string) string {
+	if v.scannable() {
+		s, _ := scanQuery(v.rawQuery, name)
+		return s
+	}
+	if vals := v.all(name); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// matching returns how parameter names are matched.
+func (v *Values) matching() NameMatching {
+	if v.nameMatching != nil {
+		return *v.nameMatching
+	}
+	return DefaultNameMatching
+}
+
+// scannable reports whether a single value can be found by scanning the
+// raw query string, because the values are lazy and names match exactly.
+func (v *Values) scannable() bool {
+	return v.lazy && v.matching() == MatchExact
+}
+
+// parse parses the raw query string of lazy values.
+func (v *Values) parse() {
+	if v.lazy {
+		v.values, _ = url.ParseQuery(v.rawQuery)
+		v.lazy = false
+	}
+}
+
+// key returns the key in the values that matches the parameter name,
+// according to the name matching rules.
+func (v *Values) key(name string) (string, bool) {
+	v.parse()
+	if _, ok := v.values[name]; ok {
+		return name, true
+	}
+	matching := v.matching()
+	if matching == MatchExact {
+		return "", false
+	}
+	if v.index == nil {
+		// When more than one key matches, choose the first
+		// in sort order so the result is deterministic.
+		keys := make([]string, 0, len(v.values))
+		for key := range v.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		v.index = make(map[string]string, len(keys))
+		for _, key := range keys {
+			norm := matching.normalize(key)
+			if _, ok := v.index[norm]; !ok {
+				v.index[norm] = key
+			}
+		}
+	}
+	key, ok := v.index[matching.normalize(name)]
+	return key, ok
+}
+
 func (v *Values) parseTime(name string) (time.Time, bool) {
 	if !v.exists(name) {
 		return time.Time{}, false
 	}
-	s := v.values.Get(name)
+	s := v.get(name)
 	s = strings.TrimSpace(s)
 	if s == "" || s == "undefined" || s == "null" {
 		return time.Time{}, false
@@ -144,20 +906,139 @@ func (v *Values) parseTime(name string) (time.Time, bool) {
 	var t time.Time
 	var err error
 
-	if t, err = time.Parse(time.RFC3339Nano, s); err != nil {
-		if t, err = time.Parse(time.RFC3339, s); err != nil {
-			v.invalidParams.Add(name)
-			return time.Time{}, false
+	if t, err = time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	if t, err = time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+
+	layouts, loc := v.timeLayouts, v.timeLocation
+	if layouts == nil {
+		layouts = TimeLayouts
+	}
+	if loc == nil {
+		loc = TimeLocation
+	}
+	for _, layout := range layouts {
+		if t, err = time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	if len(layouts) > 0 {
+		v.invalid(name, "not a valid time")
+	} else {
+		v.invalid(name, "not a valid RFC3339 time")
+	}
+	return time.Time{}, false
+}
+
+// unixMillisThreshold is the absolute value at or above which a
+// Unix timestamp is treated as milliseconds rather than seconds.
+const unixMillisThreshold = 1e11
+
+func (v *Values) parseTimeUnix(name string) (time.Time, bool) {
+	if !v.exists(name) {
+		return time.Time{}, false
+	}
+	s := strings.TrimSpace(v.get(name))
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return v.parseTime(name)
+	}
+	if math.IsNaN(f) || math.Abs(f) >= math.MaxInt64/1e6 {
+		v.invalid(name, "not a valid Unix timestamp")
+		return time.Time{}, false
+	}
+	if math.Abs(f) >= unixMillisThreshold {
+		return time.UnixMilli(int64(f)).UTC(), true
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC(), true
+}
+
+func (v *Values) parseBytes(name string) ([]byte, bool) {
+	if !v.exists(name) {
+		return nil, false
+	}
+	// A '+' in the standard alphabet is decoded as a space if the
+	// client did not escape it, so restore it before decoding.
+	s := strings.TrimSpace(strings.Replace(v.get(name), " ", "+", -1))
+	s = strings.TrimRight(s, "=")
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	b, err := enc.DecodeString(s)
+	if err != nil {
+		v.invalid(name, "not valid base64")
+		return nil, false
+	}
+	return b, true
+}
+
+func (v *Values) parseDuration(name string) (time.Duration, bool) {
+	if !v.exists(name) {
+		return 0, false
+	}
+	d, err := parseDuration(v.get(name))
+	if err != nil {
+		v.invalid(name, "not a valid duration")
+		return 0, false
+	}
+	return d, true
+}
+
+// parseDuration parses a duration in the format accepted by
+// time.ParseDuration, or as a plain number of seconds.
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(seconds) || math.Abs(seconds) > math.MaxInt64/float64(time.Second) {
+			return 0, errInvalidValue
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+func (v *Values) parseUUID(name string) ([16]byte, bool) {
+	if !v.exists(name) {
+		return [16]byte{}, false
+	}
+	u, ok := parseUUID(strings.TrimSpace(v.get(name)))
+	if !ok {
+		v.invalid(name, "not a valid UUID")
+		return [16]byte{}, false
+	}
+	return u, true
+}
+
+// parseUUID parses a UUID in the canonical 8-4-4-4-12 format,
+// or as 32 hex digits without hyphens.
+func parseUUID(s string) ([16]byte, bool) {
+	var u [16]byte
+	switch len(s) {
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return u, false
 		}
+		s = s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	case 32:
+	default:
+		return u, false
+	}
+	if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+		return [16]byte{}, false
 	}
-	return t, true
+	return u, true
 }
 
 func (v *Values) parseDate(name string) (local.Date, bool) {
 	if !v.exists(name) {
 		return local.Date{}, false
 	}
-	s := v.values.Get(name)
+	s := v.get(name)
 	s = strings.TrimSpace(s)
 	if s == "" || s == "undefined" || s == "null" {
 		return local.Date{}, false
@@ -167,7 +1048,7 @@ func (v *Values) parseDate(name string) (local.Date, bool) {
 	var err error
 
 	if d, err = local.DateParse(s); err != nil {
-		v.invalidParams.Add(name)
+		v.invalid(name, "not a valid date")
 		return local.Date{}, false
 	}
 	return d, true
@@ -177,27 +1058,104 @@ func (v *Values) parseInt(name string) (int, bool) {
 	if !v.exists(name) {
 		return 0, false
 	}
-	s := v.values.Get(name)
+	s := v.get(name)
 	var n int
 	var err error
 	if n, err = strconv.Atoi(s); err != nil {
-		v.invalidParams.Add(name)
+		v.invalid(name, "not an integer")
+		return 0, false
+	}
+	return n, true
+}
+
+func (v *Values) parseSigned(name string, bitSize int) (int64, bool) {
+	if !v.exists(name) {
+		return 0, false
+	}
+	s := v.get(name)
+	n, err := strconv.ParseInt(s, 10, bitSize)
+	if err != nil {
+		v.invalid(name, "not an integer in range")
 		return 0, false
 	}
 	return n, true
 }
 
+func (v *Values) parseUnsigned(name string, bitSize int) (uint64, bool) {
+	if !v.exists(name) {
+		return 0, false
+	}
+	s := v.get(name)
+	n, err := strconv.ParseUint(s, 10, bitSize)
+	if err != nil {
+		v.invalid(name, "not a non-negative integer in range")
+		return 0, false
+	}
+	return n, true
+}
+
+func (v *Values) parseFloat(name string, bitSize int) (float64, bool) {
+	if !v.exists(name) {
+		return 0, false
+	}
+	s := v.get(name)
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		v.invalid(name, "not a number")
+		return 0, false
+	}
+	return f, true
+}
+
 func (v *Values) parseBool(name string) (bool, bool) {
 	if !v.exists(name) {
 		return false, false
 	}
-	s := strings.ToLower(v.values.Get(name))
-	switch s {
+	if b, ok := parseBool(v.get(name)); ok {
+		return b, true
+	}
+	v.invalid(name, "not a boolean")
+	return false, false
+}
+
+// parseBool parses a boolean value, which can be "1", "true",
+// "yes", "t" or the opposite, and is not case sensitive.
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
 	case "1", "true", "yes", "t":
 		return true, true
 	case "0", "false", "no", "f":
 		return false, true
 	}
-	v.invalidParams.Add(name)
 	return false, false
 }
+
+// scanQuery returns the first value for the named parameter in the raw
+// query string, which is the same value as url.ParseQuery would return.
+// It only allocates if the parameter name or value is escaped.
+func scanQuery(query string, name string) (string, bool) {
+	for query != "" {
+		var pair string
+		pair, query, _ = strings.Cut(query, "&")
+		if pair == "" || strings.Contains(pair, ";") {
+			// url.ParseQuery rejects semicolons
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		if strings.ContainsAny(key, "%+") {
+			var err error
+			if key, err = url.QueryUnescape(key); err != nil {
+				continue
+			}
+		}
+		if key != name {
+			continue
+		}
+		value, err := url.QueryUnescape(value)
+		if err != nil {
+			continue
+		}
+		return value, true
+	}
+	return "", false
+}
@@ -3,8 +3,12 @@
 package httpapi
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -207,3 +211,638 @@ func TestQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryFloat(t *testing.T) {
+	tests := []struct {
+		query   string
+		want64  float64
+		want32  float32
+		ok      bool
+		invalid bool
+	}{
+		{query: "f=1.5", want64: 1.5, want32: 1.5, ok: true},
+		{query: "f=-2", want64: -2, want32: -2, ok: true},
+		{query: "f=1e3", want64: 1000, want32: 1000, ok: true},
+		{query: "f=abc", invalid: true},
+		{query: "f=NaN", invalid: true},
+		{query: "f=Inf", invalid: true},
+		{query: "g=1"},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		got64, ok := query.LookupFloat64("f")
+		if got64 != tt.want64 || ok != tt.ok {
+			t.Errorf("%d: want %v, %v, got %v, %v", i, tt.want64, tt.ok, got64, ok)
+		}
+		if got := query.GetFloat32("f"); got != tt.want32 {
+			t.Errorf("%d: want %v, got %v", i, tt.want32, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQuerySizedInt(t *testing.T) {
+	tests := []struct {
+		query   string
+		int64   int64
+		int32   int32
+		uint    uint
+		uint64  uint64
+		invalid []string
+	}{
+		{
+			query:  "i64=9007199254740993&i32=-7&u=42&u64=18446744073709551615",
+			int64:  9007199254740993,
+			int32:  -7,
+			uint:   42,
+			uint64: 18446744073709551615,
+		},
+		{
+			query:   "i64=x&i32=2147483648&u=-1&u64=1.5",
+			invalid: []string{"i32", "i64", "u", "u64"},
+		},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		if got := query.GetInt64("i64"); got != tt.int64 {
+			t.Errorf("%d: int64: want %v, got %v", i, tt.int64, got)
+		}
+		if got, _ := query.LookupInt32("i32"); got != tt.int32 {
+			t.Errorf("%d: int32: want %v, got %v", i, tt.int32, got)
+		}
+		if got := query.GetUint("u"); got != tt.uint {
+			t.Errorf("%d: uint: want %v, got %v", i, tt.uint, got)
+		}
+		if got, _ := query.LookupUint64("u64"); got != tt.uint64 {
+			t.Errorf("%d: uint64: want %v, got %v", i, tt.uint64, got)
+		}
+		if got, want := query.invalidParams.Values(), tt.invalid; len(got) != len(want) {
+			t.Errorf("%d: invalid: want %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestQueryDuration(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    time.Duration
+		ok      bool
+		invalid bool
+	}{
+		{query: "d=30s", want: 30 * time.Second, ok: true},
+		{query: "d=5m", want: 5 * time.Minute, ok: true},
+		{query: "d=1h30m", want: 90 * time.Minute, ok: true},
+		{query: "d=45", want: 45 * time.Second, ok: true},
+		{query: "d=0.5", want: 500 * time.Millisecond, ok: true},
+		{query: "d=soon", invalid: true},
+		{query: "d=1e300", invalid: true},
+		{query: "x=1"},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		got, ok := query.LookupDuration("d")
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%d: want %v, %v, got %v, %v", i, tt.want, tt.ok, got, ok)
+		}
+		if got := query.GetDuration("d"); got != tt.want {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQueryUUID(t *testing.T) {
+	want := [16]byte{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+	tests := []struct {
+		query   string
+		want    [16]byte
+		ok      bool
+		invalid bool
+	}{
+		{query: "id=6ba7b810-9dad-11d1-80b4-00c04fd430c8", want: want, ok: true},
+		{query: "id=6BA7B810-9DAD-11D1-80B4-00C04FD430C8", want: want, ok: true},
+		{query: "id=6ba7b8109dad11d180b400c04fd430c8", want: want, ok: true},
+		{query: "id=6ba7b810-9dad-11d1-80b4-00c04fd430c", invalid: true},
+		{query: "id=6ba7b810+9dad-11d1-80b4-00c04fd430c8", invalid: true},
+		{query: "id=zba7b810-9dad-11d1-80b4-00c04fd430c8", invalid: true},
+		{query: "x=1"},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		got, ok := query.LookupUUID("id")
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%d: want %x, %v, got %x, %v", i, tt.want, tt.ok, got, ok)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQuerySlices(t *testing.T) {
+	tests := []struct {
+		query   string
+		strings []string
+		ints    []int
+		int64s  []int64
+		uuids   [][16]byte
+		invalid bool
+	}{
+		{
+			query:   "s=a&s=b&i=1&i=2&i64=3&i64=4&u=00000000-0000-0000-0000-000000000001",
+			strings: []string{"a", "b"},
+			ints:    []int{1, 2},
+			int64s:  []int64{3, 4},
+			uuids:   [][16]byte{{15: 1}},
+		},
+		{
+			query:   "i=1&i=x",
+			invalid: true,
+		},
+		{
+			query:   "u=00000000-0000-0000-0000-000000000001&u=nope",
+			invalid: true,
+		},
+		{
+			query: "",
+		},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		if got := query.GetStrings("s"); !reflect.DeepEqual(got, tt.strings) {
+			t.Errorf("%d: strings: want %v, got %v", i, tt.strings, got)
+		}
+		if got := query.GetInts("i"); !reflect.DeepEqual(got, tt.ints) {
+			t.Errorf("%d: ints: want %v, got %v", i, tt.ints, got)
+		}
+		if got := query.GetInt64s("i64"); !reflect.DeepEqual(got, tt.int64s) {
+			t.Errorf("%d: int64s: want %v, got %v", i, tt.int64s, got)
+		}
+		if got := query.GetUUIDs("u"); !reflect.DeepEqual(got, tt.uuids) {
+			t.Errorf("%d: uuids: want %x, got %x", i, tt.uuids, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQueryLists(t *testing.T) {
+	isStatus := func(s string) bool { return s == "open" || s == "closed" }
+	tests := []struct {
+		query   string
+		strings []string
+		ints    []int
+		int64s  []int64
+		uuids   [][16]byte
+		invalid bool
+	}{
+		{
+			query:   "s=open,+closed&s=open&i=1,2,,3&i64=4&u=00000000000000000000000000000001,00000000000000000000000000000002",
+			strings: []string{"open", "closed", "open"},
+			ints:    []int{1, 2, 3},
+			int64s:  []int64{4},
+			uuids:   [][16]byte{{15: 1}, {15: 2}},
+		},
+		{
+			query:   "s=open,pending",
+			invalid: true,
+		},
+		{
+			query:   "i=1,two",
+			invalid: true,
+		},
+		{
+			query: "s=,",
+		},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		if got := query.GetStringList("s", isStatus); !reflect.DeepEqual(got, tt.strings) {
+			t.Errorf("%d: strings: want %v, got %v", i, tt.strings, got)
+		}
+		if got := query.GetIntList("i"); !reflect.DeepEqual(got, tt.ints) {
+			t.Errorf("%d: ints: want %v, got %v", i, tt.ints, got)
+		}
+		if got := query.GetInt64List("i64"); !reflect.DeepEqual(got, tt.int64s) {
+			t.Errorf("%d: int64s: want %v, got %v", i, tt.int64s, got)
+		}
+		if got := query.GetUUIDList("u"); !reflect.DeepEqual(got, tt.uuids) {
+			t.Errorf("%d: uuids: want %x, got %x", i, tt.uuids, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQueryDefaults(t *testing.T) {
+	r := &http.Request{URL: &url.URL{RawQuery: "limit=20&verbose=true&bad=x&name=bob&wait=5s"}}
+	query := Query(r)
+	if got, want := query.GetIntDefault("limit", 10), 20; got != want {
+		t.Errorf("limit: want %v, got %v", want, got)
+	}
+	if got, want := query.GetIntDefault("offset", 10), 10; got != want {
+		t.Errorf("offset: want %v, got %v", want, got)
+	}
+	if got, want := query.GetInt64Default("bad", 7), int64(7); got != want {
+		t.Errorf("bad: want %v, got %v", want, got)
+	}
+	if got, want := query.GetFloat64Default("ratio", 0.5), 0.5; got != want {
+		t.Errorf("ratio: want %v, got %v", want, got)
+	}
+	if got, want := query.GetBoolDefault("verbose", false), true; got != want {
+		t.Errorf("verbose: want %v, got %v", want, got)
+	}
+	if got, want := query.GetStringDefault("name", "alice"), "bob"; got != want {
+		t.Errorf("name: want %v, got %v", want, got)
+	}
+	if got, want := query.GetStringDefault("sort", "id"), "id"; got != want {
+		t.Errorf("sort: want %v, got %v", want, got)
+	}
+	if got, want := query.GetDurationDefault("wait", time.Second), 5*time.Second; got != want {
+		t.Errorf("wait: want %v, got %v", want, got)
+	}
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got, want := query.GetTimeDefault("since", since), since; !got.Equal(want) {
+		t.Errorf("since: want %v, got %v", want, got)
+	}
+	if got, want := query.GetDateDefault("date", local.DateFor(2020, 1, 1)), local.DateFor(2020, 1, 1); got != want {
+		t.Errorf("date: want %v, got %v", want, got)
+	}
+	if query.Err() == nil {
+		t.Error("want error for invalid parameter, got nil")
+	}
+}
+
+func TestQueryRequire(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{
+			query: "id=1&name=x&since=2020-01-02T13:14:15Z",
+		},
+		{
+			query: "name=&since=2020-01-02T13:14:15Z",
+			err:   "missing value(s) in query string: id,name",
+		},
+		{
+			query: "id=one&name=x",
+			err:   "missing value(s) in query string: since; invalid value(s) in query string: id",
+		},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		query.RequireInt("id")
+		query.RequireString("name")
+		query.RequireTime("since")
+		err := query.Err()
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%d: want no error, got %v", i, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%d: want error, got nil", i)
+			continue
+		}
+		if got, want := err.Error(), tt.err; !strings.Contains(got, want) {
+			t.Errorf("%d: want %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestQueryStringIn(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		fold    string
+		invalid bool
+	}{
+		{query: "sort=asc", want: "asc", fold: "asc"},
+		{query: "sort=DESC", want: "", fold: "desc", invalid: true},
+		{query: "sort=up", want: "", fold: "", invalid: true},
+		{query: "", want: "", fold: ""},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		if got := query.GetStringInFold("sort", "asc", "desc"); got != tt.fold {
+			t.Errorf("%d: fold: want %q, got %q", i, tt.fold, got)
+		}
+		if got := query.GetStringIn("sort", "asc", "desc"); got != tt.want {
+			t.Errorf("%d: want %q, got %q", i, tt.want, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQueryErrFields(t *testing.T) {
+	r := &http.Request{URL: &url.URL{RawQuery: "limit=x&since=yesterday"}}
+	query := Query(r)
+	query.GetInt("limit")
+	query.GetTime("since")
+	query.RequireString("name")
+
+	w := httptest.NewRecorder()
+	WriteError(w, httptest.NewRequest("GET", "/", nil), query.Err())
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Errorf("status: want %d, got %d", want, got)
+	}
+	var body struct {
+		Error struct {
+			Errors []FieldError `json:"errors"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldError{
+		{Field: "name", Message: "is required", Code: "required"},
+		{Field: "limit", Message: "not an integer", Code: "invalid"},
+		{Field: "since", Message: "not a valid RFC3339 time", Code: "invalid"},
+	}
+	if got := body.Error.Errors; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestQueryTimeLayouts(t *testing.T) {
+	sydney := time.FixedZone("AEST", 10*60*60)
+	tests := []struct {
+		query   string
+		layouts []string
+		loc     *time.Location
+		want    time.Time
+		invalid bool
+	}{
+		{
+			query: "t=2020-01-02T03:04:05Z",
+			want:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			query:   "t=2020-01-02+03:04",
+			invalid: true,
+		},
+		{
+			query:   "t=2020-01-02+03:04",
+			layouts: []string{"2006-01-02 15:04"},
+			want:    time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC),
+		},
+		{
+			query:   "t=2020-01-02+03:04",
+			layouts: []string{"2006-01-02 15:04"},
+			loc:     sydney,
+			want:    time.Date(2020, 1, 2, 3, 4, 0, 0, sydney),
+		},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		if tt.layouts != nil {
+			query.SetTimeLayouts(tt.layouts...)
+		}
+		if tt.loc != nil {
+			query.SetTimeLocation(tt.loc)
+		}
+		if got := query.GetTime("t"); !got.Equal(tt.want) {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQueryTimeUnix(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    time.Time
+		invalid bool
+	}{
+		{query: "t=1577934245", want: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{query: "t=1577934245.5", want: time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC)},
+		{query: "t=1577934245123", want: time.Date(2020, 1, 2, 3, 4, 5, 123000000, time.UTC)},
+		{query: "t=2020-01-02T03:04:05Z", want: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{query: "t=yesterday", invalid: true},
+		{query: "t=NaN", invalid: true},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		if got := query.GetTimeUnix("t"); !got.Equal(tt.want) {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQueryBytes(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []byte
+		invalid bool
+	}{
+		{query: "b=aGVsbG8%3D", want: []byte("hello")},
+		{query: "b=aGVsbG8", want: []byte("hello")},
+		{query: "b=-_8", want: []byte{0xfb, 0xff}},
+		{query: "b=%2B%2F8%3D", want: []byte{0xfb, 0xff}},
+		{query: "b=+/8=", want: []byte{0xfb, 0xff}},
+		{query: "b=", want: []byte{}},
+		{query: "b=!!", invalid: true},
+		{query: "x=1"},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		if got := query.GetBytes("b"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQueryJSON(t *testing.T) {
+	type where struct {
+		Status string `json:"status"`
+		Min    int    `json:"min"`
+	}
+	tests := []struct {
+		query   string
+		want    where
+		ok      bool
+		invalid bool
+	}{
+		{query: "w=" + url.QueryEscape(`{"status":"open","min":3}`), want: where{Status: "open", Min: 3}, ok: true},
+		{query: "w=" + url.QueryEscape(`{"status":`), invalid: true},
+		{query: "w=" + url.QueryEscape(`{"min":"x"}`), invalid: true},
+		{query: "w="},
+		{query: ""},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		var got where
+		ok := query.GetJSON("w", &got)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("%d: want %+v, %v, got %+v, %v", i, tt.want, tt.ok, got, ok)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
+
+func TestQueryPtr(t *testing.T) {
+	r := &http.Request{URL: &url.URL{RawQuery: "n=0&b=false&s=&bad=x&wait=0s"}}
+	query := Query(r)
+	if got := query.LookupIntPtr("n"); got == nil || *got != 0 {
+		t.Errorf("n: want pointer to 0, got %v", got)
+	}
+	if got := query.LookupBoolPtr("b"); got == nil || *got != false {
+		t.Errorf("b: want pointer to false, got %v", got)
+	}
+	if got := query.LookupStringPtr("s"); got == nil || *got != "" {
+		t.Errorf("s: want pointer to empty string, got %v", got)
+	}
+	if got := query.LookupDurationPtr("wait"); got == nil || *got != 0 {
+		t.Errorf("wait: want pointer to zero, got %v", got)
+	}
+	if got := query.LookupInt64Ptr("missing"); got != nil {
+		t.Errorf("missing: want nil, got %v", *got)
+	}
+	if got := query.LookupTimePtr("missing"); got != nil {
+		t.Errorf("missing: want nil, got %v", *got)
+	}
+	if got := query.LookupFloat64Ptr("bad"); got != nil {
+		t.Errorf("bad: want nil, got %v", *got)
+	}
+	if query.Err() == nil {
+		t.Error("want error for invalid parameter, got nil")
+	}
+}
+
+func TestQueryNameMatching(t *testing.T) {
+	tests := []struct {
+		matching NameMatching
+		query    string
+		want     int
+	}{
+		{matching: MatchExact, query: "page_size=5", want: 5},
+		{matching: MatchExact, query: "Page_Size=5", want: 0},
+		{matching: MatchCaseInsensitive, query: "Page_Size=5", want: 5},
+		{matching: MatchCaseInsensitive, query: "pageSize=5", want: 0},
+		{matching: MatchLoose, query: "pageSize=5", want: 5},
+		{matching: MatchLoose, query: "page-size=5", want: 5},
+		{matching: MatchLoose, query: "pageSize=6&page_size=5", want: 5},
+		{matching: MatchLoose, query: "PageSize=7&pageSize=6", want: 7},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r).SetNameMatching(tt.matching)
+		if got := query.GetInt("page_size"); got != tt.want {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestLazyQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		names []string
+	}{
+		{query: "a=1&b=2&a=3", names: []string{"a", "b", "c"}},
+		{query: "a=&b&c=x=y", names: []string{"a", "b", "c"}},
+		{query: "name=John+Smith&q=%3D%26", names: []string{"name", "q"}},
+		{query: "first+name=x&first%20name=y&a%2Bb=z", names: []string{"first name", "a+b", "first+name"}},
+		{query: "a=%zz&a=2&b;c=3&b=4", names: []string{"a", "b", "b;c"}},
+		{query: "&&a=1&", names: []string{"a", ""}},
+		{query: "", names: []string{"a"}},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/?"+tt.query, nil)
+		query := Query(r)
+		lazy := LazyQuery(r)
+		for _, name := range tt.names {
+			want, wantOK := query.LookupString(name)
+			got, gotOK := lazy.LookupString(name)
+			if got != want || gotOK != wantOK {
+				t.Errorf("%d: %s: want %q %v, got %q %v", i, name, want, wantOK, got, gotOK)
+			}
+			if !lazy.lazy {
+				t.Errorf("%d: %s: want unparsed", i, name)
+			}
+		}
+		for _, name := range tt.names {
+			if want, got := query.GetStrings(name), lazy.GetStrings(name); !reflect.DeepEqual(want, got) {
+				t.Errorf("%d: %s: want %q, got %q", i, name, want, got)
+			}
+		}
+	}
+}
+
+func TestLazyQueryMatching(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?Created_At=2020-01-02&limit=x", nil)
+	query := LazyQuery(r).SetNameMatching(MatchLoose)
+	if got, want := query.GetString("createdAt"), "2020-01-02"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got := query.GetInt("limit"); got != 0 {
+		t.Errorf("want 0, got %d", got)
+	}
+	if err := query.Err(); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("want error for limit, got %v", err)
+	}
+}
+
+func TestLazyQueryAllocs(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?offset=20&limit=10&sort=name&filter=active", nil)
+	allocs := testing.AllocsPerRun(100, func() {
+		query := LazyQuery(r)
+		if query.GetInt("limit") != 10 || query.GetString("sort") != "name" || query.Err() != nil {
+			t.Fatal("unexpected values")
+		}
+	})
+	if allocs > 1 {
+		t.Errorf("want at most 1 alloc, got %v", allocs)
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	r := httptest.NewRequest("GET", "/?offset=20&limit=10&sort=name&filter=active", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		query := Query(r)
+		_ = query.GetInt("limit")
+		_ = query.Err()
+	}
+}
+
+func BenchmarkLazyQuery(b *testing.B) {
+	r := httptest.NewRequest("GET", "/?offset=20&limit=10&sort=name&filter=active", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		query := LazyQuery(r)
+		_ = query.GetInt("limit")
+		_ = query.Err()
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -13,6 +14,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/jjeffery/errkind"
 	"github.com/jjeffery/errors"
@@ -69,9 +71,20 @@ func (data *rawData) ReadRequest(r *http.Request) error {
 		buf := make([]byte, v)
 
 		_, err = io.ReadFull(r.Body, buf)
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return errkind.BadRequest("content shorter than content-length")
+		}
 		if err != nil {
 			return errkind.BadRequest("cannot read full content")
 		}
+
+		// The server limits the body to the content length, but check anyway
+		// in case the body has been replaced, so that any data following the
+		// declared content cannot be mistaken for another request.
+		var extra [1]byte
+		if n, _ := io.ReadFull(r.Body, extra[:]); n > 0 {
+			return errkind.BadRequest("content longer than content-length")
+		}
 		data.Content = buf
 	} else {
 		reader := io.LimitReader(r.Body, int64(maxRequestLen))
@@ -104,6 +117,12 @@ func (data *rawData) ReadRequest(r *http.Request) error {
 
 // WriteResponse writes the contents to the client as a response.
 func (data *rawData) WriteResponse(w http.ResponseWriter) error {
+	return data.WriteResponseStatus(w, http.StatusOK)
+}
+
+// WriteResponseStatus writes the contents to the client as a
+// response with the status, unless there are no contents.
+func (data *rawData) WriteResponseStatus(w http.ResponseWriter, status int) error {
 	if len(data.Content) == 0 {
 		w.Header().Set("Content-Length", "0")
 		w.Header().Del("Content-Type")
@@ -119,6 +138,7 @@ func (data *rawData) WriteResponse(w http.ResponseWriter) error {
 	}
 	w.Header().Set("Content-Type", data.ContentType)
 	w.Header().Set("Content-Length", strconv.Itoa(len(data.Content)))
+	w.WriteHeader(status)
 	_, err := w.Write(data.Content)
 	if err != nil {
 		return errors.Wrap(err, "cannot write response")
@@ -127,13 +147,34 @@ func (data *rawData) WriteResponse(w http.ResponseWriter) error {
 }
 
 func (data *rawData) Decompress() error {
+	return data.decompress(0)
+}
+
+// decompressRequest is like Decompress, but the decompressed content is
+// subject to the same size limit as the request body, and errors are
+// suitable for sending to the client.
+func (data *rawData) decompressRequest() error {
+	err := data.decompress(maxRequestLen)
+	if err != nil && errkind.StatusCode(err) != http.StatusRequestEntityTooLarge {
+		return errkind.BadRequest("cannot decompress payload")
+	}
+	return err
+}
+
+// decompress decompresses the content. If limit is positive, content
+// that is that size or larger when decompressed is too large.
+func (data *rawData) decompress(limit int) error {
 	if !data.IsCompressed() {
 		return nil
 	}
 	input := bytes.NewBuffer(data.Content)
 	var reader io.Reader
 	if data.ContentEncoding == ceDeflate {
-		reader = flate.NewReader(input)
+		// HTTP deflate is the zlib format, not raw DEFLATE
+		var err error
+		if reader, err = zlib.NewReader(input); err != nil {
+			return err
+		}
 	} else if data.ContentEncoding == ceGzip {
 		var err error
 		if reader, err = gzip.NewReader(input); err != nil {
@@ -143,11 +184,17 @@ func (data *rawData) Decompress() error {
 		return errors.New("unknown content-encoding").
 			With("content-encoding", data.ContentEncoding)
 	}
+	if limit > 0 {
+		reader = io.LimitReader(reader, int64(limit))
+	}
 	writer := bytes.Buffer{}
 	_, err := io.Copy(&writer, reader)
 	if err != nil {
 		return err
 	}
+	if limit > 0 && writer.Len() >= limit {
+		return errkind.Public("payload too large", http.StatusRequestEntityTooLarge)
+	}
 	data.Content = writer.Bytes()
 	data.ContentEncoding = ""
 	data.UncompressedLength = len(data.Content)
@@ -162,21 +209,22 @@ func (data *rawData) CompressResponse(r *http.Request) error {
 		return nil
 	}
 
-	if data.IsCompressed() || len(data.Content) < overhead*4 {
-		// already compressed, or not worth compressing
-		// because data is nil or too short
+	if data.IsCompressed() || len(data.Content) == 0 {
+		// already compressed, or nothing to compress
 		return nil
 	}
 
-	// TODO(jpj): this is a fairly naive handling of the Accept-Encoding
-	// header. In particular it does not handle gzip;q=0, which is
-	// a valid way of saying that gzip is not acceptable.
-	if ae := r.Header.Get("Accept-Encoding"); !strings.Contains(ae, ceGzip) {
+	decision := compressionPolicy(r).Decide(r, data.ContentType, len(data.Content))
+	if decision.Encoding == "" {
 		return nil
 	}
 
 	var buf bytes.Buffer
-	w := gzip.NewWriter(&buf)
+	w, err := getCompressor(decision.Encoding, decision.Level, &buf)
+	if err != nil {
+		return err
+	}
+	defer putCompressor(decision.Level, w)
 	n, err := w.Write(data.Content)
 	if err != nil {
 		return err
@@ -190,24 +238,179 @@ func (data *rawData) CompressResponse(r *http.Request) error {
 	}
 	compressedBytes := buf.Bytes()
 
-	if len(compressedBytes)+overhead < len(data.Content) {
+	if decision.Force || len(compressedBytes)+overhead < len(data.Content) {
 		data.UncompressedLength = len(data.Content)
 		data.Content = compressedBytes
-		data.ContentEncoding = ceGzip
+		data.ContentEncoding = decision.Encoding
 	}
 
 	return nil
 }
 
-func (data *rawData) UnmarshalTo(v interface{}) error {
-	err := data.Decompress()
+// acceptableEncoding returns the first of the encodings that the client
+// accepts, or the empty string if none are acceptable. If encodings is
+// empty, only gzip is considered.
+func acceptableEncoding(r *http.Request, encodings []string) string {
+	if len(encodings) == 0 {
+		encodings = []string{ceGzip}
+	}
+
+	// TODO(jpj): this is a fairly naive handling of the Accept-Encoding
+	// header. In particular it does not handle gzip;q=0, which is
+	// a valid way of saying that gzip is not acceptable.
+	ae := r.Header.Get("Accept-Encoding")
+	for _, encoding := range encodings {
+		if encoding != ceGzip && encoding != ceDeflate {
+			continue
+		}
+		if strings.Contains(ae, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// UnmarshalTo unmarshals the content into v. Content that is not
+// acceptable according to the options is not unmarshalled.
+func (data *rawData) UnmarshalTo(v interface{}, opts jsonOptions) error {
+	if err := data.decompressRequest(); err != nil {
+		return err
+	}
+	if isYAML(data.ContentType) {
+		content, err := yamlToJSON(data.Content)
+		if err != nil {
+			return errkind.BadRequest("invalid YAML payload")
+		}
+		data.Content = content
+	}
+	if opts.maxDepth > 0 && jsonDepthExceeds(data.Content, opts.maxDepth) {
+		return errkind.BadRequest("JSON payload nested too deeply")
+	}
+	if opts.rejectDuplicateKeys {
+		if path, ok := duplicateJSONKey(data.Content); ok {
+			verr := ValidationError{Summary: "invalid JSON payload", Status: http.StatusBadRequest}
+			verr.Add(path, "duplicate key", "duplicate_key")
+			return &verr
+		}
+	}
+	if err := json.Unmarshal(data.Content, v); err != nil {
+		return errkind.BadRequest("invalid JSON payload")
+	}
+	return nil
+}
+
+// duplicateJSONKey returns the path of the first duplicate object key in the
+// JSON, eg "items.0.id", with an indication of whether there is one. Invalid
+// JSON is left for json.Unmarshal to report.
+func duplicateJSONKey(content []byte) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	path, err := findDuplicateKey(decoder, nil)
+	if err != nil || path == nil {
+		return "", false
+	}
+	return strings.Join(path, "."), true
+}
+
+// findDuplicateKey reads the next JSON value from the decoder, and returns
+// the path of the first duplicate object key in the value, or nil if there is
+// none. The path of the value is prefix.
+func findDuplicateKey(decoder *json.Decoder, prefix []string) ([]string, error) {
+	tok, err := decoder.Token()
 	if err != nil {
-		return errkind.BadRequest("cannot decompress payload")
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		keys := make(map[string]bool)
+		for decoder.More() {
+			tok, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := tok.(string)
+			path := append(prefix[:len(prefix):len(prefix)], key)
+			if keys[key] {
+				return path, nil
+			}
+			keys[key] = true
+			if path, err := findDuplicateKey(decoder, path); err != nil || path != nil {
+				return path, err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; decoder.More(); i++ {
+			path := append(prefix[:len(prefix):len(prefix)], strconv.Itoa(i))
+			if path, err := findDuplicateKey(decoder, path); err != nil || path != nil {
+				return path, err
+			}
+		}
+	default:
+		return nil, nil
 	}
-	err = json.Unmarshal(data.Content, v)
+	// closing delimiter
+	_, err = decoder.Token()
+	return nil, err
+}
+
+// jsonDepthExceeds reports whether objects and arrays in the JSON are nested
+// more deeply than maxDepth. It does not check that the JSON is valid.
+func jsonDepthExceeds(content []byte, maxDepth int) bool {
+	depth := 0
+	inString := false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++ // skip the escaped character
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			if depth++; depth > maxDepth {
+				return true
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return false
+}
+
+// ConvertResponse converts JSON content to YAML if the client prefers it.
+func (data *rawData) ConvertResponse(r *http.Request) error {
+	if !prefersYAML(r) {
+		return nil
+	}
+	content, err := jsonToYAML(data.Content)
 	if err != nil {
-		return errkind.BadRequest("invalid JSON payload")
+		return err
 	}
+	data.Content = content
+	data.ContentType = yamlContentType
+	data.UncompressedLength = len(content)
+	return nil
+}
+
+// MarshalFromBuffer is like MarshalFrom, but the content is marshalled
+// into the buffer, to avoid allocating. The content is only valid until
+// the buffer is reused.
+func (data *rawData) MarshalFromBuffer(v interface{}, buf *marshalBuffer) error {
+	buf.Reset()
+	if err := buf.encoder.Encode(v); err != nil {
+		return err
+	}
+	b := buf.Bytes()
+	data.Content = b[:len(b)-1] // without the newline appended by Encode
+	data.ContentType = "application/json"
+	data.ContentEncoding = ""
+	data.UncompressedLength = len(data.Content)
 	return nil
 }
 
@@ -222,3 +425,83 @@ func (data *rawData) MarshalFrom(v interface{}) error {
 	data.UncompressedLength = len(b)
 	return nil
 }
+
+// maxPooledSize is the largest capacity of a buffer that is returned to
+// its pool, so that an occasional large response does not hold memory.
+const maxPooledSize = 64 * 1024
+
+// marshalBuffer is a buffer with a JSON encoder that writes to it.
+type marshalBuffer struct {
+	bytes.Buffer
+	encoder *json.Encoder
+}
+
+var marshalBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := &marshalBuffer{}
+		buf.encoder = json.NewEncoder(&buf.Buffer)
+		return buf
+	},
+}
+
+func getMarshalBuffer() *marshalBuffer {
+	return marshalBufferPool.Get().(*marshalBuffer)
+}
+
+func putMarshalBuffer(buf *marshalBuffer) {
+	if buf.Cap() <= maxPooledSize {
+		marshalBufferPool.Put(buf)
+	}
+}
+
+// Pools of compressors, indexed by compression level, from
+// flate.HuffmanOnly to flate.BestCompression. Compressors allocate
+// hundreds of kilobytes, so it is worth reusing them.
+var (
+	gzipWriterPools [flate.BestCompression - flate.HuffmanOnly + 1]sync.Pool
+	zlibWriterPools [flate.BestCompression - flate.HuffmanOnly + 1]sync.Pool
+)
+
+// getCompressor returns a compressor for the content encoding and level
+// that writes to w. Return it to its pool with putCompressor once it has
+// been closed.
+func getCompressor(encoding string, level int, w io.Writer) (io.WriteCloser, error) {
+	i := level - flate.HuffmanOnly
+	pooled := i >= 0 && i < len(gzipWriterPools)
+	switch encoding {
+	case ceDeflate:
+		// HTTP deflate is the zlib format, not raw DEFLATE
+		if pooled {
+			if zw, ok := zlibWriterPools[i].Get().(*zlib.Writer); ok {
+				zw.Reset(w)
+				return zw, nil
+			}
+		}
+		return zlib.NewWriterLevel(w, level)
+	case ceGzip:
+		if pooled {
+			if gw, ok := gzipWriterPools[i].Get().(*gzip.Writer); ok {
+				gw.Reset(w)
+				return gw, nil
+			}
+		}
+		return gzip.NewWriterLevel(w, level)
+	}
+	return nil, errors.New("unsupported content-encoding").
+		With("content-encoding", encoding)
+}
+
+// putCompressor returns a compressor obtained from getCompressor
+// with the same level to its pool.
+func putCompressor(level int, w io.WriteCloser) {
+	i := level - flate.HuffmanOnly
+	if i < 0 || i >= len(gzipWriterPools) {
+		return
+	}
+	switch w := w.(type) {
+	case *zlib.Writer:
+		zlibWriterPools[i].Put(w)
+	case *gzip.Writer:
+		gzipWriterPools[i].Put(w)
+	}
+}
@@ -1,3 +1,54 @@
 package httpapi
 
+import (
+	"strings"
+	"testing"
+)
+
 // TODO(jpj): tests for marshalling/unmarshalling, compressing/decompressing raw data
+
+func TestJSONDepthExceeds(t *testing.T) {
+	tests := []struct {
+		content  string
+		maxDepth int
+		want     bool
+	}{
+		{content: `{"a":1}`, maxDepth: 1, want: false},
+		{content: `{"a":{"b":1}}`, maxDepth: 1, want: true},
+		{content: `[[1],[2],{"a":[3]}]`, maxDepth: 3, want: false},
+		{content: `[[1],[2],{"a":[3]}]`, maxDepth: 2, want: true},
+		{content: `{"a":"{{{{[[[["}`, maxDepth: 1, want: false},
+		{content: `{"a":"\"{{{{"}`, maxDepth: 1, want: false},
+		{content: `{"a":"\\","b":[{}]}`, maxDepth: 2, want: true},
+		{content: `"plain"`, maxDepth: 1, want: false},
+		{content: strings.Repeat("[", 10000), maxDepth: 100, want: true},
+	}
+	for i, tt := range tests {
+		if got := jsonDepthExceeds([]byte(tt.content), tt.maxDepth); got != tt.want {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestDuplicateJSONKey(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: `{"a":1,"b":2}`},
+		{content: `{"a":1,"a":2}`, want: "a"},
+		{content: `{"a":{"b":1},"c":{"b":2}}`},
+		{content: `{"a":{"b":1,"c":2,"b":3}}`, want: "a.b"},
+		{content: `{"items":[{"id":1},{"id":2,"name":"x","id":3}]}`, want: "items.1.id"},
+		{content: `[{"a":1},{"a":1}]`},
+		{content: `{"a":"{\"b\":1,\"b\":2}"}`},
+		{content: `{"a":[1,`}, // invalid JSON is reported by json.Unmarshal
+		{content: `"plain"`},
+	}
+	for i, tt := range tests {
+		got, ok := duplicateJSONKey([]byte(tt.content))
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("%d: want %q, got %q, %v", i, tt.want, got, ok)
+		}
+	}
+}
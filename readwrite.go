@@ -1,8 +1,10 @@
 package httpapi
 
 import (
-	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/jjeffery/errkind"
 	"github.com/jjeffery/errors"
@@ -12,44 +14,162 @@ import (
 // ReadRequest reads the request body as JSON, and unmarshals it
 // into the structure pointed to by body.
 //
+// If the request has a YAML content type, such as "application/yaml",
+// the body is read as YAML, using the JSON names of the fields in body.
+//
+// The body must contain a single JSON value, or YAML document. A body with
+// anything other than whitespace after the value, eg {"a":1}{"b":2}, is
+// rejected with a 400 (Bad Request) error, rather than the rest of the body
+// being ignored.
+//
 // Although not specified in the HTTP spec, if the request contains a
 // header "Content-Encoding: gzip", then the request body will be decompressed.
 // This is convenient for HTTP clients that PUT or POST large JSON content.
+// The decompressed body is subject to the same size limit as the request body.
+//
+// A body with objects and arrays nested more deeply than the limit for the
+// route (see Route.MaxJSONDepth) is rejected with a 400 (Bad Request) error,
+// as is a body with duplicate object keys, if the route rejects them (see
+// Route.RejectDuplicateKeys). The error lists the path of the duplicate key.
+//
+// If the request is handled by CaptureRequestBody, a copy of the decompressed
+// body is kept for later use by middleware, such as audit logging.
 func ReadRequest(r *http.Request, body interface{}) error {
 	var data rawData
 	if err := data.ReadRequest(r); err != nil {
 		return err
 	}
-	if err := data.UnmarshalTo(body); err != nil {
+	if err := data.decompressRequest(); err != nil {
 		return err
 	}
-	return nil
+	captureBody(r, data.Content)
+	err := data.UnmarshalTo(body, jsonOptionsFor(r))
+	logPayload(r, PayloadRequest, 0, data.Content)
+	return err
 }
 
 // WriteResponse sends the response as JSON to the HTTP client. The
 // response is compressed if the HTTP client is able to accept compressed
-// responses.
+// responses, and has a "Vary: Accept, Accept-Encoding" header so that
+// caches do not send a compressed response to other clients.
+//
+// If the request's Accept header prefers YAML, eg "application/yaml",
+// to JSON, the response is sent as YAML instead. The YAML has the same
+// field names and order as the JSON.
 func WriteResponse(w http.ResponseWriter, r *http.Request, body interface{}) {
 	// Special case if the body is an error.
 	if err, ok := body.(error); ok {
 		WriteError(w, r, err)
 		return
 	}
+	writeResponse(w, r, http.StatusOK, body)
+}
 
+// WriteCreated sends the response as JSON to the HTTP client with a 201
+// (Created) status, and a Location header with the URL of the new resource.
+// A relative location is resolved against the request URL, so for a POST
+// request to "/things/", a location of "42" becomes "/things/42". If location
+// is empty, there is no Location header. Otherwise the response is the same
+// as for WriteResponse, including compression, and a body that is an error
+// is sent using WriteError.
+//
+// For example:
+//  thing, err := create(ctx, input)
+//  if err != nil {
+//      return err
+//  }
+//  httpapi.WriteCreated(w, r, "/things/"+thing.ID, thing)
+func WriteCreated(w http.ResponseWriter, r *http.Request, location string, body interface{}) {
+	if err, ok := body.(error); ok {
+		WriteError(w, r, err)
+		return
+	}
+	if location != "" {
+		u, err := resolveLocation(r, location)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		w.Header().Set("Location", u)
+	}
+	writeResponse(w, r, http.StatusCreated, body)
+}
+
+// WriteAccepted sends the response as JSON to the HTTP client with a 202
+// (Accepted) status, for a request that starts a long-running operation.
+// The Location and Content-Location headers are set to statusURL, which is
+// the URL the client polls for the status of the operation, and is resolved
+// against the request URL in the same way as for WriteCreated. The body is
+// usually the current status of the operation.
+//
+// If the body has a method RetryAfter() time.Duration that returns a positive
+// duration, the response has a Retry-After header, which tells the client
+// how long to wait before polling. Otherwise the response is the same as for
+// WriteResponse, and a body that is an error is sent using WriteError.
+//
+// For example:
+//  job := startExport(ctx, input)
+//  httpapi.WriteAccepted(w, r, "/jobs/"+job.ID, job)
+func WriteAccepted(w http.ResponseWriter, r *http.Request, statusURL string, body interface{}) {
+	if err, ok := body.(error); ok {
+		WriteError(w, r, err)
+		return
+	}
+	if statusURL != "" {
+		u, err := resolveLocation(r, statusURL)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		w.Header().Set("Location", u)
+		w.Header().Set("Content-Location", u)
+	}
+	if ra, ok := body.(interface{ RetryAfter() time.Duration }); ok {
+		SetRetryAfter(w.Header(), ra.RetryAfter())
+	}
+	writeResponse(w, r, http.StatusAccepted, body)
+}
+
+// resolveLocation resolves the location against the request URL.
+func resolveLocation(r *http.Request, location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid location").With("location", location)
+	}
+	return r.URL.ResolveReference(u).String(), nil
+}
+
+// writeResponse is WriteResponse with a success status other than 200.
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
 	var data rawData
+	buf := getMarshalBuffer()
+	defer putMarshalBuffer(buf)
+
+	if err := data.MarshalFromBuffer(body, buf); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	logPayload(r, PayloadResponse, status, data.Content)
 
-	if err := data.MarshalFrom(body); err != nil {
+	// the response is JSON or YAML, depending on the Accept header
+	addVary(w.Header(), "Accept")
+	if err := data.ConvertResponse(r); err != nil {
 		WriteError(w, r, err)
 		return
 	}
 
+	if compressionAllowed && len(data.Content) > 0 {
+		// whether the response is compressed depends on the
+		// Accept-Encoding header, which caches need to know
+		addVary(w.Header(), "Accept-Encoding")
+	}
 	if err := data.CompressResponse(r); err != nil {
 		WriteError(w, r, err)
 		return
 	}
 
 	// TODO(jpj): log this if  logging/tracing becomes available
-	_ = data.WriteResponse(w)
+	_ = data.WriteResponseStatus(w, status)
 }
 
 // WriteError writes an error message as a JSON object.
@@ -70,6 +190,9 @@ func WriteResponse(w http.ResponseWriter, r *http.Request, body interface{}) {
 // originated on the local host (but does not include requests routed through
 // a local reverse proxy).
 //
+// Errors are written as JSON, unless the request's Accept header prefers plain
+// text or HTML to JSON, as is the case for a browser requesting the URL directly.
+//
 // The writeerror subdirectory package provides configuration on how errors are marshalled
 // to the client, and how details of the errors are logged and/or traced. The
 // defaults are sensible, so this function can be used with no configuration.
@@ -80,61 +203,122 @@ func WriteError(w http.ResponseWriter, r *http.Request, err error) {
 	config := writeerror.ConfigFromRequest(r)
 
 	// build the content to send to the client
-	var content writeerror.Content
-	{
-		cause := errors.Cause(err)
+	content := ErrorContent(r, err)
 
-		// use the status code if it is public
-		if _, ok := cause.(interface{ PublicStatusCode() }); ok {
-			content.StatusCode = errkind.StatusCode(cause)
-		}
-		if content.StatusCode < 400 || content.StatusCode > 599 {
-			content.StatusCode = http.StatusInternalServerError
-		}
-
-		// use the message if it is public, otherwise use the
-		// message for the status code
-		if _, ok := cause.(interface{ PublicMessage() }); ok {
-			// The errkind package has errors that have a Message() method
-			// that returns the message without the code. Useful here because
-			// the code is kept in a separate field in the returned error.
-			// TODO(jpj): this seems a little overcomplicated.
-			if messager, ok := cause.(interface{ Message() string }); ok {
-				content.Message = messager.Message()
-			} else {
-				content.Message = cause.Error()
-			}
-		}
-		if content.Message == "" {
-			content.Message = http.StatusText(content.StatusCode)
-		}
-
-		if _, ok := cause.(interface{ PublicCode() }); ok {
-			content.Code = errkind.Code(cause)
-		}
-
-		content.Trace = config.GetTrace(r)
-
-		if config.IsTrusted(r) {
-			// only include the error in the content for trusted clients
-			content.Err = err
-		}
-	}
-
-	// build the content bytes to write to the client
-	data := config.MarshalContent(&content)
+	// build the content bytes to write to the client, in
+	// the format that best matches the Accept header
+	contentType, data := config.MarshalRequest(r, content)
 
 	// write the response to the client
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if cacheControl := config.CacheControlFor(content.StatusCode); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	SetRetryAfter(w.Header(), content.RetryAfter)
 	w.WriteHeader(content.StatusCode)
 	w.Write(data)
 
 	// Populate the Err property if it has not been populated earlier
 	// so that it can be included in log messages or other diagnostics.
 	content.Err = err
+	config.Redact(content)
 
 	// call errorWritten for logging/tracing/diagnostics
-	config.ErrorWritten(r, &content)
+	config.ErrorWritten(r, content)
+}
+
+// ErrorContent returns the content that WriteError sends to the client
+// for err. It is useful for sending errors to clients in the same format
+// as WriteError over other channels, such as WebSocket connections.
+//
+// The content only includes err in the Err field if the client is trusted.
+// The RedactContent callback of the writeerror configuration has already
+// been applied to the content.
+func ErrorContent(r *http.Request, err error) *writeerror.Content {
+	config := writeerror.ConfigFromRequest(r)
+	content := newErrorContent(err)
+	content.Trace = config.GetTrace(r)
+	if r.URL != nil {
+		content.Instance = r.URL.Path
+	}
+
+	if config.IsTrusted(r) {
+		// only include the error and its stack trace
+		// in the content for trusted clients
+		content.Err = err
+		content.Stack = errorStack(err)
+	}
+
+	// remove any secrets before the content leaves the server
+	config.Redact(content)
+	return content
+}
+
+// ErrorBody returns the body and HTTP status code that WriteError would
+// send to an untrusted client for err, using the default writeerror
+// configuration. It does not need a HTTP request, so it can be used
+// by background workers that need to format errors in the same way.
+func ErrorBody(err error) ([]byte, int) {
+	return ErrorBodyConfig(writeerror.Default, err)
+}
+
+// ErrorBodyConfig is like ErrorBody, but the body is marshalled using
+// the specified configuration. Because there is no HTTP request, the
+// GetTrace, IsTrusted and ErrorWritten callbacks are not called.
+// The body may be shared (see writeerror.Config.Marshal), so it must
+// not be modified.
+func ErrorBodyConfig(config writeerror.Config, err error) ([]byte, int) {
+	content := newErrorContent(err)
+	config.Redact(content)
+	return config.Marshal(content), content.StatusCode
+}
+
+// newErrorContent returns the content for err that is suitable for sending
+// to any client. The Trace and Err fields are not populated.
+func newErrorContent(err error) *writeerror.Content {
+	if err == nil {
+		err = errkind.Public("no information available", http.StatusInternalServerError)
+	}
+
+	var content writeerror.Content
+	cause := errors.Cause(err)
+
+	// use the status code if it is public
+	if _, ok := cause.(interface{ PublicStatusCode() }); ok {
+		content.StatusCode = errkind.StatusCode(cause)
+	}
+	if content.StatusCode < 400 || content.StatusCode > 599 {
+		content.StatusCode = http.StatusInternalServerError
+	}
+
+	// use the message if it is public, otherwise use the
+	// message for the status code
+	if _, ok := cause.(interface{ PublicMessage() }); ok {
+		// The errkind package has errors that have a Message() method
+		// that returns the message without the code. Useful here because
+		// the code is kept in a separate field in the returned error.
+		// TODO(jpj): this seems a little overcomplicated.
+		if messager, ok := cause.(interface{ Message() string }); ok {
+			content.Message = messager.Message()
+		} else {
+			content.Message = cause.Error()
+		}
+	}
+	if content.Message == "" {
+		content.Message = http.StatusText(content.StatusCode)
+	}
+
+	if _, ok := cause.(interface{ PublicCode() }); ok {
+		content.Code = errkind.Code(cause)
+	}
+
+	if verr, ok := cause.(*ValidationError); ok {
+		content.Fields = verr.fieldErrors()
+	}
+
+	content.RetryAfter = retryAfter(err)
+
+	return &content
 }
@@ -2,13 +2,20 @@ package httpapi
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi/writeerror"
 )
 
 func readCloserFromString(s string) io.ReadCloser {
@@ -85,6 +92,40 @@ func TestReadRequest(t *testing.T) {
 			body:       infiniteReadCloser{},
 			wantStatus: http.StatusRequestEntityTooLarge,
 		},
+		{
+			header: http.Header{
+				"Content-Type":   []string{"application/json"},
+				"Content-Length": []string{"23"},
+			},
+			body: readCloserFromString(`{"String":"S","Int":99}`),
+			want: Payload{String: "S", Int: 99},
+		},
+		{
+			// body shorter than content length
+			header: http.Header{
+				"Content-Type":   []string{"application/json"},
+				"Content-Length": []string{"30"},
+			},
+			body:       readCloserFromString(`{"String":"S","Int":99}`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			// body longer than content length
+			header: http.Header{
+				"Content-Type":   []string{"application/json"},
+				"Content-Length": []string{"23"},
+			},
+			body:       readCloserFromString(`{"String":"S","Int":99}GET / HTTP/1.1`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			header: http.Header{
+				"Content-Type":   []string{"application/json"},
+				"Content-Length": []string{"10"},
+			},
+			body:       infiniteReadCloser{},
+			wantStatus: http.StatusBadRequest,
+		},
 	}
 	for i, tt := range tests {
 		r := http.Request{
@@ -111,3 +152,398 @@ func TestReadRequest(t *testing.T) {
 func TestWriteResponse(t *testing.T) {
 
 }
+
+func TestErrorBody(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			err:        errkind.Public("not here", http.StatusNotFound),
+			wantStatus: http.StatusNotFound,
+			wantBody:   "{\n  \"error\": {\n    \"message\": \"not here\",\n    \"status\": 404\n  }\n}\n",
+		},
+		{
+			err:        errors.New("secret implementation detail"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "{\n  \"error\": {\n    \"message\": \"Internal Server Error\",\n    \"status\": 500\n  }\n}\n",
+		},
+	}
+	for i, tt := range tests {
+		body, status := ErrorBody(tt.err)
+		if status != tt.wantStatus {
+			t.Errorf("%d: want status=%d, got %d", i, tt.wantStatus, status)
+		}
+		if got := string(body); got != tt.wantBody {
+			t.Errorf("%d: want body %q, got %q", i, tt.wantBody, got)
+		}
+	}
+}
+
+func TestWriteErrorProblem(t *testing.T) {
+	config := writeerror.Config{
+		MarshalContent: writeerror.MarshalProblem,
+		ContentType:    writeerror.ProblemContentType,
+	}
+	h := writeerror.Middleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, errkind.Public("no such thing", http.StatusNotFound))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/things/1", nil))
+
+	if got, want := w.Header().Get("Content-Type"), "application/problem+json"; got != want {
+		t.Errorf("content-type: want %q, got %q", want, got)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"type":     "about:blank",
+		"title":    "Not Found",
+		"status":   float64(404),
+		"detail":   "no such thing",
+		"instance": "/things/1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestWriteErrorRedact(t *testing.T) {
+	var logged *writeerror.Content
+	config := writeerror.Config{
+		IsTrusted:     func(*http.Request) bool { return true },
+		RedactContent: writeerror.RedactPatterns(regexp.MustCompile(`secret-\w+`)),
+		ErrorWritten:  func(r *http.Request, c *writeerror.Content) { logged = c },
+	}
+	h := writeerror.Middleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, errkind.Public("key secret-abc is invalid", http.StatusBadRequest))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if body := w.Body.String(); strings.Contains(body, "secret-abc") {
+		t.Errorf("body contains secret: %s", body)
+	}
+	if logged == nil {
+		t.Fatal("ErrorWritten not called")
+	}
+	if got, want := logged.Message, "key [REDACTED] is invalid"; got != want {
+		t.Errorf("message: want %q, got %q", want, got)
+	}
+	if got := logged.Err.Error(); strings.Contains(got, "secret-abc") {
+		t.Errorf("logged error contains secret: %s", got)
+	}
+}
+
+// discardResponseWriter is a response writer that
+// discards the response, for benchmarks.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+func (w *discardResponseWriter) reset() {
+	for k := range w.header {
+		delete(w.header, k)
+	}
+}
+
+func TestWriteResponsePooled(t *testing.T) {
+	// the response must not be affected by buffers reused for earlier responses
+	bodies := []interface{}{
+		map[string]string{"name": strings.Repeat("x", 1000)},
+		map[string]string{"a": "b"},
+		[]int{1, 2, 3},
+	}
+	for i, body := range bodies {
+		for _, ae := range []string{"", "gzip", "deflate"} {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept-Encoding", ae)
+			w := httptest.NewRecorder()
+			WriteResponse(w, r, body)
+
+			var want bytes.Buffer
+			json.NewEncoder(&want).Encode(body)
+			got, err := decodeBody(w)
+			if err != nil {
+				t.Fatalf("%d: %v", i, err)
+			}
+			if strings.TrimSpace(want.String()) != string(got) {
+				t.Errorf("%d: %s: want %s, got %s", i, ae, want.String(), got)
+			}
+		}
+	}
+}
+
+func TestWriteCreated(t *testing.T) {
+	tests := []struct {
+		target   string
+		location string
+		body     interface{}
+		status   int
+		want     string
+	}{
+		{target: "/things/", location: "42", status: http.StatusCreated, want: "/things/42"},
+		{target: "/things?x=1", location: "/things/42", status: http.StatusCreated, want: "/things/42"},
+		{target: "/v1/things", location: "../v2/things/42", status: http.StatusCreated, want: "/v2/things/42"},
+		{target: "/things", location: "https://example.com/things/42", status: http.StatusCreated, want: "https://example.com/things/42"},
+		{target: "/things", status: http.StatusCreated},
+		{target: "/things", location: "42", body: errkind.BadRequest("bad"), status: http.StatusBadRequest},
+		{target: "/things", location: "%zz", status: http.StatusInternalServerError},
+	}
+	for i, tt := range tests {
+		body := tt.body
+		if body == nil {
+			body = map[string]string{"id": "42"}
+		}
+		r := httptest.NewRequest("POST", tt.target, nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		WriteCreated(w, r, tt.location, body)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("Location"), tt.want; got != want {
+			t.Errorf("%d: location: want %q, got %q", i, want, got)
+		}
+		if tt.status != http.StatusCreated {
+			continue
+		}
+		got, err := decodeBody(w)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if want := `{"id":"42"}`; string(got) != want {
+			t.Errorf("%d: body: want %s, got %s", i, want, got)
+		}
+	}
+}
+
+type testJob struct {
+	ID    string        `json:"id"`
+	Retry time.Duration `json:"-"`
+}
+
+func (j testJob) RetryAfter() time.Duration {
+	return j.Retry
+}
+
+func TestWriteAccepted(t *testing.T) {
+	tests := []struct {
+		statusURL  string
+		body       interface{}
+		status     int
+		location   string
+		retryAfter string
+	}{
+		{
+			statusURL: "/jobs/1",
+			body:      testJob{ID: "1"},
+			status:    http.StatusAccepted,
+			location:  "/jobs/1",
+		},
+		{
+			statusURL:  "jobs/1",
+			body:       testJob{ID: "1", Retry: 1500 * time.Millisecond},
+			status:     http.StatusAccepted,
+			location:   "/v1/jobs/1",
+			retryAfter: "2",
+		},
+		{
+			body:   testJob{ID: "1"},
+			status: http.StatusAccepted,
+		},
+		{
+			statusURL: "/jobs/1",
+			body:      errkind.Public("busy", http.StatusServiceUnavailable),
+			status:    http.StatusServiceUnavailable,
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("POST", "/v1/exports", nil)
+		w := httptest.NewRecorder()
+		WriteAccepted(w, r, tt.statusURL, tt.body)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("Location"), tt.location; got != want {
+			t.Errorf("%d: location: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Content-Location"), tt.location; got != want {
+			t.Errorf("%d: content-location: want %q, got %q", i, want, got)
+		}
+		if got, want := w.Header().Get("Retry-After"), tt.retryAfter; got != want {
+			t.Errorf("%d: retry-after: want %q, got %q", i, want, got)
+		}
+		if tt.status == http.StatusAccepted {
+			if got, want := w.Body.String(), `{"id":"1"}`; got != want {
+				t.Errorf("%d: body: want %s, got %s", i, want, got)
+			}
+		}
+	}
+}
+
+func decodeBody(w *httptest.ResponseRecorder) ([]byte, error) {
+	data := rawData{
+		Content:         w.Body.Bytes(),
+		ContentEncoding: w.Header().Get("Content-Encoding"),
+	}
+	if err := data.Decompress(); err != nil {
+		return nil, err
+	}
+	return data.Content, nil
+}
+
+func BenchmarkWriteResponse(b *testing.B) {
+	body := &struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}{ID: "123", Name: "thing", Count: 42}
+	r := httptest.NewRequest("GET", "/things/123", nil)
+	w := &discardResponseWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.reset()
+		WriteResponse(w, r, body)
+	}
+}
+
+func BenchmarkWriteResponseGzip(b *testing.B) {
+	var body []map[string]interface{}
+	for i := 0; i < 100; i++ {
+		body = append(body, map[string]interface{}{"id": i, "name": "thing", "description": "a thing"})
+	}
+	r := httptest.NewRequest("GET", "/things", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := &discardResponseWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.reset()
+		WriteResponse(w, r, body)
+	}
+}
+
+func BenchmarkWriteError(b *testing.B) {
+	err := errors.New("not public")
+	r := httptest.NewRequest("GET", "/things/123", nil)
+	r.RemoteAddr = "192.0.2.1:1234" // untrusted
+	w := &discardResponseWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.reset()
+		WriteError(w, r, err)
+	}
+}
+
+func TestReadRequestMaxJSONDepth(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat(`{"a":`, depth) + "1" + strings.Repeat("}", depth)
+	}
+	tests := []struct {
+		route  *Route
+		body   string
+		status int
+	}{
+		{body: nested(DefaultMaxJSONDepth)},
+		{body: nested(DefaultMaxJSONDepth + 1), status: http.StatusBadRequest},
+		{route: &Route{MaxJSONDepth: 2}, body: nested(2)},
+		{route: &Route{MaxJSONDepth: 2}, body: nested(3), status: http.StatusBadRequest},
+		{route: &Route{MaxJSONDepth: -1}, body: nested(DefaultMaxJSONDepth + 1)},
+	}
+	for i, tt := range tests {
+		var err error
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var v interface{}
+			err = ReadRequest(r, &v)
+		})
+		var handler http.Handler = h
+		if tt.route != nil {
+			handler = tt.route.Handler(h)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(tt.body)))
+		if tt.status == 0 {
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+			}
+			continue
+		}
+		if got := errkind.StatusCode(err); got != tt.status {
+			t.Errorf("%d: want %d, got %v", i, tt.status, err)
+		}
+	}
+}
+
+func TestReadRequestRejectDuplicateKeys(t *testing.T) {
+	const body = `{"id":1,"tags":[{"name":"a","name":"b"}]}`
+	tests := []struct {
+		route   *Route
+		strict  bool
+		wantErr string
+	}{
+		{},
+		{route: &Route{}},
+		{route: &Route{RejectDuplicateKeys: true}, wantErr: "invalid JSON payload: tags.0.name: duplicate key"},
+		{strict: true, wantErr: "invalid JSON payload: tags.0.name: duplicate key"},
+	}
+	defer func(v bool) { DefaultRejectDuplicateKeys = v }(DefaultRejectDuplicateKeys)
+	for i, tt := range tests {
+		DefaultRejectDuplicateKeys = tt.strict
+		var err error
+		var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var v interface{}
+			err = ReadRequest(r, &v)
+		})
+		if tt.route != nil {
+			handler = tt.route.Handler(handler)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%d: want %q, got %v", i, tt.wantErr, err)
+		}
+		if got := errkind.StatusCode(err); got != http.StatusBadRequest {
+			t.Errorf("%d: want 400, got %d", i, got)
+		}
+	}
+}
+
+func TestReadRequestTrailingData(t *testing.T) {
+	tests := []struct {
+		body    string
+		wantErr bool
+	}{
+		{body: `{"a":1}`},
+		{body: "{\"a\":1}\r\n\t "},
+		{body: `{"a":1}{"b":2}`, wantErr: true},
+		{body: `{"a":1} {"b":2}`, wantErr: true},
+		{body: `{"a":1}x`, wantErr: true},
+		{body: `[1] [2]`, wantErr: true},
+		{body: `1 2`, wantErr: true},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		var v interface{}
+		err := ReadRequest(r, &v)
+		if !tt.wantErr {
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+			}
+			continue
+		}
+		if got := errkind.StatusCode(err); got != http.StatusBadRequest {
+			t.Errorf("%d: want 400, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+)
+
+// Recover returns middleware that recovers from panics in the handler. The
+// panic is converted into an error with a 500 status, which is sent to the
+// client using WriteError, so the response has the same shape as any other
+// error response. The error carries the stack trace of the panic, which is
+// included in the response for trusted clients, and is available to the
+// ErrorWritten callback of the writeerror configuration.
+//
+// A panic with the value http.ErrAbortHandler is not recovered, so that the
+// HTTP server can abort the response as intended.
+func Recover() Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				WriteError(w, r, newPanicError(v))
+			}()
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// panicError is an error created from a recovered panic.
+type panicError struct {
+	value   interface{}
+	callers []uintptr
+}
+
+func newPanicError(v interface{}) *panicError {
+	// skip runtime.Callers, newPanicError and the deferred function
+	var pcs [64]uintptr
+	n := runtime.Callers(3, pcs[:])
+	return &panicError{
+		value:   v,
+		callers: pcs[:n],
+	}
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// Callers returns the program counters of the stack at the point of the panic.
+func (e *panicError) Callers() []uintptr {
+	return e.callers
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *panicError) Unwrap() error {
+	err, _ := e.value.(error)
+	return err
+}
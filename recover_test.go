@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+func TestRecover(t *testing.T) {
+	var logged *writeerror.Content
+	config := writeerror.Config{
+		IsTrusted:    func(*http.Request) bool { return false },
+		ErrorWritten: func(r *http.Request, c *writeerror.Content) { logged = c },
+	}
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: "boom", want: "panic: boom"},
+		{value: errors.New("failed"), want: "panic: failed"},
+	}
+	for i, tt := range tests {
+		logged = nil
+		h := writeerror.Middleware(config)(Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(tt.value)
+		})))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		var body struct {
+			Error struct {
+				Message string `json:"message"`
+				Detail  string `json:"detail"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if body.Error.Detail != "" {
+			t.Errorf("%d: detail sent to untrusted client: %q", i, body.Error.Detail)
+		}
+		if logged == nil {
+			t.Errorf("%d: ErrorWritten not called", i)
+			continue
+		}
+		if got := logged.Err.Error(); got != tt.want {
+			t.Errorf("%d: err: want %q, got %q", i, tt.want, got)
+		}
+		if stack := errorStack(logged.Err); !strings.Contains(stack, "TestRecover") {
+			t.Errorf("%d: stack does not contain panic location:\n%s", i, stack)
+		}
+	}
+}
+
+func TestRecoverAbort(t *testing.T) {
+	h := Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("want ErrAbortHandler, got %v", v)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,85 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Resource is a handler for a resource that passes each request to the
+// handler for the request method. It answers OPTIONS requests with an Allow
+// header listing the methods of the resource, and sends a 405 (Method Not
+// Allowed) error with the same Allow header for methods that the resource
+// does not implement. HEAD requests are passed to the GET handler, unless
+// there is a HEAD handler. For example:
+//  http.Handle("/things/{id}", &httpapi.Resource{
+//      Handlers: map[string]http.Handler{
+//          "GET":    httpapi.HandlerFunc(getThing),
+//          "PUT":    httpapi.HandlerFunc(putThing),
+//          "DELETE": httpapi.HandlerFunc(deleteThing),
+//      },
+//  })
+type Resource struct {
+	// Handlers are the handlers for the methods that the resource
+	// implements, keyed by method, eg "GET". If there is an OPTIONS
+	// handler, it handles OPTIONS requests instead of the Resource.
+	Handlers map[string]http.Handler
+
+	// Description is an optional description of the resource, such as
+	// its parameters and the format of its requests and responses, which
+	// is sent as JSON in the response to OPTIONS requests.
+	Description interface{}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (res *Resource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h := res.handler(r.Method); h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+	allowed := make([]string, 0, len(res.Handlers)+1)
+	for method := range res.Handlers {
+		allowed = append(allowed, method)
+	}
+	allowed = append(allowed, http.MethodOptions)
+	if r.Method == http.MethodOptions {
+		OptionsHandler(res.Description, allowed...).ServeHTTP(w, r)
+		return
+	}
+	MethodNotAllowedHandler(allowed...).ServeHTTP(w, r)
+}
+
+// handler returns the handler for the method, or nil if there is none.
+func (res *Resource) handler(method string) http.Handler {
+	method = strings.ToUpper(method)
+	var get http.Handler
+	for key, h := range res.Handlers {
+		switch strings.ToUpper(key) {
+		case method:
+			return h
+		case http.MethodGet:
+			get = h
+		}
+	}
+	if method == http.MethodHead {
+		return get
+	}
+	return nil
+}
+
+// OptionsHandler returns a handler that answers OPTIONS requests for a
+// resource. The Allow header is set to the allowed methods, in the same
+// format as for MethodNotAllowedHandler, and always includes OPTIONS. If
+// description is nil, the response has a 204 (No Content) status, otherwise
+// description is sent as JSON using WriteResponse.
+func OptionsHandler(description interface{}, allowed ...string) http.Handler {
+	allow := AllowHeader(append([]string{http.MethodOptions}, allowed...)...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if description == nil {
+			w.Header().Set("Content-Length", "0")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		WriteResponse(w, r, description)
+	})
+}
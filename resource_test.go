@@ -0,0 +1,88 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResource(t *testing.T) {
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, name)
+		})
+	}
+	tests := []struct {
+		res    Resource
+		method string
+		status int
+		allow  string
+		body   string
+	}{
+		{
+			res:    Resource{Handlers: map[string]http.Handler{"GET": handler("get"), "put": handler("put")}},
+			method: "PUT",
+			status: http.StatusOK,
+			body:   "put",
+		},
+		{
+			res:    Resource{Handlers: map[string]http.Handler{"GET": handler("get")}},
+			method: "HEAD",
+			status: http.StatusOK,
+		},
+		{
+			res:    Resource{Handlers: map[string]http.Handler{"GET": handler("get"), "HEAD": handler("head")}},
+			method: "HEAD",
+			status: http.StatusOK,
+		},
+		{
+			res:    Resource{Handlers: map[string]http.Handler{"GET": handler("get"), "PUT": handler("put")}},
+			method: "OPTIONS",
+			status: http.StatusNoContent,
+			allow:  "GET, HEAD, OPTIONS, PUT",
+		},
+		{
+			res: Resource{
+				Handlers:    map[string]http.Handler{"POST": handler("post")},
+				Description: map[string]string{"description": "things"},
+			},
+			method: "OPTIONS",
+			status: http.StatusOK,
+			allow:  "OPTIONS, POST",
+			body:   `{"description":"things"}`,
+		},
+		{
+			res:    Resource{Handlers: map[string]http.Handler{"OPTIONS": handler("options")}},
+			method: "OPTIONS",
+			status: http.StatusOK,
+			body:   "options",
+		},
+		{
+			res:    Resource{Handlers: map[string]http.Handler{"GET": handler("get"), "DELETE": handler("delete")}},
+			method: "PATCH",
+			status: http.StatusMethodNotAllowed,
+			allow:  "DELETE, GET, HEAD, OPTIONS",
+		},
+		{
+			method: "GET",
+			status: http.StatusMethodNotAllowed,
+			allow:  "OPTIONS",
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.res.ServeHTTP(w, httptest.NewRequest(tt.method, "/things", nil))
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("Allow"), tt.allow; got != want {
+			t.Errorf("%d: allow: want %q, got %q", i, want, got)
+		}
+		if tt.body != "" {
+			if got, want := w.Body.String(), tt.body; got != want {
+				t.Errorf("%d: body: want %q, got %q", i, want, got)
+			}
+		}
+	}
+}
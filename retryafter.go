@@ -0,0 +1,73 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryAfter returns an error that wraps err, and informs the client that
+// it can retry the request after the duration d. When the error is passed to
+// WriteError, the response includes a Retry-After header. This is useful for
+// throttling (429 Too Many Requests) and maintenance (503 Service Unavailable)
+// responses. The status, message and code of the error are those of err.
+//
+// Any error in the chain of causes with a method RetryAfter() time.Duration
+// also causes the Retry-After header to be sent.
+func RetryAfter(err error, d time.Duration) error {
+	return &retryAfterError{err: err, d: d}
+}
+
+type retryAfterError struct {
+	err error
+	d   time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the wrapped error, so that its public
+// status, message and code are sent to the client.
+func (e *retryAfterError) Cause() error {
+	return e.err
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter returns the duration the client should wait
+// before retrying the request.
+func (e *retryAfterError) RetryAfter() time.Duration {
+	return e.d
+}
+
+// retryAfter returns the first positive retry duration in the chain
+// of causes of err, or zero if there is none.
+func retryAfter(err error) time.Duration {
+	for err != nil {
+		if ra, ok := err.(interface{ RetryAfter() time.Duration }); ok && ra.RetryAfter() > 0 {
+			return ra.RetryAfter()
+		}
+		switch e := err.(type) {
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			err = nil
+		}
+	}
+	return 0
+}
+
+// SetRetryAfter sets the Retry-After header to the duration d, rounded
+// up to whole seconds. It does nothing if d is not positive.
+func SetRetryAfter(h http.Header, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	seconds := (d + time.Second - 1) / time.Second
+	h.Set("Retry-After", strconv.FormatInt(int64(seconds), 10))
+}
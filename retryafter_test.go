@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		header string
+	}{
+		{
+			err:    RetryAfter(errkind.Public("slow down", http.StatusTooManyRequests), 30*time.Second),
+			status: http.StatusTooManyRequests,
+			header: "30",
+		},
+		{
+			err:    errors.Wrap(RetryAfter(errkind.Public("maintenance", http.StatusServiceUnavailable), 1500*time.Millisecond), "wrapped"),
+			status: http.StatusServiceUnavailable,
+			header: "2",
+		},
+		{
+			err:    errkind.Public("no retry", http.StatusServiceUnavailable),
+			status: http.StatusServiceUnavailable,
+			header: "",
+		},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		WriteError(w, httptest.NewRequest("GET", "/", nil), tt.err)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := w.Header().Get("Retry-After"), tt.header; got != want {
+			t.Errorf("%d: Retry-After: want %q, got %q", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package httpapi
+
+import (
+	"compress/flate"
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Route contains metadata about an API route. The metadata is associated
+// with requests by the route's Handler method, and is consulted by functions
+// in this package, such as WriteResponse, when handling the request.
+type Route struct {
+	Name        string // Optional name of the route
+	Method      string // HTTP method, eg "GET"
+	Path        string // Path pattern, eg "/api/things/{id}"
+	Version     string // Optional API version, eg "v1"
+	Description string // Optional description of the route
+
+	// Compression specifies how responses are compressed for the route.
+	// If nil, DefaultCompressionPolicy is used.
+	Compression CompressionPolicy
+
+	// PayloadLog specifies how request and response bodies are logged
+	// for the route. If nil, bodies are not logged.
+	PayloadLog *PayloadLog
+
+	// MaxJSONDepth is the maximum nesting depth of objects and arrays in
+	// JSON request bodies read by ReadRequest. If zero, DefaultMaxJSONDepth
+	// is used. If negative, there is no limit.
+	MaxJSONDepth int
+
+	// RejectDuplicateKeys rejects JSON request bodies read by ReadRequest
+	// that have objects with duplicate keys, eg {"id":1,"id":2}, which are
+	// otherwise accepted, with the last value silently winning. This is
+	// also enabled for all routes by DefaultRejectDuplicateKeys.
+	RejectDuplicateKeys bool
+}
+
+// DefaultMaxJSONDepth is the maximum nesting depth of objects and arrays in
+// JSON request bodies read by ReadRequest, when none is specified for the
+// route. Deeply nested request bodies are rejected with a 400 (Bad Request)
+// error before they are unmarshalled. It should only be modified during
+// program initialization.
+var DefaultMaxJSONDepth = 100
+
+// DefaultRejectDuplicateKeys rejects JSON request bodies read by ReadRequest
+// that have objects with duplicate keys for all routes (see Route). It should
+// only be modified during program initialization.
+var DefaultRejectDuplicateKeys bool
+
+// jsonOptions are the options for unmarshalling JSON request bodies.
+type jsonOptions struct {
+	maxDepth            int // zero if there is no limit
+	rejectDuplicateKeys bool
+}
+
+// jsonOptionsFor returns the options for unmarshalling JSON request
+// bodies for the route of the request.
+func jsonOptionsFor(r *http.Request) jsonOptions {
+	opts := jsonOptions{
+		maxDepth:            DefaultMaxJSONDepth,
+		rejectDuplicateKeys: DefaultRejectDuplicateKeys,
+	}
+	if rt := RouteFromRequest(r); rt != nil {
+		if rt.MaxJSONDepth != 0 {
+			opts.maxDepth = rt.MaxJSONDepth
+		}
+		opts.rejectDuplicateKeys = opts.rejectDuplicateKeys || rt.RejectDuplicateKeys
+	}
+	if opts.maxDepth < 0 {
+		opts.maxDepth = 0
+	}
+	return opts
+}
+
+// Handler returns a handler that associates the route metadata with
+// each request before passing the request to h.
+func (rt *Route) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if holder, ok := r.Context().Value(routeHolderKey).(*routeHolder); ok {
+			holder.route = rt
+		}
+		ctx := context.WithValue(r.Context(), routeKey, rt)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RouteFromRequest returns the route metadata associated with the request,
+// or nil if there is none. Middleware such as LogRequests that runs before
+// the request is routed can use it after the handler returns.
+func RouteFromRequest(r *http.Request) *Route {
+	if rt, ok := r.Context().Value(routeKey).(*Route); ok {
+		return rt
+	}
+	if holder, ok := r.Context().Value(routeHolderKey).(*routeHolder); ok {
+		return holder.route
+	}
+	return nil
+}
+
+// routeHolder is filled in with the route by Route.Handler, so that the
+// route is known to middleware that runs before the request is routed,
+// whose request does not have the context created by Route.Handler.
+type routeHolder struct {
+	route *Route
+}
+
+// withRouteHolder returns the request with a route holder in
+// its context, unless it already has one.
+func withRouteHolder(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(routeHolderKey).(*routeHolder); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), routeHolderKey, &routeHolder{}))
+}
+
+// Routes is a registry of route metadata. It is used to describe the
+// API to clients (see Index). The zero value is ready to use, and it is
+// safe for concurrent use.
+type Routes struct {
+	mutex  sync.Mutex
+	routes []*Route
+}
+
+// Handle adds the route to the registry, and returns a handler that
+// associates the route metadata with each request before passing the
+// request to h.
+func (rs *Routes) Handle(rt *Route, h http.Handler) http.Handler {
+	rs.mutex.Lock()
+	rs.routes = append(rs.routes, rt)
+	rs.mutex.Unlock()
+	return rt.Handler(h)
+}
+
+// List returns the routes in the registry, in the order that they were added.
+func (rs *Routes) List() []*Route {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	return append([]*Route(nil), rs.routes...)
+}
+
+// CompressionPolicy decides whether and how a response is compressed.
+// It is used by WriteResponse, and can be specified for a route (see Route).
+type CompressionPolicy interface {
+	// Decide returns the compression decision for a response to the request,
+	// given the response content type and length in bytes. If the length
+	// is not known in advance, it is -1.
+	Decide(r *http.Request, contentType string, length int) CompressionDecision
+}
+
+// CompressionDecision is the result of a compression policy decision.
+type CompressionDecision struct {
+	// Encoding is the content encoding to use, eg "gzip". If empty,
+	// the response is not compressed.
+	Encoding string
+
+	// Level is the compression level, eg gzip.BestSpeed.
+	Level int
+
+	// Force indicates that the compressed response should be sent even
+	// if it is not smaller than the uncompressed response.
+	Force bool
+}
+
+// DefaultCompressionPolicy is the compression policy used when none is
+// specified for the route. It should only be modified during program initialization.
+var DefaultCompressionPolicy CompressionPolicy = &Compression{}
+
+// defaultCompressionThreshold is the minimum size of a response that is
+// compressed, unless a different threshold is specified.
+const defaultCompressionThreshold = 96
+
+// defaultSkipContentTypes are the content types that are not compressed,
+// because they are already compressed.
+var defaultSkipContentTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+}
+
+// CompressionMode determines whether responses are compressed.
+type CompressionMode int
+
+// Compression modes
+const (
+	// CompressAuto compresses responses that are at least as large as the
+	// threshold, but only if compression makes the response smaller.
+	CompressAuto CompressionMode = iota
+
+	// CompressAlways compresses responses regardless of their size.
+	CompressAlways
+
+	// CompressNever never compresses responses.
+	CompressNever
+)
+
+// Compression is a declarative compression policy. It implements the
+// CompressionPolicy interface. Responses are only ever compressed using
+// an encoding that the client has indicated it accepts.
+type Compression struct {
+	// Mode determines whether responses are compressed.
+	Mode CompressionMode
+
+	// Threshold is the minimum size in bytes of a response for it to be
+	// compressed in CompressAuto mode. If zero, a default threshold is used.
+	Threshold int
+
+	// Level is the compression level, eg gzip.BestSpeed. If zero,
+	// the default compression level is used.
+	Level int
+
+	// Encodings lists the content encodings that can be used, in order of
+	// preference. Supported encodings are "gzip" and "deflate". If empty,
+	// only gzip is used.
+	Encodings []string
+
+	// SkipContentTypes lists content types that are never compressed. An
+	// entry ending in a slash, such as "image/", matches all content types
+	// with that prefix. If nil, a default list of content types that are
+	// already compressed (images, video, audio and archives) is used.
+	SkipContentTypes []string
+}
+
+// Decide implements the CompressionPolicy interface.
+func (c *Compression) Decide(r *http.Request, contentType string, length int) CompressionDecision {
+	var decision CompressionDecision
+	if c.Mode == CompressNever || length == 0 {
+		return decision
+	}
+
+	threshold := c.Threshold
+	if threshold <= 0 {
+		threshold = defaultCompressionThreshold
+	}
+	if c.Mode != CompressAlways && length > 0 && length < threshold {
+		// not worth compressing because data is too short
+		return decision
+	}
+
+	skip := c.SkipContentTypes
+	if skip == nil {
+		skip = defaultSkipContentTypes
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		for _, s := range skip {
+			if mediaType == s || (strings.HasSuffix(s, "/") && strings.HasPrefix(mediaType, s)) {
+				return decision
+			}
+		}
+	}
+
+	decision.Encoding = acceptableEncoding(r, c.Encodings)
+	if decision.Encoding == "" {
+		return decision
+	}
+	decision.Level = c.Level
+	if decision.Level == 0 {
+		decision.Level = flate.DefaultCompression
+	}
+	decision.Force = c.Mode == CompressAlways
+	return decision
+}
+
+// compressionPolicy returns the compression policy for the request.
+func compressionPolicy(r *http.Request) CompressionPolicy {
+	if rt := RouteFromRequest(r); rt != nil && rt.Compression != nil {
+		return rt.Compression
+	}
+	return DefaultCompressionPolicy
+}
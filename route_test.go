@@ -0,0 +1,146 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteCompression(t *testing.T) {
+	small := map[string]string{"a": "b"}
+	large := map[string]string{"a": strings.Repeat("b", 1000)}
+
+	tests := []struct {
+		compression    *Compression
+		acceptEncoding string
+		body           interface{}
+		wantEncoding   string
+	}{
+		{
+			compression:    nil,
+			acceptEncoding: "gzip, deflate",
+			body:           large,
+			wantEncoding:   "gzip",
+		},
+		{
+			compression:    nil,
+			acceptEncoding: "gzip, deflate",
+			body:           small,
+			wantEncoding:   "",
+		},
+		{
+			compression:    nil,
+			acceptEncoding: "",
+			body:           large,
+			wantEncoding:   "",
+		},
+		{
+			compression:    &Compression{Mode: CompressNever},
+			acceptEncoding: "gzip",
+			body:           large,
+			wantEncoding:   "",
+		},
+		{
+			compression:    &Compression{Mode: CompressAlways},
+			acceptEncoding: "gzip",
+			body:           small,
+			wantEncoding:   "gzip",
+		},
+		{
+			compression:    &Compression{Threshold: 2000},
+			acceptEncoding: "gzip",
+			body:           large,
+			wantEncoding:   "",
+		},
+		{
+			compression:    &Compression{Level: gzip.BestSpeed, Encodings: []string{"deflate", "gzip"}},
+			acceptEncoding: "gzip, deflate",
+			body:           large,
+			wantEncoding:   "deflate",
+		},
+	}
+
+	for i, tt := range tests {
+		route := &Route{}
+		if tt.compression != nil {
+			route.Compression = tt.compression
+		}
+		h := route.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if RouteFromRequest(r) != route {
+				t.Errorf("%d: route not found in request", i)
+			}
+			WriteResponse(w, r, tt.body)
+		}))
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("%d: want encoding %q, got %q", i, tt.wantEncoding, got)
+		}
+	}
+}
+
+func TestCompressionPolicy(t *testing.T) {
+	tests := []struct {
+		policy         *Compression
+		acceptEncoding string
+		contentType    string
+		length         int
+		want           CompressionDecision
+	}{
+		{
+			policy:         &Compression{},
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			length:         1000,
+			want:           CompressionDecision{Encoding: "gzip", Level: -1},
+		},
+		{
+			policy:         &Compression{},
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			length:         -1,
+			want:           CompressionDecision{Encoding: "gzip", Level: -1},
+		},
+		{
+			policy:         &Compression{},
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			length:         1000,
+			want:           CompressionDecision{},
+		},
+		{
+			policy:         &Compression{SkipContentTypes: []string{"text/csv"}},
+			acceptEncoding: "gzip",
+			contentType:    "text/csv; charset=utf-8",
+			length:         1000,
+			want:           CompressionDecision{},
+		},
+		{
+			policy:         &Compression{Mode: CompressAlways, Level: gzip.BestSpeed},
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			length:         10,
+			want:           CompressionDecision{Encoding: "gzip", Level: gzip.BestSpeed, Force: true},
+		},
+		{
+			policy:         &Compression{},
+			acceptEncoding: "br",
+			contentType:    "application/json",
+			length:         1000,
+			want:           CompressionDecision{},
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		if got := tt.policy.Decide(r, tt.contentType, tt.length); got != tt.want {
+			t.Errorf("%d: want %+v, got %+v", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Package router provides a minimal HTTP request router for JSON APIs.
+//
+// Routes are matched by HTTP method and path pattern. Unlike most routers,
+// the responses for requests that do not match a route, or that cause a
+// panic, are JSON error responses sent using httpapi.WriteError, so they
+// have the same shape as any other error sent by the API.
+//
+// Patterns are paths with wildcards that match a single path segment, eg
+// "/things/{id}". The last segment can be a wildcard that matches the rest
+// of the path, eg "/files/{path...}". The values of the wildcards are
+// available using r.PathValue, or httpapi.PathValues.
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jjeffery/httpapi"
+)
+
+// Router is a HTTP request router. The zero value is ready to use,
+// but routes must not be added while requests are being handled.
+type Router struct {
+	// NotFound handles requests whose path does not match any route.
+	// If nil, httpapi.NotFoundHandler is used.
+	NotFound http.Handler
+
+	// MethodNotAllowed handles requests whose path matches a route, but
+	// not for the request method. The Allow header has already been set
+	// when it is called. If nil, httpapi.MethodNotAllowedHandler is used.
+	MethodNotAllowed http.Handler
+
+	routes  []*route
+	once    sync.Once
+	handler http.Handler
+}
+
+// New returns a new router.
+func New() *Router {
+	return &Router{}
+}
+
+// route is a method and pattern, and the handler for matching requests.
+type route struct {
+	method   string
+	pattern  string
+	segments []segment
+	handler  http.Handler
+}
+
+// segment is one segment of a pattern.
+type segment struct {
+	literal  string // literal text, if not a wildcard
+	name     string // name of the wildcard
+	trailing bool   // wildcard matches the remainder of the path
+}
+
+// Handle registers the handler for requests with the method and path pattern.
+// It panics if the pattern is not valid, or has already been registered for
+// the method.
+func (rt *Router) Handle(method, pattern string, h http.Handler) {
+	method = strings.ToUpper(method)
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("router: %v", err))
+	}
+	for _, r := range rt.routes {
+		if r.method == method && r.pattern == pattern {
+			panic(fmt.Sprintf("router: %s %s already registered", method, pattern))
+		}
+	}
+	rt.routes = append(rt.routes, &route{
+		method:   method,
+		pattern:  pattern,
+		segments: segments,
+		handler:  h,
+	})
+}
+
+// HandleFunc registers the handler function for requests with the method
+// and path pattern. Errors returned by the function are sent to the client
+// using httpapi.WriteError.
+func (rt *Router) HandleFunc(method, pattern string, fn httpapi.HandlerFunc) {
+	rt.Handle(method, pattern, fn)
+}
+
+// Get registers the handler function for GET requests with the path pattern.
+// The handler is also used for HEAD requests, unless a HEAD handler is registered.
+func (rt *Router) Get(pattern string, fn httpapi.HandlerFunc) {
+	rt.HandleFunc(http.MethodGet, pattern, fn)
+}
+
+// Post registers the handler function for POST requests with the path pattern.
+func (rt *Router) Post(pattern string, fn httpapi.HandlerFunc) {
+	rt.HandleFunc(http.MethodPost, pattern, fn)
+}
+
+// Put registers the handler function for PUT requests with the path pattern.
+func (rt *Router) Put(pattern string, fn httpapi.HandlerFunc) {
+	rt.HandleFunc(http.MethodPut, pattern, fn)
+}
+
+// Patch registers the handler function for PATCH requests with the path pattern.
+func (rt *Router) Patch(pattern string, fn httpapi.HandlerFunc) {
+	rt.HandleFunc(http.MethodPatch, pattern, fn)
+}
+
+// Delete registers the handler function for DELETE requests with the path pattern.
+func (rt *Router) Delete(pattern string, fn httpapi.HandlerFunc) {
+	rt.HandleFunc(http.MethodDelete, pattern, fn)
+}
+
+// ServeHTTP implements the http.Handler interface. If the handler for the
+// request panics, a 500 error is sent to the client (see httpapi.Recover).
+//
+// When a route matches the request, the request's Pattern field is set to
+// the method and pattern of the route, eg "GET /things/{id}", and the values
+// of the wildcards are set using SetPathValue.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.once.Do(func() {
+		rt.handler = httpapi.Recover()(http.HandlerFunc(rt.serve))
+	})
+	rt.handler.ServeHTTP(w, r)
+}
+
+func (rt *Router) serve(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	var best, bestHead *route
+	var bestValues, bestHeadValues []string
+	var allowed []string
+	for _, route := range rt.routes {
+		values, ok := route.match(path)
+		if !ok {
+			continue
+		}
+		allowed = append(allowed, route.method)
+		switch {
+		case route.method == r.Method:
+			if best == nil || route.moreSpecific(best) {
+				best, bestValues = route, values
+			}
+		case route.method == http.MethodGet && r.Method == http.MethodHead:
+			if bestHead == nil || route.moreSpecific(bestHead) {
+				bestHead, bestHeadValues = route, values
+			}
+		}
+	}
+	if best == nil {
+		best, bestValues = bestHead, bestHeadValues
+	}
+
+	if best == nil {
+		if len(allowed) == 0 {
+			if rt.NotFound != nil {
+				rt.NotFound.ServeHTTP(w, r)
+				return
+			}
+			httpapi.NotFoundHandler().ServeHTTP(w, r)
+			return
+		}
+		if rt.MethodNotAllowed != nil {
+			w.Header().Set("Allow", httpapi.AllowHeader(allowed...))
+			rt.MethodNotAllowed.ServeHTTP(w, r)
+			return
+		}
+		httpapi.MethodNotAllowedHandler(allowed...).ServeHTTP(w, r)
+		return
+	}
+
+	r.Pattern = best.method + " " + best.pattern
+	for i, seg := range best.wildcards() {
+		r.SetPathValue(seg.name, bestValues[i])
+	}
+	best.handler.ServeHTTP(w, r)
+}
+
+// match reports whether the path matches the route, and returns
+// the values of the wildcards in the order they appear in the pattern.
+func (route *route) match(path string) ([]string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	var values []string
+	for i, seg := range route.segments {
+		if i >= len(parts) {
+			return nil, false
+		}
+		if seg.trailing {
+			values = append(values, strings.Join(parts[i:], "/"))
+			return values, true
+		}
+		if seg.name != "" {
+			if parts[i] == "" {
+				return nil, false
+			}
+			values = append(values, parts[i])
+		} else if parts[i] != seg.literal {
+			return nil, false
+		}
+	}
+	return values, len(parts) == len(route.segments)
+}
+
+// moreSpecific reports whether the route is more specific than the other
+// route. Comparing segments from the start of the path, a literal segment is
+// more specific than a wildcard, which is more specific than a wildcard
+// that matches the rest of the path.
+func (route *route) moreSpecific(other *route) bool {
+	for i := 0; i < len(route.segments) && i < len(other.segments); i++ {
+		if a, b := route.segments[i].rank(), other.segments[i].rank(); a != b {
+			return a < b
+		}
+	}
+	return len(route.segments) > len(other.segments)
+}
+
+func (seg segment) rank() int {
+	switch {
+	case seg.trailing:
+		return 2
+	case seg.name != "":
+		return 1
+	}
+	return 0
+}
+
+// wildcards returns the wildcard segments of the route.
+func (route *route) wildcards() []segment {
+	var segments []segment
+	for _, seg := range route.segments {
+		if seg.name != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// parsePattern parses a path pattern into its segments.
+func parsePattern(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("pattern %q does not start with a slash", pattern)
+	}
+	parts := strings.Split(pattern[1:], "/")
+	segments := make([]segment, 0, len(parts))
+	names := make(map[string]bool)
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, fmt.Errorf("pattern %q has an invalid wildcard", pattern)
+			}
+			segments = append(segments, segment{literal: part})
+			continue
+		}
+		if !strings.HasSuffix(part, "}") {
+			return nil, fmt.Errorf("pattern %q has an invalid wildcard", pattern)
+		}
+		name := part[1 : len(part)-1]
+		seg := segment{name: name}
+		if strings.HasSuffix(name, "...") {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("pattern %q has a %s wildcard that is not at the end", pattern, part)
+			}
+			seg.name = strings.TrimSuffix(name, "...")
+			seg.trailing = true
+		}
+		if seg.name == "" || strings.ContainsAny(seg.name, "{}.") {
+			return nil, fmt.Errorf("pattern %q has an invalid wildcard", pattern)
+		}
+		if names[seg.name] {
+			return nil, fmt.Errorf("pattern %q has duplicate wildcard %q", pattern, seg.name)
+		}
+		names[seg.name] = true
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
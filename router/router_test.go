@@ -0,0 +1,146 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi"
+)
+
+func TestRouter(t *testing.T) {
+	rt := New()
+	handler := func(name string) httpapi.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			pv := httpapi.PathValues(r)
+			fmt.Fprintf(w, "%s id=%s path=%s pattern=%s", name, pv.GetString("id"), pv.GetString("path"), r.Pattern)
+			return nil
+		}
+	}
+	rt.Get("/", handler("root"))
+	rt.Get("/things", handler("list"))
+	rt.Post("/things", handler("create"))
+	rt.Get("/things/{id}", handler("get"))
+	rt.Get("/things/new", handler("new"))
+	rt.Delete("/things/{id}", handler("delete"))
+	rt.Get("/files/{path...}", handler("files"))
+	rt.Get("/error", func(w http.ResponseWriter, r *http.Request) error {
+		return errkind.BadRequest("bad thing")
+	})
+	rt.Get("/panic", func(w http.ResponseWriter, r *http.Request) error {
+		panic("oops")
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{method: "GET", path: "/", status: 200, body: "root id= path= pattern=GET /"},
+		{method: "GET", path: "/things", status: 200, body: "list id= path= pattern=GET /things"},
+		{method: "POST", path: "/things", status: 200, body: "create id= path= pattern=POST /things"},
+		{method: "GET", path: "/things/123", status: 200, body: "get id=123 path= pattern=GET /things/{id}"},
+		{method: "HEAD", path: "/things/123", status: 200},
+		{method: "GET", path: "/things/new", status: 200, body: "new id= path= pattern=GET /things/new"},
+		{method: "DELETE", path: "/things/123", status: 200, body: "delete id=123 path= pattern=DELETE /things/{id}"},
+		{method: "GET", path: "/files/a/b/c.txt", status: 200, body: "files id= path=a/b/c.txt pattern=GET /files/{path...}"},
+		{method: "GET", path: "/files/", status: 200, body: "files id= path= pattern=GET /files/{path...}"},
+		{method: "GET", path: "/files", status: 404},
+		{method: "GET", path: "/things/", status: 404},
+		{method: "GET", path: "/things/123/more", status: 404},
+		{method: "PUT", path: "/things/123", status: 405, allow: "DELETE, GET, HEAD"},
+		{method: "DELETE", path: "/things", status: 405, allow: "GET, HEAD, POST"},
+		{method: "GET", path: "/error", status: 400},
+		{method: "GET", path: "/panic", status: 500},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+			continue
+		}
+		if got, want := w.Header().Get("Allow"), tt.allow; got != want {
+			t.Errorf("%d: allow: want %q, got %q", i, want, got)
+		}
+		if tt.status != 200 {
+			if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+				t.Errorf("%d: content type: want %q, got %q", i, want, got)
+			}
+			var body struct {
+				Error struct {
+					Status int `json:"status"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Errorf("%d: %v", i, err)
+			} else if got, want := body.Error.Status, tt.status; got != want {
+				t.Errorf("%d: body status: want %d, got %d", i, want, got)
+			}
+			continue
+		}
+		if tt.body != "" {
+			if got, want := w.Body.String(), tt.body; got != want {
+				t.Errorf("%d: body: want %q, got %q", i, want, got)
+			}
+		}
+	}
+}
+
+func TestRouterCustomHandlers(t *testing.T) {
+	rt := &Router{
+		NotFound: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+		MethodNotAllowed: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+		}),
+	}
+	rt.Handle("get", "/things", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{method: "GET", path: "/things", status: http.StatusOK},
+		{method: "GET", path: "/other", status: http.StatusTeapot},
+		{method: "POST", path: "/things", status: http.StatusConflict},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		valid   bool
+	}{
+		{pattern: "/", valid: true},
+		{pattern: "/things/{id}", valid: true},
+		{pattern: "/files/{path...}", valid: true},
+		{pattern: "things", valid: false},
+		{pattern: "/things/{id", valid: false},
+		{pattern: "/things/x{id}", valid: false},
+		{pattern: "/things/{}", valid: false},
+		{pattern: "/{path...}/more", valid: false},
+		{pattern: "/{id}/{id}", valid: false},
+	}
+	for i, tt := range tests {
+		_, err := parsePattern(tt.pattern)
+		if got, want := err == nil, tt.valid; got != want {
+			t.Errorf("%d: %s: want valid=%v, got %v", i, tt.pattern, want, err)
+		}
+	}
+}
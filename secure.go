@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultSecureHeaders contains the security headers sent by SecureHeaders,
+// unless overridden. They are suitable for JSON APIs, which are not intended
+// to be rendered by a browser.
+var DefaultSecureHeaders = map[string]string{
+	"X-Content-Type-Options":  "nosniff",
+	"X-Frame-Options":         "DENY",
+	"Referrer-Policy":         "no-referrer",
+	"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+}
+
+// SecureHeadersConfig contains the configuration for SecureHeaders.
+type SecureHeadersConfig struct {
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header,
+	// which tells browsers to only use HTTPS for the host. If zero, the
+	// header is not sent.
+	HSTSMaxAge time.Duration
+
+	// HSTSIncludeSubdomains adds the includeSubDomains directive
+	// to the Strict-Transport-Security header.
+	HSTSIncludeSubdomains bool
+
+	// HSTSPreload adds the preload directive to the
+	// Strict-Transport-Security header.
+	HSTSPreload bool
+
+	// Overrides contains header values that replace the default values in
+	// DefaultSecureHeaders, or are sent in addition to them. An empty value
+	// means that the header is not sent. For example, to allow the API to be
+	// embedded in frames on the same origin:
+	//  config.Overrides = map[string]string{
+	//      "X-Frame-Options": "SAMEORIGIN",
+	//      "Content-Security-Policy": "default-src 'none'; frame-ancestors 'self'",
+	//  }
+	Overrides map[string]string
+}
+
+// SecureHeaders returns middleware that sets security headers on every
+// response. The headers are set before the handler is called, so a handler
+// can change them for an individual response.
+func SecureHeaders(config SecureHeadersConfig) Middleware {
+	headers := make(http.Header)
+	for k, v := range DefaultSecureHeaders {
+		headers.Set(k, v)
+	}
+	if config.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.FormatInt(int64(config.HSTSMaxAge/time.Second), 10)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+		headers.Set("Strict-Transport-Security", hsts)
+	}
+	for k, v := range config.Overrides {
+		if v == "" {
+			headers.Del(k)
+		} else {
+			headers.Set(k, v)
+		}
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			dst := w.Header()
+			for k, vv := range headers {
+				dst[k] = append([]string(nil), vv...)
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	tests := []struct {
+		config SecureHeadersConfig
+		want   map[string]string
+	}{
+		{
+			want: map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Referrer-Policy":           "no-referrer",
+				"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+				"Strict-Transport-Security": "",
+			},
+		},
+		{
+			config: SecureHeadersConfig{
+				HSTSMaxAge:            365 * 24 * time.Hour,
+				HSTSIncludeSubdomains: true,
+				HSTSPreload:           true,
+			},
+			want: map[string]string{
+				"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload",
+			},
+		},
+		{
+			config: SecureHeadersConfig{
+				Overrides: map[string]string{
+					"x-frame-options":              "SAMEORIGIN",
+					"Referrer-Policy":              "",
+					"Cross-Origin-Resource-Policy": "same-origin",
+				},
+			},
+			want: map[string]string{
+				"X-Content-Type-Options":       "nosniff",
+				"X-Frame-Options":              "SAMEORIGIN",
+				"Referrer-Policy":              "",
+				"Cross-Origin-Resource-Policy": "same-origin",
+			},
+		},
+	}
+	for i, tt := range tests {
+		h := SecureHeaders(tt.config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		for k, want := range tt.want {
+			if got := w.Header().Get(k); got != want {
+				t.Errorf("%d: %s: want %q, got %q", i, k, want, got)
+			}
+		}
+	}
+}
+
+func TestSecureHeadersHandlerOverride(t *testing.T) {
+	h := SecureHeaders(SecureHeadersConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got, want := w.Header().Get("X-Frame-Options"), "SAMEORIGIN"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,115 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+// Query string parameters of signed URLs.
+const (
+	signedURLExpires   = "expires"
+	signedURLSignature = "signature"
+)
+
+// URLSigner signs URLs so that they can be used without any other
+// authentication until they expire, such as links to download a report or
+// to upload a file. The signature is an HMAC-SHA256 of the HTTP method, and
+// the path and query string of the URL, including the expiry time, so the
+// client cannot change any part of the URL other than the scheme and host,
+// and cannot use a link to download a file to upload one instead. The expiry
+// time and the signature are in the "expires" and "signature" query string
+// parameters.
+//
+// For example:
+//  signer := &httpapi.URLSigner{Key: key}
+//  link, err := signer.Sign("GET", "/reports/123/download", time.Now().Add(time.Hour))
+//  ...
+//  http.Handle("GET /reports/{id}/download", signer.Middleware()(download))
+type URLSigner struct {
+	// Key is the secret key used to sign URLs. It must not be empty.
+	Key []byte
+}
+
+// Sign returns the URL with the expiry time and signature added to its query
+// string, which is valid for requests with the method. A URL signed for GET
+// is also valid for HEAD. The URL can be absolute, or just a path with an
+// optional query string. Other query string parameters are kept, but are
+// sorted by name.
+func (s *URLSigner) Sign(method string, rawURL string, expires time.Time) (string, error) {
+	if len(s.Key) == 0 {
+		return "", errors.New("URL signer key not specified")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse URL").With("url", rawURL)
+	}
+	query := u.Query()
+	query.Del(signedURLSignature)
+	query.Set(signedURLExpires, strconv.FormatInt(expires.Unix(), 10))
+	query.Set(signedURLSignature, s.sign(method, u.Path, query))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Verify checks that the request URL has a valid signature for the request
+// method, and has not expired. If it does not, the error has a status of
+// 403 (Forbidden).
+func (s *URLSigner) Verify(r *http.Request) error {
+	if len(s.Key) == 0 {
+		return errors.New("URL signer key not specified")
+	}
+	query := r.URL.Query()
+	signature := query.Get(signedURLSignature)
+	query.Del(signedURLSignature)
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	if signature == "" || !hmac.Equal([]byte(signature), []byte(s.sign(method, r.URL.Path, query))) {
+		return errkind.Public("invalid signature", http.StatusForbidden)
+	}
+	expires, err := strconv.ParseInt(query.Get(signedURLExpires), 10, 64)
+	if err != nil {
+		return errkind.Public("invalid signature", http.StatusForbidden)
+	}
+	if time.Now().Unix() >= expires {
+		return errkind.Public("link has expired", http.StatusForbidden)
+	}
+	return nil
+}
+
+// Middleware returns middleware that only passes requests with a valid
+// signed URL to the next handler. For other requests, the error from
+// Verify is sent to the client using WriteError.
+func (s *URLSigner) Middleware() Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := s.Verify(r); err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sign returns the signature of the method, the path and the query,
+// which does not include the signature parameter.
+func (s *URLSigner) sign(method string, path string, query url.Values) string {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(strings.ToUpper(method)))
+	mac.Write([]byte{' '})
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(query.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,133 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/errkind"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := &URLSigner{Key: []byte("secret")}
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		method  string // request method, if not GET
+		url     string
+		expires time.Time
+		tamper  func(link string) string
+		status  int
+	}{
+		{url: "/reports/123/download", expires: future},
+		{url: "https://example.com/files/a%20b.txt?inline=1&v=2", expires: future},
+		{url: "/reports/123/download", expires: past, status: http.StatusForbidden},
+		{
+			url:     "/reports/123/download",
+			expires: future,
+			tamper:  func(link string) string { return strings.Replace(link, "123", "124", 1) },
+			status:  http.StatusForbidden,
+		},
+		{
+			url:     "/reports/123/download?v=1",
+			expires: future,
+			tamper:  func(link string) string { return strings.Replace(link, "v=1", "v=2", 1) },
+			status:  http.StatusForbidden,
+		},
+		{
+			url:     "/reports/123/download",
+			expires: past,
+			tamper: func(link string) string {
+				u, _ := url.Parse(link)
+				q := u.Query()
+				q.Set("expires", "99999999999")
+				u.RawQuery = q.Encode()
+				return u.String()
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			url:     "/reports/123/download",
+			expires: future,
+			tamper:  func(link string) string { return "/reports/123/download" },
+			status:  http.StatusForbidden,
+		},
+		{
+			url:     "/reports/123/download",
+			expires: future,
+			tamper:  func(link string) string { return link + "&extra=1" },
+			status:  http.StatusForbidden,
+		},
+		{
+			// link signed for GET cannot be used to upload
+			method:  "PUT",
+			url:     "/reports/123/download",
+			expires: future,
+			status:  http.StatusForbidden,
+		},
+		{
+			method:  "HEAD",
+			url:     "/reports/123/download",
+			expires: future,
+		},
+	}
+	for i, tt := range tests {
+		link, err := signer.Sign("GET", tt.url, tt.expires)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if tt.tamper != nil {
+			link = tt.tamper(link)
+		}
+		method := tt.method
+		if method == "" {
+			method = "GET"
+		}
+		r := httptest.NewRequest(method, link, nil)
+		err = signer.Verify(r)
+		if tt.status == 0 {
+			if err != nil {
+				t.Errorf("%d: %s: %v", i, link, err)
+			}
+			continue
+		}
+		if got := errkind.StatusCode(err); got != tt.status {
+			t.Errorf("%d: %s: want %d, got %v", i, link, tt.status, err)
+		}
+	}
+}
+
+func TestURLSignerMiddleware(t *testing.T) {
+	signer := &URLSigner{Key: []byte("secret")}
+	h := signer.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	link, err := signer.Sign("PUT", "/upload", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		target string
+		status int
+	}{
+		{method: "PUT", target: link, status: http.StatusNoContent},
+		{method: "GET", target: link, status: http.StatusForbidden},
+		{method: "PUT", target: "/upload", status: http.StatusForbidden},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: want %d, got %d", i, want, got)
+		}
+	}
+
+	if _, err := (&URLSigner{}).Sign("GET", "/download", time.Now()); err == nil {
+		t.Error("want error for missing key")
+	}
+}
@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"strconv"
+)
+
+// SortField is a field in a sort order.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// GetSort returns the sort order in the named query parameter, which uses
+// the conventional syntax of comma-separated field names, each with an
+// optional "-" prefix for descending order or "+" prefix for ascending
+// order, eg "?sort=-created_at,name". If any allowed fields are specified,
+// every field must be one of them, otherwise the parameter is reported by
+// Err and nil is returned. A field that appears more than once is also invalid.
+// Returns nil if the parameter is not present.
+func (v *Values) GetSort(name string, allowed ...string) []SortField {
+	items := v.splitList(name)
+	if len(items) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(items))
+	fields := make([]SortField, 0, len(items))
+	for _, item := range items {
+		var sf SortField
+		switch item[0] {
+		case '-':
+			sf.Descending = true
+			item = item[1:]
+		case '+':
+			item = item[1:]
+		}
+		sf.Field = item
+		if sf.Field == "" || (len(allowed) > 0 && !containsString(allowed, sf.Field)) {
+			v.invalid(name, "cannot sort by "+strconv.Quote(sf.Field))
+			return nil
+		}
+		if seen[sf.Field] {
+			v.invalid(name, "duplicate sort field "+sf.Field)
+			return nil
+		}
+		seen[sf.Field] = true
+		fields = append(fields, sf)
+	}
+	return fields
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestGetSort(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []SortField
+		invalid bool
+	}{
+		{
+			query: "sort=-created_at,name",
+			want:  []SortField{{Field: "created_at", Descending: true}, {Field: "name"}},
+		},
+		{
+			// "+" in a query string is decoded as a space
+			query: "sort=%2Bname",
+			want:  []SortField{{Field: "name"}},
+		},
+		{query: "sort=-password", invalid: true},
+		{query: "sort=name,-name", invalid: true},
+		{query: "sort=-", invalid: true},
+		{query: ""},
+	}
+	for i, tt := range tests {
+		r := &http.Request{URL: &url.URL{RawQuery: tt.query}}
+		query := Query(r)
+		if got := query.GetSort("sort", "created_at", "name"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: want %+v, got %+v", i, tt.want, got)
+		}
+		if got := query.Err() != nil; got != tt.invalid {
+			t.Errorf("%d: want invalid=%v, got %v", i, tt.invalid, got)
+		}
+	}
+}
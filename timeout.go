@@ -0,0 +1,183 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jjeffery/errkind"
+)
+
+// Timeout returns middleware that limits the time taken to handle each
+// request to d. The request context has a deadline, so handlers that honour
+// the context can stop work early. If the handler has not finished when the
+// deadline expires, a 504 (Gateway Timeout) error is sent to the client
+// using WriteError, and any later writes by the handler return
+// http.ErrHandlerTimeout and are not sent to the client. If the request is
+// cancelled because the client has gone away, nothing is sent.
+//
+// Unlike http.TimeoutHandler, the timeout response has the same shape as
+// other error responses. As with http.TimeoutHandler, the response is
+// buffered until the handler finishes, so this middleware is not suitable
+// for streaming responses.
+func Timeout(d time.Duration) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicChan := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+				h.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k, vv := range tw.header {
+					dst[k] = vv
+				}
+				if tw.status == 0 {
+					tw.status = http.StatusOK
+				}
+				w.WriteHeader(tw.status)
+				w.Write(tw.buf.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				if ctx.Err() != context.DeadlineExceeded {
+					// the client has gone away, so there is nobody to
+					// send a response to, and the request did not time out
+					return
+				}
+				WriteError(w, r, errkind.Public("request timed out", http.StatusGatewayTimeout))
+			}
+		})
+	}
+}
+
+// timeoutWriter buffers the response written by the handler
+// until it is known whether the handler finished in time.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
+
+// RequestTimeout returns middleware that limits the time taken to handle
+// each request to the timeout requested by the client, so that callers can
+// bound how long the server works on their behalf. The timeout is specified
+// in one of the following request headers:
+//  X-Request-Timeout: 1500    // milliseconds
+//  Grpc-Timeout: 1500m        // gRPC format: digits followed by a unit of H, M, S, m, u or n
+//
+// The timeout is limited to max. If the request does not specify a timeout,
+// max is used, unless it is zero, in which case the request has no timeout.
+// A timeout of zero means that the caller's deadline has already passed, as
+// sent by OutgoingHeaders, so a 504 (Gateway Timeout) error is sent without
+// calling the handler. An invalid timeout header results in a 400 (Bad
+// Request) error.
+//
+// As with Timeout, if the handler has not finished when the timeout expires,
+// a 504 (Gateway Timeout) error is sent to the client using WriteError.
+func RequestTimeout(max time.Duration) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, ok, err := requestTimeout(r)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			if ok && d == 0 {
+				WriteError(w, r, errkind.Public("request timed out", http.StatusGatewayTimeout))
+				return
+			}
+			if !ok || (max > 0 && d > max) {
+				d = max
+			}
+			if d <= 0 {
+				h.ServeHTTP(w, r)
+				return
+			}
+			Timeout(d)(h).ServeHTTP(w, r)
+		})
+	}
+}
+
+// grpcTimeoutUnits maps the units of the Grpc-Timeout header to durations.
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// requestTimeout returns the timeout requested by the client, and
+// whether one was requested. A timeout of zero means that the caller's
+// deadline has already passed.
+func requestTimeout(r *http.Request) (time.Duration, bool, error) {
+	if s := r.Header.Get(HeaderRequestTimeout); s != "" {
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || ms < 0 || ms > int64(math.MaxInt64/time.Millisecond) {
+			return 0, false, errkind.BadRequest("invalid X-Request-Timeout header")
+		}
+		return time.Duration(ms) * time.Millisecond, true, nil
+	}
+	if s := r.Header.Get("Grpc-Timeout"); s != "" {
+		// the gRPC spec allows at most 8 digits
+		unit, ok := grpcTimeoutUnits[s[len(s)-1]]
+		n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		if !ok || err != nil || n < 0 || len(s) > 9 {
+			return 0, false, errkind.BadRequest("invalid Grpc-Timeout header")
+		}
+		return time.Duration(n) * unit, true, nil
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,154 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	lateWrite := make(chan error, 1)
+	tests := []struct {
+		handler http.HandlerFunc
+		status  int
+		body    string
+	}{
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "1")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("done"))
+			},
+			status: http.StatusCreated,
+			body:   "done",
+		},
+		{
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				time.Sleep(10 * time.Millisecond)
+				_, err := w.Write([]byte("late"))
+				lateWrite <- err
+			},
+			status: http.StatusGatewayTimeout,
+		},
+	}
+	for i, tt := range tests {
+		h := Timeout(20 * time.Millisecond)(tt.handler)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if tt.body != "" {
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("%d: body: want %q, got %q", i, tt.body, got)
+			}
+			if got := w.Header().Get("X-Test"); got != "1" {
+				t.Errorf("%d: header: want %q, got %q", i, "1", got)
+			}
+			continue
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Errorf("%d: want JSON error body, got %q", i, w.Body.String())
+		}
+		if err := <-lateWrite; err != http.ErrHandlerTimeout {
+			t.Errorf("%d: late write: want ErrHandlerTimeout, got %v", i, err)
+		}
+	}
+}
+
+func TestTimeoutCanceled(t *testing.T) {
+	lateWrite := make(chan error, 1)
+	h := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		_, err := w.Write([]byte("late"))
+		lateWrite <- err
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	cancel()
+	h.ServeHTTP(w, r)
+	if w.Body.Len() != 0 {
+		t.Errorf("want no body, got %q", w.Body.String())
+	}
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("status: want %d (nothing written), got %d", want, got)
+	}
+	if err := <-lateWrite; err != http.ErrHandlerTimeout {
+		t.Errorf("late write: want ErrHandlerTimeout, got %v", err)
+	}
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	h := Recover()(Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got, want := w.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("status: want %d, got %d", want, got)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	tests := []struct {
+		max      time.Duration
+		header   map[string]string
+		status   int
+		deadline time.Duration // zero for no deadline
+
+		// handler is not called, because the deadline has passed
+		notCalled bool
+	}{
+		{max: 0, status: http.StatusOK},
+		{max: time.Minute, status: http.StatusOK, deadline: time.Minute},
+		{max: time.Minute, header: map[string]string{"X-Request-Timeout": "1500"}, status: http.StatusOK, deadline: 1500 * time.Millisecond},
+		{max: time.Second, header: map[string]string{"X-Request-Timeout": "1500"}, status: http.StatusOK, deadline: time.Second},
+		{max: 0, header: map[string]string{"Grpc-Timeout": "2S"}, status: http.StatusOK, deadline: 2 * time.Second},
+		{max: 0, header: map[string]string{"Grpc-Timeout": "250m"}, status: http.StatusOK, deadline: 250 * time.Millisecond},
+		{max: 0, header: map[string]string{"X-Request-Timeout": "soon"}, status: http.StatusBadRequest},
+		{max: 0, header: map[string]string{"X-Request-Timeout": "-1"}, status: http.StatusBadRequest},
+		{max: time.Minute, header: map[string]string{"X-Request-Timeout": "0"}, status: http.StatusGatewayTimeout, notCalled: true},
+		{max: 0, header: map[string]string{"Grpc-Timeout": "0m"}, status: http.StatusGatewayTimeout, notCalled: true},
+		{max: 0, header: map[string]string{"Grpc-Timeout": "10x"}, status: http.StatusBadRequest},
+		{max: 0, header: map[string]string{"Grpc-Timeout": "123456789S"}, status: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		var deadline time.Time
+		var hasDeadline, called bool
+		h := RequestTimeout(tt.max)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			deadline, hasDeadline = r.Context().Deadline()
+		}))
+		r := httptest.NewRequest("GET", "/", nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		start := time.Now()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+		if got, want := called, tt.status != http.StatusBadRequest && !tt.notCalled; got != want {
+			t.Errorf("%d: called: want %v, got %v", i, want, got)
+		}
+		if !called {
+			continue
+		}
+		if got, want := hasDeadline, tt.deadline > 0; got != want {
+			t.Errorf("%d: has deadline: want %v, got %v", i, want, got)
+			continue
+		}
+		if hasDeadline {
+			if d := deadline.Sub(start); d < tt.deadline || d > tt.deadline+time.Second {
+				t.Errorf("%d: deadline: want %v, got %v", i, tt.deadline, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package tracing provides HTTP middleware that traces requests
+// using OpenTelemetry.
+//
+// The middleware starts a server span for each request, continuing any
+// trace propagated by the client in the W3C traceparent header. When an
+// error is sent to the client using httpapi.WriteError, the error is
+// recorded in the span, and the trace ID is included in the error response
+// so that the client can quote it when reporting problems.
+package tracing
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jjeffery/httpapi"
+	"github.com/jjeffery/httpapi/writeerror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package as the source of spans.
+const instrumentationName = "github.com/jjeffery/httpapi/tracing"
+
+// Config contains the configuration for tracing requests.
+type Config struct {
+	// TracerProvider provides the tracer used to start spans. If nil,
+	// the global tracer provider is used.
+	TracerProvider trace.TracerProvider
+
+	// Propagator extracts the trace context sent by the client. If nil,
+	// the W3C trace context propagator is used.
+	Propagator propagation.TextMapPropagator
+}
+
+// Middleware returns middleware that starts a server span for each request.
+//
+// The span is named after the request method and route. The route is the
+// pattern matched by http.ServeMux, or the path of the httpapi.Route
+// associated with the request. When the request has been handled, the
+// response status is added to the span.
+//
+// The middleware arranges for calls to httpapi.WriteError to record the
+// error in the span, and to send the trace ID to the client (see GetTrace).
+// Responses with a server error (5xx) status set the span status to error,
+// whether or not they were sent using WriteError. The middleware
+// wraps any writeerror configuration earlier in the stack, so it should
+// come after the writeerror middleware.
+func Middleware(config Config) httpapi.Middleware {
+	provider := config.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	propagator := config.Propagator
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	tracer := provider.Tracer(instrumentationName)
+
+	return func(h http.Handler) http.Handler {
+		h = httpapi.LogRequests(endSpan)(h)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("url.path", r.URL.Path),
+				),
+			)
+			defer span.End()
+			r = r.WithContext(ctx)
+			writeerror.Middleware(errorConfig(writeerror.ConfigFromRequest(r)))(h).ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetTrace returns the trace ID of the span in the request context, or
+// an empty string if there is none. It can be used as the GetTrace callback
+// of the writeerror configuration. The middleware uses it automatically
+// if the configuration does not return a trace ID of its own.
+func GetTrace(r *http.Request) string {
+	sc := trace.SpanContextFromContext(r.Context())
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// errorConfig returns a copy of the writeerror configuration that
+// reports the trace ID to the client, and records errors in the span.
+func errorConfig(config writeerror.Config) writeerror.Config {
+	getTrace := config.GetTrace
+	config.GetTrace = func(r *http.Request) string {
+		if trace := getTrace(r); trace != "" {
+			return trace
+		}
+		return GetTrace(r)
+	}
+	errorWritten := config.ErrorWritten
+	config.ErrorWritten = func(r *http.Request, content *writeerror.Content) {
+		recordError(trace.SpanFromContext(r.Context()), content)
+		errorWritten(r, content)
+	}
+	return config
+}
+
+// recordError records the error sent to the client in the span.
+func recordError(span trace.Span, content *writeerror.Content) {
+	attrs := []attribute.KeyValue{
+		attribute.Int("http.response.status_code", content.StatusCode),
+	}
+	if content.Code != "" {
+		attrs = append(attrs, attribute.String("error.code", content.Code))
+	}
+	if content.Err != nil {
+		span.RecordError(content.Err, trace.WithAttributes(attrs...))
+	}
+}
+
+// endSpan annotates the span with the details of the handled request.
+func endSpan(rl *httpapi.RequestLog) {
+	span := trace.SpanFromContext(rl.Request.Context())
+	route := rl.Request.Pattern
+	if i := strings.IndexByte(route, ' '); i >= 0 {
+		// remove the method from the pattern, eg "GET /things/{id}"
+		route = strings.TrimSpace(route[i+1:])
+	}
+	if rt := httpapi.RouteFromRequest(rl.Request); rt != nil && rt.Path != "" {
+		route = rt.Path
+	}
+	if route != "" {
+		span.SetAttributes(attribute.String("http.route", route))
+		span.SetName(rl.Method + " " + route)
+	}
+	span.SetAttributes(
+		attribute.Int("http.response.status_code", rl.Status),
+		attribute.Int64("http.response.body.size", rl.Bytes),
+	)
+	if rl.Status >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(rl.Status))
+	}
+}
@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/httpapi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("id") {
+		case "missing":
+			httpapi.WriteError(w, r, errkind.Public("thing not found", http.StatusNotFound))
+		case "broken":
+			httpapi.WriteError(w, r, errkind.Public("cannot get thing", http.StatusInternalServerError))
+		default:
+			httpapi.WriteResponse(w, r, map[string]string{"id": r.PathValue("id")})
+		}
+	})
+	h := Middleware(Config{TracerProvider: provider})(mux)
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		path       string
+		status     int
+		spanStatus codes.Code
+		events     int
+	}{
+		{path: "/things/1", status: http.StatusOK},
+		{path: "/things/missing", status: http.StatusNotFound, events: 1},
+		{path: "/things/broken", status: http.StatusInternalServerError, spanStatus: codes.Error, events: 1},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Header.Set("traceparent", traceparent)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+		}
+
+		spans := recorder.Ended()
+		if got, want := len(spans), i+1; got != want {
+			t.Fatalf("%d: spans: want %d, got %d", i, want, got)
+		}
+		span := spans[i]
+		if got, want := span.Name(), "GET /things/{id}"; got != want {
+			t.Errorf("%d: name: want %q, got %q", i, want, got)
+		}
+		if got, want := span.SpanKind(), trace.SpanKindServer; got != want {
+			t.Errorf("%d: kind: want %v, got %v", i, want, got)
+		}
+		if got, want := span.SpanContext().TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+			t.Errorf("%d: trace: want %q, got %q", i, want, got)
+		}
+		if got, want := span.Parent().SpanID().String(), "00f067aa0ba902b7"; got != want {
+			t.Errorf("%d: parent: want %q, got %q", i, want, got)
+		}
+		if got, want := span.Status().Code, tt.spanStatus; got != want {
+			t.Errorf("%d: span status: want %v, got %v", i, want, got)
+		}
+		if got, want := len(span.Events()), tt.events; got != want {
+			t.Errorf("%d: events: want %d, got %d", i, want, got)
+		}
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes() {
+			attrs[kv.Key] = kv.Value
+		}
+		if got, want := attrs["http.route"].AsString(), "/things/{id}"; got != want {
+			t.Errorf("%d: route: want %q, got %q", i, want, got)
+		}
+		if got, want := attrs["http.response.status_code"].AsInt64(), int64(tt.status); got != want {
+			t.Errorf("%d: status attribute: want %d, got %d", i, want, got)
+		}
+
+		if tt.status != http.StatusOK {
+			var body struct {
+				Error struct {
+					Trace string `json:"trace"`
+				} `json:"error"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if got, want := body.Error.Trace, "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+				t.Errorf("%d: error trace: want %q, got %q", i, want, got)
+			}
+		}
+	}
+}
+
+func TestMiddlewareRoute(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// the route is associated with the request inside the mux,
+	// after the middleware has started the span
+	route := &httpapi.Route{Method: "GET", Path: "/api/things/{id}"}
+	mux := http.NewServeMux()
+	mux.Handle("/api/", route.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	h := Middleware(Config{TracerProvider: provider})(mux)
+
+	r := httptest.NewRequest("GET", "/api/things/1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	spans := recorder.Ended()
+	if got, want := len(spans), 1; got != want {
+		t.Fatalf("spans: want %d, got %d", want, got)
+	}
+	if got, want := spans[0].Name(), "GET /api/things/{id}"; got != want {
+		t.Errorf("name: want %q, got %q", want, got)
+	}
+	for _, kv := range spans[0].Attributes() {
+		if kv.Key == "http.route" {
+			if got, want := kv.Value.AsString(), "/api/things/{id}"; got != want {
+				t.Errorf("route: want %q, got %q", want, got)
+			}
+		}
+	}
+}
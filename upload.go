@@ -0,0 +1,117 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+// uploadBufferSize is the size of the buffer used to copy request bodies.
+const uploadBufferSize = 32 * 1024
+
+// ReadToWriter streams the request body to w, such as a file or an upload to
+// object storage, without reading the whole body into memory. It returns the
+// number of bytes written to w.
+//
+// If the body has a Content-Encoding of gzip or deflate, it is decompressed as
+// it is written. If more than maxSize bytes would be written to w, a 413 (Payload
+// Too Large) error is returned, after at most maxSize bytes have been written.
+// If maxSize is zero or negative, the limit is the same as for ReadRequest.
+//
+// The optional progress callbacks are called after each write to w with the
+// total number of bytes written so far, eg:
+//  n, err := httpapi.ReadToWriter(r, file, 1<<30, func(n int64) {
+//      log.Printf("received %d bytes", n)
+//  })
+//
+// Errors reading the body, including a body that is shorter or longer than its
+// Content-Length header, are bad request errors. Errors writing to w are
+// returned wrapped, and are not suitable for sending to the client.
+func ReadToWriter(r *http.Request, w io.Writer, maxSize int64, progress ...func(n int64)) (int64, error) {
+	if maxSize <= 0 {
+		maxSize = int64(maxRequestLen)
+	}
+	contentLength := int64(-1) // length of the body, if it is not encoded
+	if cl := r.Header.Get("Content-Length"); cl != "" {
+		v, err := strconv.ParseInt(cl, 10, 64)
+		if err != nil || v < 0 {
+			return 0, errkind.BadRequest("invalid content-length")
+		}
+		if ce := r.Header.Get("Content-Encoding"); ce == "" || ce == ceIdentity {
+			if v > maxSize {
+				return 0, errkind.Public("payload too large", http.StatusRequestEntityTooLarge)
+			}
+			contentLength = v
+		}
+	}
+
+	var reader io.Reader
+	switch ce := r.Header.Get("Content-Encoding"); ce {
+	case "", ceIdentity:
+		reader = r.Body
+	case ceGzip:
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return 0, errkind.BadRequest("cannot decompress payload")
+		}
+		defer zr.Close()
+		reader = zr
+	case ceDeflate:
+		// HTTP deflate is the zlib format, not raw DEFLATE
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			return 0, errkind.BadRequest("cannot decompress payload")
+		}
+		defer zr.Close()
+		reader = zr
+	default:
+		return 0, errkind.Public("unsupported content-encoding", http.StatusUnsupportedMediaType)
+	}
+
+	buf := make([]byte, uploadBufferSize)
+	var total int64
+	for {
+		n, rerr := reader.Read(buf)
+		tooLarge := total+int64(n) > maxSize
+		if tooLarge {
+			// write up to the limit, so that the caller can tell
+			// how much of the body was received
+			n = int(maxSize - total)
+		}
+		if n > 0 {
+			written, werr := w.Write(buf[:n])
+			total += int64(written)
+			if werr == nil && written != n {
+				werr = io.ErrShortWrite
+			}
+			if werr != nil {
+				return total, errors.Wrap(werr, "cannot write request body")
+			}
+			for _, fn := range progress {
+				fn(total)
+			}
+		}
+		switch {
+		case tooLarge:
+			return total, errkind.Public("payload too large", http.StatusRequestEntityTooLarge)
+		case rerr == io.EOF:
+			if contentLength >= 0 && total != contentLength {
+				if total < contentLength {
+					return total, errkind.BadRequest("content shorter than content-length")
+				}
+				return total, errkind.BadRequest("content longer than content-length")
+			}
+			return total, nil
+		case rerr != nil:
+			if reader != r.Body {
+				return total, errkind.BadRequest("cannot decompress payload")
+			}
+			return total, errkind.BadRequest("cannot read full content")
+		}
+	}
+}
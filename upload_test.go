@@ -0,0 +1,167 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jjeffery/errkind"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestReadToWriter(t *testing.T) {
+	gzipped := func(s string) string {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		io.WriteString(zw, s)
+		zw.Close()
+		return buf.String()
+	}
+	deflated := func(s string) string {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		io.WriteString(zw, s)
+		zw.Close()
+		return buf.String()
+	}
+	large := strings.Repeat("0123456789", 10000)
+	tests := []struct {
+		body     string
+		header   map[string]string
+		maxSize  int64
+		writer   io.Writer
+		want     string
+		status   int
+		wantErr  string
+		progress int64
+	}{
+		{
+			body:     large,
+			maxSize:  int64(len(large)),
+			want:     large,
+			progress: int64(len(large)),
+		},
+		{
+			body:     gzipped(large),
+			header:   map[string]string{"Content-Encoding": "gzip"},
+			want:     large,
+			progress: int64(len(large)),
+		},
+		{
+			body:     deflated(large),
+			header:   map[string]string{"Content-Encoding": "deflate"},
+			want:     large,
+			progress: int64(len(large)),
+		},
+		{
+			// limit applies to the decompressed body
+			body:     gzipped(large),
+			header:   map[string]string{"Content-Encoding": "gzip"},
+			maxSize:  50000,
+			want:     large[:50000],
+			status:   http.StatusRequestEntityTooLarge,
+			progress: 50000,
+		},
+		{
+			body:     large,
+			maxSize:  100,
+			want:     large[:100],
+			status:   http.StatusRequestEntityTooLarge,
+			progress: 100,
+		},
+		{
+			// Content-Length is checked before reading
+			body:    large,
+			header:  map[string]string{"Content-Length": "100000"},
+			maxSize: 100,
+			status:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			body:   "not gzip",
+			header: map[string]string{"Content-Encoding": "gzip"},
+			status: http.StatusBadRequest,
+		},
+		{
+			body:   "data",
+			header: map[string]string{"Content-Encoding": "br"},
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			body:    "data",
+			writer:  failingWriter{},
+			wantErr: "cannot write request body",
+		},
+		{
+			body:     "data",
+			header:   map[string]string{"Content-Length": "4"},
+			want:     "data",
+			progress: 4,
+		},
+		{
+			// body shorter than content length
+			body:     "data",
+			header:   map[string]string{"Content-Length": "10"},
+			want:     "data",
+			status:   http.StatusBadRequest,
+			progress: 4,
+		},
+		{
+			// body longer than content length
+			body:     "data",
+			header:   map[string]string{"Content-Length": "2"},
+			want:     "data",
+			status:   http.StatusBadRequest,
+			progress: 4,
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("PUT", "/", strings.NewReader(tt.body))
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		var buf bytes.Buffer
+		w := tt.writer
+		if w == nil {
+			w = &buf
+		}
+		var progress int64
+		n, err := ReadToWriter(r, w, tt.maxSize, func(n int64) {
+			if n <= progress {
+				t.Errorf("%d: progress went from %d to %d", i, progress, n)
+			}
+			progress = n
+		})
+		switch {
+		case tt.status != 0:
+			if err == nil || errkind.StatusCode(err) != tt.status {
+				t.Errorf("%d: want status %d, got %v", i, tt.status, err)
+			}
+		case tt.wantErr != "":
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("%d: want error %q, got %v", i, tt.wantErr, err)
+			}
+		case err != nil:
+			t.Errorf("%d: %v", i, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%d: want %d bytes, got %d", i, len(tt.want), len(got))
+		}
+		if n != int64(buf.Len()) {
+			t.Errorf("%d: want n=%d, got %d", i, buf.Len(), n)
+		}
+		if progress != tt.progress {
+			t.Errorf("%d: want progress %d, got %d", i, tt.progress, progress)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+// FieldError describes a validation error with an individual field.
+type FieldError struct {
+	Field   string // Name of the field, eg "name" or "address.postcode"
+	Message string // Message suitable for sending to the client
+	Code    string // Optional error code, eg "required"
+}
+
+// ValidationError is an error that describes one or more invalid fields in
+// a request. It has a public status of 422 (Unprocessable Entity) unless
+// another status is specified, and
+// WriteError sends the field errors to the client in an "errors" array.
+//
+// Handlers typically add field errors while validating the request, and
+// then call Err to obtain an error only if there were any field errors:
+//  var verr httpapi.ValidationError
+//  if input.Name == "" {
+//      verr.Add("name", "is required", "required")
+//  }
+//  if err := verr.Err(); err != nil {
+//      httpapi.WriteError(w, r, err)
+//      return
+//  }
+type ValidationError struct {
+	// Summary is an optional message describing the error.
+	// If not specified, the message is "validation failed".
+	Summary string
+
+	// Fields contains the field errors.
+	Fields []FieldError
+
+	// Status is an optional HTTP status for the error. If not
+	// specified, the status is 422 (Unprocessable Entity).
+	Status int
+}
+
+// Add appends a field error.
+func (e *ValidationError) Add(field, message, code string) {
+	e.Fields = append(e.Fields, FieldError{
+		Field:   field,
+		Message: message,
+		Code:    code,
+	})
+}
+
+// Err returns nil if there are no field errors, otherwise it returns e.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	if len(msgs) == 0 {
+		return e.Message()
+	}
+	return e.Message() + ": " + strings.Join(msgs, "; ")
+}
+
+// Message returns the error message without the field errors.
+func (e *ValidationError) Message() string {
+	if e.Summary == "" {
+		return "validation failed"
+	}
+	return e.Summary
+}
+
+// StatusCode returns the HTTP status, which is 422 (Unprocessable Entity)
+// unless Status is specified.
+func (e *ValidationError) StatusCode() int {
+	if e.Status != 0 {
+		return e.Status
+	}
+	return http.StatusUnprocessableEntity
+}
+
+// PublicMessage indicates that the message can be sent to the client.
+func (e *ValidationError) PublicMessage() {}
+
+// PublicStatusCode indicates that the status code can be sent to the client.
+func (e *ValidationError) PublicStatusCode() {}
+
+// fieldErrors converts the field errors for use in the writeerror content.
+func (e *ValidationError) fieldErrors() []writeerror.FieldError {
+	fields := make([]writeerror.FieldError, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		fields = append(fields, writeerror.FieldError{
+			Field:   f.Field,
+			Message: f.Message,
+			Code:    f.Code,
+		})
+	}
+	return fields
+}
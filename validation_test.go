@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestValidationError(t *testing.T) {
+	var verr ValidationError
+	if err := verr.Err(); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+	verr.Add("name", "is required", "required")
+	verr.Add("age", "must be positive", "")
+	err := verr.Err()
+	if err == nil {
+		t.Fatal("want error, got nil")
+	}
+	if got, want := err.Error(), "validation failed: name: is required; age: must be positive"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	w := httptest.NewRecorder()
+	WriteError(w, httptest.NewRequest("POST", "/people", nil), err)
+	if got, want := w.Code, http.StatusUnprocessableEntity; got != want {
+		t.Errorf("status: want %d, got %d", want, got)
+	}
+	var payload struct {
+		Error struct {
+			Message string                   `json:"message"`
+			Errors  []map[string]interface{} `json:"errors"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := payload.Error.Message, "validation failed"; got != want {
+		t.Errorf("message: want %q, got %q", want, got)
+	}
+	wantErrors := []map[string]interface{}{
+		{"field": "name", "message": "is required", "code": "required"},
+		{"field": "age", "message": "must be positive"},
+	}
+	if !reflect.DeepEqual(payload.Error.Errors, wantErrors) {
+		t.Errorf("errors: want %v, got %v", wantErrors, payload.Error.Errors)
+	}
+}
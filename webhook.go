@@ -0,0 +1,130 @@
+package httpapi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+)
+
+// Default values for WebhookConfig.
+const (
+	defaultWebhookSignatureHeader = "X-Hub-Signature-256"
+	defaultWebhookTolerance       = 5 * time.Minute
+)
+
+// webhookSignaturePrefix is the prefix of the hex-encoded signature.
+const webhookSignaturePrefix = "sha256="
+
+// WebhookConfig contains the configuration for VerifyWebhook.
+type WebhookConfig struct {
+	// Secret is the secret shared with the sender of the webhooks,
+	// which is used to sign the request body. It must not be empty.
+	Secret []byte
+
+	// SignatureHeader is the request header that contains the signature,
+	// in the format "sha256=<hex>". If empty, the header is
+	// "X-Hub-Signature-256".
+	SignatureHeader string
+
+	// TimestampHeader is an optional request header that contains the time
+	// the webhook was sent, in seconds since the Unix epoch. If it is set,
+	// the timestamp is included in the signature (see SignWebhook), and
+	// requests sent too long ago are rejected, so that captured requests
+	// cannot be replayed.
+	TimestampHeader string
+
+	// Tolerance is the maximum difference between the timestamp and the
+	// current time. If zero, the tolerance is five minutes.
+	Tolerance time.Duration
+}
+
+// VerifyWebhook returns middleware that verifies the signature of inbound
+// webhook requests, which is an HMAC-SHA256 of the raw request body using
+// a shared secret, in the same format as the X-Hub-Signature-256 header
+// sent by GitHub. Requests without a valid signature are rejected with a
+// 401 (Unauthorized) error, sent using WriteError.
+//
+// The request body is read before the handler is called, subject to the same
+// size limit as ReadRequest, and is replaced with the raw body, so the handler
+// can read it as usual, eg using ReadRequest.
+//
+// For example:
+//  http.Handle("POST /webhooks/github", httpapi.VerifyWebhook(httpapi.WebhookConfig{
+//      Secret: []byte(os.Getenv("GITHUB_WEBHOOK_SECRET")),
+//  })(handler))
+func VerifyWebhook(config WebhookConfig) Middleware {
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = defaultWebhookSignatureHeader
+	}
+	if config.Tolerance <= 0 {
+		config.Tolerance = defaultWebhookTolerance
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := config.verify(r)
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// verify reads the request body and verifies its signature.
+func (config *WebhookConfig) verify(r *http.Request) ([]byte, error) {
+	if len(config.Secret) == 0 {
+		return nil, errors.New("webhook secret not specified")
+	}
+	signature := r.Header.Get(config.SignatureHeader)
+	if signature == "" {
+		return nil, errkind.Public("missing webhook signature", http.StatusUnauthorized)
+	}
+	var timestamp string
+	if config.TimestampHeader != "" {
+		timestamp = r.Header.Get(config.TimestampHeader)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, errkind.Public("invalid webhook timestamp", http.StatusUnauthorized)
+		}
+		age := time.Since(time.Unix(seconds, 0))
+		if age > config.Tolerance || age < -config.Tolerance {
+			return nil, errkind.Public("webhook timestamp outside tolerance", http.StatusUnauthorized)
+		}
+	}
+
+	var data rawData
+	if err := data.ReadRequest(r); err != nil {
+		return nil, err
+	}
+	want := SignWebhook(config.Secret, timestamp, data.Content)
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(want)) {
+		return nil, errkind.Public("invalid webhook signature", http.StatusUnauthorized)
+	}
+	return data.Content, nil
+}
+
+// SignWebhook returns the signature of a webhook body, in the format
+// "sha256=<hex>", for the secret. If timestamp is not empty, the signed
+// content is the timestamp, a dot, and the body, eg "1700000000.{...}",
+// otherwise it is just the body.
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	if timestamp != "" {
+		mac.Write([]byte(timestamp))
+		mac.Write([]byte{'.'})
+	}
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
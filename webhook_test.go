@@ -0,0 +1,127 @@
+package httpapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("secret")
+	body := `{"action":"opened"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		config    WebhookConfig
+		header    map[string]string
+		status    int
+		wantError string
+	}{
+		{
+			config: WebhookConfig{Secret: secret},
+			header: map[string]string{"X-Hub-Signature-256": SignWebhook(secret, "", []byte(body))},
+			status: http.StatusOK,
+		},
+		{
+			config: WebhookConfig{Secret: secret},
+			header: map[string]string{"X-Hub-Signature-256": strings.ToUpper(SignWebhook(secret, "", []byte(body)))},
+			status: http.StatusOK,
+		},
+		{
+			config:    WebhookConfig{Secret: secret},
+			status:    http.StatusUnauthorized,
+			wantError: "missing webhook signature",
+		},
+		{
+			config:    WebhookConfig{Secret: secret},
+			header:    map[string]string{"X-Hub-Signature-256": SignWebhook([]byte("other"), "", []byte(body))},
+			status:    http.StatusUnauthorized,
+			wantError: "invalid webhook signature",
+		},
+		{
+			config: WebhookConfig{Secret: secret, SignatureHeader: "X-Signature", TimestampHeader: "X-Timestamp"},
+			header: map[string]string{
+				"X-Signature": SignWebhook(secret, now, []byte(body)),
+				"X-Timestamp": now,
+			},
+			status: http.StatusOK,
+		},
+		{
+			// timestamp is signed
+			config: WebhookConfig{Secret: secret, TimestampHeader: "X-Timestamp"},
+			header: map[string]string{
+				"X-Hub-Signature-256": SignWebhook(secret, "", []byte(body)),
+				"X-Timestamp":         now,
+			},
+			status:    http.StatusUnauthorized,
+			wantError: "invalid webhook signature",
+		},
+		{
+			config: WebhookConfig{Secret: secret, TimestampHeader: "X-Timestamp"},
+			header: map[string]string{
+				"X-Hub-Signature-256": SignWebhook(secret, old, []byte(body)),
+				"X-Timestamp":         old,
+			},
+			status:    http.StatusUnauthorized,
+			wantError: "webhook timestamp outside tolerance",
+		},
+		{
+			config: WebhookConfig{Secret: secret, TimestampHeader: "X-Timestamp", Tolerance: 2 * time.Hour},
+			header: map[string]string{
+				"X-Hub-Signature-256": SignWebhook(secret, old, []byte(body)),
+				"X-Timestamp":         old,
+			},
+			status: http.StatusOK,
+		},
+		{
+			config: WebhookConfig{Secret: secret, TimestampHeader: "X-Timestamp"},
+			header: map[string]string{
+				"X-Hub-Signature-256": SignWebhook(secret, "", []byte(body)),
+			},
+			status:    http.StatusUnauthorized,
+			wantError: "invalid webhook timestamp",
+		},
+		{
+			config: WebhookConfig{},
+			header: map[string]string{"X-Hub-Signature-256": SignWebhook(nil, "", []byte(body))},
+			status: http.StatusInternalServerError,
+		},
+	}
+	for i, tt := range tests {
+		var got string
+		h := VerifyWebhook(tt.config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			got = string(b)
+		}))
+		r := httptest.NewRequest("POST", "/webhooks", strings.NewReader(body))
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got, want := w.Code, tt.status; got != want {
+			t.Errorf("%d: status: want %d, got %d", i, want, got)
+			continue
+		}
+		if tt.status == http.StatusOK && got != body {
+			t.Errorf("%d: body: want %q, got %q", i, body, got)
+		}
+		if tt.wantError != "" && !strings.Contains(w.Body.String(), tt.wantError) {
+			t.Errorf("%d: want error %q, got %s", i, tt.wantError, w.Body.String())
+		}
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	// example from the GitHub documentation
+	got := SignWebhook([]byte("It's a Secret to Everybody"), "", []byte("Hello, World!"))
+	want := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
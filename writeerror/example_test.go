@@ -0,0 +1,40 @@
+package writeerror_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+// This example shows how to compose custom configuration callbacks
+// from the default implementations.
+func Example() {
+	config := writeerror.Config{
+		// trust clients with a specific header, as well as the default
+		IsTrusted: func(r *http.Request) bool {
+			return r.Header.Get("X-Debug") == "secret" || writeerror.DefaultIsTrusted(r)
+		},
+
+		// the default JSON, plus an extra field
+		MarshalContent: func(content *writeerror.Content) []byte {
+			var payload map[string]map[string]interface{}
+			if err := json.Unmarshal(writeerror.DefaultMarshalContent(content), &payload); err != nil {
+				return writeerror.DefaultMarshalContent(content)
+			}
+			payload["error"]["docs"] = "https://example.com/errors"
+			data, _ := json.Marshal(payload)
+			return data
+		},
+	}
+
+	data := config.MarshalContent(&writeerror.Content{
+		Message:    "Not Found",
+		StatusCode: http.StatusNotFound,
+	})
+	os.Stdout.Write(data)
+
+	// Output:
+	// {"error":{"docs":"https://example.com/errors","message":"Not Found","status":404}}
+}
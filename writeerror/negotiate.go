@@ -0,0 +1,148 @@
+package writeerror
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MarshalText marshals the content as plain text suitable for displaying
+// to a person, for example:
+//  404 Not Found: no such thing
+//  code: XXX999
+//  trace: a8845f4dc3792a63
+// The code and trace lines are only present if they have values. For trusted
+// clients the text includes the error detail, the field errors and the stack trace.
+func MarshalText(content *Content) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d %s", content.StatusCode, http.StatusText(content.StatusCode))
+	if content.Message != "" && content.Message != http.StatusText(content.StatusCode) {
+		fmt.Fprintf(&buf, ": %s", content.Message)
+	}
+	buf.WriteString("\n")
+	if content.Code != "" {
+		fmt.Fprintf(&buf, "code: %s\n", content.Code)
+	}
+	if content.Trace != "" {
+		fmt.Fprintf(&buf, "trace: %s\n", content.Trace)
+	}
+	for _, f := range content.Fields {
+		fmt.Fprintf(&buf, "%s: %s\n", f.Field, f.Message)
+	}
+	if content.Err != nil {
+		fmt.Fprintf(&buf, "detail: %s\n", content.Err.Error())
+	}
+	if content.Stack != "" {
+		fmt.Fprintf(&buf, "\n%s", content.Stack)
+	}
+	return buf.Bytes()
+}
+
+// MarshalHTML marshals the content as a minimal HTML page containing
+// the same text as MarshalText.
+func MarshalHTML(content *Content) []byte {
+	title := html.EscapeString(fmt.Sprintf("%d %s", content.StatusCode, http.StatusText(content.StatusCode)))
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head><title>")
+	buf.WriteString(title)
+	buf.WriteString("</title></head>\n<body>\n<pre>")
+	buf.WriteString(html.EscapeString(string(MarshalText(content))))
+	buf.WriteString("</pre>\n</body>\n</html>\n")
+	return buf.Bytes()
+}
+
+// MarshalRequest marshals the content in the format that best matches the
+// Accept header of the request, and returns the content type and the body.
+// The config's Marshal method is used unless the client prefers one of the
+// media types in AlternateFormats over JSON. Clients that do not send an
+// Accept header, or that accept any media type, receive the config's format.
+func (c Config) MarshalRequest(r *http.Request, content *Content) (contentType string, data []byte) {
+	c = c.withDefaults()
+	for _, mediaType := range acceptedMediaTypes(r) {
+		if c.acceptsDefault(mediaType) {
+			break
+		}
+		if format := c.alternateFormat(mediaType); format != "" {
+			contentType = format
+			if strings.HasPrefix(mediaType, "text/") {
+				contentType += "; charset=utf-8"
+			}
+			return contentType, c.AlternateFormats[format](content)
+		}
+	}
+	return c.ContentType, c.Marshal(content)
+}
+
+// acceptsDefault reports whether the media type from an Accept
+// header matches the config's content type.
+func (c Config) acceptsDefault(mediaType string) bool {
+	switch mediaType {
+	case "*/*", "application/*", "application/json":
+		return true
+	}
+	return mediaType == c.ContentType || strings.HasSuffix(mediaType, "+json")
+}
+
+// alternateFormat returns the key in AlternateFormats that matches
+// the media type from an Accept header, which can be a wildcard such
+// as "text/*". Returns the empty string if there is no match.
+func (c Config) alternateFormat(mediaType string) string {
+	if _, ok := c.AlternateFormats[mediaType]; ok {
+		return mediaType
+	}
+	if prefix := strings.TrimSuffix(mediaType, "*"); prefix != mediaType {
+		// choose deterministically if more than one format matches
+		var keys []string
+		for key := range c.AlternateFormats {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) > 0 {
+			sort.Strings(keys)
+			return keys[len(keys)-1]
+		}
+	}
+	return ""
+}
+
+// acceptedMediaTypes returns the media types in the Accept header of the
+// request, in order of preference. Media types with a quality of zero
+// are not included.
+func acceptedMediaTypes(r *http.Request) []string {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var list []accepted
+	for _, header := range r.Header["Accept"] {
+		for _, part := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if s, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(s, 64); err != nil {
+					continue
+				}
+			}
+			if q > 0 {
+				list = append(list, accepted{mediaType: mediaType, q: q})
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].q > list[j].q
+	})
+	mediaTypes := make([]string, len(list))
+	for i, a := range list {
+		mediaTypes[i] = a.mediaType
+	}
+	return mediaTypes
+}
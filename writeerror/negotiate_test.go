@@ -0,0 +1,58 @@
+package writeerror
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMarshalRequest(t *testing.T) {
+	tests := []struct {
+		accept      string
+		config      Config
+		contentType string
+	}{
+		{accept: "", contentType: "application/json"},
+		{accept: "*/*", contentType: "application/json"},
+		{accept: "application/json", contentType: "application/json"},
+		{accept: "text/plain", contentType: "text/plain; charset=utf-8"},
+		{accept: "text/*", contentType: "text/plain; charset=utf-8"},
+		{
+			accept:      "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			contentType: "text/html; charset=utf-8",
+		},
+		{accept: "text/html;q=0.5, application/json", contentType: "application/json"},
+		{accept: "text/html;q=0, */*", contentType: "application/json"},
+		{accept: "application/problem+json, text/plain", contentType: "application/json"},
+		{
+			accept:      "text/plain",
+			config:      Config{AlternateFormats: map[string]func(*Content) []byte{}},
+			contentType: "application/json",
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		contentType, _ := tt.config.MarshalRequest(r, &Content{Message: "Not Found", StatusCode: 404})
+		if got, want := contentType, tt.contentType; got != want {
+			t.Errorf("%d: want %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	content := &Content{
+		Message:    "<no such thing>",
+		StatusCode: 404,
+		Code:       "NF001",
+		Trace:      "abc",
+	}
+	if got, want := string(MarshalText(content)), "404 Not Found: <no such thing>\ncode: NF001\ntrace: abc\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got := string(MarshalHTML(content)); !strings.Contains(got, "&lt;no such thing&gt;") {
+		t.Errorf("html not escaped: %s", got)
+	}
+}
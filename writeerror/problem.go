@@ -0,0 +1,67 @@
+package writeerror
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the content type for RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// MarshalProblem is an implementation of Config.MarshalContent that marshals
+// errors as RFC 7807 problem details. When using this function, set the
+// Config.ContentType to ProblemContentType. An error is marshalled into the
+// following JSON:
+//  {
+//      "type": "about:blank",
+//      "title": "Bad Request",
+//      "status": 400,
+//      "detail": "message text",
+//      "instance": "/api/things/123",
+//      "code": "XXX999",
+//      "trace": "a8845f4dc3792a63",
+//      "debug": "detailed information for trusted clients",
+//      "stack": "stack trace for trusted clients",
+//      "errors": [
+//          {"field": "name", "message": "is required", "code": "required"}
+//      ]
+//  }
+// The "detail" key is omitted if the message is the same as the title. The
+// "code", "trace", "debug", "stack" and "errors" keys are extension members, and are optional.
+func MarshalProblem(content *Content) []byte {
+	var payload struct {
+		Type     string       `json:"type"`
+		Title    string       `json:"title"`
+		Status   int          `json:"status"`
+		Detail   string       `json:"detail,omitempty"`
+		Instance string       `json:"instance,omitempty"`
+		Code     string       `json:"code,omitempty"`
+		Trace    string       `json:"trace,omitempty"`
+		Debug    string       `json:"debug,omitempty"`
+		Stack    string       `json:"stack,omitempty"`
+		Errors   []FieldError `json:"errors,omitempty"`
+	}
+	payload.Type = "about:blank"
+	payload.Title = http.StatusText(content.StatusCode)
+	payload.Status = content.StatusCode
+	if content.Message != payload.Title {
+		payload.Detail = content.Message
+	}
+	payload.Instance = content.Instance
+	payload.Code = content.Code
+	payload.Trace = content.Trace
+	if content.Err != nil {
+		payload.Debug = content.Err.Error()
+	}
+	payload.Stack = content.Stack
+	payload.Errors = content.Fields
+
+	// format errors nicely to make diagnostics easier when using curl
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(payload) // should not fail
+
+	return buf.Bytes()
+}
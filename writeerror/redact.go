@@ -0,0 +1,46 @@
+package writeerror
+
+import "regexp"
+
+// Redacted is the text that replaces sensitive details removed by RedactPatterns.
+const Redacted = "[REDACTED]"
+
+// RedactPatterns returns a RedactContent callback that replaces any text
+// matching one of the patterns with Redacted. The patterns are applied to the
+// message, code, field messages, and the message of the error. If the error
+// message contains a match, the error is replaced with an error that has the
+// redacted message, so the original error is not available to ErrorWritten.
+//
+// For example, to remove bearer tokens and email addresses:
+//  writeerror.Default.RedactContent = writeerror.RedactPatterns(
+//      regexp.MustCompile(`(?i)bearer\s+[a-z0-9._~+/-]+=*`),
+//      regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
+//  )
+func RedactPatterns(patterns ...*regexp.Regexp) func(*Content) {
+	redact := func(s string) string {
+		for _, p := range patterns {
+			s = p.ReplaceAllLiteralString(s, Redacted)
+		}
+		return s
+	}
+	return func(content *Content) {
+		content.Message = redact(content.Message)
+		content.Code = redact(content.Code)
+		for i := range content.Fields {
+			content.Fields[i].Message = redact(content.Fields[i].Message)
+		}
+		if content.Err != nil {
+			msg := content.Err.Error()
+			if redacted := redact(msg); redacted != msg {
+				content.Err = redactedError(redacted)
+			}
+		}
+	}
+}
+
+// redactedError replaces an error whose message contained sensitive details.
+type redactedError string
+
+func (e redactedError) Error() string {
+	return string(e)
+}
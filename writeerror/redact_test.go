@@ -0,0 +1,64 @@
+package writeerror
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestRedactPatterns(t *testing.T) {
+	redact := RedactPatterns(
+		regexp.MustCompile(`token=\w+`),
+		regexp.MustCompile(`\w+@example\.com`),
+	)
+	tests := []struct {
+		content Content
+		want    Content
+	}{
+		{
+			content: Content{Message: "bad token=abc123", Code: "X1"},
+			want:    Content{Message: "bad [REDACTED]", Code: "X1"},
+		},
+		{
+			content: Content{
+				Message: "invalid",
+				Fields:  []FieldError{{Field: "email", Message: "bob@example.com is taken"}},
+				Err:     errors.New("lookup bob@example.com failed"),
+			},
+			want: Content{
+				Message: "invalid",
+				Fields:  []FieldError{{Field: "email", Message: "[REDACTED] is taken"}},
+				Err:     redactedError("lookup [REDACTED] failed"),
+			},
+		},
+	}
+	for i, tt := range tests {
+		content := tt.content
+		redact(&content)
+		if got, want := content.Message, tt.want.Message; got != want {
+			t.Errorf("%d: message: want %q, got %q", i, want, got)
+		}
+		if got, want := content.Code, tt.want.Code; got != want {
+			t.Errorf("%d: code: want %q, got %q", i, want, got)
+		}
+		for j := range content.Fields {
+			if got, want := content.Fields[j].Message, tt.want.Fields[j].Message; got != want {
+				t.Errorf("%d: field %d: want %q, got %q", i, j, want, got)
+			}
+		}
+		if tt.want.Err != nil {
+			if got, want := content.Err.Error(), tt.want.Err.Error(); got != want {
+				t.Errorf("%d: err: want %q, got %q", i, want, got)
+			}
+		}
+	}
+}
+
+func TestRedactUnchangedError(t *testing.T) {
+	err := errors.New("nothing to hide")
+	content := Content{Err: err}
+	RedactPatterns(regexp.MustCompile(`secret`))(&content)
+	if content.Err != err {
+		t.Errorf("want original error, got %v", content.Err)
+	}
+}
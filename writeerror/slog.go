@@ -0,0 +1,44 @@
+package writeerror
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// SlogErrorWritten returns an implementation of Config.ErrorWritten that
+// logs a structured entry for each error written to a client. If logger
+// is nil, the default slog logger is used when the error is logged.
+//
+// Errors with a 5xx status are logged at error level, and other errors are
+// logged at warning level. Each entry includes the request method and path,
+// and the status, code, trace and error of the content.
+//
+// To log errors for all requests that do not have a specific configuration:
+//  writeerror.Default.ErrorWritten = writeerror.SlogErrorWritten(nil)
+func SlogErrorWritten(logger *slog.Logger) func(*http.Request, *Content) {
+	return func(r *http.Request, content *Content) {
+		l := logger
+		if l == nil {
+			l = slog.Default()
+		}
+		level := slog.LevelWarn
+		if content.StatusCode >= 500 {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", content.StatusCode),
+		}
+		if content.Code != "" {
+			attrs = append(attrs, slog.String("code", content.Code))
+		}
+		if content.Trace != "" {
+			attrs = append(attrs, slog.String("trace", content.Trace))
+		}
+		if content.Err != nil {
+			attrs = append(attrs, slog.Any("error", content.Err))
+		}
+		l.LogAttrs(r.Context(), level, content.Message, attrs...)
+	}
+}
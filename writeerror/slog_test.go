@@ -0,0 +1,46 @@
+package writeerror
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSlogErrorWritten(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	errorWritten := SlogErrorWritten(logger)
+
+	r := httptest.NewRequest("GET", "/things/1", nil)
+	errorWritten(r, &Content{
+		Message:    "Internal Server Error",
+		StatusCode: 500,
+		Trace:      "trace-1",
+		Err:        errors.New("database unavailable"),
+	})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"level":  "ERROR",
+		"msg":    "Internal Server Error",
+		"method": "GET",
+		"path":   "/things/1",
+		"status": float64(500),
+		"trace":  "trace-1",
+		"error":  "database unavailable",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s: want %v, got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["code"]; ok {
+		t.Errorf("want no code, got %v", entry["code"])
+	}
+}
@@ -0,0 +1,108 @@
+package writeerror
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+
+	"github.com/jjeffery/errors"
+)
+
+// proxyHeaders are request headers that indicate the request
+// was forwarded by a reverse proxy.
+var proxyHeaders = []string{
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Real-Ip",
+}
+
+// IsLocalhost reports whether the request originated on the local host.
+// Requests that have been routed through a reverse proxy running on the
+// local host are not considered to be from the local host, because the
+// original client is elsewhere.
+func IsLocalhost(r *http.Request) bool {
+	if isProxied(r) {
+		return false
+	}
+	ip := remoteIP(r)
+	return ip != nil && ip.IsLoopback()
+}
+
+// TrustNetworks returns an IsTrusted callback that trusts requests from
+// remote addresses in any of the networks, which are specified in CIDR
+// notation, eg "10.0.0.0/8" or "::1/128". As with IsLocalhost, requests
+// routed through a reverse proxy are not trusted. Returns an error if any
+// of the networks cannot be parsed.
+func TrustNetworks(cidrs ...string) (func(*http.Request) bool, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid network").With("cidr", cidr)
+		}
+		networks = append(networks, network)
+	}
+	isTrusted := func(r *http.Request) bool {
+		if isProxied(r) {
+			return false
+		}
+		ip := remoteIP(r)
+		if ip == nil {
+			return false
+		}
+		for _, network := range networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return isTrusted, nil
+}
+
+// TrustHeader returns an IsTrusted callback that trusts requests with a
+// header containing a shared secret. Because the header is sent by the client,
+// this works with requests routed through a reverse proxy. If secret is empty,
+// no requests are trusted.
+func TrustHeader(name, secret string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		if secret == "" {
+			return false
+		}
+		value := r.Header.Get(name)
+		return subtle.ConstantTimeCompare([]byte(value), []byte(secret)) == 1
+	}
+}
+
+// TrustAny returns an IsTrusted callback that trusts a request
+// if any of the callbacks trust the request.
+func TrustAny(isTrusted ...func(*http.Request) bool) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, fn := range isTrusted {
+			if fn != nil && fn(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func isProxied(r *http.Request) bool {
+	for _, name := range proxyHeaders {
+		if r.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the IP address of the remote address of the
+// request, or nil if it cannot be determined.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
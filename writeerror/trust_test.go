@@ -0,0 +1,61 @@
+package writeerror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsTrusted(t *testing.T) {
+	networks, err := TrustNetworks("10.0.0.0/8", "fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := TrustHeader("X-Debug-Secret", "s3cret")
+
+	tests := []struct {
+		remoteAddr string
+		header     http.Header
+		localhost  bool
+		network    bool
+		secret     bool
+	}{
+		{remoteAddr: "127.0.0.1:1234", localhost: true},
+		{remoteAddr: "[::1]:1234", localhost: true},
+		{remoteAddr: "127.0.0.1:1234", header: http.Header{"X-Forwarded-For": {"203.0.113.1"}}},
+		{remoteAddr: "10.1.2.3:1234", network: true},
+		{remoteAddr: "[fd00::1]:1234", network: true},
+		{remoteAddr: "10.1.2.3:1234", header: http.Header{"X-Real-Ip": {"203.0.113.1"}}},
+		{remoteAddr: "203.0.113.1:1234"},
+		{remoteAddr: "203.0.113.1:1234", header: http.Header{"X-Debug-Secret": {"s3cret"}}, secret: true},
+		{remoteAddr: "203.0.113.1:1234", header: http.Header{"X-Debug-Secret": {"wrong"}}},
+		{remoteAddr: "invalid"},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		for k, v := range tt.header {
+			r.Header[k] = v
+		}
+		if got := DefaultIsTrusted(r); got != tt.localhost {
+			t.Errorf("%d: localhost: want %v, got %v", i, tt.localhost, got)
+		}
+		if got := networks(r); got != tt.network {
+			t.Errorf("%d: network: want %v, got %v", i, tt.network, got)
+		}
+		if got := header(r); got != tt.secret {
+			t.Errorf("%d: secret: want %v, got %v", i, tt.secret, got)
+		}
+		want := tt.localhost || tt.network || tt.secret
+		if got := TrustAny(IsLocalhost, networks, header)(r); got != want {
+			t.Errorf("%d: any: want %v, got %v", i, want, got)
+		}
+	}
+
+	if _, err := TrustNetworks("not-a-network"); err == nil {
+		t.Errorf("want error, got nil")
+	}
+	if TrustHeader("X-Debug-Secret", "")(httptest.NewRequest("GET", "/", nil)) {
+		t.Errorf("want not trusted for empty secret")
+	}
+}
@@ -12,16 +12,31 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
 )
 
 // Content contains the information sent back to the HTTP client
 // in an error response.
 type Content struct {
-	Message    string // Message sent to client, which may be different to err.Error().
-	StatusCode int    // HTTP status
-	Code       string // Optional Error code
-	Trace      string // Optional unique ID for cross reference with tracing/logging
-	Err        error  // Only sent to trusted clients
+	Message    string        // Message sent to client, which may be different to err.Error().
+	StatusCode int           // HTTP status
+	Code       string        // Optional Error code
+	Trace      string        // Optional unique ID for cross reference with tracing/logging
+	Instance   string        // Optional URI reference that identifies the request, usually its path
+	Fields     []FieldError  // Optional field-level errors, eg for validation failures
+	Err        error         // Only sent to trusted clients
+	Stack      string        // Optional stack trace, only sent to trusted clients
+	RetryAfter time.Duration // Optional time the client should wait before retrying
+}
+
+// FieldError describes an error with an individual field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
 }
 
 // Config contains configuration in the form of callback functions that are
@@ -48,26 +63,85 @@ type Config struct {
 	//          "status": 400,
 	//          "code": "XXX999",
 	//          "trace": "a8845f4dc3792a63",
-	//          "detail": "detailed information for trusted clients"
+	//          "detail": "detailed information for trusted clients",
+	//          "stack": "stack trace for trusted clients",
+	//          "errors": [
+	//              {"field": "name", "message": "is required", "code": "required"}
+	//          ]
 	//      }
 	//  }
-	// In the example above, the "code", "trace" and "detail" keys are optional.
+	// In the example above, the "code", "trace", "detail", "stack" and "errors" keys are optional.
 	MarshalContent func(*Content) []byte
 
+	// MarshalContentByStatus specifies optional callback functions that are
+	// called instead of MarshalContent to marshal errors with particular
+	// status codes. Keys are either a status code, eg "401", or a status
+	// class, eg "4xx". A status code takes precedence over a status class.
+	// For example, to use a different JSON shape for authentication errors:
+	//  config.MarshalContentByStatus = map[string]func(*writeerror.Content) []byte{
+	//      "401": marshalAuthError,
+	//      "403": marshalAuthError,
+	//  }
+	MarshalContentByStatus map[string]func(*Content) []byte
+
+	// ContentType specifies the content type of the marshalled error details.
+	// If not specified, the content type is "application/json". If MarshalContent
+	// is set to MarshalProblem, this should be set to ProblemContentType.
+	ContentType string
+
+	// AlternateFormats specifies callback functions for marshalling errors into
+	// media types other than ContentType. They are used when the client's Accept
+	// header prefers one of the media types to JSON, for example a browser that
+	// requests an API URL directly. The keys are media types, eg "text/html". By
+	// default plain text and HTML are supported, using MarshalText and MarshalHTML.
+	// To always use ContentType, set this to an empty, non-nil map.
+	AlternateFormats map[string]func(*Content) []byte
+
+	// CacheControl specifies the value of the Cache-Control header sent with
+	// error responses. If not specified, the value is "no-store", which prevents
+	// proxies and CDNs from caching transient errors.
+	CacheControl string
+
+	// CacheControlByStatus specifies the value of the Cache-Control header for
+	// error responses with particular status codes. Keys are either a status code,
+	// eg "404", or a status class, eg "4xx", as for MarshalContentByStatus. An
+	// empty value means that no Cache-Control header is sent. For example, to
+	// allow not found responses to be cached for a minute:
+	//  config.CacheControlByStatus = map[string]string{
+	//      "404": "public, max-age=60",
+	//  }
+	CacheControlByStatus map[string]string
+
 	// ErrorWrittenCallback specifies an optional callback function that is called whenever
 	// an error has been written to the client. This can be used to log all error
 	// messages sent to the client. The default implementation does nothing.
 	ErrorWritten func(*http.Request, *Content)
+
+	// RedactContent specifies an optional callback function that is called to
+	// remove secrets, tokens or personal information from the content. It is
+	// called before the content is marshalled, and again before ErrorWritten
+	// is called, so that sensitive details reach neither clients nor logs. It
+	// should modify the Message, Code, Err and Fields of the content in place.
+	// The default implementation does nothing. Use RedactPatterns to build a
+	// callback from a list of regular expressions.
+	RedactContent func(*Content)
 }
 
 // Default contains the default configuration callbacks.
 var Default Config
 
 func init() {
-	Default.GetTrace = defaultGetTrace
-	Default.IsTrusted = defaultIsTrusted
-	Default.MarshalContent = defaultMarshalContent
-	Default.ErrorWritten = defaultErrorWritten
+	Default.GetTrace = DefaultGetTrace
+	Default.IsTrusted = DefaultIsTrusted
+	Default.MarshalContent = DefaultMarshalContent
+	Default.ErrorWritten = DefaultErrorWritten
+	Default.RedactContent = DefaultRedactContent
+	Default.ContentType = "application/json"
+	Default.CacheControl = "no-store"
+	Default.AlternateFormats = map[string]func(*Content) []byte{
+		"text/plain": MarshalText,
+		"text/html":  MarshalHTML,
+	}
 }
 
 type contextKey int
@@ -94,19 +168,127 @@ func (c Config) newRequest(r *http.Request) *http.Request {
 // callbacks, pointing to the default implementation if not specified otherwise.
 func ConfigFromRequest(r *http.Request) Config {
 	config, _ := r.Context().Value(errorCallbackKey).(Config)
-	if config.GetTrace == nil {
-		config.GetTrace = Default.GetTrace
+	return config.withDefaults()
+}
+
+// withDefaults returns a copy of the config with any nil callbacks
+// replaced with the default callbacks.
+func (c Config) withDefaults() Config {
+	if c.GetTrace == nil {
+		c.GetTrace = Default.GetTrace
+	}
+	if c.IsTrusted == nil {
+		c.IsTrusted = Default.IsTrusted
+	}
+	if c.MarshalContent == nil {
+		c.MarshalContent = Default.MarshalContent
+	}
+	if c.MarshalContentByStatus == nil {
+		c.MarshalContentByStatus = Default.MarshalContentByStatus
+	}
+	if c.ErrorWritten == nil {
+		c.ErrorWritten = Default.ErrorWritten
+	}
+	if c.AlternateFormats == nil {
+		c.AlternateFormats = Default.AlternateFormats
+	}
+	if c.RedactContent == nil {
+		c.RedactContent = Default.RedactContent
+	}
+	if c.ContentType == "" {
+		c.ContentType = Default.ContentType
+	}
+	if c.CacheControl == "" {
+		c.CacheControl = Default.CacheControl
 	}
-	if config.IsTrusted == nil {
-		config.IsTrusted = Default.IsTrusted
+	if c.CacheControlByStatus == nil {
+		c.CacheControlByStatus = Default.CacheControlByStatus
 	}
-	if config.MarshalContent == nil {
-		config.MarshalContent = Default.MarshalContent
+	return c
+}
+
+// Marshal marshals the content using the config's MarshalContentByStatus
+// callback for the content's status, or its MarshalContent callback if there
+// is none for the status. If there is no callback, the default callback is used.
+// It does not require a HTTP request, so it can be used by background workers
+// and other code that needs to format errors in the same way as httpapi.WriteError.
+//
+// When the callback is DefaultMarshalContent, and the content only has the
+// status and its standard message, eg a generic 404 or 500 error, the result
+// is marshalled once per status and shared, so the returned bytes must not
+// be modified.
+func (c Config) Marshal(content *Content) []byte {
+	c = c.withDefaults()
+	marshal := c.MarshalContentByStatus[strconv.Itoa(content.StatusCode)]
+	if marshal == nil {
+		marshal = c.MarshalContentByStatus[strconv.Itoa(content.StatusCode/100)+"xx"]
 	}
-	if config.ErrorWritten == nil {
-		config.ErrorWritten = Default.ErrorWritten
+	if marshal == nil {
+		marshal = c.MarshalContent
 	}
-	return config
+	if marshal == nil {
+		marshal = DefaultMarshalContent
+	}
+	if isGeneric(content) && reflect.ValueOf(marshal).Pointer() == defaultMarshalPointer {
+		if data, ok := genericPayloads.Load(content.StatusCode); ok {
+			return data.([]byte)
+		}
+		data := marshal(content)
+		data = data[:len(data):len(data)] // append copies
+		genericPayloads.Store(content.StatusCode, data)
+		return data
+	}
+	return marshal(content)
+}
+
+var (
+	// genericPayloads contains the bytes marshalled by DefaultMarshalContent
+	// for generic error content, keyed by status code.
+	genericPayloads sync.Map
+
+	// defaultMarshalPointer identifies DefaultMarshalContent, because
+	// functions cannot be compared.
+	defaultMarshalPointer = reflect.ValueOf(DefaultMarshalContent).Pointer()
+)
+
+// isGeneric reports whether the content has nothing but an error status
+// and its standard message, so that it marshals identically for every
+// request with the same status.
+func isGeneric(content *Content) bool {
+	return content.StatusCode >= 400 && content.StatusCode <= 599 &&
+		content.Message == http.StatusText(content.StatusCode) &&
+		content.Code == "" &&
+		content.Trace == "" &&
+		content.Err == nil &&
+		content.Stack == "" &&
+		len(content.Fields) == 0
+}
+
+// Redact removes sensitive details from the content using the config's
+// RedactContent callback, or the default callback if there is none.
+func (c Config) Redact(content *Content) {
+	c = c.withDefaults()
+	if c.RedactContent != nil {
+		c.RedactContent(content)
+	}
+}
+
+// CacheControlFor returns the value of the Cache-Control header for an error
+// response with the status code, or the empty string if no header should be sent.
+func (c Config) CacheControlFor(status int) string {
+	c = c.withDefaults()
+	if v, ok := c.CacheControlByStatus[strconv.Itoa(status)]; ok {
+		return v
+	}
+	if v, ok := c.CacheControlByStatus[strconv.Itoa(status/100)+"xx"]; ok {
+		return v
+	}
+	return c.CacheControl
+}
+
+// Marshal marshals the content using the default configuration.
+func Marshal(content *Content) []byte {
+	return Default.Marshal(content)
 }
 
 // Middleware returns middleware that associates the configuration
@@ -121,23 +303,32 @@ func Middleware(c Config) func(http.Handler) http.Handler {
 	}
 }
 
-func defaultGetTrace(r *http.Request) string {
+// DefaultGetTrace is the default implementation of Config.GetTrace.
+// It always returns an empty string.
+func DefaultGetTrace(r *http.Request) string {
 	return ""
 }
 
-func defaultIsTrusted(r *http.Request) bool {
-	// TODO(jpj): check for localhost request
-	return false
+// DefaultIsTrusted is the default implementation of Config.IsTrusted.
+// It trusts requests that originate on the local host (see IsLocalhost).
+// Use TrustNetworks, TrustHeader and TrustAny to build other policies.
+func DefaultIsTrusted(r *http.Request) bool {
+	return IsLocalhost(r)
 }
 
-func defaultMarshalContent(content *Content) []byte {
+// DefaultMarshalContent is the default implementation of Config.MarshalContent.
+// See Config.MarshalContent for the format of the JSON. Custom implementations
+// can wrap this function, for example to add an extra field to the JSON.
+func DefaultMarshalContent(content *Content) []byte {
 	var payload struct {
 		Error struct {
-			Message string `json:"message"`
-			Status  int    `json:"status"`
-			Code    string `json:"code,omitempty"`
-			Trace   string `json:"trace,omitempty"`
-			Detail  string `json:"detail,omitempty"`
+			Message string       `json:"message"`
+			Status  int          `json:"status"`
+			Code    string       `json:"code,omitempty"`
+			Trace   string       `json:"trace,omitempty"`
+			Detail  string       `json:"detail,omitempty"`
+			Stack   string       `json:"stack,omitempty"`
+			Errors  []FieldError `json:"errors,omitempty"`
 		} `json:"error"`
 	}
 	payload.Error.Message = content.Message
@@ -147,6 +338,8 @@ func defaultMarshalContent(content *Content) []byte {
 	if content.Err != nil {
 		payload.Error.Detail = content.Err.Error()
 	}
+	payload.Error.Stack = content.Stack
+	payload.Error.Errors = content.Fields
 
 	// format errors nicely to make diagnostics easier when using curl
 	var buf bytes.Buffer
@@ -157,5 +350,12 @@ func defaultMarshalContent(content *Content) []byte {
 	return buf.Bytes()
 }
 
-func defaultErrorWritten(r *http.Request, content *Content) {
+// DefaultErrorWritten is the default implementation of Config.ErrorWritten.
+// It does nothing.
+func DefaultErrorWritten(r *http.Request, content *Content) {
+}
+
+// DefaultRedactContent is the default implementation of Config.RedactContent.
+// It does nothing.
+func DefaultRedactContent(content *Content) {
 }
@@ -0,0 +1,96 @@
+package writeerror
+
+import (
+	"testing"
+)
+
+func TestMarshalByStatus(t *testing.T) {
+	marshal := func(s string) func(*Content) []byte {
+		return func(*Content) []byte { return []byte(s) }
+	}
+	config := Config{
+		MarshalContent: marshal("default"),
+		MarshalContentByStatus: map[string]func(*Content) []byte{
+			"401": marshal("401"),
+			"4xx": marshal("4xx"),
+		},
+	}
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{status: 401, want: "401"},
+		{status: 403, want: "4xx"},
+		{status: 404, want: "4xx"},
+		{status: 500, want: "default"},
+	}
+	for _, tt := range tests {
+		if got := string(config.Marshal(&Content{StatusCode: tt.status})); got != tt.want {
+			t.Errorf("%d: want %q, got %q", tt.status, tt.want, got)
+		}
+	}
+}
+
+func TestCacheControlFor(t *testing.T) {
+	tests := []struct {
+		config Config
+		status int
+		want   string
+	}{
+		{status: 500, want: "no-store"},
+		{status: 404, want: "no-store"},
+		{config: Config{CacheControl: "no-cache"}, status: 500, want: "no-cache"},
+		{
+			config: Config{CacheControlByStatus: map[string]string{"404": "public, max-age=60", "4xx": ""}},
+			status: 404,
+			want:   "public, max-age=60",
+		},
+		{
+			config: Config{CacheControlByStatus: map[string]string{"404": "public, max-age=60", "4xx": ""}},
+			status: 400,
+			want:   "",
+		},
+		{
+			config: Config{CacheControlByStatus: map[string]string{"404": "public, max-age=60"}},
+			status: 503,
+			want:   "no-store",
+		},
+	}
+	for i, tt := range tests {
+		if got := tt.config.CacheControlFor(tt.status); got != tt.want {
+			t.Errorf("%d: want %q, got %q", i, tt.want, got)
+		}
+	}
+}
+
+func TestMarshalGeneric(t *testing.T) {
+	generic := &Content{StatusCode: 404, Message: "Not Found"}
+	tests := []struct {
+		config  Config
+		content *Content
+		shared  bool
+	}{
+		{content: generic, shared: true},
+		{content: &Content{StatusCode: 500, Message: "Internal Server Error"}, shared: true},
+		{content: &Content{StatusCode: 404, Message: "no such thing"}},
+		{content: &Content{StatusCode: 404, Message: "Not Found", Trace: "a8845f4dc3792a63"}},
+		{content: &Content{StatusCode: 404, Message: "Not Found", Fields: []FieldError{{Field: "id", Message: "invalid"}}}},
+		{
+			config:  Config{MarshalContent: func(c *Content) []byte { return DefaultMarshalContent(c) }},
+			content: generic,
+		},
+	}
+	for i, tt := range tests {
+		data1 := tt.config.Marshal(tt.content)
+		data2 := tt.config.Marshal(tt.content)
+		if got, want := string(data1), string(DefaultMarshalContent(tt.content)); got != want {
+			t.Errorf("%d: want %s, got %s", i, want, got)
+		}
+		if shared := &data1[0] == &data2[0]; shared != tt.shared {
+			t.Errorf("%d: want shared=%v, got %v", i, tt.shared, shared)
+		}
+		if tt.shared && cap(data1) != len(data1) {
+			t.Errorf("%d: want cap=%d, got %d", i, len(data1), cap(data1))
+		}
+	}
+}
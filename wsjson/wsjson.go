@@ -0,0 +1,128 @@
+// Package wsjson provides support for sending and receiving JSON messages
+// over WebSocket connections.
+//
+// Errors are sent to the client using the same JSON format as httpapi.WriteError,
+// and are subject to the same configuration (see the writeerror package). This means
+// that real-time endpoints share the same error model as the rest of the API.
+package wsjson
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/jjeffery/errkind"
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/httpapi"
+	"github.com/jjeffery/httpapi/writeerror"
+)
+
+// defaultReadLimit is the default maximum size of a message read from the client.
+const defaultReadLimit = 1024 * 1024 * 16
+
+// Upgrader upgrades HTTP requests to WebSocket connections.
+type Upgrader struct {
+	// WebSocket contains the options for upgrading the connection. The Error
+	// callback is ignored: if the upgrade fails the error is written to the
+	// client using httpapi.WriteError.
+	WebSocket websocket.Upgrader
+
+	// ReadLimit is the maximum size in bytes of a message read from the client.
+	// If zero, a default limit of 16MB is used.
+	ReadLimit int64
+}
+
+// Conn is a WebSocket connection that sends and receives JSON messages.
+type Conn struct {
+	conn *websocket.Conn
+	r    *http.Request
+}
+
+// Upgrade upgrades the HTTP request to a WebSocket connection using the
+// default options. If the upgrade fails, an error response has already been
+// sent to the client.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	var u Upgrader
+	return u.Upgrade(w, r)
+}
+
+// Upgrade upgrades the HTTP request to a WebSocket connection. If the
+// upgrade fails, an error response has already been sent to the client.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	upgrader := u.WebSocket
+	upgrader.Error = func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		httpapi.WriteError(w, r, errkind.Public(reason.Error(), status))
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot upgrade to websocket")
+	}
+	readLimit := u.ReadLimit
+	if readLimit == 0 {
+		readLimit = defaultReadLimit
+	}
+	conn.SetReadLimit(readLimit)
+
+	c := &Conn{
+		conn: conn,
+		r:    r,
+	}
+	return c, nil
+}
+
+// ReadJSON reads the next message from the client and unmarshals it into
+// the structure pointed to by v. If the message is not valid JSON, the error
+// returned has a bad request status and can be passed to WriteError.
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errkind.BadRequest("invalid JSON payload")
+	}
+	return nil
+}
+
+// WriteJSON sends v to the client as a JSON text message.
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal message")
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// WriteError sends err to the client as a JSON text message. The message has the
+// same format as the response body written by httpapi.WriteError, and the
+// writeerror configuration associated with the original HTTP request is used.
+func (c *Conn) WriteError(err error) error {
+	config := writeerror.ConfigFromRequest(c.r)
+	content := httpapi.ErrorContent(c.r, err)
+	data := config.Marshal(content)
+	if werr := c.conn.WriteMessage(websocket.TextMessage, data); werr != nil {
+		return werr
+	}
+
+	// populate Err for logging/tracing/diagnostics
+	content.Err = err
+	config.Redact(content)
+	config.ErrorWritten(c.r, content)
+	return nil
+}
+
+// Request returns the HTTP request that was upgraded to the WebSocket connection.
+func (c *Conn) Request() *http.Request {
+	return c.r
+}
+
+// UnderlyingConn returns the underlying WebSocket connection, which
+// provides access to features such as ping/pong handlers and deadlines.
+func (c *Conn) UnderlyingConn() *websocket.Conn {
+	return c.conn
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
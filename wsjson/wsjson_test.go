@@ -0,0 +1,94 @@
+package wsjson
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestConn(t *testing.T) {
+	type Message struct {
+		Text string `json:"text"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				if websocket.IsCloseError(err) || websocket.IsUnexpectedCloseError(err) {
+					return
+				}
+				if err := conn.WriteError(err); err != nil {
+					return
+				}
+				continue
+			}
+			conn.WriteJSON(Message{Text: strings.ToUpper(msg.Text)})
+		}
+	}))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"text":"hello"}`)); err != nil {
+		t.Fatal(err)
+	}
+	var msg Message
+	if err := ws.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.Text, "HELLO"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(`{"text":`)); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  int    `json:"status"`
+		} `json:"error"`
+	}
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := payload.Error.Status, http.StatusBadRequest; got != want {
+		t.Errorf("status: want %d, got %d", want, got)
+	}
+	if got, want := payload.Error.Message, "invalid JSON payload"; got != want {
+		t.Errorf("message: want %q, got %q", want, got)
+	}
+}
+
+func TestUpgradeFailure(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, err := Upgrade(w, r); err == nil {
+		t.Fatal("want error, got nil")
+	}
+	if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("content-type: want %q, got %q", want, got)
+	}
+	if w.Code < 400 {
+		t.Errorf("want error status, got %d", w.Code)
+	}
+}
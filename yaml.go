@@ -0,0 +1,171 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjeffery/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlContentType is the content type of YAML responses.
+const yamlContentType = "application/yaml"
+
+// isYAML reports whether the media type, eg from a Content-Type header, is YAML.
+func isYAML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+yaml")
+}
+
+// prefersYAML reports whether the Accept header of the request prefers
+// YAML to JSON. Clients that do not send an Accept header, or that accept
+// any media type, receive JSON.
+func prefersYAML(r *http.Request) bool {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var list []accepted
+	for _, header := range r.Header["Accept"] {
+		for _, part := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if s, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(s, 64); err != nil {
+					continue
+				}
+			}
+			if q > 0 {
+				list = append(list, accepted{mediaType: mediaType, q: q})
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].q > list[j].q
+	})
+	for _, a := range list {
+		switch {
+		case a.mediaType == "*/*", a.mediaType == "application/*", a.mediaType == "application/json",
+			strings.HasSuffix(a.mediaType, "+json"):
+			return false
+		case isYAML(a.mediaType):
+			return true
+		}
+	}
+	return false
+}
+
+// yamlToJSON converts a YAML document to JSON, so that it can be unmarshalled
+// using the JSON struct tags of the destination. Mapping keys are converted to
+// strings, and timestamps are converted to RFC3339 strings.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&v); err != nil && err != io.EOF {
+		return nil, err
+	}
+	// like JSON, only one document is allowed
+	var extra interface{}
+	if err := decoder.Decode(&extra); err != io.EOF {
+		return nil, errors.New("more than one YAML document")
+	}
+	v, err := jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// jsonValue converts a value unmarshalled from YAML
+// to a value that can be marshalled as JSON.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			var err error
+			if v[key], err = jsonValue(value); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			var err error
+			if m[fmt.Sprint(key)], err = jsonValue(value); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		for i, value := range v {
+			var err error
+			if v[i], err = jsonValue(value); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	return v, nil
+}
+
+// jsonToYAML converts a JSON document to YAML, keeping
+// the order of the members of JSON objects.
+func jsonToYAML(data []byte) ([]byte, error) {
+	// JSON is valid YAML, so it can be read as a YAML node tree
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blockStyle clears the JSON flow style and quoting from the node tree,
+// so that it is written in the usual YAML block style. Strings that would
+// otherwise be read as another type are still quoted.
+func blockStyle(node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode || node.Tag != "!!str" || !yaml11Bools[node.Value] {
+		node.Style = 0
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// yaml11Bools contains the strings that are booleans in YAML 1.1 but
+// not in YAML 1.2, so they remain quoted for clients that use YAML 1.1.
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true, "off": true, "Off": true, "OFF": true,
+}
@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrefersYAML(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "*/*", want: false},
+		{accept: "application/json", want: false},
+		{accept: "application/yaml", want: true},
+		{accept: "text/yaml", want: true},
+		{accept: "application/x-yaml, application/json", want: true},
+		{accept: "application/json, application/yaml", want: false},
+		{accept: "application/json;q=0.5, application/yaml", want: true},
+		{accept: "application/yaml;q=0, */*", want: false},
+		{accept: "application/problem+json, application/yaml", want: false},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := prefersYAML(r); got != tt.want {
+			t.Errorf("%d: want %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestReadRequestYAML(t *testing.T) {
+	type Payload struct {
+		Name    string            `json:"name"`
+		Count   int               `json:"count"`
+		Tags    []string          `json:"tags"`
+		Created string            `json:"created"`
+		Labels  map[string]string `json:"labels"`
+	}
+	tests := []struct {
+		contentType string
+		body        string
+		want        Payload
+		wantErr     string
+	}{
+		{
+			contentType: "application/yaml",
+			body:        "name: thing\ncount: 3\ntags: [a, b]\ncreated: 2020-01-02\nlabels:\n  1: one\n",
+			want: Payload{
+				Name:    "thing",
+				Count:   3,
+				Tags:    []string{"a", "b"},
+				Created: "2020-01-02T00:00:00Z",
+				Labels:  map[string]string{"1": "one"},
+			},
+		},
+		{
+			contentType: "text/yaml; charset=utf-8",
+			body:        "{\"name\": \"json is yaml\"}",
+			want:        Payload{Name: "json is yaml"},
+		},
+		{
+			contentType: "application/yaml",
+			body:        "name: [unclosed",
+			wantErr:     "invalid YAML payload",
+		},
+		{
+			contentType: "application/json",
+			body:        "name: thing",
+			wantErr:     "invalid JSON payload",
+		},
+		{
+			// only one document is allowed
+			contentType: "application/yaml",
+			body:        "name: one\n---\nname: two\n",
+			wantErr:     "invalid YAML payload",
+		},
+		{
+			contentType: "application/yaml",
+			body:        "---\nname: one\n",
+			want:        Payload{Name: "one"},
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+		var got Payload
+		err := ReadRequest(r, &got)
+		if tt.wantErr != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("%d: want error %q, got %v", i, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: want %+v, got %+v", i, tt.want, got)
+		}
+	}
+}
+
+func TestWriteResponseYAML(t *testing.T) {
+	body := struct {
+		Name   string   `json:"name"`
+		ID     string   `json:"id"`
+		Count  int      `json:"count"`
+		Active bool     `json:"active"`
+		Tags   []string `json:"tags"`
+		Empty  []string `json:"empty"`
+	}{
+		Name:   "thing",
+		ID:     "123",
+		Count:  3,
+		Active: true,
+		Tags:   []string{"a", "yes"},
+	}
+	tests := []struct {
+		accept      string
+		contentType string
+		want        string
+	}{
+		{
+			accept:      "application/yaml",
+			contentType: "application/yaml",
+			want:        "name: thing\nid: \"123\"\ncount: 3\nactive: true\ntags:\n  - a\n  - \"yes\"\nempty: null\n",
+		},
+		{
+			accept:      "application/json",
+			contentType: "application/json",
+			want:        `{"name":"thing","id":"123","count":3,"active":true,"tags":["a","yes"],"empty":null}`,
+		},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		WriteResponse(w, r, body)
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%d: want content type %q, got %q", i, tt.contentType, got)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept, Accept-Encoding" {
+			t.Errorf("%d: want Vary Accept, Accept-Encoding, got %q", i, got)
+		}
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%d: want\n%s\ngot\n%s", i, tt.want, got)
+		}
+	}
+}